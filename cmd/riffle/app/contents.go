@@ -0,0 +1,198 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/spf13/cobra"
+)
+
+// NewContentsCommand creates the contents command, which reads the content
+// of a running server
+func NewContentsCommand() *cobra.Command {
+	var opts remoteOptions
+
+	cmd := &cobra.Command{
+		Use:   "contents",
+		Short: "List, search and show the content of a riffle server",
+	}
+
+	opts.addFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(newContentsListCommand(&opts))
+	cmd.AddCommand(newContentsSearchCommand(&opts))
+	cmd.AddCommand(newContentsShowCommand(&opts))
+
+	return cmd
+}
+
+// newContentsListCommand creates the contents list command
+func newContentsListCommand(opts *remoteOptions) *cobra.Command {
+	var (
+		options client.ListContentsOptions
+		limit   int
+		unread  bool
+		starred bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the newest content of your subscriptions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			if unread {
+				options.Read = new(bool)
+			}
+			if starred {
+				options.Starred = &starred
+			}
+			options.Limit = min(limit, 100)
+			if limit <= 0 {
+				options.Limit = 100
+			}
+
+			// Walk through the pages until the limit is reached
+			contents := []client.Content{}
+			it := c.Contents(cmd.Context(), options)
+			for (limit <= 0 || len(contents) < limit) && it.Next() {
+				contents = append(contents, it.Value())
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+
+			return opts.print(cmd.OutOrStdout(), contents, func(w io.Writer) {
+				printContents(w, contents)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&options.SourceID, "source", "", "Only list content of this source")
+	cmd.Flags().StringVar(&options.Folder, "folder", "", "Only list content of subscriptions in this folder")
+	cmd.Flags().StringSliceVar(&options.Tags, "tag", nil, "Only list content with all of these tags")
+	cmd.Flags().BoolVar(&unread, "unread", false, "Only list unread content")
+	cmd.Flags().BoolVar(&starred, "starred", false, "Only list starred content")
+	cmd.Flags().BoolVar(&options.Collapse, "collapse", false, "List only the first item of each story of near-duplicates")
+	cmd.Flags().StringVar(&options.Language, "language", "", "Only list content in this language, such as en or zh")
+	cmd.Flags().IntVar(&limit, "limit", 20, "Maximum number of items to list (0 for all)")
+
+	return cmd
+}
+
+// newContentsSearchCommand creates the contents search command
+func newContentsSearchCommand(opts *remoteOptions) *cobra.Command {
+	var options client.SearchOptions
+
+	cmd := &cobra.Command{
+		Use:   "search <keyword>...",
+		Short: "Search the content of your subscriptions for any of the keywords",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			options.Keywords = strings.Join(args, ",")
+			contents, err := c.SearchContents(cmd.Context(), options)
+			if err != nil {
+				return err
+			}
+
+			return opts.print(cmd.OutOrStdout(), contents, func(w io.Writer) {
+				printContents(w, contents)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&options.SourceID, "source", "", "Only search content of this source")
+	cmd.Flags().StringSliceVar(&options.Tags, "tag", nil, "Only search content with all of these tags")
+	cmd.Flags().StringVar(&options.Language, "language", "", "Only search content in this language, such as en or zh")
+	cmd.Flags().IntVar(&options.Limit, "limit", 20, "Maximum number of results")
+
+	return cmd
+}
+
+// newContentsShowCommand creates the contents show command
+func newContentsShowCommand(opts *remoteOptions) *cobra.Command {
+	var width int
+
+	cmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a content item as text",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			content, err := c.GetContent(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			return opts.print(cmd.OutOrStdout(), content, func(w io.Writer) {
+				fmt.Fprintf(w, "Title:\t%s\n", content.Title)
+				fmt.Fprintf(w, "Link:\t%s\n", content.Link)
+				if content.Author != "" {
+					fmt.Fprintf(w, "Author:\t%s\n", content.Author)
+				}
+				fmt.Fprintf(w, "Published:\t%s\n", formatTime(&content.PublishedAt))
+				if len(content.Categories) > 0 {
+					fmt.Fprintf(w, "Categories:\t%s\n", strings.Join(content.Categories, ", "))
+				}
+				if len(content.Tags) > 0 {
+					fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(content.Tags, ", "))
+				}
+				body := content.Content
+				if body == "" {
+					body = content.Description
+				}
+				fmt.Fprintf(w, "\n%s\n", riffle.HTMLToText(body, width))
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&width, "width", 80, "Column to wrap the text at (0 to not wrap)")
+
+	return cmd
+}
+
+// printContents writes a table of content items
+func printContents(w io.Writer, contents []client.Content) {
+	fmt.Fprintln(w, "ID\tPUBLISHED\tSTATE\tTITLE")
+	for _, content := range contents {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			content.ID, formatTime(&content.PublishedAt), formatState(content.State), truncate(content.Title, 70))
+	}
+}
+
+// formatState formats a reading state for tables
+func formatState(state *client.ContentState) string {
+	if state == nil {
+		return "new"
+	}
+	var flags []string
+	if !state.Read {
+		flags = append(flags, "new")
+	}
+	if state.Starred {
+		flags = append(flags, "starred")
+	}
+	if state.ReadLater {
+		flags = append(flags, "later")
+	}
+	if len(flags) == 0 {
+		return "-"
+	}
+	return strings.Join(flags, ",")
+}
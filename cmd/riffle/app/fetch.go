@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// NewFetchCommand creates the fetch command, which starts a fetch job on a
+// running server
+func NewFetchCommand() *cobra.Command {
+	var (
+		opts     remoteOptions
+		input    client.FetchInput
+		wait     bool
+		interval time.Duration
+		timeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch new content on a riffle server (requires the editor role)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			job, err := c.Fetch(cmd.Context(), input)
+			if err != nil {
+				return fmt.Errorf("failed to start fetch job: %w", err)
+			}
+
+			// Poll the job until it is done
+			if wait {
+				ctx := cmd.Context()
+				if timeout > 0 {
+					var cancel func()
+					ctx, cancel = context.WithTimeout(ctx, timeout)
+					defer cancel()
+				}
+				id := job.ID
+				job, err = c.WaitForFetchJob(ctx, id, interval)
+				if err != nil {
+					return fmt.Errorf("failed to wait for fetch job %s: %w", id, err)
+				}
+			}
+
+			if err := opts.print(cmd.OutOrStdout(), job, func(w io.Writer) {
+				fmt.Fprintln(w, "JOB\tSTATUS\tITEMS\tCOMPLETED")
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", job.ID, job.Status, job.ItemsProcessed, formatTime(job.CompletedAt))
+			}); err != nil {
+				return err
+			}
+			if job.Status == client.FetchJobFailed {
+				return fmt.Errorf("fetch job %s failed: %v", job.ID, job.Errors)
+			}
+			return nil
+		},
+	}
+
+	opts.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&input.SourceID, "source", "", "Only fetch this source")
+	cmd.Flags().IntVar(&input.Days, "days", 7, "Fetch items published in the last number of days")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the fetch job to finish")
+	cmd.Flags().DurationVar(&interval, "poll-interval", client.DefaultPollInterval, "How often to check the fetch job with --wait")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "How long to wait with --wait (0 to wait forever)")
+
+	return cmd
+}
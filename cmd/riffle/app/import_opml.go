@@ -1,6 +1,7 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/flyer103/riffle/pkg/riffle"
@@ -14,6 +15,7 @@ func NewImportOPMLCommand() *cobra.Command {
 	var (
 		opmlFile string
 		dbPath   string
+		username string
 	)
 
 	cmd := &cobra.Command{
@@ -21,13 +23,14 @@ func NewImportOPMLCommand() *cobra.Command {
 		Short: "Import RSS sources from an OPML file into the database",
 		Long:  "Parse an OPML file and import the RSS sources into the SQLite database for use by the serve command",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return importOPML(opmlFile, dbPath)
+			return importOPML(opmlFile, dbPath, username)
 		},
 	}
 
 	// Add flags
 	cmd.Flags().StringVarP(&opmlFile, "opml", "o", "", "Path to OPML file (required)")
 	cmd.Flags().StringVar(&dbPath, "db-path", "./riffle.db", "Path to the SQLite database file")
+	cmd.Flags().StringVar(&username, "user", "", "Subscribe this user to the imported feeds, keeping the OPML folders")
 
 	// Mark required flags
 	cmd.MarkFlagRequired("opml")
@@ -35,8 +38,10 @@ func NewImportOPMLCommand() *cobra.Command {
 	return cmd
 }
 
-// importOPML imports RSS sources from an OPML file into the database
-func importOPML(opmlFile, dbPath string) error {
+// importOPML imports RSS sources from an OPML file into the database. If a
+// username is given, the user is subscribed to the feeds instead, which reuses
+// sources that already exist.
+func importOPML(opmlFile, dbPath, username string) error {
 	// Parse the OPML file
 	feeds, err := riffle.ParseOPML(opmlFile)
 	if err != nil {
@@ -50,20 +55,35 @@ func importOPML(opmlFile, dbPath string) error {
 	}
 	defer db.Close()
 
-	// Prepare batch input
-	var sourcesInput storage.BatchCreateSourcesInput
-	for _, feed := range feeds {
-		sourcesInput.Sources = append(sourcesInput.Sources, storage.CreateSourceInput{
-			Name:        feed.Title,
-			URL:         feed.URL,
-			Description: fmt.Sprintf("Imported from OPML file: %s", opmlFile),
-		})
+	if username != "" {
+		return subscribeOPML(db, feeds, username)
 	}
 
-	// Import the sources
-	result, err := db.BatchCreateSources(sourcesInput)
-	if err != nil {
-		return fmt.Errorf("failed to import RSS sources: %w", err)
+	// Import the sources in chunks that fit within the batch size limit
+	result := &storage.BatchCreateSourcesResult{}
+	for start := 0; start < len(feeds); start += storage.MaxBatchSize {
+		end := min(start+storage.MaxBatchSize, len(feeds))
+
+		// Prepare batch input
+		var sourcesInput storage.BatchCreateSourcesInput
+		for _, feed := range feeds[start:end] {
+			sourcesInput.Sources = append(sourcesInput.Sources, storage.CreateSourceInput{
+				Name:        feed.Title,
+				URL:         feed.URL,
+				Description: fmt.Sprintf("Imported from OPML file: %s", opmlFile),
+			})
+		}
+
+		chunkResult, err := db.BatchCreateSources(sourcesInput)
+		if err != nil {
+			return fmt.Errorf("failed to import RSS sources: %w", err)
+		}
+
+		result.Sources = append(result.Sources, chunkResult.Sources...)
+		for _, batchErr := range chunkResult.Errors {
+			batchErr.Index += start
+			result.Errors = append(result.Errors, batchErr)
+		}
 	}
 
 	// Print results
@@ -101,3 +121,45 @@ func importOPML(opmlFile, dbPath string) error {
 
 	return nil
 }
+
+// subscribeOPML subscribes a user to the feeds of an OPML file
+func subscribeOPML(db *storage.SQLiteDB, feeds []riffle.Feed, username string) error {
+	// Look up the user
+	user, err := db.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %q not found", username)
+	}
+
+	// Subscribe to each feed
+	subscribed, skipped := 0, 0
+	for _, feed := range feeds {
+		_, err := db.CreateSubscription(user.ID, storage.CreateSubscriptionInput{
+			URL: feed.URL,
+			UpdateSubscriptionInput: storage.UpdateSubscriptionInput{
+				DisplayName: feed.Title,
+				Folder:      feed.Folder,
+			},
+			AllowNewSource: true,
+		})
+		if errors.Is(err, storage.ErrAlreadySubscribed) {
+			skipped++
+			continue
+		} else if err != nil {
+			fmt.Printf("- Failed to subscribe to %s: %v\n", feed.URL, err)
+			continue
+		}
+		subscribed++
+		fmt.Printf("- %s (%s)\n", feed.Title, feed.URL)
+	}
+
+	klog.InfoS("OPML import completed",
+		"user", username,
+		"totalFeeds", len(feeds),
+		"subscribed", subscribed,
+		"alreadySubscribed", skipped)
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// NewRecommendCommand creates the recommend command, which gets
+// recommendations from a running server
+func NewRecommendCommand() *cobra.Command {
+	var (
+		opts    remoteOptions
+		options client.RecommendationsOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "recommend",
+		Short: "Show your recommendations from a riffle server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			recommendations, err := c.GetRecommendations(cmd.Context(), options)
+			if err != nil {
+				return err
+			}
+
+			return opts.print(cmd.OutOrStdout(), recommendations, func(w io.Writer) {
+				fmt.Fprintln(w, "SCORE\tID\tTITLE\tLINK")
+				for _, recommendation := range recommendations {
+					fmt.Fprintf(w, "%.2f\t%s\t%s\t%s\n", recommendation.Score, recommendation.Content.ID,
+						truncate(recommendation.Content.Title, 60), recommendation.Content.Link)
+				}
+			})
+		},
+	}
+
+	opts.addFlags(cmd.Flags())
+	cmd.Flags().StringSliceVar(&options.SourceIDs, "source", nil, "Only recommend content of these sources")
+	cmd.Flags().IntVar(&options.Limit, "limit", 10, "Number of recommendations")
+	cmd.Flags().BoolVar(&options.KeepDuplicates, "keep-duplicates", false, "Recommend every item of a story instead of the best one")
+
+	return cmd
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/version"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the commands talking to a server
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// remoteOptions are the flags of the commands that talk to a running riffle
+// server instead of opening the database
+type remoteOptions struct {
+	server string
+	token  string
+	output string
+}
+
+// addFlags adds the server, token and output flags
+func (o *remoteOptions) addFlags(fs *pflag.FlagSet) {
+	o.addServerFlags(fs)
+	fs.StringVarP(&o.output, "output", "o", outputTable, "Output format: table, json or yaml")
+}
+
+// addServerFlags adds only the server and token flags, for commands without
+// output to format
+func (o *remoteOptions) addServerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.server, "server", "", "URL of the riffle server (defaults to the RIFFLE_SERVER environment variable or http://localhost:8080)")
+	fs.StringVar(&o.token, "token", "", "API token (defaults to the RIFFLE_TOKEN environment variable)")
+}
+
+// client creates a client for the server
+func (o *remoteOptions) client() (*client.Client, error) {
+	switch o.output {
+	case outputTable, outputJSON, outputYAML:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use table, json or yaml", o.output)
+	}
+
+	server := o.server
+	if server == "" {
+		server = os.Getenv("RIFFLE_SERVER")
+	}
+	if server == "" {
+		server = "http://localhost:8080"
+	}
+	token := o.token
+	if token == "" {
+		token = os.Getenv("RIFFLE_TOKEN")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("an API token is required; create one with POST /users/me/tokens and pass it with --token or RIFFLE_TOKEN")
+	}
+
+	return client.New(client.Config{
+		URL:       server,
+		Token:     token,
+		UserAgent: "riffle-cli/" + version.Version,
+	})
+}
+
+// print writes v to out as JSON or YAML, or calls table to write it as a table
+func (o *remoteOptions) print(out io.Writer, v interface{}, table func(w io.Writer)) error {
+	switch o.output {
+	case outputJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		// Go through JSON so the keys match the API
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(generic); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// formatTime formats an optional time for tables
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// truncate shortens s to at most n runes for tables
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/serving"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/spf13/cobra"
+)
+
+// testFeed is an RSS feed with two items published in the last day
+func testFeed() string {
+	now := time.Now().UTC()
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Test Feed</title><link>https://example.com/</link><description>Test</description>`)
+	for i, title := range []string{"Go generics explained", "Rust ownership explained"} {
+		fmt.Fprintf(&b, `<item><title>%s</title><link>https://example.com/%d</link><description>All about %s.</description><pubDate>%s</pubDate></item>`,
+			title, i, title, now.Add(-time.Duration(i+1)*time.Hour).Format(time.RFC1123Z))
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.String()
+}
+
+// newTestServer starts a server on a temporary database with an admin
+// subscribed to a source serving testFeed, and returns the server's URL, the
+// admin's API token and the source
+func newTestServer(t *testing.T) (url, token string, source *storage.RSSSource) {
+	t.Helper()
+
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, testFeed())
+	}))
+	t.Cleanup(feed.Close)
+
+	options := serving.NewServerOptions()
+	options.DBPath = filepath.Join(t.TempDir(), "riffle.db")
+	options.TrashRetention = 0
+
+	// Create the admin and the source before the server opens the database
+	db, err := storage.NewSQLiteDB(options.DBPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	user, err := db.CreateUser(storage.CreateUserInput{Username: "admin", Password: "password123", Role: storage.RoleAdmin})
+	if err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+	_, token, err = db.CreateAPIToken(user.ID, storage.CreateAPITokenInput{Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	source, err = db.CreateSource(storage.CreateSourceInput{Name: "Test Feed", URL: feed.URL + "/feed.xml"})
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	if _, err := db.CreateSubscription(user.ID, storage.CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("failed to close database: %v", err)
+	}
+
+	server, err := serving.NewServer(options)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		server.Shutdown(context.Background())
+	})
+
+	return ts.URL, token, source
+}
+
+// run runs a command with the arguments and returns what it printed
+func run(t *testing.T, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(args)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+// runJSON runs a command with JSON output and decodes what it printed into v
+func runJSON(t *testing.T, v interface{}, cmd *cobra.Command, args ...string) {
+	t.Helper()
+	out, err := run(t, cmd, append(args, "--output", "json")...)
+	if err != nil {
+		t.Fatalf("%s %v error = %v", cmd.Name(), args, err)
+	}
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		t.Fatalf("%s %v printed invalid JSON %q: %v", cmd.Name(), args, out, err)
+	}
+}
+
+func TestSourcesCommands(t *testing.T) {
+	url, token, existing := newTestServer(t)
+	server := []string{"--server", url, "--token", token}
+
+	var added client.Source
+	runJSON(t, &added, NewSourcesCommand(), append([]string{"add", "https://example.com/new.xml", "--name", "New"}, server...)...)
+	if added.ID == "" || added.Name != "New" || added.URL != "https://example.com/new.xml" {
+		t.Errorf("sources add printed %+v", added)
+	}
+
+	// The table lists both sources, the limit only one
+	out, err := run(t, NewSourcesCommand(), append([]string{"list"}, server...)...)
+	if err != nil {
+		t.Fatalf("sources list error = %v", err)
+	}
+	if !strings.HasPrefix(out, "ID") || !strings.Contains(out, existing.ID) || !strings.Contains(out, added.ID) {
+		t.Errorf("sources list printed %q, want a table of both sources", out)
+	}
+	var sources []client.Source
+	runJSON(t, &sources, NewSourcesCommand(), append([]string{"list", "--limit", "1"}, server...)...)
+	if len(sources) != 1 {
+		t.Errorf("sources list --limit 1 printed %d sources", len(sources))
+	}
+
+	out, err = run(t, NewSourcesCommand(), append([]string{"rm", added.ID}, server...)...)
+	if err != nil || !strings.Contains(out, added.ID) {
+		t.Errorf("sources rm printed %q, error = %v", out, err)
+	}
+	runJSON(t, &sources, NewSourcesCommand(), append([]string{"list"}, server...)...)
+	if len(sources) != 1 || sources[0].ID != existing.ID {
+		t.Errorf("sources after rm = %+v, want only %s", sources, existing.ID)
+	}
+}
+
+func TestFetchAndContentsCommands(t *testing.T) {
+	url, token, source := newTestServer(t)
+	server := []string{"--server", url, "--token", token}
+
+	var job client.FetchJob
+	runJSON(t, &job, NewFetchCommand(), append([]string{"--wait", "--poll-interval", "10ms", "--timeout", "10s"}, server...)...)
+	if job.Status != client.FetchJobCompleted || job.ItemsProcessed != 2 {
+		t.Fatalf("fetch --wait printed %+v, want a completed job with 2 items", job)
+	}
+
+	var contents []client.Content
+	runJSON(t, &contents, NewContentsCommand(), append([]string{"list", "--source", source.ID}, server...)...)
+	if len(contents) != 2 {
+		t.Fatalf("contents list printed %d items, want 2", len(contents))
+	}
+
+	// Searching matches any of the keywords
+	var found []client.Content
+	runJSON(t, &found, NewContentsCommand(), append([]string{"search", "generics"}, server...)...)
+	if len(found) != 1 || found[0].Title != "Go generics explained" {
+		t.Errorf("contents search printed %+v, want the Go item", found)
+	}
+
+	// Items are shown as text
+	out, err := run(t, NewContentsCommand(), append([]string{"show", found[0].ID}, server...)...)
+	if err != nil {
+		t.Fatalf("contents show error = %v", err)
+	}
+	if !strings.Contains(out, "Go generics explained") || !strings.Contains(out, "All about Go generics explained.") {
+		t.Errorf("contents show printed %q", out)
+	}
+
+	out, err = run(t, NewRecommendCommand(), append([]string{"--output", "yaml"}, server...)...)
+	if err != nil {
+		t.Fatalf("recommend error = %v", err)
+	}
+	if strings.HasPrefix(out, "{") || strings.HasPrefix(out, "[") {
+		t.Errorf("recommend --output yaml printed JSON %q", out)
+	}
+}
+
+func TestRemoteCommandErrors(t *testing.T) {
+	url, token, _ := newTestServer(t)
+	t.Setenv("RIFFLE_TOKEN", "")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"missing token", []string{"list", "--server", url}, "an API token is required"},
+		{"unknown output", []string{"list", "--server", url, "--token", token, "--output", "xml"}, `unknown output format "xml"`},
+		{"invalid token", []string{"list", "--server", url, "--token", "invalid"}, "401"},
+		{"missing item", []string{"show", "missing", "--server", url, "--token", token}, "404"},
+	}
+	for _, tt := range tests {
+		_, err := run(t, NewContentsCommand(), tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -15,6 +15,12 @@ func NewRiffleCommand() *cobra.Command {
 		Long: `Riffle analyzes RSS feeds from an OPML file and recommends articles
 based on content quality and user interests. It helps you find the most
 valuable content from your RSS subscriptions.`,
+		// Execute prints errors, and the usage only helps with invalid
+		// arguments, not with errors from running the command
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmd.SilenceUsage = true
+		},
 	}
 
 	return cmd
@@ -28,6 +34,12 @@ func Execute() {
 	cmd.AddCommand(NewRunCommand())
 	cmd.AddCommand(NewServeCommand())
 	cmd.AddCommand(NewImportOPMLCommand())
+	cmd.AddCommand(NewUserCommand())
+	cmd.AddCommand(NewSourcesCommand())
+	cmd.AddCommand(NewContentsCommand())
+	cmd.AddCommand(NewFetchCommand())
+	cmd.AddCommand(NewRecommendCommand())
+	cmd.AddCommand(NewTUICommand())
 
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
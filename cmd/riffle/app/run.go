@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/flyer103/riffle/pkg/serving/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +18,9 @@ func NewRunCommand() *cobra.Command {
 	var (
 		opmlFile      string
 		interestsFile string
+		scoringFile   string
+		dbPath        string
+		username      string
 		articleCount  int
 		topCount      int
 		modelName     string
@@ -27,13 +31,16 @@ func NewRunCommand() *cobra.Command {
 		Short: "Run RSS feed analysis and content recommendations",
 		Long:  "Analyze RSS feeds from an OPML file and recommend articles based on content quality and user interests",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRiffle(cmd, args, opmlFile, interestsFile, articleCount, topCount, modelName)
+			return runRiffle(cmd, args, opmlFile, interestsFile, scoringFile, dbPath, username, articleCount, topCount, modelName)
 		},
 	}
 
 	// Add flags
 	cmd.Flags().StringVarP(&opmlFile, "opml", "o", "", "Path to OPML file (required)")
 	cmd.Flags().StringVarP(&interestsFile, "interests", "i", "", "Path to file containing interests (one per line)")
+	cmd.Flags().StringVarP(&scoringFile, "scoring", "s", "", "Path to a YAML file configuring the scorers, their weights and keywords")
+	cmd.Flags().StringVar(&dbPath, "db-path", "", "Path to a SQLite database whose content weighs the terms of interests; the fetched articles are used if not set")
+	cmd.Flags().StringVar(&username, "user", "", "Use the interest profile of this user in the database given with --db-path instead of --interests")
 	cmd.Flags().IntVarP(&articleCount, "articles", "n", 3, "Number of articles to fetch from each feed")
 	cmd.Flags().IntVarP(&topCount, "top", "t", 1, "Number of top articles to recommend")
 	cmd.Flags().StringVarP(&modelName, "model", "m", "r1-1776", "Perplexity API model to use for article analysis")
@@ -78,21 +85,58 @@ func cleanAIAnalysis(content string) string {
 	return content
 }
 
-func runRiffle(cmd *cobra.Command, args []string, opmlFile, interestsFile string, articleCount, topCount int, modelName string) error {
+func runRiffle(cmd *cobra.Command, args []string, opmlFile, interestsFile, scoringFile, dbPath, username string, articleCount, topCount int, modelName string) error {
+	if username != "" && (dbPath == "" || interestsFile != "") {
+		return fmt.Errorf("--user requires --db-path and cannot be combined with --interests")
+	}
+
 	feeds, err := riffle.ParseOPML(opmlFile)
 	if err != nil {
 		return fmt.Errorf("failed to parse OPML file: %w", err)
 	}
 
-	analyzer, err := riffle.NewContentAnalyzer(interestsFile)
+	scoring, err := riffle.LoadScoringConfig(scoringFile)
+	if err != nil {
+		return fmt.Errorf("failed to load scoring configuration: %w", err)
+	}
+
+	analyzer, err := riffle.NewContentAnalyzer(interestsFile, scoring)
 	if err != nil {
 		return fmt.Errorf("failed to initialize content analyzer: %w", err)
 	}
 
+	// Weigh the terms of interests by their rarity in the stored content,
+	// and use the user's interest profile if one is given
+	if dbPath != "" {
+		if err := withUserDB(dbPath, func(db *storage.SQLiteDB) error {
+			corpus, err := db.LoadCorpus()
+			if err != nil {
+				return fmt.Errorf("failed to load corpus: %w", err)
+			}
+			analyzer.SetCorpus(corpus)
+
+			if username == "" {
+				return nil
+			}
+			user, err := lookupUser(db, username)
+			if err != nil {
+				return err
+			}
+			interests, err := db.UserInterests(user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to load interests: %w", err)
+			}
+			analyzer.SetInterests(interests)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
 	ctx := context.Background()
 
-	// Store all article scores for final recommendation
-	var allScores []riffle.ArticleScore
+	// Store all articles to score them together for final recommendation
+	var allArticles []*riffle.Article
 	// Track feeds without recent updates
 	var noUpdateFeeds []string
 
@@ -116,14 +160,9 @@ func runRiffle(cmd *cobra.Command, args []string, opmlFile, interestsFile string
 		fmt.Printf("\nFeed: %s\n", feed.Title)
 		fmt.Println(strings.Repeat("-", 80))
 
-		// Analyze and score each article
-		for _, article := range articles {
-			score, err := analyzer.AnalyzeArticle(&article)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error analyzing article '%s': %v\n", article.Title, err)
-				continue
-			}
-			allScores = append(allScores, score)
+		for i := range articles {
+			article := &articles[i]
+			allArticles = append(allArticles, article)
 
 			fmt.Printf("\nTitle: %s\n", article.Title)
 			fmt.Printf("URL: %s\n", article.URL)
@@ -146,6 +185,13 @@ func runRiffle(cmd *cobra.Command, args []string, opmlFile, interestsFile string
 		fmt.Println(strings.Repeat("-", 50))
 	}
 
+	// Score the articles together, so that how well they match the
+	// interests is compared across them
+	allScores, err := analyzer.AnalyzeArticles(allArticles)
+	if err != nil {
+		return fmt.Errorf("failed to analyze articles: %w", err)
+	}
+
 	// Print overall highest-value article recommendations
 	if len(allScores) > 0 {
 		sort.Slice(allScores, func(i, j int) bool {
@@ -160,10 +206,16 @@ func runRiffle(cmd *cobra.Command, args []string, opmlFile, interestsFile string
 			fmt.Printf("Source: %s\n", getFeedTitleByURL(feeds, score.Article.URL))
 			fmt.Printf("URL: %s\n", score.Article.URL)
 			fmt.Printf("Published: %s\n", score.Article.PublishedAt.Format(time.RFC3339))
+			if score.Language != "" {
+				fmt.Printf("Language: %s\n", score.Language)
+			}
 			if score.Article.Summary != "" {
 				fmt.Printf("Summary: %s\n", score.Article.Summary)
 			}
 			fmt.Printf("Scores:\n")
+			for _, component := range score.Components {
+				fmt.Printf("  - %s: %.2f (weight %.2f)\n", component.Name, component.Score, component.Weight)
+			}
 			fmt.Printf("  - Interest Match: %.2f\n", score.InterestScore)
 			fmt.Printf("  - Content Quality: %.2f\n", score.ContentScore)
 			fmt.Printf("  - Overall: %.2f\n", score.Score)
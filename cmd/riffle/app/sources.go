@@ -0,0 +1,128 @@
+package app
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// NewSourcesCommand creates the sources command, which manages the sources
+// of a running server
+func NewSourcesCommand() *cobra.Command {
+	var opts remoteOptions
+
+	cmd := &cobra.Command{
+		Use:   "sources",
+		Short: "List, add and remove the RSS sources of a riffle server",
+	}
+
+	opts.addFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(newSourcesListCommand(&opts))
+	cmd.AddCommand(newSourcesAddCommand(&opts))
+	cmd.AddCommand(newSourcesRemoveCommand(&opts))
+
+	return cmd
+}
+
+// newSourcesListCommand creates the sources list command
+func newSourcesListCommand(opts *remoteOptions) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List sources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			// Walk through the pages until the limit is reached
+			sources := []client.Source{}
+			it := c.Sources(cmd.Context(), 100)
+			for (limit <= 0 || len(sources) < limit) && it.Next() {
+				sources = append(sources, it.Value())
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+
+			return opts.print(cmd.OutOrStdout(), sources, func(w io.Writer) {
+				fmt.Fprintln(w, "ID\tNAME\tURL\tLAST FETCHED")
+				for _, source := range sources {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+						source.ID, truncate(source.Name, 40), source.URL, formatTime(source.LastFetchedAt))
+				}
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 0, "Maximum number of sources to list (0 for all)")
+
+	return cmd
+}
+
+// newSourcesAddCommand creates the sources add command
+func newSourcesAddCommand(opts *remoteOptions) *cobra.Command {
+	var name, description string
+
+	cmd := &cobra.Command{
+		Use:   "add <url>",
+		Short: "Add a source (requires the editor role)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				name = args[0]
+			}
+			source, err := c.CreateSource(cmd.Context(), client.SourceInput{
+				Name:        name,
+				URL:         args[0],
+				Description: description,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to add source: %w", err)
+			}
+
+			return opts.print(cmd.OutOrStdout(), source, func(w io.Writer) {
+				fmt.Fprintf(w, "Added source %s (%s)\n", source.Name, source.ID)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Name of the source (defaults to the URL)")
+	cmd.Flags().StringVar(&description, "description", "", "Description of the source")
+
+	return cmd
+}
+
+// newSourcesRemoveCommand creates the sources rm command
+func newSourcesRemoveCommand(opts *remoteOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm <id>...",
+		Short: "Move sources to the trash (requires the admin role)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.client()
+			if err != nil {
+				return err
+			}
+
+			for _, id := range args {
+				if err := c.DeleteSource(cmd.Context(), id); err != nil {
+					return fmt.Errorf("failed to remove source %s: %w", id, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Moved source %s to the trash\n", id)
+			}
+			return nil
+		},
+	}
+}
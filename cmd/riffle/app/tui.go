@@ -0,0 +1,63 @@
+package app
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/flyer103/riffle/pkg/tui"
+	"github.com/spf13/cobra"
+)
+
+// NewTUICommand creates the tui command, a full-screen reader for the local
+// database or a running server
+func NewTUICommand() *cobra.Command {
+	var (
+		opts     remoteOptions
+		dbPath   string
+		username string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Read your subscriptions in a full-screen terminal reader",
+		Long: `Read your subscriptions in a full-screen terminal reader.
+
+With --user the reader opens the local database as that user; otherwise it
+talks to a riffle server, like the sources and contents commands.
+
+Keys: j/k or the arrows move, Enter opens, q goes back, m toggles read,
+s toggles the star, 1-5 rate the item for recommendations, o opens the
+link in $BROWSER or the desktop's browser, a shows read items, n/p move
+between articles and Q quits.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if username == "" {
+				opts.output = outputTable
+				c, err := opts.client()
+				if err != nil {
+					return err
+				}
+				return tui.Run(ctx, tui.NewRemoteBackend(c))
+			}
+
+			return withUserDB(dbPath, func(db *storage.SQLiteDB) error {
+				user, err := lookupUser(db, username)
+				if err != nil {
+					return err
+				}
+				return tui.Run(ctx, tui.NewLocalBackend(db, user.ID))
+			})
+		},
+	}
+
+	opts.addServerFlags(cmd.Flags())
+	cmd.Flags().StringVar(&username, "user", "", "Read the local database as this user instead of using a server")
+	cmd.Flags().StringVar(&dbPath, "db-path", "./riffle.db", "Path to the SQLite database file, with --user")
+
+	return cmd
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTUICommandErrors(t *testing.T) {
+	// Both fail before the terminal is opened
+	url, _, _ := newTestServer(t)
+	t.Setenv("RIFFLE_TOKEN", "")
+	dbPath := filepath.Join(t.TempDir(), "riffle.db")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"missing token", []string{"--server", url}, "an API token is required"},
+		{"unknown user", []string{"--user", "nobody", "--db-path", dbPath}, `user "nobody" not found`},
+	}
+	for _, tt := range tests {
+		_, err := run(t, NewTUICommand(), tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
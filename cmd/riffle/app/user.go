@@ -0,0 +1,252 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/spf13/cobra"
+)
+
+// NewUserCommand creates a new user command for managing user accounts
+// directly in the database, such as creating the first admin
+func NewUserCommand() *cobra.Command {
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage user accounts and roles",
+		Long: fmt.Sprintf(`Manage the user accounts in the SQLite database used by the serve command.
+
+Users have one of the roles %s, %s or %s. Readers can subscribe to sources
+and organize their own reading, editors can also change shared sources and
+content and trigger fetches, and admins can also delete sources and content
+for good and manage users.`, storage.RoleAdmin, storage.RoleEditor, storage.RoleReader),
+	}
+
+	cmd.PersistentFlags().StringVar(&dbPath, "db-path", "./riffle.db", "Path to the SQLite database file")
+
+	cmd.AddCommand(newUserCreateCommand(&dbPath))
+	cmd.AddCommand(newUserListCommand(&dbPath))
+	cmd.AddCommand(newUserSetRoleCommand(&dbPath))
+	cmd.AddCommand(newUserPasswordCommand(&dbPath))
+	cmd.AddCommand(newUserDeleteCommand(&dbPath))
+	cmd.AddCommand(newUserImportInterestsCommand(&dbPath))
+
+	return cmd
+}
+
+// newUserCreateCommand creates the user create command
+func newUserCreateCommand(dbPath *string) *cobra.Command {
+	var password, role string
+
+	cmd := &cobra.Command{
+		Use:   "create <username>",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withUserDB(*dbPath, func(db *storage.SQLiteDB) error {
+				if password == "" {
+					var err error
+					if password, err = readPassword(); err != nil {
+						return err
+					}
+				}
+
+				user, err := db.CreateUser(storage.CreateUserInput{
+					Username: args[0],
+					Password: password,
+					Role:     role,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create user: %w", err)
+				}
+
+				fmt.Printf("Created user %s (%s) with role %s\n", user.Username, user.ID, user.Role)
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&password, "password", "", "Password of the user; read from standard input if not set")
+	cmd.Flags().StringVar(&role, "role", storage.RoleReader, "Role of the user: admin, editor or reader")
+
+	return cmd
+}
+
+// newUserListCommand creates the user list command
+func newUserListCommand(dbPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withUserDB(*dbPath, func(db *storage.SQLiteDB) error {
+				users, err := db.ListUsers()
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "USERNAME\tROLE\tID\tCREATED")
+				for _, user := range users {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+						user.Username, user.Role, user.ID, user.CreatedAt.Format("2006-01-02 15:04"))
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
+
+// newUserSetRoleCommand creates the user set-role command
+func newUserSetRoleCommand(dbPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-role <username> <role>",
+		Short: "Change the role of a user",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withUserDB(*dbPath, func(db *storage.SQLiteDB) error {
+				user, err := lookupUser(db, args[0])
+				if err != nil {
+					return err
+				}
+
+				user, err = db.SetUserRole(user.ID, args[1])
+				if err != nil {
+					return fmt.Errorf("failed to set role: %w", err)
+				}
+
+				fmt.Printf("User %s now has role %s\n", user.Username, user.Role)
+				return nil
+			})
+		},
+	}
+}
+
+// newUserPasswordCommand creates the user passwd command
+func newUserPasswordCommand(dbPath *string) *cobra.Command {
+	var password string
+
+	cmd := &cobra.Command{
+		Use:   "passwd <username>",
+		Short: "Reset the password of a user and sign out their sessions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withUserDB(*dbPath, func(db *storage.SQLiteDB) error {
+				user, err := lookupUser(db, args[0])
+				if err != nil {
+					return err
+				}
+
+				if password == "" {
+					if password, err = readPassword(); err != nil {
+						return err
+					}
+				}
+
+				if err := db.UpdateUserPassword(user.ID, password); err != nil {
+					return fmt.Errorf("failed to update password: %w", err)
+				}
+
+				fmt.Printf("Updated the password of user %s\n", user.Username)
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&password, "password", "", "New password; read from standard input if not set")
+
+	return cmd
+}
+
+// newUserDeleteCommand creates the user delete command
+func newUserDeleteCommand(dbPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <username>",
+		Short: "Delete a user together with their subscriptions and reading state",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withUserDB(*dbPath, func(db *storage.SQLiteDB) error {
+				user, err := lookupUser(db, args[0])
+				if err != nil {
+					return err
+				}
+
+				if _, err := db.DeleteUser(user.ID); err != nil {
+					return fmt.Errorf("failed to delete user: %w", err)
+				}
+
+				fmt.Printf("Deleted user %s\n", user.Username)
+				return nil
+			})
+		},
+	}
+}
+
+// newUserImportInterestsCommand creates the user import-interests command
+func newUserImportInterestsCommand(dbPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "import-interests <username> <file>",
+		Short: "Add the interests of a file with one interest per line to the interest profile of a user",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withUserDB(*dbPath, func(db *storage.SQLiteDB) error {
+				user, err := lookupUser(db, args[0])
+				if err != nil {
+					return err
+				}
+
+				file, err := os.Open(args[1])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+
+				interests, err := db.ImportInterests(user.ID, file)
+				if err != nil {
+					return fmt.Errorf("failed to import interests: %w", err)
+				}
+
+				fmt.Printf("Added %d interests to the profile of user %s\n", len(interests), user.Username)
+				return nil
+			})
+		},
+	}
+}
+
+// withUserDB opens the database, runs fn and closes the database again
+func withUserDB(dbPath string, fn func(db *storage.SQLiteDB) error) error {
+	db, err := storage.NewSQLiteDB(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return fn(db)
+}
+
+// lookupUser gets a user by username, returning an error if there is none
+func lookupUser(db *storage.SQLiteDB, username string) (*storage.User, error) {
+	user, err := db.GetUserByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", username)
+	}
+	return user, nil
+}
+
+// readPassword reads a password from the first line of standard input
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
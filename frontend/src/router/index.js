@@ -1,11 +1,19 @@
 import { createRouter, createWebHistory } from 'vue-router'
 import HomeView from '../views/HomeView.vue'
+import LoginView from '../views/LoginView.vue'
+import ApiService from '@/services/api'
 
 const routes = [
   {
     path: '/',
     name: 'home',
     component: HomeView
+  },
+  {
+    path: '/login',
+    name: 'login',
+    component: LoginView,
+    meta: { public: true }
   }
 ]
 
@@ -14,4 +22,17 @@ const router = createRouter({
   routes
 })
 
+// Send users who are not logged in to the login page
+router.beforeEach(async (to) => {
+  if (to.meta.public) {
+    return true
+  }
+  try {
+    await ApiService.getCurrentUser()
+    return true
+  } catch (error) {
+    return { name: 'login', query: { redirect: to.fullPath } }
+  }
+})
+
 export default router 
\ No newline at end of file
@@ -2,7 +2,7 @@ import axios from 'axios'
 
 const apiClient = axios.create({
   baseURL: 'http://localhost:8080',
-  withCredentials: false,
+  withCredentials: true,
   headers: {
     Accept: 'application/json',
     'Content-Type': 'application/json'
@@ -11,18 +11,54 @@ const apiClient = axios.create({
 })
 
 export default {
+  // Authentication
+  getAuthMethods() {
+    return apiClient.get('/auth/methods')
+  },
+  // The browser is sent to the identity provider and comes back to the
+  // server, which redirects to --oidc-post-login-url afterwards
+  oidcLoginUrl() {
+    return apiClient.defaults.baseURL + '/auth/oidc/login'
+  },
+  signup(username, password) {
+    return apiClient.post('/auth/signup', { username, password })
+  },
+  login(username, password) {
+    return apiClient.post('/auth/login', { username, password })
+  },
+  logout() {
+    return apiClient.post('/auth/logout')
+  },
+  getCurrentUser() {
+    return apiClient.get('/users/me')
+  },
+
   // RSS Sources
+  // The sidebar shows the sources the user is subscribed to, under the
+  // display name the user chose for each of them
   getSources() {
-    return apiClient.get('/sources')
+    return apiClient.get('/subscriptions').then(response => ({
+      ...response,
+      data: {
+        sources: response.data.subscriptions.map(subscription => ({
+          ...subscription.source,
+          name: subscription.displayName || subscription.source.name,
+          subscriptionId: subscription.id
+        }))
+      }
+    }))
   },
   getSource(id) {
     return apiClient.get(`/sources/${id}`)
   },
   createSource(source) {
-    return apiClient.post('/sources', source)
+    return apiClient.post('/subscriptions', {
+      url: source.url,
+      displayName: source.name
+    }).then(response => ({ ...response, data: response.data.source }))
   },
   batchCreateSources(sources) {
-    return apiClient.post('/sources/batch', { sources })
+    return Promise.all(sources.map(source => this.createSource(source)))
   },
   updateSource(id, source) {
     return apiClient.put(`/sources/${id}`, source)
@@ -30,6 +66,9 @@ export default {
   deleteSource(id) {
     return apiClient.delete(`/sources/${id}`)
   },
+  unsubscribe(subscriptionId) {
+    return apiClient.delete(`/subscriptions/${subscriptionId}`)
+  },
 
   // RSS Contents
   getContents(params = {}) {
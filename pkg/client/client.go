@@ -0,0 +1,123 @@
+// Package client is a Go client for the riffle REST API described in
+// docs/api.yaml.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the timeout of requests when Config.HTTPClient is not set
+const DefaultTimeout = 30 * time.Second
+
+// Config configures a Client
+type Config struct {
+	// URL is the base URL of the riffle server, such as
+	// "https://riffle.example.com"
+	URL string
+	// Token is an API token created with POST /users/me/tokens, or a JWT
+	// from the server's identity provider
+	Token string
+	// HTTPClient sends the requests; a client with DefaultTimeout is used
+	// if it is nil
+	HTTPClient *http.Client
+	// UserAgent is sent with every request if set
+	UserAgent string
+}
+
+// Client calls the riffle REST API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	userAgent  string
+}
+
+// New creates a Client
+func New(config Config) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimRight(config.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL %q: %w", config.URL, err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q: must be an absolute http or https URL", config.URL)
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		token:      config.Token,
+		httpClient: httpClient,
+		userAgent:  config.UserAgent,
+	}, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out unless it is nil. path must be escaped. Responses other
+// than 2xx are returned as an *APIError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	u := *c.baseURL
+	u.RawPath = c.baseURL.EscapedPath() + path
+	unescaped, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	u.Path = unescaped
+	u.RawQuery = query.Encode()
+
+	// Encode the request body
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	// Turn error responses into typed errors
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(method, path, resp)
+	}
+
+	// Decode the response body
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+	}
+	return nil
+}
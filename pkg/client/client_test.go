@@ -0,0 +1,259 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/serving"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// newTestServer starts a server on a temporary database and returns its URL
+// and API tokens of an admin and a reader
+func newTestServer(t *testing.T) (url, adminToken, readerToken string) {
+	t.Helper()
+
+	options := serving.NewServerOptions()
+	options.DBPath = filepath.Join(t.TempDir(), "riffle.db")
+	options.TrashRetention = 0
+
+	// Create the users before the server opens the database
+	db, err := storage.NewSQLiteDB(options.DBPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	tokens := map[string]string{}
+	for _, role := range []string{storage.RoleAdmin, storage.RoleReader} {
+		user, err := db.CreateUser(storage.CreateUserInput{Username: role, Password: "password123", Role: role})
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", role, err)
+		}
+		_, secret, err := db.CreateAPIToken(user.ID, storage.CreateAPITokenInput{Name: "test"})
+		if err != nil {
+			t.Fatalf("failed to create token of %s: %v", role, err)
+		}
+		tokens[role] = secret
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("failed to close database: %v", err)
+	}
+
+	server, err := serving.NewServer(options)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		server.Shutdown(context.Background())
+	})
+
+	return ts.URL, tokens[storage.RoleAdmin], tokens[storage.RoleReader]
+}
+
+// newTestClient creates a client of a test server
+func newTestClient(t *testing.T, url, token string) *client.Client {
+	t.Helper()
+	c, err := client.New(client.Config{URL: url, Token: token})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestSourcesCRUD(t *testing.T) {
+	ctx := context.Background()
+	url, adminToken, _ := newTestServer(t)
+	c := newTestClient(t, url, adminToken)
+
+	created, err := c.CreateSource(ctx, client.SourceInput{Name: "Go Blog", URL: "https://go.dev/blog/feed.atom"})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+	if created.ID == "" || created.Name != "Go Blog" {
+		t.Fatalf("CreateSource() = %+v", created)
+	}
+
+	got, err := c.GetSource(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetSource() error = %v", err)
+	}
+	if got.URL != "https://go.dev/blog/feed.atom" {
+		t.Errorf("GetSource().URL = %q", got.URL)
+	}
+
+	updated, err := c.UpdateSource(ctx, created.ID, client.SourceInput{
+		Name: "The Go Blog", URL: got.URL, Description: "News from the Go team",
+	})
+	if err != nil {
+		t.Fatalf("UpdateSource() error = %v", err)
+	}
+	if updated.Name != "The Go Blog" || updated.Description != "News from the Go team" {
+		t.Errorf("UpdateSource() = %+v", updated)
+	}
+
+	if err := c.DeleteSource(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	if _, err := c.GetSource(ctx, created.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetSource() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSourcesIterator(t *testing.T) {
+	ctx := context.Background()
+	url, adminToken, _ := newTestServer(t)
+	c := newTestClient(t, url, adminToken)
+
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		source, err := c.CreateSource(ctx, client.SourceInput{
+			Name: fmt.Sprintf("Source %d", i),
+			URL:  fmt.Sprintf("https://example.com/%d.xml", i),
+		})
+		if err != nil {
+			t.Fatalf("CreateSource() error = %v", err)
+		}
+		want[source.ID] = true
+	}
+
+	tests := []struct {
+		name     string
+		pageSize int
+	}{
+		{"pages of 2", 2},
+		{"exact page", 5},
+		{"single page", 10},
+		{"pages of 1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, err := c.Sources(ctx, tt.pageSize).All()
+			if err != nil {
+				t.Fatalf("All() error = %v", err)
+			}
+			if len(sources) != len(want) {
+				t.Fatalf("All() returned %d sources, want %d", len(sources), len(want))
+			}
+			seen := map[string]bool{}
+			for _, source := range sources {
+				if !want[source.ID] || seen[source.ID] {
+					t.Errorf("All() returned unexpected or repeated source %s", source.ID)
+				}
+				seen[source.ID] = true
+			}
+		})
+	}
+}
+
+func TestIteratorStopsOnError(t *testing.T) {
+	url, _, _ := newTestServer(t)
+	c := newTestClient(t, url, "invalid")
+
+	it := c.Sources(context.Background(), 2)
+	if it.Next() {
+		t.Fatal("Next() = true, want false")
+	}
+	if !errors.Is(it.Err(), client.ErrUnauthorized) {
+		t.Errorf("Err() = %v, want ErrUnauthorized", it.Err())
+	}
+}
+
+func TestAPIErrorFromServer(t *testing.T) {
+	ctx := context.Background()
+	url, adminToken, readerToken := newTestServer(t)
+	admin := newTestClient(t, url, adminToken)
+	reader := newTestClient(t, url, readerToken)
+	anonymous := newTestClient(t, url, "")
+
+	tests := []struct {
+		name   string
+		call   func() error
+		status int
+		want   error
+	}{
+		{
+			name:   "missing source",
+			call:   func() error { _, err := admin.GetSource(ctx, "missing"); return err },
+			status: http.StatusNotFound,
+			want:   client.ErrNotFound,
+		},
+		{
+			name: "reader creating a source",
+			call: func() error {
+				_, err := reader.CreateSource(ctx, client.SourceInput{URL: "https://example.com/feed"})
+				return err
+			},
+			status: http.StatusForbidden,
+			want:   client.ErrForbidden,
+		},
+		{
+			name:   "no token",
+			call:   func() error { _, _, err := anonymous.ListSources(ctx, 10, ""); return err },
+			status: http.StatusUnauthorized,
+			want:   client.ErrUnauthorized,
+		},
+		{
+			name:   "search without keywords",
+			call:   func() error { _, err := reader.SearchContents(ctx, client.SearchOptions{}); return err },
+			status: http.StatusBadRequest,
+			want:   client.ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			if apiErr.Message == "" {
+				t.Error("Message is empty")
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	targets := []error{
+		client.ErrBadRequest, client.ErrUnauthorized, client.ErrForbidden,
+		client.ErrNotFound, client.ErrConflict, client.ErrServer,
+	}
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, client.ErrBadRequest},
+		{http.StatusUnauthorized, client.ErrUnauthorized},
+		{http.StatusForbidden, client.ErrForbidden},
+		{http.StatusNotFound, client.ErrNotFound},
+		{http.StatusConflict, client.ErrConflict},
+		{http.StatusInternalServerError, client.ErrServer},
+		{http.StatusBadGateway, client.ErrServer},
+		{http.StatusServiceUnavailable, client.ErrServer},
+		{http.StatusTooManyRequests, nil},
+		{http.StatusRequestEntityTooLarge, nil},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := error(&client.APIError{Method: http.MethodGet, Path: "/sources", StatusCode: tt.status, Message: "failed"})
+			for _, target := range targets {
+				if got := errors.Is(err, target); got != (target == tt.want) {
+					t.Errorf("errors.Is(%d, %v) = %v", tt.status, target, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// values encodes the options as query parameters
+func (o ListContentsOptions) values() url.Values {
+	query := url.Values{}
+	if o.SourceID != "" {
+		query.Set("sourceId", o.SourceID)
+	}
+	if o.Folder != "" {
+		query.Set("folder", o.Folder)
+	}
+	for _, tag := range o.Tags {
+		query.Add("tag", tag)
+	}
+	for name, value := range map[string]*bool{"read": o.Read, "starred": o.Starred, "readLater": o.ReadLater} {
+		if value != nil {
+			query.Set(name, strconv.FormatBool(*value))
+		}
+	}
+	if o.MinPriority != nil {
+		query.Set("minPriority", strconv.Itoa(*o.MinPriority))
+	}
+	if o.Collapse {
+		query.Set("collapse", "true")
+	}
+	if o.Language != "" {
+		query.Set("language", o.Language)
+	}
+	if !o.StartDate.IsZero() {
+		query.Set("startDate", o.StartDate.Format(time.RFC3339))
+	}
+	if !o.EndDate.IsZero() {
+		query.Set("endDate", o.EndDate.Format(time.RFC3339))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// ListContents gets a page of the content of the user's subscriptions in
+// ID order. Pass the returned token to get the next page; it is empty
+// after the last page.
+func (c *Client) ListContents(ctx context.Context, options ListContentsOptions, nextToken string) ([]Content, string, error) {
+	query := options.values()
+	if nextToken != "" {
+		query.Set("nextToken", nextToken)
+	}
+
+	var resp struct {
+		Contents  []Content `json:"contents"`
+		NextToken string    `json:"nextToken"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/contents", query, nil, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Contents, resp.NextToken, nil
+}
+
+// Contents iterates over all content matching the options, getting
+// options.Limit items per request
+func (c *Client) Contents(ctx context.Context, options ListContentsOptions) *Iterator[Content] {
+	return newIterator(ctx, func(ctx context.Context, nextToken string) ([]Content, string, error) {
+		return c.ListContents(ctx, options, nextToken)
+	})
+}
+
+// GetContent gets a content item
+func (c *Client) GetContent(ctx context.Context, id string) (*Content, error) {
+	var content Content
+	if err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id), nil, nil, &content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// UpdateContent updates a content item. It needs the editor role.
+func (c *Client) UpdateContent(ctx context.Context, id string, input ContentInput) (*Content, error) {
+	var content Content
+	if err := c.do(ctx, http.MethodPut, "/contents/"+url.PathEscape(id), nil, input, &content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// DeleteContent moves a content item to the trash. It needs the editor role.
+func (c *Client) DeleteContent(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/contents/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// UpdateContentState sets the user's read, starred or read-later flags of a
+// content item
+func (c *Client) UpdateContentState(ctx context.Context, id string, input ContentStateInput) (*ContentState, error) {
+	var state ContentState
+	if err := c.do(ctx, http.MethodPut, "/contents/"+url.PathEscape(id)+"/state", nil, input, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetUnreadCounts gets the numbers of unread items of the user's
+// subscriptions
+func (c *Client) GetUnreadCounts(ctx context.Context) (*UnreadCounts, error) {
+	var counts UnreadCounts
+	if err := c.do(ctx, http.MethodGet, "/contents/unread-counts", nil, nil, &counts); err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+// SearchContents searches the content of the user's subscriptions
+func (c *Client) SearchContents(ctx context.Context, options SearchOptions) ([]Content, error) {
+	query := url.Values{}
+	query.Set("keywords", options.Keywords)
+	if options.SourceID != "" {
+		query.Set("sourceId", options.SourceID)
+	}
+	for _, tag := range options.Tags {
+		query.Add("tag", tag)
+	}
+	if options.Language != "" {
+		query.Set("language", options.Language)
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+
+	var resp struct {
+		Contents []Content `json:"contents"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/contents/search", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Contents, nil
+}
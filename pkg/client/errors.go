@@ -0,0 +1,82 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Errors that an *APIError matches with errors.Is according to its status
+// code
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrServer       = errors.New("server error")
+)
+
+// maxErrorBody is how much of a response is read for its error message
+const maxErrorBody = 64 * 1024
+
+// APIError is a response from the server with a status code other than 2xx
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the error reported by the server
+	Message string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is reports whether the error has the status code of target, so that
+// errors.Is(err, client.ErrNotFound) works
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// newAPIError reads the error message of a response
+func newAPIError(method, path string, resp *http.Response) *APIError {
+	apiErr := &APIError{
+		Method:     method,
+		Path:       path,
+		StatusCode: resp.StatusCode,
+	}
+
+	// The server reports errors as {"error": "..."}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error != "" {
+		apiErr.Message = payload.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultPollInterval is how often WaitForFetchJob checks a job by default
+const DefaultPollInterval = time.Second
+
+// Fetch starts a job fetching new content. It needs the editor role.
+func (c *Client) Fetch(ctx context.Context, input FetchInput) (*FetchJob, error) {
+	var job FetchJob
+	if err := c.do(ctx, http.MethodPost, "/contents/fetch", nil, input, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetFetchJob gets the status of a fetch job
+func (c *Client) GetFetchJob(ctx context.Context, id string) (*FetchJob, error) {
+	var job FetchJob
+	if err := c.do(ctx, http.MethodGet, "/contents/fetch/"+url.PathEscape(id), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitForFetchJob polls a fetch job every interval, or DefaultPollInterval
+// if it is 0, until the job is done or ctx is cancelled
+func (c *Client) WaitForFetchJob(ctx context.Context, id string, interval time.Duration) (*FetchJob, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := c.GetFetchJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import "context"
+
+// Iterator walks through the items of a paginated list, fetching pages as
+// needed. Use it like sql.Rows:
+//
+//	it := c.Sources(ctx, 100)
+//	for it.Next() {
+//		source := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	ctx   context.Context
+	fetch func(ctx context.Context, nextToken string) ([]T, string, error)
+	page  []T
+	index int
+	next  string
+	done  bool
+	err   error
+}
+
+// newIterator creates an Iterator that gets pages from fetch, which returns
+// the items and the token of the next page, empty after the last page
+func newIterator[T any](ctx context.Context, fetch func(ctx context.Context, nextToken string) ([]T, string, error)) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, fetch: fetch, index: -1}
+}
+
+// Next advances to the next item, fetching the next page if needed. It
+// returns false when there are no more items or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.index++
+	for it.index >= len(it.page) {
+		if it.done {
+			return false
+		}
+		page, next, err := it.fetch(it.ctx, it.next)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.index, it.next = page, 0, next
+		it.done = next == ""
+	}
+	return true
+}
+
+// Value returns the current item
+func (it *Iterator[T]) Value() T {
+	return it.page[it.index]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All reads the remaining items
+func (it *Iterator[T]) All() ([]T, error) {
+	var items []T
+	for it.Next() {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
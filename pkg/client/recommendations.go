@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GetRecommendations gets the user's recommended content
+func (c *Client) GetRecommendations(ctx context.Context, options RecommendationsOptions) ([]Recommendation, error) {
+	query := url.Values{}
+	for _, id := range options.SourceIDs {
+		query.Add("sourceIds", id)
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.KeepDuplicates {
+		query.Set("collapse", "false")
+	}
+
+	var resp struct {
+		Recommendations []Recommendation `json:"recommendations"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/recommendations", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Recommendations, nil
+}
+
+// SubmitFeedback rates a content item, which shapes future recommendations
+func (c *Client) SubmitFeedback(ctx context.Context, input FeedbackInput) (*Feedback, error) {
+	var feedback Feedback
+	if err := c.do(ctx, http.MethodPost, "/recommendations/feedback", nil, input, &feedback); err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// ListFeedback gets the user's ratings
+func (c *Client) ListFeedback(ctx context.Context) ([]Feedback, error) {
+	var resp struct {
+		Feedback []Feedback `json:"feedback"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/recommendations/feedback", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Feedback, nil
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListSources gets a page of sources. Pass the returned token to get the
+// next page; it is empty after the last page.
+func (c *Client) ListSources(ctx context.Context, limit int, nextToken string) ([]Source, string, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if nextToken != "" {
+		query.Set("nextToken", nextToken)
+	}
+
+	var resp struct {
+		Sources   []Source `json:"sources"`
+		NextToken string   `json:"nextToken"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/sources", query, nil, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Sources, resp.NextToken, nil
+}
+
+// Sources iterates over all sources, getting pageSize of them per request
+func (c *Client) Sources(ctx context.Context, pageSize int) *Iterator[Source] {
+	return newIterator(ctx, func(ctx context.Context, nextToken string) ([]Source, string, error) {
+		return c.ListSources(ctx, pageSize, nextToken)
+	})
+}
+
+// GetSource gets a source
+func (c *Client) GetSource(ctx context.Context, id string) (*Source, error) {
+	var source Source
+	if err := c.do(ctx, http.MethodGet, "/sources/"+url.PathEscape(id), nil, nil, &source); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// CreateSource creates a source. It needs the editor role.
+func (c *Client) CreateSource(ctx context.Context, input SourceInput) (*Source, error) {
+	var source Source
+	if err := c.do(ctx, http.MethodPost, "/sources", nil, input, &source); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// UpdateSource updates a source. It needs the editor role.
+func (c *Client) UpdateSource(ctx context.Context, id string, input SourceInput) (*Source, error) {
+	var source Source
+	if err := c.do(ctx, http.MethodPut, "/sources/"+url.PathEscape(id), nil, input, &source); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// DeleteSource moves a source to the trash. It needs the admin role.
+func (c *Client) DeleteSource(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/sources/"+url.PathEscape(id), nil, nil, nil)
+}
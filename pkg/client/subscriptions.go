@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListSubscriptions gets the user's subscriptions, only those in folder if it
+// is not empty
+func (c *Client) ListSubscriptions(ctx context.Context, folder string) ([]Subscription, error) {
+	query := url.Values{}
+	if folder != "" {
+		query.Set("folder", folder)
+	}
+
+	var resp struct {
+		Subscriptions []Subscription `json:"subscriptions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/subscriptions", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Subscriptions, nil
+}
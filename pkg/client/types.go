@@ -0,0 +1,231 @@
+package client
+
+import "time"
+
+// Source is an RSS source
+type Source struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	Description   string     `json:"description"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	LastFetchedAt *time.Time `json:"lastFetchedAt,omitempty"`
+}
+
+// SourceInput is the input for creating or updating a source
+type SourceInput struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+// Content is an item of an RSS source
+type Content struct {
+	ID       string `json:"id"`
+	SourceID string `json:"sourceId"`
+	Title    string `json:"title"`
+	Link     string `json:"link"`
+	// CanonicalLink identifies the article regardless of tracking
+	// parameters and redirects
+	CanonicalLink string     `json:"canonicalLink,omitempty"`
+	Description   string     `json:"description"`
+	Content       string     `json:"content,omitempty"`
+	PublishedAt   time.Time  `json:"publishedAt"`
+	FetchedAt     time.Time  `json:"fetchedAt"`
+	UpdatedAt     *time.Time `json:"updatedAt,omitempty"`
+	Author        string     `json:"author,omitempty"`
+	Categories    []string   `json:"categories,omitempty"`
+	// StoryID groups the item with its near-duplicates from other sources
+	StoryID string `json:"storyId,omitempty"`
+	// Language is the ISO 639-1 code of the item's language, such as "en"
+	Language string `json:"language,omitempty"`
+	// Tags are the user's personal tags
+	Tags []string `json:"tags,omitempty"`
+	// State is the user's reading state
+	State *ContentState `json:"state,omitempty"`
+}
+
+// ContentInput is the input for updating a content item
+type ContentInput struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Content     string   `json:"content"`
+	Categories  []string `json:"categories"`
+}
+
+// ContentState is a user's reading state of a content item
+type ContentState struct {
+	Read      bool `json:"read"`
+	Starred   bool `json:"starred"`
+	ReadLater bool `json:"readLater"`
+	Priority  int  `json:"priority,omitempty"`
+	// Folder is set if the item was moved out of its subscription's folder
+	Folder string `json:"folder,omitempty"`
+}
+
+// ContentStateInput changes the flags that are set
+type ContentStateInput struct {
+	Read      *bool `json:"read,omitempty"`
+	Starred   *bool `json:"starred,omitempty"`
+	ReadLater *bool `json:"readLater,omitempty"`
+	Priority  *int  `json:"priority,omitempty"`
+	// Folder moves the item; an empty folder moves it back
+	Folder *string `json:"folder,omitempty"`
+}
+
+// ListContentsOptions filters and pages content listings. Zero values do not
+// filter.
+type ListContentsOptions struct {
+	SourceID  string
+	Folder    string
+	Tags      []string
+	Read      *bool
+	Starred   *bool
+	ReadLater *bool
+	// MinPriority limits the listing to items of at least this priority
+	MinPriority *int
+	// Collapse lists only the first item of each story
+	Collapse bool
+	// Language limits the listing to items in this language, such as "zh"
+	Language  string
+	StartDate time.Time
+	EndDate   time.Time
+	// Limit is the page size; the server defaults to 50
+	Limit int
+}
+
+// SearchOptions are the options of a content search
+type SearchOptions struct {
+	// Keywords are comma-separated; items matching any of them are found
+	Keywords string
+	SourceID string
+	Tags     []string
+	// Language limits the results to items in this language, such as "zh"
+	Language string
+	Limit    int
+}
+
+// FetchJob is a job fetching new content from sources
+type FetchJob struct {
+	ID             string     `json:"jobId"`
+	Status         string     `json:"status"`
+	StartedAt      time.Time  `json:"startedAt"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	ItemsProcessed int        `json:"itemsProcessed"`
+	SourceID       *string    `json:"sourceId,omitempty"`
+	Days           int        `json:"days"`
+	Errors         []string   `json:"errors,omitempty"`
+}
+
+// Fetch job statuses
+const (
+	FetchJobPending             = "pending"
+	FetchJobInProgress          = "in-progress"
+	FetchJobCompleted           = "completed"
+	FetchJobCompletedWithErrors = "completed_with_errors"
+	FetchJobFailed              = "failed"
+)
+
+// Done reports whether the job finished, successfully or not
+func (j *FetchJob) Done() bool {
+	return j.Status != FetchJobPending && j.Status != FetchJobInProgress
+}
+
+// FetchInput is the input for starting a fetch job
+type FetchInput struct {
+	// SourceID fetches a single source; all sources are fetched if empty
+	SourceID string `json:"sourceId,omitempty"`
+	// Days is how far back items are fetched; the server defaults to 7
+	Days int `json:"days,omitempty"`
+}
+
+// Recommendation is a recommended content item
+type Recommendation struct {
+	Content      Content `json:"content"`
+	Score        float64 `json:"score"`
+	RecommendFor string  `json:"recommendFor,omitempty"`
+	// StorySize is the number of near-duplicates the item stands for
+	StorySize int `json:"storySize,omitempty"`
+	// Components are the scores of the server's scorers
+	Components []ComponentScore `json:"components,omitempty"`
+}
+
+// ComponentScore is the score of one of the server's scorers for an item
+type ComponentScore struct {
+	Name string `json:"name"`
+	// Weight is how much the scorer counts, the weights adding up to 1
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
+}
+
+// RecommendationsOptions are the options of GetRecommendations
+type RecommendationsOptions struct {
+	// SourceIDs limits recommendations to these sources
+	SourceIDs []string
+	// Limit is the number of recommendations; the server defaults to 10
+	Limit int
+	// KeepDuplicates recommends every item of a story instead of the best
+	KeepDuplicates bool
+}
+
+// Feedback is a user's rating of a content item
+type Feedback struct {
+	ID        string    `json:"id"`
+	ContentID string    `json:"contentId"`
+	UserID    string    `json:"userId"`
+	Rating    int       `json:"rating"`
+	Timestamp time.Time `json:"timestamp"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// FeedbackInput is the input for rating a content item
+type FeedbackInput struct {
+	ContentID string `json:"contentId"`
+	// Rating is between 1 and 5
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Subscription is the user's subscription to a source
+type Subscription struct {
+	ID                string    `json:"id"`
+	UserID            string    `json:"userId"`
+	SourceID          string    `json:"sourceId"`
+	DisplayName       string    `json:"displayName,omitempty"`
+	Folder            string    `json:"folder,omitempty"`
+	NotifyNewContent  bool      `json:"notifyNewContent"`
+	NotifyFetchErrors bool      `json:"notifyFetchErrors"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	Source            Source    `json:"source"`
+}
+
+// Name returns the display name of the subscription, or else the name of
+// its source
+func (s *Subscription) Name() string {
+	if s.DisplayName != "" {
+		return s.DisplayName
+	}
+	return s.Source.Name
+}
+
+// UnreadCounts are the numbers of unread items of the user's subscriptions
+type UnreadCounts struct {
+	Total   int                 `json:"total"`
+	Sources []SourceUnreadCount `json:"sources"`
+	Folders []FolderUnreadCount `json:"folders"`
+}
+
+// SourceUnreadCount is the number of unread items of a subscribed source
+type SourceUnreadCount struct {
+	SourceID string `json:"sourceId"`
+	Folder   string `json:"folder,omitempty"`
+	Unread   int    `json:"unread"`
+}
+
+// FolderUnreadCount is the number of unread items in a folder
+type FolderUnreadCount struct {
+	Folder string `json:"folder"`
+	Unread int    `json:"unread"`
+}
@@ -1,7 +1,6 @@
 package riffle
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -10,7 +9,6 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -21,25 +19,46 @@ type ArticleScore struct {
 	Score         float64
 	InterestScore float64 // Score based on user interests
 	ContentScore  float64 // Score based on content quality
+	Language      string  // Detected language of the article
+	// Components are the scores of the individual scorers, which add up to
+	// Score when weighted
+	Components []ComponentScore
+}
+
+// ComponentScore is the score of one scorer for an article
+type ComponentScore struct {
+	Name string `json:"name"`
+	// Weight is how much the scorer counts, the weights adding up to 1
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
 }
 
 // ContentAnalyzer analyzes article content and scores it
 type ContentAnalyzer struct {
-	// Keywords that indicate valuable content
-	valueKeywords []string
+	// Scorers rating the articles
+	scorers []weightedScorer
 	// User's current interests
-	interests []string
+	interests []Interest
+	// Corpus the terms of the articles are weighed against, or nil to weigh
+	// them against the analyzed articles
+	corpus *Corpus
 }
 
-// NewContentAnalyzer creates a new ContentAnalyzer
-func NewContentAnalyzer(interestsFile string) (*ContentAnalyzer, error) {
+// NewContentAnalyzer creates a new ContentAnalyzer that scores articles
+// with the scorers of config, or with the default scorers if config is nil
+func NewContentAnalyzer(interestsFile string, config *ScoringConfig) (*ContentAnalyzer, error) {
+	if config == nil {
+		var err error
+		if config, err = LoadScoringConfig(""); err != nil {
+			return nil, err
+		}
+	}
+	scorers, err := buildScorers(config)
+	if err != nil {
+		return nil, err
+	}
 	analyzer := &ContentAnalyzer{
-		valueKeywords: []string{
-			"research", "study", "analysis", "guide", "tutorial",
-			"introduction", "review", "comparison", "best practices",
-			"how to", "explained", "deep dive", "architecture",
-			"performance", "security", "scalability",
-		},
+		scorers: scorers,
 	}
 
 	// Load interests if file is provided
@@ -50,15 +69,7 @@ func NewContentAnalyzer(interestsFile string) (*ContentAnalyzer, error) {
 		}
 		defer file.Close()
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			interest := strings.TrimSpace(scanner.Text())
-			if interest != "" {
-				analyzer.interests = append(analyzer.interests, interest)
-			}
-		}
-
-		if err := scanner.Err(); err != nil {
+		if analyzer.interests, err = ReadInterests(file); err != nil {
 			return nil, err
 		}
 	}
@@ -66,132 +77,127 @@ func NewContentAnalyzer(interestsFile string) (*ContentAnalyzer, error) {
 	return analyzer, nil
 }
 
+// SetInterests replaces the reader's interests, such as with the interest
+// profile of a user
+func (ca *ContentAnalyzer) SetInterests(interests []Interest) {
+	ca.interests = interests
+}
+
+// SetCorpus makes the analyzer weigh the terms of articles by their
+// frequency in a corpus, such as the stored content, instead of in the
+// articles analyzed together
+func (ca *ContentAnalyzer) SetCorpus(corpus *Corpus) {
+	ca.corpus = corpus
+}
+
 // AnalyzeArticle scores an article based on various factors
 func (ca *ContentAnalyzer) AnalyzeArticle(article *Article) (ArticleScore, error) {
-	// Get the full content if available
-	content := article.Summary
-	if article.Content != "" {
-		content = article.Content
-	}
-
-	// Clean and prepare the content
-	cleanContent := html.UnescapeString(content)
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(cleanContent))
+	scores, err := ca.AnalyzeArticles([]*Article{article})
 	if err != nil {
 		return ArticleScore{Article: article}, err
 	}
-
-	// Calculate content quality scores
-	textScore := ca.calculateTextScore(doc)
-	keywordScore := ca.calculateKeywordScore(doc.Text())
-	linkScore := ca.calculateLinkScore(doc)
-
-	// Calculate interest relevance score
-	interestScore := ca.calculateInterestScore(article.Title + " " + doc.Text())
-
-	// Combine content quality scores (50% weight)
-	contentScore := (textScore * 0.4) + (keywordScore * 0.4) + (linkScore * 0.2)
-
-	// Final score is 50% interest relevance and 50% content quality
-	totalScore := (interestScore * 0.5) + (contentScore * 0.5)
-
-	return ArticleScore{
-		Article:       article,
-		Score:         totalScore,
-		InterestScore: interestScore,
-		ContentScore:  contentScore,
-	}, nil
+	return scores[0], nil
 }
 
-// calculateInterestScore evaluates how well the content matches user interests
-func (ca *ContentAnalyzer) calculateInterestScore(text string) float64 {
-	if len(ca.interests) == 0 {
-		return 0.5 // Neutral score if no interests defined
+// AnalyzeArticles scores articles together, so that how well they match the
+// reader's interests is compared across them
+func (ca *ContentAnalyzer) AnalyzeArticles(articles []*Article) ([]ArticleScore, error) {
+	docs := make([]*Document, len(articles))
+	for i, article := range articles {
+		doc, err := ca.prepareDocument(article)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze article '%s': %w", article.Title, err)
+		}
+		docs[i] = doc
 	}
 
-	text = strings.ToLower(text)
-	var matchCount int
-
-	for _, interest := range ca.interests {
-		// Split interest into words for more flexible matching
-		interestWords := strings.Fields(strings.ToLower(interest))
-
-		// Count how many words from this interest appear in the text
-		wordMatches := 0
-		for _, word := range interestWords {
-			if strings.Contains(text, word) {
-				wordMatches++
-			}
+	// Weigh terms against the analyzed articles without a corpus
+	corpus := ca.corpus
+	if corpus == nil {
+		corpus = NewCorpus()
+		for _, doc := range docs {
+			corpus.Add(doc.Terms)
 		}
+	}
+	for _, doc := range docs {
+		doc.Corpus = corpus
+	}
 
-		// Consider it a match if more than half of the words match
-		if float64(wordMatches) >= float64(len(interestWords))*0.5 {
-			matchCount++
+	for _, s := range ca.scorers {
+		if batch, ok := s.scorer.(BatchScorer); ok {
+			batch.Prepare(docs)
 		}
 	}
 
-	// Score based on the proportion of matching interests
-	return float64(matchCount) / float64(len(ca.interests))
+	scores := make([]ArticleScore, len(docs))
+	for i, doc := range docs {
+		scores[i] = ca.scoreDocument(doc)
+	}
+	return scores, nil
 }
 
-// calculateTextScore evaluates the quality of the text content
-func (ca *ContentAnalyzer) calculateTextScore(doc *goquery.Document) float64 {
-	text := doc.Text()
-	textLen := utf8.RuneCountInString(text)
-
-	// Score based on content length (0-1)
-	lengthScore := float64(0)
-	switch {
-	case textLen > 2000:
-		lengthScore = 1.0
-	case textLen > 1000:
-		lengthScore = 0.8
-	case textLen > 500:
-		lengthScore = 0.6
-	case textLen > 200:
-		lengthScore = 0.4
-	default:
-		lengthScore = 0.2
+// prepareDocument extracts the text, terms and language of an article
+func (ca *ContentAnalyzer) prepareDocument(article *Article) (*Document, error) {
+	// Get the full content if available
+	content := article.Summary
+	if article.Content != "" {
+		content = article.Content
 	}
 
-	return lengthScore
-}
-
-// calculateKeywordScore evaluates the presence of valuable keywords
-func (ca *ContentAnalyzer) calculateKeywordScore(text string) float64 {
-	text = strings.ToLower(text)
-	var keywordCount int
+	// Clean and prepare the content
+	cleanContent := html.UnescapeString(content)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(cleanContent))
+	if err != nil {
+		return nil, err
+	}
+	text := doc.Text()
 
-	for _, keyword := range ca.valueKeywords {
-		if strings.Contains(text, strings.ToLower(keyword)) {
-			keywordCount++
-		}
+	// Detect the language, falling back to the one the feed declares
+	language := DetectLanguage(article.Title + " " + text)
+	if language == "" {
+		language = NormalizeLanguage(article.Language)
 	}
 
-	// Score based on keyword presence (0-1)
-	return float64(keywordCount) / float64(len(ca.valueKeywords))
+	titleTerms := Tokenize(article.Title)
+	return &Document{
+		Article:    article,
+		HTML:       doc,
+		Text:       text,
+		Terms:      append(titleTerms[:len(titleTerms):len(titleTerms)], Tokenize(text)...),
+		TitleTerms: titleTerms,
+		Language:   language,
+		Interests:  ca.interests,
+	}, nil
 }
 
-// calculateLinkScore evaluates the quality of links
-func (ca *ContentAnalyzer) calculateLinkScore(doc *goquery.Document) float64 {
-	links := doc.Find("a")
-	linkCount := links.Length()
-
-	if linkCount == 0 {
-		return 0.5 // Neutral score for no links
+// scoreDocument runs the scorers on a prepared article. Interest relevance
+// is reported apart from the other scores, which rate the quality of the
+// content.
+func (ca *ContentAnalyzer) scoreDocument(document *Document) ArticleScore {
+	score := ArticleScore{
+		Article:  document.Article,
+		Language: document.Language,
 	}
-
-	// Count external links (usually more valuable)
-	var externalLinks int
-	links.Each(func(_ int, link *goquery.Selection) {
-		href, exists := link.Attr("href")
-		if exists && strings.HasPrefix(href, "http") {
-			externalLinks++
+	var interestWeight, contentWeight float64
+	for _, s := range ca.scorers {
+		component := ComponentScore{Name: s.name, Weight: s.weight, Score: s.scorer.Score(document)}
+		score.Components = append(score.Components, component)
+		score.Score += component.Weight * component.Score
+		if s.scorerType == "interests" {
+			score.InterestScore += component.Weight * component.Score
+			interestWeight += component.Weight
+		} else {
+			score.ContentScore += component.Weight * component.Score
+			contentWeight += component.Weight
 		}
-	})
-
-	// Score based on external link ratio (0-1)
-	return float64(externalLinks) / float64(linkCount)
+	}
+	if interestWeight > 0 {
+		score.InterestScore /= interestWeight
+	}
+	if contentWeight > 0 {
+		score.ContentScore /= contentWeight
+	}
+	return score
 }
 
 // PerplexityAnalysis represents the analysis result from Perplexity API
@@ -0,0 +1,189 @@
+package riffle
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// DefaultStrippedParams are the tracking query parameters removed from
+// links. A trailing * matches any parameter starting with the rest.
+var DefaultStrippedParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "yclid", "igshid",
+	"mc_cid", "mc_eid", "_hsenc", "_hsmi", "mkt_tok", "ref_src",
+}
+
+// DefaultResolvedHosts are the hosts of feed proxies whose links are only
+// resolved to the article by following their redirects
+var DefaultResolvedHosts = []string{"feedproxy.google.com", "feeds.feedburner.com"}
+
+// redirectParams maps redirect pages that carry their target in a query
+// parameter to that parameter
+var redirectParams = map[string]string{
+	"www.google.com/url":       "q",
+	"google.com/url":           "q",
+	"l.facebook.com/l.php":     "u",
+	"lm.facebook.com/l.php":    "u",
+	"www.youtube.com/redirect": "q",
+	"t.umblr.com/redirect":     "z",
+	"out.reddit.com":           "url",
+}
+
+// maxUnwraps limits how many redirects are unwrapped, so that links
+// redirecting to themselves do not loop
+const maxUnwraps = 5
+
+// Canonicalizer turns the links of feed items into a canonical form, so that
+// the same article linked in different ways is recognized. Canonical links
+// use https, a lowercase host without the default port, no fragment, no
+// trailing slash, no tracking parameters and sorted query parameters.
+type Canonicalizer struct {
+	params   map[string]bool
+	prefixes []string
+	hosts    map[string]bool
+	client   *http.Client
+}
+
+// NewCanonicalizer creates a Canonicalizer that removes the given query
+// parameters and follows the redirects of links to the given hosts, giving
+// up on a redirect after timeout
+func NewCanonicalizer(strippedParams, resolvedHosts []string, timeout time.Duration) *Canonicalizer {
+	c := &Canonicalizer{
+		params: map[string]bool{},
+		hosts:  map[string]bool{},
+		client: &http.Client{Timeout: timeout},
+	}
+	for _, param := range strippedParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			c.prefixes = append(c.prefixes, prefix)
+		} else if param != "" {
+			c.params[param] = true
+		}
+	}
+	for _, host := range resolvedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			c.hosts[host] = true
+		}
+	}
+	return c
+}
+
+// Canonicalize returns the canonical form of a link. Links that are not
+// absolute http or https URLs are returned trimmed but otherwise unchanged.
+func (c *Canonicalizer) Canonicalize(ctx context.Context, link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || (!strings.EqualFold(u.Scheme, "http") && !strings.EqualFold(u.Scheme, "https")) || u.Host == "" {
+		return link
+	}
+
+	for i := 0; i < maxUnwraps; i++ {
+		next := c.unwrap(ctx, u)
+		if next == nil {
+			break
+		}
+		u = next
+	}
+	return c.normalize(u)
+}
+
+// unwrap returns the target of a redirect link, or nil if the link is not
+// a known redirect or its target cannot be found
+func (c *Canonicalizer) unwrap(ctx context.Context, u *url.URL) *url.URL {
+	host := strings.ToLower(u.Hostname())
+
+	// Redirect pages that carry the target in a parameter
+	param, ok := redirectParams[host+strings.TrimSuffix(u.Path, "/")]
+	if !ok {
+		param, ok = redirectParams[host]
+	}
+	if ok {
+		target, err := url.Parse(u.Query().Get(param))
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil
+		}
+		return target
+	}
+
+	// Feed proxies that have to be asked where they redirect to
+	if !c.hosts[host] {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	if resp.Request.URL.String() == u.String() {
+		return nil
+	}
+	return resp.Request.URL
+}
+
+// normalize formats a URL in the canonical form
+func (c *Canonicalizer) normalize(u *url.URL) string {
+	out := *u
+	out.Scheme = "https"
+	out.User = nil
+	out.Fragment = ""
+	out.RawFragment = ""
+
+	// Lowercase the host and drop the default ports
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	out.Host = host
+
+	// Drop the trailing slash, but keep the root path
+	out.Path = strings.TrimSuffix(u.Path, "/")
+	out.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	if out.Path == "" {
+		out.Path = "/"
+	}
+
+	// Drop the tracking parameters and sort the rest
+	query := u.Query()
+	for name := range query {
+		if c.stripped(name) {
+			query.Del(name)
+		}
+	}
+	out.RawQuery = query.Encode()
+	out.ForceQuery = false
+	return out.String()
+}
+
+// stripped reports whether a query parameter is removed
+func (c *Canonicalizer) stripped(name string) bool {
+	name = strings.ToLower(name)
+	if c.params[name] {
+		return true
+	}
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginalLink returns the original link FeedBurner records for an item it
+// proxies, or an empty string if there is none
+func OriginalLink(item *gofeed.Item) string {
+	for _, ext := range item.Extensions["feedburner"]["origLink"] {
+		if link := strings.TrimSpace(ext.Value); link != "" {
+			return link
+		}
+	}
+	return ""
+}
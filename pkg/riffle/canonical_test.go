@@ -0,0 +1,168 @@
+package riffle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+func TestCanonicalize(t *testing.T) {
+	c := NewCanonicalizer(DefaultStrippedParams, nil, time.Second)
+
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{
+			name: "utm parameters",
+			link: "https://example.com/post?utm_source=rss&utm_medium=feed&utm_campaign=x",
+			want: "https://example.com/post",
+		},
+		{
+			name: "utm parameters in any case",
+			link: "https://example.com/post?UTM_Source=rss&id=7",
+			want: "https://example.com/post?id=7",
+		},
+		{
+			name: "other tracking parameters",
+			link: "https://example.com/post?fbclid=abc&gclid=def&page=2",
+			want: "https://example.com/post?page=2",
+		},
+		{
+			name: "sorted parameters",
+			link: "https://example.com/post?b=2&a=1",
+			want: "https://example.com/post?a=1&b=2",
+		},
+		{
+			name: "scheme, host, port and fragment",
+			link: "http://Example.COM:80/post/#comments",
+			want: "https://example.com/post",
+		},
+		{
+			name: "non-default port",
+			link: "https://example.com:8443/post",
+			want: "https://example.com:8443/post",
+		},
+		{
+			name: "root path",
+			link: "https://example.com",
+			want: "https://example.com/",
+		},
+		{
+			name: "google redirect",
+			link: "https://www.google.com/url?q=https%3A%2F%2Fexample.com%2Fpost%3Futm_source%3Dgoogle&sa=D",
+			want: "https://example.com/post",
+		},
+		{
+			name: "nested redirects",
+			link: "https://l.facebook.com/l.php?u=" + url.QueryEscape("https://www.google.com/url?q=https://example.com/post"),
+			want: "https://example.com/post",
+		},
+		{
+			name: "redirect to another scheme",
+			link: "https://www.google.com/url?q=javascript:alert(1)",
+			want: "https://www.google.com/url?q=javascript%3Aalert%281%29",
+		},
+		{
+			name: "not an http link",
+			link: " mailto:someone@example.com ",
+			want: "mailto:someone@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Canonicalize(context.Background(), tt.link); got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeFeedProxy(t *testing.T) {
+	// The proxy redirects its links to the article, which ends the chain
+	mux := http.NewServeMux()
+	mux.HandleFunc("/~r/blog/~3/abc/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article?utm_source=feedburner", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	proxy, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name  string
+		hosts []string
+		link  string
+		want  string
+	}{
+		{
+			name:  "resolved host",
+			hosts: []string{proxy.Hostname()},
+			link:  server.URL + "/~r/blog/~3/abc/",
+			want:  "https://" + proxy.Host + "/article",
+		},
+		{
+			name:  "host not resolved",
+			hosts: nil,
+			link:  server.URL + "/~r/blog/~3/abc/",
+			want:  "https://" + proxy.Host + "/~r/blog/~3/abc",
+		},
+		{
+			name:  "link without redirect",
+			hosts: []string{proxy.Hostname()},
+			link:  server.URL + "/article",
+			want:  "https://" + proxy.Host + "/article",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCanonicalizer(DefaultStrippedParams, tt.hosts, time.Second)
+			if got := c.Canonicalize(context.Background(), tt.link); got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginalLink(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions ext.Extensions
+		want       string
+	}{
+		{
+			name: "feedburner original link",
+			extensions: ext.Extensions{"feedburner": {"origLink": {
+				{Value: " https://example.com/post "},
+			}}},
+			want: "https://example.com/post",
+		},
+		{
+			name:       "no extension",
+			extensions: nil,
+			want:       "",
+		},
+		{
+			name: "empty original link",
+			extensions: ext.Extensions{"feedburner": {"origLink": {
+				{Value: " "},
+			}}},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &gofeed.Item{Link: "http://feedproxy.google.com/~r/blog/~3/abc/", Extensions: tt.extensions}
+			if got := OriginalLink(item); got != tt.want {
+				t.Errorf("OriginalLink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
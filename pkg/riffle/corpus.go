@@ -0,0 +1,51 @@
+package riffle
+
+import "math"
+
+// Corpus holds how many documents contain each stemmed term, from which the
+// weight of a term is derived: terms that appear in few documents tell more
+// about a document than terms that appear in most of them
+type Corpus struct {
+	// Documents is the number of documents added
+	Documents int
+	// frequencies maps stemmed terms to the number of documents containing
+	// them
+	frequencies map[string]int
+}
+
+// NewCorpus creates an empty corpus
+func NewCorpus() *Corpus {
+	return &Corpus{frequencies: map[string]int{}}
+}
+
+// Add adds a document given by its terms, as split by Tokenize
+func (c *Corpus) Add(terms []string) {
+	c.Documents++
+	seen := map[string]bool{}
+	for _, term := range terms {
+		stem := Stem(term)
+		if !seen[stem] {
+			seen[stem] = true
+			c.frequencies[stem]++
+		}
+	}
+}
+
+// DocumentFrequency returns the number of documents containing a stemmed
+// term
+func (c *Corpus) DocumentFrequency(stem string) int {
+	return c.frequencies[stem]
+}
+
+// IDF returns the inverse document frequency of a stemmed term, which is
+// higher for rarer terms and always positive
+func (c *Corpus) IDF(stem string) float64 {
+	n := float64(c.frequencies[stem])
+	return math.Log(1 + (float64(c.Documents)-n+0.5)/(n+0.5))
+}
+
+// UnseenIDF returns the inverse document frequency of a term found in no
+// document, the highest there is
+func (c *Corpus) UnseenIDF() float64 {
+	return math.Log(1 + (float64(c.Documents)+0.5)/0.5)
+}
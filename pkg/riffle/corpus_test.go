@@ -0,0 +1,67 @@
+package riffle
+
+import "testing"
+
+func TestCorpusDocumentFrequency(t *testing.T) {
+	corpus := NewCorpus()
+	corpus.Add(Tokenize("Running and runs: the runner keeps running"))
+	corpus.Add(Tokenize("Go is fun"))
+	corpus.Add(Tokenize("Google runs Go"))
+
+	tests := []struct {
+		stem string
+		want int
+	}{
+		// Repeated terms count once per document, stemmed
+		{"run", 2},
+		{"runner", 1},
+		{"go", 2},
+		{"googl", 1},
+		{"google", 0},
+		{"missing", 0},
+	}
+	if corpus.Documents != 3 {
+		t.Errorf("Documents = %d, want 3", corpus.Documents)
+	}
+	for _, tt := range tests {
+		if got := corpus.DocumentFrequency(tt.stem); got != tt.want {
+			t.Errorf("DocumentFrequency(%q) = %d, want %d", tt.stem, got, tt.want)
+		}
+	}
+}
+
+func TestCorpusIDF(t *testing.T) {
+	corpus := NewCorpus()
+	for _, text := range []string{
+		"the kubernetes release",
+		"the go release",
+		"the rust release",
+		"the weekly news",
+	} {
+		corpus.Add(Tokenize(text))
+	}
+
+	tests := []struct {
+		name         string
+		rarer, other string
+	}{
+		{"rare over common", "kubernet", "releas"},
+		{"common over everywhere", "releas", "the"},
+		{"unseen over rare", "missing", "kubernet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if corpus.IDF(tt.rarer) <= corpus.IDF(tt.other) {
+				t.Errorf("IDF(%q) = %v, want more than IDF(%q) = %v",
+					tt.rarer, corpus.IDF(tt.rarer), tt.other, corpus.IDF(tt.other))
+			}
+		})
+	}
+
+	if idf := corpus.IDF("the"); idf <= 0 {
+		t.Errorf("IDF of a term in every document = %v, want positive", idf)
+	}
+	if unseen := corpus.UnseenIDF(); unseen != corpus.IDF("missing") {
+		t.Errorf("UnseenIDF() = %v, want IDF of a missing term %v", unseen, corpus.IDF("missing"))
+	}
+}
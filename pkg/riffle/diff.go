@@ -0,0 +1,130 @@
+package riffle
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DiffOp is the kind of change a DiffChunk represents
+type DiffOp string
+
+const (
+	// DiffEqual marks text present in both versions
+	DiffEqual DiffOp = "equal"
+	// DiffInsert marks text only present in the new version
+	DiffInsert DiffOp = "insert"
+	// DiffDelete marks text only present in the old version
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffChunk is a run of consecutive tokens sharing the same DiffOp
+type DiffChunk struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the size of the LCS table. Inputs whose differing
+// middle sections are larger than this are reported as a full replacement.
+const maxDiffCells = 4_000_000
+
+// DiffLines computes a line-by-line diff between two texts
+func DiffLines(oldText, newText string) []DiffChunk {
+	return diffTokens(splitKeep(oldText, func(r rune) bool { return r == '\n' }),
+		splitKeep(newText, func(r rune) bool { return r == '\n' }))
+}
+
+// DiffWords computes a word-by-word diff between two texts
+func DiffWords(oldText, newText string) []DiffChunk {
+	return diffTokens(splitKeep(oldText, unicode.IsSpace), splitKeep(newText, unicode.IsSpace))
+}
+
+// splitKeep splits text after every rune matching sep, keeping the separators
+// so that joining the tokens gives back the original text
+func splitKeep(text string, sep func(rune) bool) []string {
+	var tokens []string
+	start := 0
+	for i, r := range text {
+		if sep(r) {
+			end := i + len(string(r))
+			tokens = append(tokens, text[start:end])
+			start = end
+		}
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// diffTokens computes the diff between two token lists using the longest
+// common subsequence of their differing middle sections
+func diffTokens(a, b []string) []DiffChunk {
+	var chunks []DiffChunk
+	add := func(op DiffOp, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Text += text
+			return
+		}
+		chunks = append(chunks, DiffChunk{Op: op, Text: text})
+	}
+
+	// Trim the common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	add(DiffEqual, strings.Join(a[:prefix], ""))
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	n, m := len(midA), len(midB)
+
+	if n == 0 || m == 0 || n*m > maxDiffCells {
+		// Nothing in common in the middle (or too large to compare)
+		add(DiffDelete, strings.Join(midA, ""))
+		add(DiffInsert, strings.Join(midB, ""))
+	} else {
+		// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case midA[i] == midB[j]:
+				add(DiffEqual, midA[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add(DiffDelete, midA[i])
+				i++
+			default:
+				add(DiffInsert, midB[j])
+				j++
+			}
+		}
+		add(DiffDelete, strings.Join(midA[i:], ""))
+		add(DiffInsert, strings.Join(midB[j:], ""))
+	}
+
+	add(DiffEqual, strings.Join(a[len(a)-suffix:], ""))
+	return chunks
+}
@@ -0,0 +1,84 @@
+package riffle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// join rebuilds one side of a diff
+func join(chunks []DiffChunk, skip DiffOp) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Op != skip {
+			b.WriteString(chunk.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []DiffChunk
+	}{
+		{"both empty", "", "", nil},
+		{"unchanged", "a\nb\n", "a\nb\n", []DiffChunk{{DiffEqual, "a\nb\n"}}},
+		{"from empty", "", "a\nb\n", []DiffChunk{{DiffInsert, "a\nb\n"}}},
+		{"to empty", "a\nb\n", "", []DiffChunk{{DiffDelete, "a\nb\n"}}},
+		{
+			"insert",
+			"a\nc\n", "a\nb\nc\n",
+			[]DiffChunk{{DiffEqual, "a\n"}, {DiffInsert, "b\n"}, {DiffEqual, "c\n"}},
+		},
+		{
+			"delete",
+			"a\nb\nc\n", "a\nc\n",
+			[]DiffChunk{{DiffEqual, "a\n"}, {DiffDelete, "b\n"}, {DiffEqual, "c\n"}},
+		},
+		{
+			"replace",
+			"a\nb\nc\n", "a\nx\nc\n",
+			[]DiffChunk{{DiffEqual, "a\n"}, {DiffDelete, "b\n"}, {DiffInsert, "x\n"}, {DiffEqual, "c\n"}},
+		},
+		{
+			"changes between common lines",
+			"a\nb\nc\nd\n", "b\nx\nd\ne\n",
+			[]DiffChunk{{DiffDelete, "a\n"}, {DiffEqual, "b\n"}, {DiffDelete, "c\n"}, {DiffInsert, "x\n"}, {DiffEqual, "d\n"}, {DiffInsert, "e\n"}},
+		},
+		{
+			"missing final newline",
+			"a\nb", "a\nb\n",
+			[]DiffChunk{{DiffEqual, "a\n"}, {DiffDelete, "b"}, {DiffInsert, "b\n"}},
+		},
+	}
+	for _, tt := range tests {
+		got := DiffLines(tt.old, tt.new)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DiffLines() = %q, want %q", tt.name, got, tt.want)
+		}
+		if join(got, DiffInsert) != tt.old || join(got, DiffDelete) != tt.new {
+			t.Errorf("%s: the diff does not rebuild both texts", tt.name)
+		}
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	got := DiffWords("the quick fox", "the slow fox")
+	want := []DiffChunk{{DiffEqual, "the "}, {DiffDelete, "quick "}, {DiffInsert, "slow "}, {DiffEqual, "fox"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffWords() = %q, want %q", got, want)
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	// Differing sections above maxDiffCells are reported as a replacement
+	old := strings.Repeat("a\n", 2001) + "end\n"
+	new := strings.Repeat("b\n", 2001) + "end\n"
+	got := DiffLines(old, new)
+	want := []DiffChunk{{DiffDelete, strings.Repeat("a\n", 2001)}, {DiffInsert, strings.Repeat("b\n", 2001)}, {DiffEqual, "end\n"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffLines() of large inputs returned %d chunks, want a replacement", len(got))
+	}
+}
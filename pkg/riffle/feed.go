@@ -15,6 +15,8 @@ type Article struct {
 	Content     string // Full content of the article
 	URL         string // URL of the article
 	PublishedAt time.Time
+	Language    string // Language the feed declares, if any
+	Source      string // URL of the feed
 }
 
 // FetchLatestArticles fetches articles from the last 2 days from a feed URL, up to n articles
@@ -63,6 +65,8 @@ func FetchLatestArticles(ctx context.Context, feedURL string, n int) ([]Article,
 			Content:     content,
 			URL:         url,
 			PublishedAt: pubDate,
+			Language:    feed.Language,
+			Source:      feedURL,
 		})
 
 		// Stop if we have enough articles
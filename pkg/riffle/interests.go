@@ -0,0 +1,231 @@
+package riffle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Interest is a topic the reader wants to read more or, with a negative
+// weight, less about
+type Interest struct {
+	// Terms are the words or phrase of the interest
+	Terms string
+	// Weight scales how much matching articles are promoted, or demoted if
+	// it is negative
+	Weight float64
+	// Synonyms are other words or phrases for the interest
+	Synonyms []string
+	// Pattern is an optional regular expression matched against the title
+	// and text
+	Pattern *regexp.Regexp
+}
+
+// ReadInterests reads interests of weight 1 from a text with one interest
+// per line, skipping blank lines
+func ReadInterests(r io.Reader) ([]Interest, error) {
+	var interests []Interest
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if terms := strings.TrimSpace(scanner.Text()); terms != "" {
+			interests = append(interests, Interest{Terms: terms, Weight: 1})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return interests, nil
+}
+
+// BM25 parameters: bm25K1 controls how quickly repeated terms stop adding to
+// the score, and bm25B how much longer articles are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// interestsOptions are the options of the interests scorer
+type interestsOptions struct {
+	// TitleBoost is how many occurrences in the text an occurrence in the
+	// title counts for
+	TitleBoost float64 `yaml:"titleBoost"`
+	// PhraseBoost multiplies the score of an interest of several terms when
+	// its terms appear in order in the title or text
+	PhraseBoost float64 `yaml:"phraseBoost"`
+}
+
+// interestsScorer rates articles by how well they match the reader's
+// interests. The terms of the interests and articles are stemmed and each
+// matched term is weighted by its frequency in the article and its rarity
+// in the corpus, with BM25. An interest scores as its best-matching
+// alternative among its terms, synonyms and pattern, scaled by its weight.
+// Scores are normalized across the analyzed articles, from 0 for the
+// article matching the negative interests most to 1 for the one matching
+// the positive interests most.
+type interestsScorer struct {
+	options interestsOptions
+	// scores are the normalized scores of the prepared articles
+	scores map[*Document]float64
+}
+
+// newInterestsScorer creates a scorer that rates articles by how well they
+// match the reader's interests
+func newInterestsScorer(decode func(interface{}) error) (Scorer, error) {
+	options := interestsOptions{TitleBoost: 2, PhraseBoost: 1.5}
+	if err := decode(&options); err != nil {
+		return nil, err
+	}
+	if options.TitleBoost < 1 {
+		return nil, fmt.Errorf("titleBoost must be at least 1")
+	}
+	if options.PhraseBoost < 1 {
+		return nil, fmt.Errorf("phraseBoost must be at least 1")
+	}
+	return &interestsScorer{options: options}, nil
+}
+
+// Prepare scores the articles and normalizes their scores
+func (s *interestsScorer) Prepare(docs []*Document) {
+	s.scores = make(map[*Document]float64, len(docs))
+	if len(docs) == 0 {
+		return
+	}
+
+	var totalLength int
+	for _, doc := range docs {
+		totalLength += len(doc.Terms)
+	}
+	averageLength := float64(totalLength) / float64(len(docs))
+
+	// Stem the alternatives of the interests once
+	alternatives := map[*Interest][][]string{}
+	var lowest, highest float64
+	for _, doc := range docs {
+		stemmed := s.stemDocument(doc, averageLength)
+		var score float64
+		for i := range doc.Interests {
+			interest := &doc.Interests[i]
+			stems, ok := alternatives[interest]
+			if !ok {
+				for _, alternative := range append([]string{interest.Terms}, interest.Synonyms...) {
+					stems = append(stems, stemTerms(Tokenize(alternative)))
+				}
+				alternatives[interest] = stems
+			}
+
+			var best float64
+			for _, alternative := range stems {
+				best = math.Max(best, s.match(stemmed, alternative))
+			}
+			if interest.Pattern != nil {
+				best = math.Max(best, s.matchPattern(doc, stemmed, interest.Pattern))
+			}
+			score += interest.Weight * best
+		}
+		s.scores[doc] = score
+		lowest = math.Min(lowest, score)
+		highest = math.Max(highest, score)
+	}
+
+	// Articles matching no interest score 0 unless there are negative
+	// interests, which push the articles matching them below the others
+	for doc, score := range s.scores {
+		if highest > lowest {
+			s.scores[doc] = (score - lowest) / (highest - lowest)
+		}
+	}
+}
+
+// Score returns the normalized score of an article, which is neutral if the
+// reader has no interests
+func (s *interestsScorer) Score(doc *Document) float64 {
+	if len(doc.Interests) == 0 {
+		return 0.5 // Neutral score if no interests defined
+	}
+	score, ok := s.scores[doc]
+	if !ok {
+		s.Prepare([]*Document{doc})
+		score = s.scores[doc]
+	}
+	return score
+}
+
+// stemmedDocument is an article prepared for matching interests
+type stemmedDocument struct {
+	title, text []string
+	// frequencies count the stems, occurrences in the title counting more
+	frequencies map[string]float64
+	// lengthNorm is the BM25 normalization of the length of the article
+	lengthNorm float64
+	corpus     *Corpus
+}
+
+// stemDocument stems the terms of an article and counts them
+func (s *interestsScorer) stemDocument(doc *Document, averageLength float64) *stemmedDocument {
+	d := &stemmedDocument{
+		title:       stemTerms(doc.TitleTerms),
+		text:        stemTerms(doc.Terms[len(doc.TitleTerms):]),
+		frequencies: map[string]float64{},
+		lengthNorm:  1,
+		corpus:      doc.Corpus,
+	}
+	for _, stem := range d.title {
+		d.frequencies[stem] += s.options.TitleBoost
+	}
+	for _, stem := range d.text {
+		d.frequencies[stem]++
+	}
+	if averageLength > 0 {
+		d.lengthNorm = 1 - bm25B + bm25B*float64(len(doc.Terms))/averageLength
+	}
+	if d.corpus == nil {
+		d.corpus = NewCorpus()
+		d.corpus.Add(doc.Terms)
+	}
+	return d
+}
+
+// match returns the BM25 score of an article for the stemmed terms of an
+// interest, boosted if the article contains them as a phrase
+func (s *interestsScorer) match(doc *stemmedDocument, interest []string) float64 {
+	var score float64
+	seen := map[string]bool{}
+	for _, stem := range interest {
+		if seen[stem] {
+			continue
+		}
+		seen[stem] = true
+		if tf := doc.frequencies[stem]; tf > 0 {
+			score += doc.corpus.IDF(stem) * tf * (bm25K1 + 1) / (tf + bm25K1*doc.lengthNorm)
+		}
+	}
+
+	if len(interest) > 1 && (containsPhrase(doc.title, interest) || containsPhrase(doc.text, interest)) {
+		score *= s.options.PhraseBoost
+	}
+	return score
+}
+
+// matchPattern returns the BM25 score of an article for a regular
+// expression, which counts as a single term as rare as a term found in no
+// other article
+func (s *interestsScorer) matchPattern(doc *Document, stemmed *stemmedDocument, pattern *regexp.Regexp) float64 {
+	tf := s.options.TitleBoost*float64(len(pattern.FindAllStringIndex(doc.Article.Title, -1))) +
+		float64(len(pattern.FindAllStringIndex(doc.Text, -1)))
+	if tf == 0 {
+		return 0
+	}
+	return stemmed.corpus.UnseenIDF() * tf * (bm25K1 + 1) / (tf + bm25K1*stemmed.lengthNorm)
+}
+
+// stemTerms stems each of a list of terms
+func stemTerms(terms []string) []string {
+	stems := make([]string, len(terms))
+	for i, term := range terms {
+		stems[i] = Stem(term)
+	}
+	return stems
+}
@@ -0,0 +1,255 @@
+package riffle
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testArticle is the title and text of an article scored in tests
+type testArticle struct {
+	title, text string
+}
+
+// newTestDocument prepares an article for the interests scorer
+func newTestDocument(article testArticle, interests []Interest) *Document {
+	titleTerms := Tokenize(article.title)
+	return &Document{
+		Article:    &Article{Title: article.title},
+		Text:       article.text,
+		Terms:      append(append([]string{}, titleTerms...), Tokenize(article.text)...),
+		TitleTerms: titleTerms,
+		Interests:  interests,
+	}
+}
+
+// scoreInterests scores articles together with the interests scorer
+func scoreInterests(t *testing.T, options interestsOptions, interests []Interest, articles []testArticle) []float64 {
+	t.Helper()
+	docs := make([]*Document, len(articles))
+	corpus := NewCorpus()
+	for i, article := range articles {
+		docs[i] = newTestDocument(article, interests)
+		corpus.Add(docs[i].Terms)
+	}
+	for _, doc := range docs {
+		doc.Corpus = corpus
+	}
+
+	scorer := &interestsScorer{options: options}
+	scorer.Prepare(docs)
+	scores := make([]float64, len(docs))
+	for i, doc := range docs {
+		scores[i] = scorer.Score(doc)
+	}
+	return scores
+}
+
+func TestReadInterests(t *testing.T) {
+	interests, err := ReadInterests(strings.NewReader("machine learning\n\n  rust  \n"))
+	if err != nil {
+		t.Fatalf("ReadInterests() error = %v", err)
+	}
+	if len(interests) != 2 || interests[0].Terms != "machine learning" || interests[1].Terms != "rust" {
+		t.Fatalf("ReadInterests() = %+v", interests)
+	}
+	for _, interest := range interests {
+		if interest.Weight != 1 {
+			t.Errorf("Weight of %q = %v, want 1", interest.Terms, interest.Weight)
+		}
+	}
+}
+
+func TestInterestsScorerBoosts(t *testing.T) {
+	defaults := interestsOptions{TitleBoost: 2, PhraseBoost: 1.5}
+	// A third article matching nothing makes the scores relative to 0
+	unrelated := testArticle{"Weekly notes", "the city council met on tuesday"}
+
+	tests := []struct {
+		name      string
+		options   interestsOptions
+		interests []Interest
+		a, b      testArticle
+		// higher is whether a scores higher than b, or else the same
+		higher bool
+	}{
+		{
+			name:      "title boost",
+			options:   defaults,
+			interests: []Interest{{Terms: "kubernetes", Weight: 1}},
+			a:         testArticle{"Kubernetes released", "the new version is out today"},
+			b:         testArticle{"Release notes", "the new kubernetes version is out"},
+			higher:    true,
+		},
+		{
+			name:      "no title boost",
+			options:   interestsOptions{TitleBoost: 1, PhraseBoost: 1.5},
+			interests: []Interest{{Terms: "kubernetes", Weight: 1}},
+			a:         testArticle{"Kubernetes released", "the new version is out today"},
+			b:         testArticle{"Release notes", "the new kubernetes version is out"},
+			higher:    false,
+		},
+		{
+			name:      "phrase boost",
+			options:   defaults,
+			interests: []Interest{{Terms: "machine learning", Weight: 1}},
+			a:         testArticle{"News", "advances in machine learning research this year"},
+			b:         testArticle{"News", "learning about the machine behind this research"},
+			higher:    true,
+		},
+		{
+			name:      "no phrase boost",
+			options:   interestsOptions{TitleBoost: 2, PhraseBoost: 1},
+			interests: []Interest{{Terms: "machine learning", Weight: 1}},
+			a:         testArticle{"News", "advances in machine learning research this year"},
+			b:         testArticle{"News", "learning about the machine behind this research"},
+			higher:    false,
+		},
+		{
+			name:      "stemmed terms",
+			options:   defaults,
+			interests: []Interest{{Terms: "run", Weight: 1}},
+			a:         testArticle{"News", "she runs every day and keeps running"},
+			b:         testArticle{"News", "she walks every day and keeps walking"},
+			higher:    true,
+		},
+		{
+			name:      "synonyms",
+			options:   defaults,
+			interests: []Interest{{Terms: "golang", Weight: 1, Synonyms: []string{"go language"}}},
+			a:         testArticle{"News", "the go language gets generics"},
+			b:         testArticle{"News", "the new language gets generics"},
+			higher:    true,
+		},
+		{
+			name:      "pattern",
+			options:   defaults,
+			interests: []Interest{{Terms: "releases", Weight: 1, Pattern: regexp.MustCompile(`v\d+\.\d+`)}},
+			a:         testArticle{"News", "version v1.22 is out now"},
+			b:         testArticle{"News", "a new version is out now"},
+			higher:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := scoreInterests(t, tt.options, tt.interests, []testArticle{tt.a, tt.b, unrelated})
+			if scores[2] != 0 {
+				t.Errorf("score of the unrelated article = %v, want 0", scores[2])
+			}
+			if tt.higher && scores[0] <= scores[1] {
+				t.Errorf("scores = %v, %v, want the first higher", scores[0], scores[1])
+			}
+			if !tt.higher && scores[0] != scores[1] {
+				t.Errorf("scores = %v, %v, want them equal", scores[0], scores[1])
+			}
+		})
+	}
+}
+
+func TestInterestsScorerMatchesWholeStems(t *testing.T) {
+	interests := []Interest{{Terms: "go", Weight: 1}}
+	scores := scoreInterests(t, interestsOptions{TitleBoost: 2, PhraseBoost: 1.5}, interests, []testArticle{
+		{"Google announces", "a search update was shipped a week ago"},
+		{"Go released", "the go team shipped a new version"},
+	})
+	if scores[0] != 0 || scores[1] != 1 {
+		t.Errorf("scores = %v, want [0 1]", scores)
+	}
+}
+
+func TestInterestsScorerNeutralWithoutInterests(t *testing.T) {
+	scores := scoreInterests(t, interestsOptions{TitleBoost: 2, PhraseBoost: 1.5}, nil, []testArticle{
+		{"Go released", "the go team shipped a new version"},
+	})
+	if scores[0] != 0.5 {
+		t.Errorf("score = %v, want 0.5", scores[0])
+	}
+}
+
+func TestNewInterestsScorerOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options interestsOptions
+		wantErr bool
+	}{
+		{"defaults", interestsOptions{}, false},
+		{"title boost below 1", interestsOptions{TitleBoost: 0.5, PhraseBoost: 1}, true},
+		{"phrase boost below 1", interestsOptions{TitleBoost: 1, PhraseBoost: 0.5}, true},
+		{"no boosts", interestsOptions{TitleBoost: 1, PhraseBoost: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newInterestsScorer(func(v interface{}) error {
+				if tt.options != (interestsOptions{}) {
+					*v.(*interestsOptions) = tt.options
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newInterestsScorer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInterestsScorerNormalization(t *testing.T) {
+	options := interestsOptions{TitleBoost: 2, PhraseBoost: 1.5}
+	articles := []testArticle{
+		{"Go released", "the go team shipped a new version"},
+		{"Weekly notes", "the city council met on tuesday"},
+		{"Sponsored", "buy the best sponsored gadget today"},
+	}
+
+	tests := []struct {
+		name      string
+		interests []Interest
+		// want are the scores of the positive, neutral and negative articles
+		want func(positive, neutral, negative float64) bool
+	}{
+		{
+			name:      "positive interests only",
+			interests: []Interest{{Terms: "go", Weight: 1}},
+			want: func(positive, neutral, negative float64) bool {
+				return positive == 1 && neutral == 0 && negative == 0
+			},
+		},
+		{
+			name:      "negative interests only",
+			interests: []Interest{{Terms: "sponsored", Weight: -1}},
+			want: func(positive, neutral, negative float64) bool {
+				return positive == 1 && neutral == 1 && negative == 0
+			},
+		},
+		{
+			name:      "positive and negative interests",
+			interests: []Interest{{Terms: "go", Weight: 1}, {Terms: "sponsored", Weight: -1}},
+			want: func(positive, neutral, negative float64) bool {
+				return positive == 1 && neutral > 0 && neutral < 1 && negative == 0
+			},
+		},
+		{
+			name:      "weights",
+			interests: []Interest{{Terms: "go", Weight: 0.5}, {Terms: "sponsored", Weight: -5}},
+			want: func(positive, neutral, negative float64) bool {
+				// The heavy negative interest leaves the neutral article
+				// close to the positive one
+				return positive == 1 && neutral > 0.5 && neutral < 1 && negative == 0
+			},
+		},
+		{
+			name:      "interests matching nothing",
+			interests: []Interest{{Terms: "kubernetes", Weight: 1}, {Terms: "crypto", Weight: -1}},
+			want: func(positive, neutral, negative float64) bool {
+				return positive == 0 && neutral == 0 && negative == 0
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := scoreInterests(t, options, tt.interests, articles)
+			if !tt.want(scores[0], scores[1], scores[2]) {
+				t.Errorf("scores = %v", scores)
+			}
+		})
+	}
+}
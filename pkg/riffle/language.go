@@ -0,0 +1,180 @@
+package riffle
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minLanguageLetters is the number of letters below which a text is too
+// short for its language to be detected
+const minLanguageLetters = 10
+
+// stopwords are frequent words of the languages written in the Latin
+// alphabet, which tell them apart
+var stopwords = []struct {
+	language string
+	words    map[string]bool
+}{
+	{"en", wordSet("the and of to is in that it for with as was on are this be by you not have")},
+	{"de", wordSet("der die und das ist nicht mit den von zu ein eine auf sich für dem auch es im werden")},
+	{"fr", wordSet("le la les et des est une un du que pour dans pas qui sur au avec ce sont il")},
+	{"es", wordSet("el la los las y que de en es un una por con para del se no su al como")},
+	{"it", wordSet("il di che e la un una per non sono del della con gli le si da in è anche")},
+	{"pt", wordSet("o a os as e de que do da em um uma para com não por se no na é")},
+	{"nl", wordSet("de het een en van is dat op te in niet met voor zijn er ook aan die dit worden")},
+}
+
+// scriptLanguages maps the scripts used by a single language to it
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// DetectLanguage returns the ISO 639-1 code of the language a text is
+// written in, such as "en", "zh" or "ja". Languages are told apart by their
+// script and, for the Latin alphabet, by their most frequent words. It
+// returns an empty string if the text is too short or the language is not
+// recognized.
+func DetectLanguage(text string) string {
+	// Count the letters of each script. Chinese and Japanese characters and
+	// Korean syllables stand for several Latin letters.
+	var letters, han, kana, latin int
+	counts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		switch {
+		case !unicode.IsLetter(r):
+			continue
+		case unicode.Is(unicode.Han, r):
+			han += 3
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana += 3
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for i, s := range scriptLanguages {
+				if unicode.Is(s.script, r) {
+					counts[i] += 3
+					break
+				}
+			}
+		}
+		letters++
+	}
+	if letters < minLanguageLetters {
+		return ""
+	}
+
+	// Pick the most used script
+	best, language := han+kana, ""
+	if kana > 0 && kana*10 >= han+kana {
+		language = "ja"
+	} else if han > 0 {
+		language = "zh"
+	}
+	for i, s := range scriptLanguages {
+		if counts[i] > best {
+			best, language = counts[i], s.language
+		}
+	}
+	if latin <= best {
+		return language
+	}
+
+	// Tell the languages written in the Latin alphabet apart by their
+	// stopwords
+	hits := make([]int, len(stopwords))
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		for i, s := range stopwords {
+			if s.words[word] {
+				hits[i]++
+			}
+		}
+	}
+	best, language = 1, ""
+	for i, s := range stopwords {
+		if hits[i] > best {
+			best, language = hits[i], s.language
+		}
+	}
+	return language
+}
+
+// NormalizeLanguage returns the primary language of a language tag such as
+// "zh-CN" or "en_US", in lowercase
+func NormalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// Tokenize splits a text into lowercase terms for matching. Languages
+// written with spaces are split into words; Chinese and Japanese, which are
+// written without them, are segmented into overlapping pairs of characters,
+// so that a phrase matches wherever its characters appear in that order.
+func Tokenize(text string) []string {
+	var terms []string
+	var word strings.Builder
+	var run []rune
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+			word.Reset()
+		}
+	}
+	flushRun := func() {
+		if len(run) == 1 {
+			terms = append(terms, string(run))
+		}
+		for i := 0; i+1 < len(run); i++ {
+			terms = append(terms, string(run[i:i+2]))
+		}
+		run = run[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isIdeographic(r):
+			flushWord()
+			run = append(run, r)
+		case isWordSeparator(r):
+			flushWord()
+			flushRun()
+		default:
+			flushRun()
+			word.WriteRune(unicode.ToLower(r))
+		}
+	}
+	flushWord()
+	flushRun()
+	return terms
+}
+
+// isIdeographic reports whether a rune belongs to the scripts written
+// without spaces between words
+func isIdeographic(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// isWordSeparator reports whether a rune is not part of a word
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+}
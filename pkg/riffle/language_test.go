@@ -0,0 +1,126 @@
+package riffle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "english",
+			text: "The Go team is happy to announce the release of a new version with many improvements.",
+			want: "en",
+		},
+		{
+			name: "chinese",
+			text: "谷歌发布了新版本的编程语言，带来了许多性能改进。",
+			want: "zh",
+		},
+		{
+			name: "chinese with english names",
+			text: "Google 发布了 Go 1.22，新版本带来了许多性能改进和新的标准库功能。",
+			want: "zh",
+		},
+		{
+			name: "english with a chinese name",
+			text: "The new release of the language was announced in 北京 by the team that is working on it.",
+			want: "en",
+		},
+		{
+			name: "japanese",
+			text: "新しいバージョンのプログラミング言語がリリースされました。",
+			want: "ja",
+		},
+		{
+			name: "german",
+			text: "Die neue Version der Sprache ist da und es gibt auch viele Verbesserungen für den Compiler.",
+			want: "de",
+		},
+		{
+			name: "russian",
+			text: "Вышла новая версия языка программирования с множеством улучшений.",
+			want: "ru",
+		},
+		{
+			name: "too short",
+			text: "Go 1.22",
+			want: "",
+		},
+		{
+			name: "latin without stopwords",
+			text: "Kubernetes Prometheus Grafana Terraform",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectLanguage(tt.text); got != tt.want {
+				t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"zh-CN", "zh"},
+		{"en_US", "en"},
+		{" EN ", "en"},
+		{"ja", "ja"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLanguage(tt.tag); got != tt.want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "words",
+			text: "Hello, World! Go-1.22",
+			want: []string{"hello", "world", "go", "1", "22"},
+		},
+		{
+			name: "chinese pairs",
+			text: "机器学习",
+			want: []string{"机器", "器学", "学习"},
+		},
+		{
+			name: "single character",
+			text: "新 版本",
+			want: []string{"新", "版本"},
+		},
+		{
+			name: "mixed scripts",
+			text: "Go语言发布",
+			want: []string{"go", "语言", "言发", "发布"},
+		},
+		{
+			name: "empty",
+			text: " ,. ",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
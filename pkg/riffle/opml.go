@@ -28,9 +28,9 @@ type Body struct {
 type Outline struct {
 	Title    string    `xml:"title,attr"`
 	Text     string    `xml:"text,attr"`
-	Type     string    `xml:"type,attr"`
-	XMLURL   string    `xml:"xmlUrl,attr"`
-	HTMLURL  string    `xml:"htmlUrl,attr"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
 	Outlines []Outline `xml:"outline"`
 }
 
@@ -38,6 +38,8 @@ type Outline struct {
 type Feed struct {
 	Title string
 	URL   string
+	// Folder is the title of the outline the feed is nested in, if any
+	Folder string
 }
 
 // ParseOPML parses an OPML file and returns a list of feeds
@@ -54,32 +56,77 @@ func ParseOPML(filename string) ([]Feed, error) {
 
 	var feeds []Feed
 	for _, outline := range doc.Body.Outlines {
-		feeds = append(feeds, extractFeeds(outline)...)
+		feeds = append(feeds, extractFeeds(outline, "")...)
 	}
 
 	return feeds, nil
 }
 
 // extractFeeds recursively extracts feeds from an outline and its children
-func extractFeeds(outline Outline) []Feed {
+func extractFeeds(outline Outline, folder string) []Feed {
 	var feeds []Feed
 
+	title := outline.Title
+	if title == "" {
+		title = outline.Text
+	}
+
 	// If this outline is a feed
 	if outline.XMLURL != "" {
-		title := outline.Title
-		if title == "" {
-			title = outline.Text
-		}
 		feeds = append(feeds, Feed{
-			Title: title,
-			URL:   outline.XMLURL,
+			Title:  title,
+			URL:    outline.XMLURL,
+			Folder: folder,
 		})
+	} else if title != "" {
+		// Otherwise it is a folder for its children
+		folder = title
 	}
 
 	// Process child outlines
 	for _, child := range outline.Outlines {
-		feeds = append(feeds, extractFeeds(child)...)
+		feeds = append(feeds, extractFeeds(child, folder)...)
 	}
 
 	return feeds
 }
+
+// MarshalOPML renders feeds as an OPML document, nesting feeds that have a
+// folder under an outline for that folder
+func MarshalOPML(title string, feeds []Feed) ([]byte, error) {
+	doc := OPML{
+		Version: "2.0",
+		Head:    Head{Title: title},
+	}
+
+	// Group feeds by folder, keeping the order folders first appear in
+	folders := map[string]int{}
+	for _, feed := range feeds {
+		outline := Outline{
+			Title:  feed.Title,
+			Text:   feed.Title,
+			Type:   "rss",
+			XMLURL: feed.URL,
+		}
+
+		if feed.Folder == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			continue
+		}
+
+		i, ok := folders[feed.Folder]
+		if !ok {
+			i = len(doc.Body.Outlines)
+			folders[feed.Folder] = i
+			doc.Body.Outlines = append(doc.Body.Outlines, Outline{Title: feed.Folder, Text: feed.Folder})
+		}
+		doc.Body.Outlines[i].Outlines = append(doc.Body.Outlines[i].Outlines, outline)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render OPML: %w", err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
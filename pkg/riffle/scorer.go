@@ -0,0 +1,201 @@
+package riffle
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"gopkg.in/yaml.v3"
+)
+
+// Scorer scores one aspect of an article, such as its length or how well it
+// matches the reader's interests, between 0 and 1
+type Scorer interface {
+	Score(doc *Document) float64
+}
+
+// ScorerFunc adapts a function to the Scorer interface
+type ScorerFunc func(doc *Document) float64
+
+// Score calls f(doc)
+func (f ScorerFunc) Score(doc *Document) float64 {
+	return f(doc)
+}
+
+// BatchScorer is a Scorer that compares the articles analyzed together, for
+// example to normalize its scores across them. Prepare is called with all
+// the articles before any of them is scored.
+type BatchScorer interface {
+	Scorer
+	Prepare(docs []*Document)
+}
+
+// Document is an article prepared for scoring
+type Document struct {
+	Article *Article
+	// HTML is the parsed content of the article
+	HTML *goquery.Document
+	// Text is the plain text of the content
+	Text string
+	// Terms are the terms of the title followed by those of the text, as
+	// split by Tokenize
+	Terms []string
+	// TitleTerms are the terms of the title, which start Terms
+	TitleTerms []string
+	// Language is the detected language of the article
+	Language string
+	// Interests are the reader's interests
+	Interests []Interest
+	// Corpus holds the frequencies of terms the article is compared against
+	Corpus *Corpus
+}
+
+// ScorerFactory creates a scorer from its options in the scoring
+// configuration. decode decodes the options into a struct, leaving it
+// untouched if there are none.
+type ScorerFactory func(decode func(options interface{}) error) (Scorer, error)
+
+var (
+	scorersMu       sync.RWMutex
+	scorerFactories = map[string]ScorerFactory{}
+)
+
+// RegisterScorer makes a scorer type available to the scoring
+// configuration. It panics if the type is registered twice.
+func RegisterScorer(scorerType string, factory ScorerFactory) {
+	scorersMu.Lock()
+	defer scorersMu.Unlock()
+	if _, ok := scorerFactories[scorerType]; ok {
+		panic("riffle: scorer " + scorerType + " registered twice")
+	}
+	scorerFactories[scorerType] = factory
+}
+
+// ScoringConfig configures the scorers of a ContentAnalyzer and how much
+// each counts towards the score of an article
+type ScoringConfig struct {
+	Scorers []ScorerConfig `yaml:"scorers"`
+}
+
+// ScorerConfig configures one scorer
+type ScorerConfig struct {
+	// Name identifies the scorer in the scores of articles
+	Name string `yaml:"name"`
+	// Type is the registered scorer type; it defaults to the name
+	Type string `yaml:"type,omitempty"`
+	// Weight is how much the scorer counts relative to the others
+	Weight float64 `yaml:"weight"`
+	// Options are decoded by the scorer
+	Options yaml.Node `yaml:"options,omitempty"`
+}
+
+// DefaultScoringConfig weighs how well articles match the reader's
+// interests and the quality of their content equally
+const DefaultScoringConfig = `scorers:
+  - name: interests
+    weight: 0.5
+  - name: length
+    weight: 0.2
+  - name: keywords
+    weight: 0.2
+  - name: links
+    weight: 0.1
+  - name: recency
+    weight: 0
+  - name: reputation
+    weight: 0
+`
+
+// ParseScoringConfig parses a YAML scoring configuration
+func ParseScoringConfig(data []byte) (*ScoringConfig, error) {
+	var config ScoringConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse scoring configuration: %w", err)
+	}
+	return &config, nil
+}
+
+// LoadScoringConfig reads a YAML scoring configuration from a file, or
+// returns the default configuration if path is empty
+func LoadScoringConfig(path string) (*ScoringConfig, error) {
+	if path == "" {
+		return ParseScoringConfig([]byte(DefaultScoringConfig))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScoringConfig(data)
+}
+
+// weightedScorer is a configured scorer
+type weightedScorer struct {
+	name       string
+	scorerType string
+	weight     float64
+	scorer     Scorer
+}
+
+// buildScorers creates the scorers of a configuration, leaving out the ones
+// without weight, and normalizes their weights to add up to 1
+func buildScorers(config *ScoringConfig) ([]weightedScorer, error) {
+	scorersMu.RLock()
+	defer scorersMu.RUnlock()
+
+	var scorers []weightedScorer
+	var total float64
+	names := map[string]bool{}
+	for _, c := range config.Scorers {
+		if c.Name == "" {
+			return nil, fmt.Errorf("scorer without a name")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("scorer %s configured twice", c.Name)
+		}
+		names[c.Name] = true
+		if c.Weight < 0 {
+			return nil, fmt.Errorf("scorer %s has a negative weight", c.Name)
+		}
+
+		scorerType := c.Type
+		if scorerType == "" {
+			scorerType = c.Name
+		}
+		factory, ok := scorerFactories[scorerType]
+		if !ok {
+			var types []string
+			for scorerType := range scorerFactories {
+				types = append(types, scorerType)
+			}
+			sort.Strings(types)
+			return nil, fmt.Errorf("scorer %s has unknown type %s, use one of %s", c.Name, scorerType, strings.Join(types, ", "))
+		}
+		if c.Weight == 0 {
+			continue
+		}
+
+		options := c.Options
+		scorer, err := factory(func(v interface{}) error {
+			if options.Kind == 0 {
+				return nil
+			}
+			return options.Decode(v)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid options of scorer %s: %w", c.Name, err)
+		}
+		scorers = append(scorers, weightedScorer{name: c.Name, scorerType: scorerType, weight: c.Weight, scorer: scorer})
+		total += c.Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no scorer has a weight")
+	}
+
+	for i := range scorers {
+		scorers[i].weight /= total
+	}
+	return scorers, nil
+}
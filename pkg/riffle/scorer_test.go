@@ -0,0 +1,178 @@
+package riffle
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustParseScoringConfig parses a scoring configuration, failing the test
+// on errors
+func mustParseScoringConfig(t *testing.T, data string) *ScoringConfig {
+	t.Helper()
+	config, err := ParseScoringConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseScoringConfig() error = %v", err)
+	}
+	return config
+}
+
+func TestBuildScorersInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"unknown type", "scorers:\n  - name: unknown\n    weight: 1\n", "unknown type unknown, use one of"},
+		{"unknown explicit type", "scorers:\n  - name: mine\n    type: unknown\n    weight: 1\n", "scorer mine has unknown type unknown"},
+		{"negative weight", "scorers:\n  - name: length\n    weight: -1\n", "negative weight"},
+		{"zero weights", "scorers:\n  - name: length\n    weight: 0\n  - name: links\n    weight: 0\n", "no scorer has a weight"},
+		{"no scorers", "scorers: []\n", "no scorer has a weight"},
+		{"duplicate name", "scorers:\n  - name: length\n    weight: 1\n  - name: length\n    weight: 2\n", "configured twice"},
+		{"no name", "scorers:\n  - type: length\n    weight: 1\n", "without a name"},
+		{"invalid options", "scorers:\n  - name: keywords\n    weight: 1\n    options:\n      keywords: [go]\n", "invalid options of scorer keywords"},
+	}
+	for _, tt := range tests {
+		_, err := buildScorers(mustParseScoringConfig(t, tt.config))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+		if _, err := NewContentAnalyzer("", mustParseScoringConfig(t, tt.config)); err == nil {
+			t.Errorf("%s: NewContentAnalyzer() succeeded", tt.name)
+		}
+	}
+}
+
+func TestBuildScorersWeights(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   map[string]float64
+	}{
+		{
+			"normalized",
+			"scorers:\n  - name: interests\n    weight: 3\n  - name: length\n    weight: 1\n",
+			map[string]float64{"interests": 0.75, "length": 0.25},
+		},
+		{
+			"zero weight left out",
+			"scorers:\n  - name: interests\n    weight: 2\n  - name: recency\n    weight: 0\n",
+			map[string]float64{"interests": 1},
+		},
+		{
+			"named by type",
+			"scorers:\n  - name: short\n    type: length\n    weight: 1\n  - name: long\n    type: length\n    weight: 1\n",
+			map[string]float64{"short": 0.5, "long": 0.5},
+		},
+		{
+			"default",
+			DefaultScoringConfig,
+			map[string]float64{"interests": 0.5, "length": 0.2, "keywords": 0.2, "links": 0.1},
+		},
+	}
+	for _, tt := range tests {
+		scorers, err := buildScorers(mustParseScoringConfig(t, tt.config))
+		if err != nil {
+			t.Fatalf("%s: buildScorers() error = %v", tt.name, err)
+		}
+		got := map[string]float64{}
+		for _, s := range scorers {
+			got[s.name] = s.weight
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: weights = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for name, weight := range tt.want {
+			if math.Abs(got[name]-weight) > 1e-9 {
+				t.Errorf("%s: weight of %s = %v, want %v", tt.name, name, got[name], weight)
+			}
+		}
+	}
+}
+
+func TestRegisterScorerTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterScorer() of a registered type did not panic")
+		}
+	}()
+	RegisterScorer("length", newLengthScorer)
+}
+
+func TestKeywordsScorerOptions(t *testing.T) {
+	doc := func(text, language string) *Document {
+		return &Document{Terms: Tokenize(text), Language: language}
+	}
+	tests := []struct {
+		name    string
+		options string
+		doc     *Document
+		want    float64
+	}{
+		{"default keywords", "", doc("a tutorial and a guide", "en"), 2.0 / float64(len(defaultValueKeywords["en"]))},
+		{"configured keywords", "keywords:\n  en: [golang, generics]\n", doc("golang generics", "en"), 1},
+		{"configured keywords replace the defaults", "keywords:\n  en: [golang, generics]\n", doc("a tutorial and a guide", "en"), 0},
+		{"phrases", "keywords:\n  en: [best practices, golang]\n", doc("best go practices", "en"), 0},
+		{"language without keywords", "keywords:\n  en: [golang, generics]\n", doc("golang", "fr"), 0.5},
+		{"default language", "keywords:\n  en: [golang]\n  de: [rust]\ndefaultLanguage: de\n", doc("rust", "fr"), 1},
+		{"no keywords of the default language", "keywords:\n  de: [rust]\n", doc("rust", "fr"), 0},
+	}
+	for _, tt := range tests {
+		config := "scorers:\n  - name: keywords\n    weight: 1\n"
+		if tt.options != "" {
+			config += "    options:\n      " + strings.ReplaceAll(strings.TrimSuffix(tt.options, "\n"), "\n", "\n      ") + "\n"
+		}
+		scorers, err := buildScorers(mustParseScoringConfig(t, config))
+		if err != nil {
+			t.Fatalf("%s: buildScorers() error = %v", tt.name, err)
+		}
+		if got := scorers[0].scorer.Score(tt.doc); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Score() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestComponentsAddUpToScore(t *testing.T) {
+	analyzer, err := NewContentAnalyzer("", mustParseScoringConfig(t, `scorers:
+  - name: interests
+    weight: 2
+  - name: length
+    weight: 1
+  - name: keywords
+    weight: 1
+  - name: links
+    weight: 1
+  - name: recency
+    weight: 1
+`))
+	if err != nil {
+		t.Fatalf("NewContentAnalyzer() error = %v", err)
+	}
+	analyzer.SetInterests([]Interest{{Terms: "golang", Weight: 1}})
+
+	scores, err := analyzer.AnalyzeArticles([]*Article{
+		{Title: "Golang tutorial", Summary: `<p>A guide to <a href="https://go.dev">golang</a>.</p>`, PublishedAt: time.Now()},
+		{Title: "Rust news", Summary: "<p>Nothing else.</p>", PublishedAt: time.Now().Add(-48 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeArticles() error = %v", err)
+	}
+	for _, score := range scores {
+		if len(score.Components) != 5 {
+			t.Errorf("%q has %d components, want 5", score.Article.Title, len(score.Components))
+		}
+		var sum, weights float64
+		for _, component := range score.Components {
+			sum += component.Weight * component.Score
+			weights += component.Weight
+		}
+		if math.Abs(sum-score.Score) > 1e-9 || math.Abs(weights-1) > 1e-9 {
+			t.Errorf("%q components add up to %v with weights %v, want %v with weights 1", score.Article.Title, sum, weights, score.Score)
+		}
+	}
+	if scores[0].Score <= scores[1].Score {
+		t.Errorf("matching article scored %v, not above %v", scores[0].Score, scores[1].Score)
+	}
+}
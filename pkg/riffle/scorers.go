@@ -0,0 +1,219 @@
+package riffle
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func init() {
+	RegisterScorer("length", newLengthScorer)
+	RegisterScorer("keywords", newKeywordsScorer)
+	RegisterScorer("links", newLinksScorer)
+	RegisterScorer("interests", newInterestsScorer)
+	RegisterScorer("recency", newRecencyScorer)
+	RegisterScorer("reputation", newReputationScorer)
+}
+
+// defaultValueKeywords are the keywords that indicate valuable content, by
+// language
+var defaultValueKeywords = map[string][]string{
+	"en": {
+		"research", "study", "analysis", "guide", "tutorial",
+		"introduction", "review", "comparison", "best practices",
+		"how to", "explained", "deep dive", "architecture",
+		"performance", "security", "scalability",
+	},
+	"zh": {
+		"研究", "分析", "指南", "教程", "入门", "评测", "对比",
+		"最佳实践", "详解", "深入", "架构", "性能", "安全", "可扩展",
+	},
+	"ja": {
+		"研究", "分析", "ガイド", "チュートリアル", "入門", "レビュー",
+		"比較", "ベストプラクティス", "解説", "詳解", "アーキテクチャ",
+		"パフォーマンス", "セキュリティ", "スケーラビリティ",
+	},
+}
+
+// newLengthScorer creates a scorer that rates longer articles higher
+func newLengthScorer(decode func(interface{}) error) (Scorer, error) {
+	return ScorerFunc(func(doc *Document) float64 {
+		switch textLen := utf8.RuneCountInString(doc.Text); {
+		case textLen > 2000:
+			return 1.0
+		case textLen > 1000:
+			return 0.8
+		case textLen > 500:
+			return 0.6
+		case textLen > 200:
+			return 0.4
+		default:
+			return 0.2
+		}
+	}), nil
+}
+
+// keywordsOptions are the options of the keywords scorer
+type keywordsOptions struct {
+	// Keywords are the keywords that indicate valuable content, by
+	// language. They replace the default keywords of all languages.
+	Keywords map[string][]string `yaml:"keywords"`
+	// DefaultLanguage is the language whose keywords are used for articles
+	// in languages without keywords
+	DefaultLanguage string `yaml:"defaultLanguage"`
+}
+
+// newKeywordsScorer creates a scorer that rates articles by how many
+// keywords of their language they contain
+func newKeywordsScorer(decode func(interface{}) error) (Scorer, error) {
+	// Configured keywords replace the default ones
+	options := keywordsOptions{DefaultLanguage: "en"}
+	if err := decode(&options); err != nil {
+		return nil, err
+	}
+	if options.Keywords == nil {
+		options.Keywords = defaultValueKeywords
+	}
+
+	// Split the keywords into terms once
+	keywords := map[string][][]string{}
+	for language, list := range options.Keywords {
+		for _, keyword := range list {
+			if terms := Tokenize(keyword); len(terms) > 0 {
+				keywords[language] = append(keywords[language], terms)
+			}
+		}
+	}
+
+	return ScorerFunc(func(doc *Document) float64 {
+		list, ok := keywords[doc.Language]
+		if !ok {
+			list = keywords[options.DefaultLanguage]
+		}
+		if len(list) == 0 {
+			return 0
+		}
+		var keywordCount int
+		for _, keyword := range list {
+			if containsPhrase(doc.Terms, keyword) {
+				keywordCount++
+			}
+		}
+		return float64(keywordCount) / float64(len(list))
+	}), nil
+}
+
+// newLinksScorer creates a scorer that rates articles by the share of their
+// links that point to other sites
+func newLinksScorer(decode func(interface{}) error) (Scorer, error) {
+	return ScorerFunc(func(doc *Document) float64 {
+		links := doc.HTML.Find("a")
+		linkCount := links.Length()
+		if linkCount == 0 {
+			return 0.5 // Neutral score for no links
+		}
+
+		// Count external links (usually more valuable)
+		var externalLinks int
+		links.Each(func(_ int, link *goquery.Selection) {
+			href, exists := link.Attr("href")
+			if exists && strings.HasPrefix(href, "http") {
+				externalLinks++
+			}
+		})
+		return float64(externalLinks) / float64(linkCount)
+	}), nil
+}
+
+// recencyOptions are the options of the recency scorer
+type recencyOptions struct {
+	// HalfLife is the age at which an article scores 0.5
+	HalfLife time.Duration `yaml:"halfLife"`
+}
+
+// newRecencyScorer creates a scorer that rates newer articles higher, from
+// 1 for articles published now, halving every half-life
+func newRecencyScorer(decode func(interface{}) error) (Scorer, error) {
+	options := recencyOptions{HalfLife: 24 * time.Hour}
+	if err := decode(&options); err != nil {
+		return nil, err
+	}
+	if options.HalfLife <= 0 {
+		return nil, fmt.Errorf("halfLife must be positive")
+	}
+
+	return ScorerFunc(func(doc *Document) float64 {
+		age := time.Since(doc.Article.PublishedAt)
+		if age < 0 {
+			return 1
+		}
+		return math.Pow(0.5, float64(age)/float64(options.HalfLife))
+	}), nil
+}
+
+// reputationOptions are the options of the reputation scorer
+type reputationOptions struct {
+	// Sources maps feed URLs or the hosts of feeds or articles to their
+	// reputation between 0 and 1
+	Sources map[string]float64 `yaml:"sources"`
+	// Default is the reputation of the other sources
+	Default float64 `yaml:"default"`
+}
+
+// newReputationScorer creates a scorer that rates articles by the
+// reputation of their source
+func newReputationScorer(decode func(interface{}) error) (Scorer, error) {
+	options := reputationOptions{Default: 0.5}
+	if err := decode(&options); err != nil {
+		return nil, err
+	}
+	for source, reputation := range options.Sources {
+		if reputation < 0 || reputation > 1 {
+			return nil, fmt.Errorf("reputation of %s must be between 0 and 1", source)
+		}
+	}
+
+	return ScorerFunc(func(doc *Document) float64 {
+		for _, key := range []string{doc.Article.Source, hostOf(doc.Article.Source), hostOf(doc.Article.URL)} {
+			if reputation, ok := options.Sources[key]; ok && key != "" {
+				return reputation
+			}
+		}
+		return options.Default
+	}), nil
+}
+
+// hostOf returns the lowercase host of a URL, without a leading www.
+func hostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// containsPhrase reports whether the terms of a phrase appear in order
+// among the terms of a text
+func containsPhrase(terms, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(terms); i++ {
+		match := true
+		for j, term := range phrase {
+			if terms[i+j] != term {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package riffle
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+)
+
+// shingleSize is the number of consecutive words hashed together
+const shingleSize = 3
+
+// minSimHashWords is the number of words below which a text is too short
+// for its signature to say anything
+const minSimHashWords = 8
+
+// MaxNearDuplicateDistance is the number of bits in which the SimHash
+// signatures of two texts may differ for them to be near-duplicates. Feed
+// items are short, so rewording a few words moves several bits, while
+// unrelated texts differ in about half of the 64 bits.
+const MaxNearDuplicateDistance = 10
+
+// SimHash computes the 64-bit SimHash signature of a text from shingles of
+// its terms, as split by Tokenize. Near-identical texts have signatures that
+// differ in few bits. It returns 0 for texts too short to compare.
+func SimHash(text string) uint64 {
+	words := Tokenize(text)
+	if len(words) < minSimHashWords {
+		return 0
+	}
+
+	// Add up the bits of the hash of every shingle
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	// Set the bits most shingles set
+	var signature uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			signature |= 1 << bit
+		}
+	}
+	return signature
+}
+
+// SimHashDistance returns the number of bits in which two SimHash signatures
+// differ
+func SimHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,84 @@
+package riffle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimHashNearDuplicates(t *testing.T) {
+	const story = "Go 1.22 released with range over integers and improved routing patterns in net/http. " +
+		"The release also brings a new math/rand/v2 package, faster builds and smaller binaries, " +
+		"and the loop variable of for loops is now created anew on each iteration."
+
+	tests := []struct {
+		name      string
+		a, b      string
+		duplicate bool
+	}{
+		{
+			name:      "identical",
+			a:         story,
+			b:         story,
+			duplicate: true,
+		},
+		{
+			name:      "case and punctuation",
+			a:         story,
+			b:         strings.ToUpper(strings.ReplaceAll(story, ".", "!")),
+			duplicate: true,
+		},
+		{
+			name: "reworded by another source",
+			a:    story,
+			b: "Go 1.22 released with range over integers and improved routing patterns in net/http. " +
+				"The release also brings a new math/rand/v2 package, faster builds and smaller binaries, " +
+				"and the loop variable of for loops is now created fresh for each iteration.",
+			duplicate: true,
+		},
+		{
+			name: "unrelated",
+			a:    story,
+			b: "The city council approved the new budget on Tuesday after a long debate about funding " +
+				"for public transport, schools and the renovation of the central library.",
+			duplicate: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := SimHash(tt.a), SimHash(tt.b)
+			if a == 0 || b == 0 {
+				t.Fatalf("SimHash() = %x, %x, want non-zero signatures", a, b)
+			}
+			distance := SimHashDistance(a, b)
+			if got := distance <= MaxNearDuplicateDistance; got != tt.duplicate {
+				t.Errorf("SimHashDistance() = %d, near-duplicate = %v, want %v", distance, got, tt.duplicate)
+			}
+		})
+	}
+}
+
+func TestSimHashShortText(t *testing.T) {
+	tests := []string{"", "Breaking news", "only seven words in this short title"}
+	for _, text := range tests {
+		if got := SimHash(text); got != 0 {
+			t.Errorf("SimHash(%q) = %x, want 0", text, got)
+		}
+	}
+}
+
+func TestSimHashDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0b1011, 0b1011, 0},
+		{0b1011, 0b0010, 2},
+		{0, ^uint64(0), 64},
+	}
+	for _, tt := range tests {
+		if got := SimHashDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("SimHashDistance(%b, %b) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package riffle
+
+import "strings"
+
+// Stem reduces an English word to its stem with the Porter algorithm, so
+// that "running" and "runs" both become "run". Terms that are not lowercase
+// ASCII words, such as Chinese terms, are returned unchanged.
+func Stem(term string) string {
+	if len(term) <= 2 {
+		return term
+	}
+	for i := 0; i < len(term); i++ {
+		if term[i] < 'a' || term[i] > 'z' {
+			return term
+		}
+	}
+
+	w := []byte(term)
+	w = stemStep1a(w)
+	w = stemStep1b(w)
+	w = stemStep1c(w)
+	w = stemStep2(w)
+	w = stemStep3(w)
+	w = stemStep4(w)
+	w = stemStep5(w)
+	return string(w)
+}
+
+// isConsonant reports whether the letter at i is a consonant in the sense of
+// the Porter algorithm, where y after a consonant is a vowel
+func isConsonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure returns the number of vowel-consonant sequences in a stem
+func measure(w []byte) int {
+	m, i := 0, 0
+	for i < len(w) && isConsonant(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !isConsonant(w, i) {
+			i++
+		}
+		if i == len(w) {
+			break
+		}
+		for i < len(w) && isConsonant(w, i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+// hasVowel reports whether a stem contains a vowel
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsWithDoubleConsonant reports whether a stem ends with two equal
+// consonants
+func endsWithDoubleConsonant(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsWithCVC reports whether a stem ends with consonant, vowel, consonant,
+// the last not being w, x or y
+func endsWithCVC(w []byte) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-1) || isConsonant(w, n-2) || !isConsonant(w, n-3) {
+		return false
+	}
+	return w[n-1] != 'w' && w[n-1] != 'x' && w[n-1] != 'y'
+}
+
+// replaceSuffix replaces suffix with replacement if the word ends with it
+// and the remaining stem has a measure above minMeasure. It reports whether
+// the word ends with suffix, whether or not it was replaced.
+func replaceSuffix(w *[]byte, suffix, replacement string, minMeasure int) bool {
+	if !strings.HasSuffix(string(*w), suffix) {
+		return false
+	}
+	stem := (*w)[:len(*w)-len(suffix)]
+	if measure(stem) > minMeasure {
+		*w = append(stem[:len(stem):len(stem)], replacement...)
+	}
+	return true
+}
+
+// stemStep1a removes plurals
+func stemStep1a(w []byte) []byte {
+	s := string(w)
+	switch {
+	case strings.HasSuffix(s, "sses"), strings.HasSuffix(s, "ies"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(s, "ss"):
+		return w
+	case strings.HasSuffix(s, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// stemStep1b removes -ed and -ing
+func stemStep1b(w []byte) []byte {
+	s := string(w)
+	if strings.HasSuffix(s, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+
+	var stem []byte
+	switch {
+	case strings.HasSuffix(s, "ed") && hasVowel(w[:len(w)-2]):
+		stem = w[:len(w)-2]
+	case strings.HasSuffix(s, "ing") && hasVowel(w[:len(w)-3]):
+		stem = w[:len(w)-3]
+	default:
+		return w
+	}
+
+	t := string(stem)
+	switch {
+	case strings.HasSuffix(t, "at"), strings.HasSuffix(t, "bl"), strings.HasSuffix(t, "iz"):
+		return append(stem[:len(stem):len(stem)], 'e')
+	case endsWithDoubleConsonant(stem) && !strings.HasSuffix(t, "l") && !strings.HasSuffix(t, "s") && !strings.HasSuffix(t, "z"):
+		return stem[:len(stem)-1]
+	case measure(stem) == 1 && endsWithCVC(stem):
+		return append(stem[:len(stem):len(stem)], 'e')
+	}
+	return stem
+}
+
+// stemStep1c turns a final y into i after a vowel
+func stemStep1c(w []byte) []byte {
+	if w[len(w)-1] == 'y' && hasVowel(w[:len(w)-1]) {
+		w = append(w[:len(w)-1:len(w)-1], 'i')
+	}
+	return w
+}
+
+// step2Suffixes map double suffixes to single ones
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"abli", "able"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+}
+
+func stemStep2(w []byte) []byte {
+	for _, s := range step2Suffixes {
+		if replaceSuffix(&w, s[0], s[1], 0) {
+			break
+		}
+	}
+	return w
+}
+
+// step3Suffixes map suffixes such as -ful and -ness to shorter ones
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func stemStep3(w []byte) []byte {
+	for _, s := range step3Suffixes {
+		if replaceSuffix(&w, s[0], s[1], 0) {
+			break
+		}
+	}
+	return w
+}
+
+// step4Suffixes are removed from stems of measure above 1
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func stemStep4(w []byte) []byte {
+	s := string(w)
+	// The longest matching suffix is removed
+	best := ""
+	for _, suffix := range step4Suffixes {
+		if strings.HasSuffix(s, suffix) && len(suffix) > len(best) {
+			best = suffix
+		}
+	}
+	if best == "" {
+		return w
+	}
+	stem := w[:len(w)-len(best)]
+	if measure(stem) <= 1 {
+		return w
+	}
+	if best == "ion" && len(stem) > 0 && stem[len(stem)-1] != 's' && stem[len(stem)-1] != 't' {
+		return w
+	}
+	return stem
+}
+
+// stemStep5 removes a final e and a double l
+func stemStep5(w []byte) []byte {
+	if w[len(w)-1] == 'e' {
+		stem := w[:len(w)-1]
+		if m := measure(stem); m > 1 || (m == 1 && !endsWithCVC(stem)) {
+			w = stem
+		}
+	}
+	if measure(w) > 1 && endsWithDoubleConsonant(w) && w[len(w)-1] == 'l' {
+		w = w[:len(w)-1]
+	}
+	return w
+}
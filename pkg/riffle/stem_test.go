@@ -0,0 +1,52 @@
+package riffle
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"cats", "cat"},
+		{"running", "run"},
+		{"runs", "run"},
+		{"agreed", "agre"},
+		{"happy", "happi"},
+		{"relational", "relat"},
+		{"generalization", "gener"},
+		{"hopeful", "hope"},
+		{"electricity", "electr"},
+		{"adjustment", "adjust"},
+		{"learning", "learn"},
+		{"google", "googl"},
+		{"go", "go"},
+		{"is", "is"},
+		// Terms that are not lowercase ASCII words are not stemmed
+		{"Running", "Running"},
+		{"go1", "go1"},
+		{"学习", "学习"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Stem(tt.term); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestStemKeepsWordsApart(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"google", "go"},
+		{"ago", "go"},
+		{"going", "google"},
+	}
+	for _, tt := range tests {
+		if Stem(tt.a) == Stem(tt.b) {
+			t.Errorf("Stem(%q) = Stem(%q) = %q", tt.a, tt.b, Stem(tt.a))
+		}
+	}
+}
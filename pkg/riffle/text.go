@@ -0,0 +1,218 @@
+package riffle
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockElements start a new paragraph when rendering HTML as text
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"tr": true, "ul": true,
+}
+
+// textRenderer renders HTML as plain text
+type textRenderer struct {
+	b     strings.Builder
+	links []string
+	pre   int
+}
+
+// HTMLToText renders article HTML as readable text wrapped at width
+// columns, listing the links at the end
+func HTMLToText(s string, width int) string {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+
+	r := &textRenderer{}
+	r.render(doc)
+
+	// Wrap the paragraphs, leaving preformatted text alone
+	var paragraphs []string
+	for _, paragraph := range strings.Split(r.b.String(), "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		var lines []string
+		for _, line := range strings.Split(paragraph, "\n") {
+			if strings.HasPrefix(line, "    ") {
+				lines = append(lines, line)
+				continue
+			}
+			lines = append(lines, wrap(line, width)...)
+		}
+		paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+	}
+
+	// List the links as references
+	if len(r.links) > 0 {
+		var refs []string
+		for i, link := range r.links {
+			refs = append(refs, fmt.Sprintf("[%d] %s", i+1, link))
+		}
+		paragraphs = append(paragraphs, strings.Join(refs, "\n"))
+	}
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// PlainText returns the words of article HTML on a single line, without
+// markup or link references
+func PlainText(s string) string {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+
+	r := &textRenderer{}
+	r.render(doc)
+	return strings.Join(strings.Fields(r.b.String()), " ")
+}
+
+// render appends the text of a node and its children
+func (r *textRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "head", "noscript":
+			return
+		case "br":
+			r.b.WriteString("\n")
+			return
+		case "img":
+			if alt := attr(n, "alt"); alt != "" {
+				r.text("[image: " + alt + "]")
+			}
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.Data]
+	if block {
+		r.paragraph()
+	}
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "li":
+			r.b.WriteString("• ")
+		case "pre":
+			r.pre++
+			defer func() { r.pre-- }()
+		case "hr":
+			r.b.WriteString("────────")
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		r.render(child)
+	}
+
+	if n.Type == html.ElementNode && n.Data == "a" {
+		if href := attr(n, "href"); strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") {
+			r.links = append(r.links, href)
+			fmt.Fprintf(&r.b, " [%d]", len(r.links))
+		}
+	}
+	if block {
+		r.paragraph()
+	}
+}
+
+// text appends text, collapsing whitespace outside of preformatted text
+func (r *textRenderer) text(s string) {
+	if r.pre > 0 {
+		for i, line := range strings.Split(strings.Trim(s, "\n"), "\n") {
+			if i > 0 {
+				r.b.WriteString("\n")
+			}
+			r.b.WriteString("    " + line)
+		}
+		return
+	}
+
+	collapsed := strings.Join(strings.Fields(s), " ")
+	if collapsed == "" {
+		if s != "" && !r.atBreak() && !strings.HasSuffix(r.b.String(), " ") {
+			r.b.WriteString(" ")
+		}
+		return
+	}
+	if len(s) > 0 && isSpace(s[0]) && !r.atBreak() && !strings.HasSuffix(r.b.String(), " ") {
+		r.b.WriteString(" ")
+	}
+	r.b.WriteString(collapsed)
+	if isSpace(s[len(s)-1]) {
+		r.b.WriteString(" ")
+	}
+}
+
+// paragraph starts a new paragraph unless one was just started
+func (r *textRenderer) paragraph() {
+	current := strings.TrimRight(r.b.String(), " ")
+	r.b.Reset()
+	r.b.WriteString(current)
+	if current != "" && !strings.HasSuffix(current, "\n\n") {
+		if strings.HasSuffix(current, "\n") {
+			r.b.WriteString("\n")
+		} else {
+			r.b.WriteString("\n\n")
+		}
+	}
+}
+
+// atBreak reports whether the output is at the start of a line
+func (r *textRenderer) atBreak() bool {
+	s := r.b.String()
+	return s == "" || strings.HasSuffix(s, "\n") || strings.HasSuffix(s, "• ")
+}
+
+// attr returns the value of an attribute of a node
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// isSpace reports whether b is ASCII whitespace
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// wrap breaks a line into lines of at most width runes at spaces
+func wrap(line string, width int) []string {
+	if width <= 0 {
+		return []string{line}
+	}
+
+	var lines []string
+	var current []rune
+	for _, word := range strings.Fields(line) {
+		w := []rune(word)
+		if len(current) > 0 && len(current)+1+len(w) > width {
+			lines = append(lines, string(current))
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, ' ')
+		}
+		current = append(current, w...)
+	}
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/api/middleware"
+	"github.com/flyer103/riffle/pkg/serving/oidc"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthConfig configures how users sign up and log in
+type AuthConfig struct {
+	// AllowSignup allows anyone to create an account through the API
+	AllowSignup bool
+	// SessionTTL is how long a login session lasts
+	SessionTTL time.Duration
+	// OIDC signs users in through an identity provider when it is set
+	OIDC *oidc.Provider
+	// OIDCPostLoginURL is where the browser is sent after signing in
+	// through the identity provider, unless the login asked for another
+	// path on this server
+	OIDCPostLoginURL string
+}
+
+// AuthHandler handles API requests for signing up, logging in and out
+type AuthHandler struct {
+	db     *storage.SQLiteDB
+	config AuthConfig
+}
+
+// LoginInput represents the input for logging in
+type LoginInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// NewAuthHandler creates a new AuthHandler
+func NewAuthHandler(db *storage.SQLiteDB, config AuthConfig) *AuthHandler {
+	return &AuthHandler{
+		db:     db,
+		config: config,
+	}
+}
+
+// currentUser returns the authenticated user of a request
+func currentUser(c *gin.Context) *storage.User {
+	if principal := middleware.GetPrincipal(c); principal != nil {
+		return principal.User
+	}
+	return nil
+}
+
+// GetAuthMethods handles GET /auth/methods
+func (h *AuthHandler) GetAuthMethods(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"password": true,
+		"signup":   h.config.AllowSignup,
+		"oidc":     h.config.OIDC != nil,
+	})
+}
+
+// Signup handles POST /auth/signup
+func (h *AuthHandler) Signup(c *gin.Context) {
+	// Check that signing up is allowed
+	if !h.config.AllowSignup {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Signing up is disabled",
+		})
+		return
+	}
+
+	// Parse the request body
+	var input storage.CreateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate required fields
+	if input.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "username is required",
+		})
+		return
+	}
+
+	// Create the user
+	user, err := h.db.CreateUser(input)
+	if errors.Is(err, storage.ErrInvalidPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if errors.Is(err, storage.ErrUsernameTaken) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create user: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created user
+	c.JSON(http.StatusCreated, user)
+}
+
+// Login handles POST /auth/login
+func (h *AuthHandler) Login(c *gin.Context) {
+	// Parse the request body
+	var input LoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Check the credentials
+	user, err := h.db.AuthenticateUser(input.Username, input.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log in: " + err.Error(),
+		})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid username or password",
+		})
+		return
+	}
+
+	// Start a session
+	session, secret, err := h.db.CreateSession(user.ID, h.config.SessionTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log in: " + err.Error(),
+		})
+		return
+	}
+	setSessionCookie(c, secret, int(h.config.SessionTTL.Seconds()))
+
+	// Return the user and when the session expires
+	c.JSON(http.StatusOK, gin.H{
+		"user":      user,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// Logout handles POST /auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	// End the session if there is one
+	if secret, err := c.Cookie(middleware.SessionCookieName); err == nil && secret != "" {
+		if err := h.db.DeleteSession(secret); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to log out: " + err.Error(),
+			})
+			return
+		}
+	}
+	setSessionCookie(c, "", -1)
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out",
+	})
+}
+
+// setSessionCookie sets or, with a negative maxAge, clears the session cookie
+func setSessionCookie(c *gin.Context, value string, maxAge int) {
+	setCookie(c, middleware.SessionCookieName, value, maxAge, "/")
+}
+
+// setCookie sets an HTTP-only cookie that is only sent over HTTPS when the
+// request came in over HTTPS
+func setCookie(c *gin.Context, name, value string, maxAge int, path string) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", secure, true)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// respondBatchError writes the error response for a failed batch operation
+func respondBatchError(c *gin.Context, action string, err error) {
+	// Oversized batches are a client error
+	if errors.Is(err, storage.ErrBatchTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Failed to " + action + ": " + err.Error(),
+	})
+}
+
+// batchStatus returns the HTTP status for a batch result, signalling with
+// 422 that an atomic batch was rolled back and nothing was applied
+func batchStatus(rolledBack bool) int {
+	if rolledBack {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+func TestBatchCreateSourcesStatus(t *testing.T) {
+	db := newTestDB(t)
+	editor := newTestUser(t, db, "editor", storage.RoleEditor)
+	router := newTestRouter(editor)
+	router.POST("/sources/batch", NewSourcesHandler(db).BatchCreateSources)
+
+	existing := newTestContent(t, db, "https://example.com/existing.xml")
+	sources := []storage.CreateSourceInput{
+		{Name: "New", URL: "https://example.com/new.xml"},
+		{Name: "Duplicate", URL: "https://example.com/existing.xml"},
+	}
+
+	// A rolled back atomic batch is unprocessable
+	w := serveJSON(t, router, http.MethodPost, "/sources/batch", storage.BatchCreateSourcesInput{Sources: sources, Atomic: true})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("atomic batch: status = %d, want 422", w.Code)
+	}
+	var result storage.BatchCreateSourcesResult
+	decodeJSON(t, w, &result)
+	if !result.RolledBack || len(result.Sources) != 0 || len(result.Errors) != 1 {
+		t.Errorf("atomic batch result = %+v, want a rollback with one error", result)
+	}
+
+	// A partly failed batch succeeds with the errors of the failed items
+	w = serveJSON(t, router, http.MethodPost, "/sources/batch", storage.BatchCreateSourcesInput{Sources: sources})
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch: status = %d, want 200", w.Code)
+	}
+	result = storage.BatchCreateSourcesResult{}
+	decodeJSON(t, w, &result)
+	if len(result.Sources) != 1 || len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+		t.Errorf("batch result = %+v, want one source and an error for item 1", result)
+	}
+
+	// Oversized batches are rejected
+	w = serveJSON(t, router, http.MethodPost, "/sources/batch", storage.BatchCreateSourcesInput{Sources: make([]storage.CreateSourceInput, storage.MaxBatchSize+1)})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("oversized batch: status = %d, want 400", w.Code)
+	}
+	if source, _ := db.GetSource(existing.SourceID); source == nil {
+		t.Error("the existing source is gone")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/flyer103/riffle/pkg/serving/events"
 	"github.com/flyer103/riffle/pkg/serving/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/mmcdole/gofeed"
@@ -16,28 +18,93 @@ import (
 
 // ContentsHandler handles API requests for RSS contents
 type ContentsHandler struct {
-	db *storage.SQLiteDB
+	db            *storage.SQLiteDB
+	bus           *events.Bus
+	canonicalizer *riffle.Canonicalizer
 }
 
-// NewContentsHandler creates a new ContentsHandler
-func NewContentsHandler(db *storage.SQLiteDB) *ContentsHandler {
+// NewContentsHandler creates a new ContentsHandler. The fetch pipeline
+// publishes what happens to bus and identifies articles by the links
+// canonicalizer makes of theirs.
+func NewContentsHandler(db *storage.SQLiteDB, bus *events.Bus, canonicalizer *riffle.Canonicalizer) *ContentsHandler {
 	return &ContentsHandler{
-		db: db,
+		db:            db,
+		bus:           bus,
+		canonicalizer: canonicalizer,
 	}
 }
 
 // ListContents handles GET /contents
 func (h *ContentsHandler) ListContents(c *gin.Context) {
 	// Parse query parameters
-	sourceID := c.Query("sourceId")
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
-	nextToken := c.Query("nextToken")
+	input := storage.ListContentsInput{
+		UserID:    currentUser(c).ID,
+		Folder:    c.Query("folder"),
+		Tags:      c.QueryArray("tag"),
+		SourceID:  c.Query("sourceId"),
+		Language:  riffle.NormalizeLanguage(c.Query("language")),
+		NextToken: c.Query("nextToken"),
+	}
+	input.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	// Parse reading state filters if provided
+	for name, filter := range map[string]**bool{
+		"read":      &input.Read,
+		"starred":   &input.Starred,
+		"readLater": &input.ReadLater,
+	} {
+		if value, ok := c.GetQuery(name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Invalid " + name + " filter. Use true or false",
+				})
+				return
+			}
+			*filter = &parsed
+		}
+	}
+
+	// Parse the hidden filter if provided
+	if value, ok := c.GetQuery("hidden"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid hidden filter. Use true or false",
+			})
+			return
+		}
+		input.Hidden = parsed
+	}
+
+	// Parse whether near-duplicates are collapsed into one item per story
+	if value, ok := c.GetQuery("collapse"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid collapse parameter. Use true or false",
+			})
+			return
+		}
+		input.Collapse = parsed
+	}
+
+	// Parse the priority filter if provided
+	if value, ok := c.GetQuery("minPriority"); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid minPriority filter. Use a whole number",
+			})
+			return
+		}
+		input.MinPriority = &parsed
+	}
 
 	// Parse date filters if provided
-	var startDate, endDate time.Time
 	if startDateStr := c.Query("startDate"); startDateStr != "" {
 		if parsed, err := time.Parse(time.RFC3339, startDateStr); err == nil {
-			startDate = parsed
+			input.StartDate = parsed
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "Invalid startDate format. Use RFC3339 format (e.g., 2023-01-01T00:00:00Z)",
@@ -47,7 +114,7 @@ func (h *ContentsHandler) ListContents(c *gin.Context) {
 	}
 	if endDateStr := c.Query("endDate"); endDateStr != "" {
 		if parsed, err := time.Parse(time.RFC3339, endDateStr); err == nil {
-			endDate = parsed
+			input.EndDate = parsed
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "Invalid endDate format. Use RFC3339 format (e.g., 2023-01-01T00:00:00Z)",
@@ -57,7 +124,7 @@ func (h *ContentsHandler) ListContents(c *gin.Context) {
 	}
 
 	// Get contents from the database
-	contents, newNextToken, err := h.db.ListContents(sourceID, startDate, endDate, limit, nextToken)
+	contents, newNextToken, err := h.db.ListContents(input)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to list contents: " + err.Error(),
@@ -78,18 +145,26 @@ func (h *ContentsHandler) GetContent(c *gin.Context) {
 	id := c.Param("id")
 
 	// Get the content from the database
-	content, err := h.db.GetContent(id)
+	content, ok := getSubscribedContent(c, h.db, id)
+	if !ok {
+		return
+	}
+
+	// Add the user's reading state
+	var err error
+	content.State, err = h.db.GetContentState(currentUser(c).ID, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get content: " + err.Error(),
+			"error": "Failed to get content state: " + err.Error(),
 		})
 		return
 	}
 
-	// Check if the content exists
-	if content == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Content not found",
+	// Add the user's tags
+	content.Tags, err = h.db.GetContentTags(currentUser(c).ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get content tags: " + err.Error(),
 		})
 		return
 	}
@@ -129,6 +204,8 @@ func (h *ContentsHandler) UpdateContent(c *gin.Context) {
 		return
 	}
 
+	h.bus.Publish(events.ContentUpdated, content)
+
 	// Return the updated content
 	c.JSON(http.StatusOK, content)
 }
@@ -149,10 +226,32 @@ func (h *ContentsHandler) DeleteContent(c *gin.Context) {
 
 	// Return success
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Content deleted successfully",
+		"message": "Content moved to trash",
 	})
 }
 
+// BatchUpdateContents handles PUT /contents/batch
+func (h *ContentsHandler) BatchUpdateContents(c *gin.Context) {
+	// Parse the request body
+	var input storage.BatchUpdateContentsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the contents
+	result, err := h.db.BatchUpdateContents(input)
+	if err != nil {
+		respondBatchError(c, "batch update contents", err)
+		return
+	}
+
+	// Return the result
+	c.JSON(batchStatus(result.RolledBack), result)
+}
+
 // BatchDeleteContents handles DELETE /contents/batch
 func (h *ContentsHandler) BatchDeleteContents(c *gin.Context) {
 	// Parse the request body
@@ -167,14 +266,12 @@ func (h *ContentsHandler) BatchDeleteContents(c *gin.Context) {
 	// Delete the contents
 	result, err := h.db.BatchDeleteContents(input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to batch delete contents: " + err.Error(),
-		})
+		respondBatchError(c, "batch delete contents", err)
 		return
 	}
 
 	// Return the result
-	c.JSON(http.StatusOK, result)
+	c.JSON(batchStatus(result.RolledBack), result)
 }
 
 // FetchContents handles POST /contents/fetch
@@ -197,6 +294,23 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 		req.Days = 7 // Default to 7 days if not specified or invalid
 	}
 
+	// Check that the requested source exists
+	if req.SourceID != nil {
+		source, err := h.db.GetSource(*req.SourceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to get source: " + err.Error(),
+			})
+			return
+		}
+		if source == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Source not found",
+			})
+			return
+		}
+	}
+
 	// Create a fetch job
 	job, err := h.db.CreateFetchJob(req.SourceID, req.Days)
 	if err != nil {
@@ -225,8 +339,8 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 		if req.SourceID != nil {
 			// Fetch for a specific source
 			source, err := h.db.GetSource(*req.SourceID)
-			if err != nil {
-				h.db.UpdateFetchJobStatus(job.ID, "failed", 0, fmt.Sprintf("Failed to get source: %v", err))
+			if err != nil || source == nil {
+				h.finishFetchJob(job.ID, "failed", 0, fmt.Sprintf("Failed to get source: %v", err))
 				return
 			}
 			sources = []storage.RSSSource{*source}
@@ -234,7 +348,7 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 			// Fetch for all sources
 			sources, _, err = h.db.ListSources(0, "") // Get all sources
 			if err != nil {
-				h.db.UpdateFetchJobStatus(job.ID, "failed", 0, fmt.Sprintf("Failed to list sources: %v", err))
+				h.finishFetchJob(job.ID, "failed", 0, fmt.Sprintf("Failed to list sources: %v", err))
 				return
 			}
 		}
@@ -245,6 +359,12 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 			// Log the error but continue
 			log.Printf("Failed to update job status: %v", err)
 		}
+		progress := events.FetchProgress{
+			JobID:        job.ID,
+			Status:       "in-progress",
+			SourcesTotal: len(sources),
+		}
+		h.bus.Publish(events.FetchJobProgress, progress)
 
 		// Calculate the cutoff time based on the requested days
 		cutoffTime := time.Now().AddDate(0, 0, -req.Days)
@@ -257,6 +377,14 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 			feed, err := fp.ParseURLWithContext(source.URL, ctx)
 			if err != nil {
 				errors = append(errors, fmt.Sprintf("Failed to parse feed %s: %v", source.URL, err))
+				h.bus.Publish(events.SourceFailed, events.SourceFailure{
+					SourceID: source.ID,
+					Name:     source.Name,
+					URL:      source.URL,
+					JobID:    job.ID,
+					Error:    err.Error(),
+				})
+				h.publishProgress(&progress, source.ID, itemsProcessed)
 				continue
 			}
 
@@ -289,22 +417,60 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 					url = item.GUID // fallback to GUID if link is not available
 				}
 
+				// Identify the article by its canonical link, starting from
+				// the original link of items proxied by FeedBurner
+				canonicalLink := riffle.OriginalLink(item)
+				if canonicalLink == "" {
+					canonicalLink = url
+				}
+				canonicalLink = h.canonicalizer.Canonicalize(ctx, canonicalLink)
+
+				// Skip content that was deleted so it is not ingested again
+				deleted, err := h.db.IsContentDeleted(url, canonicalLink)
+				if err != nil {
+					errors = append(errors, fmt.Sprintf("Failed to check content %s: %v", url, err))
+					continue
+				}
+				if deleted {
+					continue
+				}
+
 				// Check if the content already exists in the database
-				existingContent, err := h.db.GetContentByURL(url)
+				existingContent, err := h.db.GetContentByURL(url, canonicalLink)
 				if err == nil && existingContent != nil {
-					// Content already exists, skip it
+					// The same article linked from another feed is a duplicate
+					if existingContent.SourceID != source.ID {
+						continue
+					}
+
+					// Content already exists, record any edits made by the publisher
+					changed, err := h.db.UpdateContentFromFeed(existingContent.ID, storage.FeedContentInput{
+						Title:       item.Title,
+						Description: item.Description,
+						Content:     content,
+					})
+					if err != nil {
+						errors = append(errors, fmt.Sprintf("Failed to update content %s: %v", url, err))
+					} else if changed {
+						itemsProcessed++
+						h.publishContentUpdated(existingContent.ID)
+					}
 					continue
 				}
 
 				// Create a new content item
 				rssContent := &storage.RSSContent{
-					SourceID:    source.ID,
-					Title:       item.Title,
-					Link:        url,
-					Description: item.Description,
-					Content:     content,
-					PublishedAt: pubDate,
-					FetchedAt:   time.Now().UTC(),
+					SourceID:      source.ID,
+					Title:         item.Title,
+					Link:          url,
+					CanonicalLink: canonicalLink,
+					Description:   item.Description,
+					Content:       content,
+					PublishedAt:   pubDate,
+					FetchedAt:     time.Now().UTC(),
+					// The feed's language is kept if the text is too short
+					// for its language to be detected
+					Language: feed.Language,
 				}
 
 				// Add author if available
@@ -317,12 +483,23 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 					rssContent.Categories = item.Categories
 				}
 
-				// Store the content in the database
-				err = h.db.CreateContent(rssContent)
+				// Sign the text so that near-duplicates are grouped into stories
+				rssContent.SimHash = riffle.SimHash(item.Title + "\n" + riffle.PlainText(content))
+
+				// Store the content through the filter and routing rules;
+				// dropped content is not stored and hidden content is not
+				// announced
+				stored, err := h.db.IngestContent(rssContent)
 				if err != nil {
 					errors = append(errors, fmt.Sprintf("Failed to store content %s: %v", url, err))
 					continue
 				}
+				if !stored {
+					continue
+				}
+				if !rssContent.Hidden {
+					h.bus.Publish(events.ContentCreated, rssContent)
+				}
 
 				itemsProcessed++
 			}
@@ -332,6 +509,7 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 			if err != nil {
 				errors = append(errors, fmt.Sprintf("Failed to update source last fetched time %s: %v", source.ID, err))
 			}
+			h.publishProgress(&progress, source.ID, itemsProcessed)
 		}
 
 		// Update job status to completed
@@ -345,13 +523,100 @@ func (h *ContentsHandler) FetchContents(c *gin.Context) {
 			errorMsg = strings.Join(errors, "; ")
 		}
 
-		err = h.db.UpdateFetchJobStatus(job.ID, status, itemsProcessed, errorMsg)
+		h.finishFetchJob(job.ID, status, itemsProcessed, errorMsg)
+	}()
+}
+
+// publishProgress announces that a fetch job is done with another source
+func (h *ContentsHandler) publishProgress(progress *events.FetchProgress, sourceID string, itemsProcessed int) {
+	progress.SourcesDone++
+	progress.SourceID = sourceID
+	progress.ItemsProcessed = itemsProcessed
+	h.bus.Publish(events.FetchJobProgress, *progress)
+}
+
+// getSubscribedContent gets a content item of a source the current user is
+// subscribed to, responding with an error if it cannot. Like content
+// listings, other items are reported as not found.
+func getSubscribedContent(c *gin.Context, db *storage.SQLiteDB, id string) (*storage.RSSContent, bool) {
+	// Get the content from the database
+	content, err := db.GetContent(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get content: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	// Check if the content exists and the user is subscribed to its source
+	if content != nil {
+		subscribed, err := db.IsSubscribed(currentUser(c).ID, content.SourceID)
 		if err != nil {
-			// Log the error but don't return it to the client
-			// since this is an asynchronous operation
-			log.Printf("Failed to update job status: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check subscription: " + err.Error(),
+			})
+			return nil, false
 		}
-	}()
+		if !subscribed {
+			content = nil
+		}
+	}
+	if content == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Content not found",
+		})
+		return nil, false
+	}
+
+	return content, true
+}
+
+// checkSubscribedContents responds with 404 unless all of the given content
+// items exist and belong to sources the current user is subscribed to, the
+// same way getSubscribedContent does for a single item
+func (h *ContentsHandler) checkSubscribedContents(c *gin.Context, action string, ids []string) bool {
+	missing, err := h.db.UnsubscribedContentIDs(currentUser(c).ID, ids)
+	if err != nil {
+		respondBatchError(c, action, err)
+		return false
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":      "Content not found",
+			"contentIds": missing,
+		})
+		return false
+	}
+	return true
+}
+
+// publishContentUpdated announces that the publisher edited a content item
+func (h *ContentsHandler) publishContentUpdated(id string) {
+	content, err := h.db.GetContent(id)
+	if err != nil || content == nil {
+		log.Printf("Failed to get content %s: %v", id, err)
+		return
+	}
+	h.bus.Publish(events.ContentUpdated, content)
+}
+
+// finishFetchJob records the final status of a fetch job and announces that
+// it completed
+func (h *ContentsHandler) finishFetchJob(jobID, status string, itemsProcessed int, errorMsg string) {
+	err := h.db.UpdateFetchJobStatus(jobID, status, itemsProcessed, errorMsg)
+	if err != nil {
+		// Log the error but don't return it to the client
+		// since this is an asynchronous operation
+		log.Printf("Failed to update job status: %v", err)
+		return
+	}
+
+	job, err := h.db.GetFetchJob(jobID)
+	if err != nil || job == nil {
+		log.Printf("Failed to get fetch job %s: %v", jobID, err)
+		return
+	}
+	h.bus.Publish(events.FetchJobCompleted, job)
 }
 
 // GetFetchStatus handles GET /contents/fetch/:jobId
@@ -383,12 +648,17 @@ func (h *ContentsHandler) GetFetchStatus(c *gin.Context) {
 // SearchContents handles GET /contents/search
 func (h *ContentsHandler) SearchContents(c *gin.Context) {
 	// Parse query parameters
-	keywords := c.Query("keywords")
-	sourceID := c.Query("sourceId")
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	input := storage.SearchContentsInput{
+		Keywords: c.Query("keywords"),
+		UserID:   currentUser(c).ID,
+		Tags:     c.QueryArray("tag"),
+		SourceID: c.Query("sourceId"),
+		Language: riffle.NormalizeLanguage(c.Query("language")),
+	}
+	input.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
 
 	// Validate keywords
-	if keywords == "" {
+	if input.Keywords == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Keywords parameter is required",
 		})
@@ -396,7 +666,7 @@ func (h *ContentsHandler) SearchContents(c *gin.Context) {
 	}
 
 	// Search contents
-	contents, err := h.db.SearchContents(keywords, sourceID, limit)
+	contents, err := h.db.SearchContents(input)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to search contents: " + err.Error(),
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/digest"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// DigestConfig configures the email digests
+type DigestConfig struct {
+	// Sender sends the digests; it is nil if no SMTP server is configured
+	Sender *digest.Sender
+	// DefaultSendTime and DefaultTimezone are used for subscriptions that
+	// do not set them
+	DefaultSendTime string
+	DefaultTimezone string
+}
+
+// DigestsHandler handles API requests for email digests
+type DigestsHandler struct {
+	db     *storage.SQLiteDB
+	config DigestConfig
+}
+
+// NewDigestsHandler creates a new DigestsHandler
+func NewDigestsHandler(db *storage.SQLiteDB, config DigestConfig) *DigestsHandler {
+	return &DigestsHandler{
+		db:     db,
+		config: config,
+	}
+}
+
+// GetDigestSubscription handles GET /users/me/digest
+func (h *DigestsHandler) GetDigestSubscription(c *gin.Context) {
+	// Get the digest subscription from the database
+	subscription, err := h.db.GetDigestSubscription(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get digest subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the user has a digest subscription
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Digest subscription not found",
+		})
+		return
+	}
+
+	// Return the digest subscription
+	c.JSON(http.StatusOK, subscription)
+}
+
+// SetDigestSubscription handles PUT /users/me/digest
+func (h *DigestsHandler) SetDigestSubscription(c *gin.Context) {
+	// Parse the request body
+	var input storage.DigestSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create or replace the digest subscription
+	subscription, err := h.db.SetDigestSubscription(currentUser(c).ID, input, h.config.DefaultSendTime, h.config.DefaultTimezone)
+	if errors.Is(err, storage.ErrInvalidDigestSubscription) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to set digest subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Ask the owner of a new address to confirm it
+	if !subscription.Confirmed && h.config.Sender != nil {
+		sent, err := h.config.Sender.SendConfirmation(subscription, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": "Saved the digest subscription but failed to send the confirmation email: " + err.Error(),
+			})
+			return
+		}
+		if sent {
+			subscription, err = h.db.GetDigestSubscription(currentUser(c).ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to get digest subscription: " + err.Error(),
+				})
+				return
+			}
+		}
+	}
+
+	// Return the digest subscription
+	c.JSON(http.StatusOK, subscription)
+}
+
+// SendConfirmation handles POST /users/me/digest/confirm, which sends the
+// email confirming the digest address again
+func (h *DigestsHandler) SendConfirmation(c *gin.Context) {
+	// Check that emails can be sent
+	if h.config.Sender == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Email digests are not configured on this server",
+		})
+		return
+	}
+
+	// Get the digest subscription from the database
+	subscription, err := h.db.GetDigestSubscription(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get digest subscription: " + err.Error(),
+		})
+		return
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Digest subscription not found",
+		})
+		return
+	}
+	if subscription.Confirmed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The digest address is already confirmed",
+		})
+		return
+	}
+
+	// Send the confirmation email
+	sent, err := h.config.Sender.SendConfirmation(subscription, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "Failed to send confirmation email: " + err.Error(),
+		})
+		return
+	}
+	if !sent {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "A confirmation email was sent less than " + storage.DigestConfirmationInterval.String() + " ago",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Confirmation email sent to " + subscription.Email,
+	})
+}
+
+// DeleteDigestSubscription handles DELETE /users/me/digest
+func (h *DigestsHandler) DeleteDigestSubscription(c *gin.Context) {
+	// Delete the digest subscription
+	deleted, err := h.db.DeleteDigestSubscription(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete digest subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the digest subscription existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Digest subscription not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Digest subscription deleted",
+	})
+}
+
+// ListDigests handles GET /users/me/digest/history
+func (h *DigestsHandler) ListDigests(c *gin.Context) {
+	// Get the digests sent to the user
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	digests, err := h.db.ListDigests(currentUser(c).ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list digests: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the digests
+	c.JSON(http.StatusOK, gin.H{
+		"digests": digests,
+	})
+}
+
+// SendDigest handles POST /users/me/digest/send
+func (h *DigestsHandler) SendDigest(c *gin.Context) {
+	// Check that digests can be sent
+	if h.config.Sender == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Email digests are not configured on this server",
+		})
+		return
+	}
+
+	// Get the digest subscription from the database
+	subscription, err := h.db.GetDigestSubscription(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get digest subscription: " + err.Error(),
+		})
+		return
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Digest subscription not found",
+		})
+		return
+	}
+
+	// Send the digest now
+	sent, err := h.config.Sender.Send(subscription, time.Now())
+	if errors.Is(err, storage.ErrDigestNotConfirmed) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Confirm the digest address with the link emailed to " + subscription.Email + " first",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "Failed to send digest: " + err.Error(),
+		})
+		return
+	}
+	if sent == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "No new recommendations to send",
+		})
+		return
+	}
+
+	// Return the sent digest
+	c.JSON(http.StatusOK, sent)
+}
+
+// digestLinkPage is shown when an unsubscribe or confirmation link is
+// opened. With a token it asks to confirm with a button, so that opening the
+// link, as link scanners do, does not change anything by itself.
+var digestLinkPage = template.Must(template.New("digest-link").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>riffle digests</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 64px auto;">
+<p>{{.Text}}</p>
+{{- if .Token}}
+<form method="post" action="?token={{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+// digestLinkPageData is the data of the digest link page
+type digestLinkPageData struct {
+	Text   string
+	Token  string
+	Button string
+}
+
+// UnsubscribePage handles GET /digests/unsubscribe, the link in digest
+// emails. It asks to confirm with a form that POSTs back.
+func (h *DigestsHandler) UnsubscribePage(c *gin.Context) {
+	// Look up the digest subscription of the token without changing it
+	token := c.Query("token")
+	subscription, err := h.db.GetDigestSubscriptionByToken(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get digest subscription: " + err.Error(),
+		})
+		return
+	}
+
+	if subscription == nil {
+		h.renderDigestLinkPage(c, http.StatusNotFound, digestLinkPageData{Text: "This unsubscribe link is not valid."})
+		return
+	}
+	if !subscription.Enabled {
+		h.renderDigestLinkPage(c, http.StatusOK, digestLinkPageData{
+			Text: "You no longer receive riffle digests at " + subscription.Email + ".",
+		})
+		return
+	}
+	h.renderDigestLinkPage(c, http.StatusOK, digestLinkPageData{
+		Text:   "Stop receiving riffle digests at " + subscription.Email + "?",
+		Token:  token,
+		Button: "Unsubscribe",
+	})
+}
+
+// Unsubscribe handles POST /digests/unsubscribe, sent by the confirmation
+// page and by mail clients for RFC 8058 one-click unsubscribe
+func (h *DigestsHandler) Unsubscribe(c *gin.Context) {
+	// Disable the digest subscription of the token
+	subscription, err := h.db.UnsubscribeDigest(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unsubscribe: " + err.Error(),
+		})
+		return
+	}
+
+	if subscription == nil {
+		h.renderDigestLinkPage(c, http.StatusNotFound, digestLinkPageData{Text: "This unsubscribe link is not valid."})
+		return
+	}
+	h.renderDigestLinkPage(c, http.StatusOK, digestLinkPageData{
+		Text: "You will no longer receive riffle digests at " + subscription.Email + ".",
+	})
+}
+
+// ConfirmPage handles GET /digests/confirm, the link in confirmation emails.
+// It asks to confirm with a form that POSTs back.
+func (h *DigestsHandler) ConfirmPage(c *gin.Context) {
+	// Look up the digest subscription of the token without changing it
+	token := c.Query("token")
+	subscription, err := h.db.GetDigestSubscriptionByConfirmToken(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get digest subscription: " + err.Error(),
+		})
+		return
+	}
+
+	if subscription == nil {
+		h.renderDigestLinkPage(c, http.StatusNotFound, digestLinkPageData{Text: "This confirmation link is not valid."})
+		return
+	}
+	if subscription.Confirmed {
+		h.renderDigestLinkPage(c, http.StatusOK, digestLinkPageData{
+			Text: "You receive riffle digests at " + subscription.Email + ".",
+		})
+		return
+	}
+	h.renderDigestLinkPage(c, http.StatusOK, digestLinkPageData{
+		Text:   "Receive riffle digests at " + subscription.Email + "?",
+		Token:  token,
+		Button: "Confirm",
+	})
+}
+
+// Confirm handles POST /digests/confirm, sent by the confirmation page
+func (h *DigestsHandler) Confirm(c *gin.Context) {
+	// Confirm the address of the token's digest subscription
+	subscription, err := h.db.ConfirmDigestSubscription(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to confirm digest address: " + err.Error(),
+		})
+		return
+	}
+
+	if subscription == nil {
+		h.renderDigestLinkPage(c, http.StatusNotFound, digestLinkPageData{Text: "This confirmation link is not valid."})
+		return
+	}
+	h.renderDigestLinkPage(c, http.StatusOK, digestLinkPageData{
+		Text: "You will receive riffle digests at " + subscription.Email + ".",
+	})
+}
+
+// renderDigestLinkPage responds with the digest link page
+func (h *DigestsHandler) renderDigestLinkPage(c *gin.Context, status int, data digestLinkPageData) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := digestLinkPage.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/serving/digest"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+func TestConfirmDigestAddress(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", storage.RoleReader)
+	h := NewDigestsHandler(db, DigestConfig{DefaultSendTime: "07:00", DefaultTimezone: "UTC"})
+	router := newTestRouter(user)
+	router.PUT("/users/me/digest", h.SetDigestSubscription)
+	router.GET("/digests/confirm", h.ConfirmPage)
+	router.POST("/digests/confirm", h.Confirm)
+
+	w := serveJSON(t, router, http.MethodPut, "/users/me/digest", map[string]string{"email": "alice@example.com"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT /users/me/digest: status = %d, body %s", w.Code, w.Body)
+	}
+	var subscription storage.DigestSubscription
+	decodeJSON(t, w, &subscription)
+	if subscription.Confirmed {
+		t.Fatal("new digest address is confirmed")
+	}
+	stored, err := db.GetDigestSubscription(user.ID)
+	if err != nil {
+		t.Fatalf("GetDigestSubscription() error = %v", err)
+	}
+	link := "/digests/confirm?token=" + stored.ConfirmationToken
+
+	// Opening the link only asks to confirm
+	w = serveJSON(t, router, http.MethodGet, link, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<button") {
+		t.Fatalf("GET %s: status = %d, body %s", link, w.Code, w.Body)
+	}
+	if stored, _ = db.GetDigestSubscription(user.ID); stored.Confirmed {
+		t.Fatal("opening the confirmation link confirmed the address")
+	}
+
+	w = serveJSON(t, router, http.MethodPost, link, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s: status = %d, body %s", link, w.Code, w.Body)
+	}
+	if stored, _ = db.GetDigestSubscription(user.ID); !stored.Confirmed {
+		t.Error("confirming did not confirm the address")
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if w := serveJSON(t, router, method, "/digests/confirm?token=unknown", nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s with an unknown token: status = %d, want 404", method, w.Code)
+		}
+	}
+}
+
+func TestSendDigestRequiresConfirmation(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", storage.RoleReader)
+	if _, err := db.SetDigestSubscription(user.ID, storage.DigestSubscriptionInput{Email: "alice@example.com"}, "07:00", "UTC"); err != nil {
+		t.Fatalf("SetDigestSubscription() error = %v", err)
+	}
+
+	// Nothing is sent, so the SMTP server is never contacted
+	sender, err := digest.NewSender(digest.Config{SMTPHost: "127.0.0.1", SMTPPort: 1, From: "riffle@example.com"}, db)
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+	h := NewDigestsHandler(db, DigestConfig{Sender: sender})
+	router := newTestRouter(user)
+	router.POST("/users/me/digest/send", h.SendDigest)
+
+	if w := serveJSON(t, router, http.MethodPost, "/users/me/digest/send", nil); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+}
+
+func TestSendConfirmationWithoutSMTP(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", storage.RoleReader)
+	h := NewDigestsHandler(db, DigestConfig{})
+	router := newTestRouter(user)
+	router.POST("/users/me/digest/confirm", h.SendConfirmation)
+
+	if w := serveJSON(t, router, http.MethodPost, "/users/me/digest/confirm", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
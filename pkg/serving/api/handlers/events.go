@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+// StreamEventTypes are the event types that can be streamed
+var StreamEventTypes = []string{
+	events.ContentCreated,
+	events.ContentUpdated,
+	events.FetchJobProgress,
+	events.FetchJobCompleted,
+	events.SourceFailed,
+}
+
+// StreamResetEvent is sent when events since the client's Last-Event-ID are
+// no longer kept, so the client should reload what it shows
+const StreamResetEvent = "stream.reset"
+
+const (
+	// streamBuffer is how many events may wait to be sent to a client
+	streamBuffer = 256
+	// streamHeartbeat is how often a comment is sent to keep idle
+	// connections open through proxies
+	streamHeartbeat = 15 * time.Second
+)
+
+// EventsHandler streams events as Server-Sent Events
+type EventsHandler struct {
+	db  *storage.SQLiteDB
+	bus *events.Bus
+}
+
+// NewEventsHandler creates a new EventsHandler
+func NewEventsHandler(db *storage.SQLiteDB, bus *events.Bus) *EventsHandler {
+	return &EventsHandler{
+		db:  db,
+		bus: bus,
+	}
+}
+
+// StreamEvents handles GET /events
+func (h *EventsHandler) StreamEvents(c *gin.Context) {
+	// Parse the requested event types
+	types := map[string]bool{}
+	if param := c.Query("types"); param != "" {
+		for _, eventType := range strings.Split(param, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !isStreamEventType(eventType) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Unknown event type " + strconv.Quote(eventType) + ", use one of " + strings.Join(StreamEventTypes, ", "),
+				})
+				return
+			}
+			types[eventType] = true
+		}
+	} else {
+		for _, eventType := range StreamEventTypes {
+			types[eventType] = true
+		}
+	}
+
+	// Resume after the last event the client received. Browsers send the
+	// header when reconnecting; the query parameter is for the first request.
+	var lastID uint64
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid Last-Event-ID: " + lastEventID,
+			})
+			return
+		}
+	}
+
+	ch, complete, cancel := h.bus.Subscribe(lastID, streamBuffer)
+	defer cancel()
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		klog.V(2).Infof("Failed to clear the write deadline of an event stream: %v", err)
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if !complete {
+		c.Render(-1, sse.Event{
+			Event: StreamResetEvent,
+			Data:  gin.H{"message": "Events since the last event ID are no longer available"},
+		})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	user := currentUser(c)
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-ch:
+			if !ok {
+				// The client fell behind; it reconnects with the ID of the
+				// last event it received
+				return
+			}
+			if !types[event.Type] {
+				continue
+			}
+			event, ok = h.visible(user, event)
+			if !ok {
+				continue
+			}
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(event.ID, 10),
+				Event: event.Type,
+				Data:  event.Data,
+			})
+			c.Writer.Flush()
+		}
+	}
+}
+
+// visible returns an event as a user may see it, and whether they may see
+// it at all. Like content listings, content and source events are limited
+// to the user's subscriptions; fetch job progress is sent to everyone, but
+// without the source that was just fetched unless the user is subscribed.
+func (h *EventsHandler) visible(user *storage.User, event events.Event) (events.Event, bool) {
+	var sourceID string
+	switch data := event.Data.(type) {
+	case *storage.RSSContent:
+		sourceID = data.SourceID
+	case events.SourceFailure:
+		sourceID = data.SourceID
+	case events.FetchProgress:
+		if data.SourceID == "" {
+			return event, true
+		}
+		if !h.subscribed(user, data.SourceID) {
+			data.SourceID = ""
+			event.Data = data
+		}
+		return event, true
+	default:
+		return event, true
+	}
+
+	return event, h.subscribed(user, sourceID)
+}
+
+// subscribed reports whether a user is subscribed to a source, treating
+// errors as not subscribed
+func (h *EventsHandler) subscribed(user *storage.User, sourceID string) bool {
+	subscribed, err := h.db.IsSubscribed(user.ID, sourceID)
+	if err != nil {
+		klog.Errorf("Failed to check subscription: %v", err)
+		return false
+	}
+	return subscribed
+}
+
+// isStreamEventType reports whether an event type can be streamed
+func isStreamEventType(eventType string) bool {
+	for _, t := range StreamEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
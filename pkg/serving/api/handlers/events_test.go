@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// sseEvent is an event read from a stream
+type sseEvent struct {
+	id, event, data string
+}
+
+// eventStream is a response of GET /events
+type eventStream struct {
+	resp   *http.Response
+	lines  *bufio.Scanner
+	cancel context.CancelFunc
+}
+
+// newEventsServer serves GET /events for a reader from a bus keeping up to
+// history events
+func newEventsServer(t *testing.T, history int) (*httptest.Server, *events.Bus) {
+	db := newTestDB(t)
+	return newUserEventsServer(t, db, newTestUser(t, db, "reader", storage.RoleReader), history)
+}
+
+// newUserEventsServer serves GET /events for a user from a bus keeping up
+// to history events
+func newUserEventsServer(t *testing.T, db *storage.SQLiteDB, user *storage.User, history int) (*httptest.Server, *events.Bus) {
+	bus := events.NewBus(history)
+	router := newTestRouter(user)
+	router.GET("/events", NewEventsHandler(db, bus).StreamEvents)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, bus
+}
+
+// openStream requests the event stream, resuming after lastEventID if set
+func openStream(t *testing.T, server *httptest.Server, query, lastEventID string) *eventStream {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events"+query, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open the event stream: %v", err)
+	}
+	stream := &eventStream{resp: resp, lines: bufio.NewScanner(resp.Body), cancel: cancel}
+	t.Cleanup(stream.close)
+	return stream
+}
+
+// close ends the stream
+func (s *eventStream) close() {
+	s.cancel()
+	s.resp.Body.Close()
+}
+
+// next reads the next event, skipping comments
+func (s *eventStream) next(t *testing.T) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for s.lines.Scan() {
+		line := s.lines.Text()
+		if line == "" {
+			if event != (sseEvent{}) {
+				return event
+			}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		}
+	}
+	t.Fatalf("the stream ended before the next event: %v", s.lines.Err())
+	return event
+}
+
+// publishJobs publishes fetch job progress events and returns their IDs
+func publishJobs(bus *events.Bus, jobIDs ...string) []string {
+	ch, _, cancel := bus.Subscribe(0, len(jobIDs))
+	defer cancel()
+	ids := make([]string, len(jobIDs))
+	for i, jobID := range jobIDs {
+		bus.Publish(events.FetchJobProgress, events.FetchProgress{JobID: jobID})
+		ids[i] = strconv.FormatUint((<-ch).ID, 10)
+	}
+	return ids
+}
+
+func TestStreamEventsResumes(t *testing.T) {
+	server, bus := newEventsServer(t, 10)
+	ids := publishJobs(bus, "job-1", "job-2", "job-3")
+
+	// The events after the last received one are replayed, then new ones
+	// follow
+	stream := openStream(t, server, "", ids[0])
+	if stream.resp.StatusCode != http.StatusOK || stream.resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type %q", stream.resp.StatusCode, stream.resp.Header.Get("Content-Type"))
+	}
+	for _, id := range ids[1:] {
+		if event := stream.next(t); event.id != id || event.event != events.FetchJobProgress {
+			t.Errorf("replayed event = %+v, want %s %s", event, events.FetchJobProgress, id)
+		}
+	}
+	live := publishJobs(bus, "job-4")
+	if event := stream.next(t); event.id != live[0] || !strings.Contains(event.data, `"jobId":"job-4"`) {
+		t.Errorf("live event = %+v, want job-4 with ID %s", event, live[0])
+	}
+
+	// The query parameter resumes the first request of a client
+	stream = openStream(t, server, "?lastEventId="+ids[2], "")
+	if event := stream.next(t); event.id != live[0] {
+		t.Errorf("event after lastEventId = %+v, want ID %s", event, live[0])
+	}
+}
+
+func TestStreamEventsResetsWhenEventsWereDropped(t *testing.T) {
+	server, bus := newEventsServer(t, 2)
+	ids := publishJobs(bus, "job-1", "job-2", "job-3", "job-4")
+
+	// The client missed job-2, which is no longer kept
+	stream := openStream(t, server, "", ids[0])
+	if event := stream.next(t); event.event != StreamResetEvent || event.id != "" {
+		t.Fatalf("first event = %+v, want %s without an ID", event, StreamResetEvent)
+	}
+	for _, id := range ids[2:] {
+		if event := stream.next(t); event.id != id {
+			t.Errorf("event after the reset = %+v, want ID %s", event, id)
+		}
+	}
+
+	// Clients that only missed kept events are not reset
+	stream = openStream(t, server, "", ids[1])
+	if event := stream.next(t); event.event == StreamResetEvent || event.id != ids[2] {
+		t.Errorf("first event = %+v, want ID %s", event, ids[2])
+	}
+}
+
+func TestStreamEventsRejectsInvalidRequests(t *testing.T) {
+	server, _ := newEventsServer(t, 10)
+	for name, query := range map[string]string{
+		"invalid last event ID": "?lastEventId=abc",
+		"unknown event type":    "?types=content.created,user.deleted",
+	} {
+		stream := openStream(t, server, query, "")
+		if stream.resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, stream.resp.StatusCode)
+		}
+	}
+}
+
+func TestStreamEventsLimitsSourcesToSubscriptions(t *testing.T) {
+	db := newTestDB(t)
+	reader := newTestUser(t, db, "reader", storage.RoleReader)
+	subscribed := newTestContent(t, db, "https://example.com/subscribed.xml")
+	other := newTestContent(t, db, "https://example.com/other.xml")
+	subscribe(t, db, reader.ID, subscribed.SourceID)
+	server, bus := newUserEventsServer(t, db, reader, 10)
+
+	start := publishJobs(bus, "job-0")
+	bus.Publish(events.ContentCreated, other)
+	bus.Publish(events.SourceFailed, events.SourceFailure{SourceID: other.SourceID, JobID: "job-1"})
+	bus.Publish(events.FetchJobProgress, events.FetchProgress{JobID: "job-1", SourcesDone: 1, SourceID: other.SourceID})
+	bus.Publish(events.FetchJobProgress, events.FetchProgress{JobID: "job-1", SourcesDone: 2, SourceID: subscribed.SourceID})
+	bus.Publish(events.ContentCreated, subscribed)
+
+	// Content and failures of other sources are left out, and so is the
+	// source of their fetch progress
+	stream := openStream(t, server, "", start[0])
+	event := stream.next(t)
+	if event.event != events.FetchJobProgress || !strings.Contains(event.data, `"sourcesDone":1`) || strings.Contains(event.data, "sourceId") {
+		t.Errorf("first event = %+v, want progress without the source", event)
+	}
+	event = stream.next(t)
+	if event.event != events.FetchJobProgress || !strings.Contains(event.data, `"sourceId":"`+subscribed.SourceID+`"`) {
+		t.Errorf("second event = %+v, want progress of the subscribed source", event)
+	}
+	event = stream.next(t)
+	if event.event != events.ContentCreated || !strings.Contains(event.data, subscribed.ID) {
+		t.Errorf("third event = %+v, want the subscribed source's content", event)
+	}
+}
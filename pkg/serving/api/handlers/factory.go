@@ -1,23 +1,62 @@
 package handlers
 
 import (
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/flyer103/riffle/pkg/serving/events"
 	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/flyer103/riffle/pkg/serving/webhooks"
 )
 
 // Factory creates and initializes all API handlers
 type Factory struct {
 	Sources         *SourcesHandler
 	Contents        *ContentsHandler
+	Subscriptions   *SubscriptionsHandler
 	Recommendations *RecommendationsHandler
+	SavedSearches   *SavedSearchesHandler
+	Feeds           *FeedsHandler
+	Digests         *DigestsHandler
+	Webhooks        *WebhooksHandler
+	Filters         *FiltersHandler
+	RoutingRules    *RoutingRulesHandler
+	Stories         *StoriesHandler
+	Interests       *InterestsHandler
+	Events          *EventsHandler
+	Trash           *TrashHandler
+	Auth            *AuthHandler
+	OIDC            *OIDCHandler
+	Users           *UsersHandler
 	System          *SystemHandler
 }
 
-// NewFactory creates a new handler factory
-func NewFactory(db *storage.SQLiteDB, version string) *Factory {
-	return &Factory{
+// NewFactory creates a new handler factory. Handlers publish events to bus,
+// which dispatcher delivers to webhooks, and fetched links are canonicalized
+// by canonicalizer.
+func NewFactory(db *storage.SQLiteDB, version string, bus *events.Bus, dispatcher *webhooks.Dispatcher, authConfig AuthConfig, digestConfig DigestConfig, canonicalizer *riffle.Canonicalizer) *Factory {
+	factory := &Factory{
 		Sources:         NewSourcesHandler(db),
-		Contents:        NewContentsHandler(db),
+		Contents:        NewContentsHandler(db, bus, canonicalizer),
+		Subscriptions:   NewSubscriptionsHandler(db),
 		Recommendations: NewRecommendationsHandler(db),
+		SavedSearches:   NewSavedSearchesHandler(db),
+		Feeds:           NewFeedsHandler(db),
+		Digests:         NewDigestsHandler(db, digestConfig),
+		Webhooks:        NewWebhooksHandler(db, dispatcher),
+		Filters:         NewFiltersHandler(db),
+		RoutingRules:    NewRoutingRulesHandler(db),
+		Stories:         NewStoriesHandler(db),
+		Interests:       NewInterestsHandler(db),
+		Events:          NewEventsHandler(db, bus),
+		Trash:           NewTrashHandler(db),
+		Auth:            NewAuthHandler(db, authConfig),
+		Users:           NewUsersHandler(db),
 		System:          NewSystemHandler(version),
 	}
+
+	// Only sign in through an identity provider if one is configured
+	if authConfig.OIDC != nil {
+		factory.OIDC = NewOIDCHandler(db, authConfig)
+	}
+
+	return factory
 }
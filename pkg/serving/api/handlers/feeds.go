@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/api/middleware"
+	"github.com/flyer103/riffle/pkg/serving/feeds"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultFeedLimit is the number of items in a feed unless ?limit is given
+	defaultFeedLimit = 50
+	// maxFeedLimit is the most items a feed can have. Larger limits are
+	// lowered rather than rejected so that feed readers keep working.
+	maxFeedLimit = 200
+)
+
+// FeedsHandler handles requests for the published RSS, Atom and JSON feeds
+type FeedsHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewFeedsHandler creates a new FeedsHandler
+func NewFeedsHandler(db *storage.SQLiteDB) *FeedsHandler {
+	return &FeedsHandler{
+		db: db,
+	}
+}
+
+// GetRecommendationsFeed handles GET /feeds/recommendations.{rss,atom,json}
+func (h *FeedsHandler) GetRecommendationsFeed(c *gin.Context) {
+	// Parse the feed name and format
+	name, format, ok := parseFeedFile(c)
+	if !ok {
+		return
+	}
+	if name != "recommendations" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Feed not found",
+		})
+		return
+	}
+
+	// Get recommendations from the database
+	user := currentUser(c)
+	recommendations, err := h.db.GetRecommendations(storage.GetRecommendationsInput{
+		UserID:   user.ID,
+		Limit:    feedLimit(c),
+		Collapse: true,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get recommendations: " + err.Error(),
+		})
+		return
+	}
+	contents := make([]storage.RSSContent, len(recommendations))
+	for i, recommendation := range recommendations {
+		contents[i] = recommendation.Content
+	}
+
+	h.render(c, format, "recommendations", "", "Recommendations", "Content recommended for "+user.Username, contents)
+}
+
+// GetFolderFeed handles GET /feeds/folders/:file
+func (h *FeedsHandler) GetFolderFeed(c *gin.Context) {
+	// Parse the folder and format
+	folder, format, ok := parseFeedFile(c)
+	if !ok {
+		return
+	}
+
+	// Get the folder's newest contents
+	contents, _, err := h.db.ListContents(storage.ListContentsInput{
+		UserID: currentUser(c).ID,
+		Folder: folder,
+		Limit:  feedLimit(c),
+		Newest: true,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list contents: " + err.Error(),
+		})
+		return
+	}
+
+	h.render(c, format, "folder", folder, "Folder "+folder, "Newest content in the folder "+folder, contents)
+}
+
+// GetTagFeed handles GET /feeds/tags/:file
+func (h *FeedsHandler) GetTagFeed(c *gin.Context) {
+	// Parse the tag and format
+	tag, format, ok := parseFeedFile(c)
+	if !ok {
+		return
+	}
+	tag = storage.NormalizeTag(tag)
+
+	// Get the newest contents carrying the tag
+	contents, _, err := h.db.ListContents(storage.ListContentsInput{
+		UserID: currentUser(c).ID,
+		Tags:   []string{tag},
+		Limit:  feedLimit(c),
+		Newest: true,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list contents: " + err.Error(),
+		})
+		return
+	}
+
+	h.render(c, format, "tag", tag, "Tag "+tag, "Newest content tagged "+tag, contents)
+}
+
+// GetSavedSearchFeed handles GET /feeds/searches/:file
+func (h *FeedsHandler) GetSavedSearchFeed(c *gin.Context) {
+	// Parse the saved search ID and format
+	id, format, ok := parseFeedFile(c)
+	if !ok {
+		return
+	}
+
+	// Get the saved search
+	search, err := h.db.GetSavedSearch(currentUser(c).ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get saved search: " + err.Error(),
+		})
+		return
+	}
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Saved search not found",
+		})
+		return
+	}
+
+	// Run the search
+	contents, err := h.db.SearchContents(search.SearchInput(feedLimit(c)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to search contents: " + err.Error(),
+		})
+		return
+	}
+
+	h.render(c, format, "search", search.ID, "Search "+search.Name, "Content matching "+search.Keywords, contents)
+}
+
+// render writes contents as a feed. The feed ID is derived from the user and
+// the kind and name of the view so that it stays the same between requests.
+func (h *FeedsHandler) render(c *gin.Context, format feeds.Format, kind, name, title, description string, contents []storage.RSSContent) {
+	// Fill in the full text, author and categories
+	if err := h.db.LoadContentDetails(contents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to render feed: " + err.Error(),
+		})
+		return
+	}
+
+	user := currentUser(c)
+	feed := feeds.Feed{
+		ID:          "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte("riffle:"+user.ID+"/"+kind+"/"+name)).String(),
+		Title:       "riffle: " + title,
+		Description: description,
+		Link:        feedSelfLink(c),
+	}
+	for _, content := range contents {
+		item := feeds.Item{
+			ID:          "urn:uuid:" + content.ID,
+			Title:       content.Title,
+			Link:        content.Link,
+			Summary:     content.Description,
+			Content:     content.Content,
+			Author:      content.Author,
+			Categories:  content.Categories,
+			PublishedAt: content.PublishedAt,
+		}
+		if content.UpdatedAt != nil {
+			item.UpdatedAt = *content.UpdatedAt
+		}
+		feed.Items = append(feed.Items, item)
+
+		// The feed is as new as its newest item
+		for _, t := range []time.Time{item.PublishedAt, item.UpdatedAt} {
+			if t.After(feed.Updated) {
+				feed.Updated = t
+			}
+		}
+	}
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Now().UTC()
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Status(http.StatusOK)
+	if err := feeds.Render(c.Writer, format, feed); err != nil {
+		c.Error(err)
+	}
+}
+
+// parseFeedFile splits the :file parameter, such as "news.atom", into the
+// name and format of the feed. It responds with an error if the format is
+// not known.
+func parseFeedFile(c *gin.Context) (string, feeds.Format, bool) {
+	file := c.Param("file")
+	ext := path.Ext(file)
+	format, err := feeds.ParseFormat(strings.TrimPrefix(ext, "."))
+	if ext == "" || err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Feed not found; use the .rss, .atom or .json extension",
+		})
+		return "", "", false
+	}
+	return strings.TrimSuffix(file, ext), format, true
+}
+
+// feedLimit returns the number of items requested with ?limit, at most
+// maxFeedLimit
+func feedLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultFeedLimit
+	}
+	return min(limit, maxFeedLimit)
+}
+
+// feedSelfLink returns the URL of the requested feed without the feed token
+func feedSelfLink(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	query := c.Request.URL.Query()
+	query.Del(middleware.FeedTokenParam)
+	link := url.URL{
+		Scheme:   scheme,
+		Host:     c.Request.Host,
+		Path:     c.Request.URL.Path,
+		RawQuery: query.Encode(),
+	}
+	return link.String()
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFeedLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", defaultFeedLimit},
+		{"?limit=10", 10},
+		{"?limit=0", defaultFeedLimit},
+		{"?limit=-5", defaultFeedLimit},
+		{"?limit=many", defaultFeedLimit},
+		{"?limit=200", maxFeedLimit},
+		{"?limit=1000000", maxFeedLimit},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/feeds/recommendations.rss"+tt.query, nil)
+		if got := feedLimit(c); got != tt.want {
+			t.Errorf("feedLimit(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
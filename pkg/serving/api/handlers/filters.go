@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFilterTestItems is the most items a filter rule can be tested against
+const maxFilterTestItems = 1000
+
+// FiltersHandler handles API requests for filter rules
+type FiltersHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewFiltersHandler creates a new FiltersHandler
+func NewFiltersHandler(db *storage.SQLiteDB) *FiltersHandler {
+	return &FiltersHandler{
+		db: db,
+	}
+}
+
+// ListFilterRules handles GET /filters
+func (h *FiltersHandler) ListFilterRules(c *gin.Context) {
+	// Get the filter rules from the database
+	rules, err := h.db.ListFilterRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list filter rules: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the filter rules
+	c.JSON(http.StatusOK, gin.H{
+		"filters": rules,
+	})
+}
+
+// CreateFilterRule handles POST /filters
+func (h *FiltersHandler) CreateFilterRule(c *gin.Context) {
+	// Parse the request body
+	var input storage.FilterRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create the filter rule
+	rule, err := h.db.CreateFilterRule(currentUser(c).ID, input)
+	if errors.Is(err, storage.ErrInvalidFilterRule) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create filter rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created filter rule
+	c.JSON(http.StatusCreated, rule)
+}
+
+// GetFilterRule handles GET /filters/:id
+func (h *FiltersHandler) GetFilterRule(c *gin.Context) {
+	rule, ok := h.getFilterRule(c)
+	if !ok {
+		return
+	}
+
+	// Return the filter rule
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateFilterRule handles PUT /filters/:id
+func (h *FiltersHandler) UpdateFilterRule(c *gin.Context) {
+	// Parse the request body
+	var input storage.FilterRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the filter rule
+	rule, err := h.db.UpdateFilterRule(c.Param("id"), input)
+	if errors.Is(err, storage.ErrInvalidFilterRule) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update filter rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the filter rule exists
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Filter rule not found",
+		})
+		return
+	}
+
+	// Return the updated filter rule
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteFilterRule handles DELETE /filters/:id
+func (h *FiltersHandler) DeleteFilterRule(c *gin.Context) {
+	// Delete the filter rule
+	deleted, err := h.db.DeleteFilterRule(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete filter rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the filter rule existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Filter rule not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Filter rule deleted",
+	})
+}
+
+// TestFilterRule handles POST /filters/test, which tries a rule that has not
+// been saved
+func (h *FiltersHandler) TestFilterRule(c *gin.Context) {
+	// Parse the request body
+	var input storage.FilterRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Build the rule without saving it
+	rule, err := storage.NewFilterRule(input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	h.testFilterRule(c, rule)
+}
+
+// TestSavedFilterRule handles GET /filters/:id/test
+func (h *FiltersHandler) TestSavedFilterRule(c *gin.Context) {
+	rule, ok := h.getFilterRule(c)
+	if !ok {
+		return
+	}
+
+	h.testFilterRule(c, rule)
+}
+
+// ApplyFilterRules handles POST /filters/apply, which re-applies the enabled
+// rules to existing content
+func (h *FiltersHandler) ApplyFilterRules(c *gin.Context) {
+	result, err := h.db.ApplyFilterRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to apply filter rules: " + err.Error(),
+		})
+		return
+	}
+
+	// Return what changed
+	c.JSON(http.StatusOK, result)
+}
+
+// testFilterRule responds with the items among the last ones the rule
+// matches
+func (h *FiltersHandler) testFilterRule(c *gin.Context, rule *storage.FilterRule) {
+	// Parse the number of items to test against
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxFilterTestItems {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit. Use a number from 1 to " + strconv.Itoa(maxFilterTestItems),
+		})
+		return
+	}
+
+	// Match the rule against the newest items
+	matches, tested, err := h.db.TestFilterRule(rule, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to test filter rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the matching items and what the rule would do with them
+	c.JSON(http.StatusOK, gin.H{
+		"action":   rule.Action,
+		"tested":   tested,
+		"count":    len(matches),
+		"contents": matches,
+	})
+}
+
+// getFilterRule gets the filter rule named in the URL, responding with an
+// error if it cannot
+func (h *FiltersHandler) getFilterRule(c *gin.Context) (*storage.FilterRule, bool) {
+	// Get the filter rule from the database
+	rule, err := h.db.GetFilterRule(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get filter rule: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	// Check if the filter rule exists
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Filter rule not found",
+		})
+		return nil, false
+	}
+
+	return rule, true
+}
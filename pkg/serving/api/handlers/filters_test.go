@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// filterTestResult is the response of a filter rule test
+type filterTestResult struct {
+	Action   string               `json:"action"`
+	Tested   int                  `json:"tested"`
+	Count    int                  `json:"count"`
+	Contents []storage.RSSContent `json:"contents"`
+}
+
+func TestTestFilterRule(t *testing.T) {
+	db := newTestDB(t)
+	editor := newTestUser(t, db, "editor", storage.RoleEditor)
+	source, err := db.CreateSource(storage.CreateSourceInput{Name: "Blog", URL: "https://example.com/feed.xml"})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+
+	// Five items, newest first, of which the first and fourth are sponsored
+	titles := []string{"Sponsored: a deal", "Release notes", "Weekly links", "SPONSORED post", "Hello"}
+	now := time.Now().UTC()
+	for i, title := range titles {
+		content := &storage.RSSContent{
+			SourceID:    source.ID,
+			Title:       title,
+			Link:        "https://example.com/" + strconv.Itoa(i),
+			PublishedAt: now.Add(-time.Duration(i) * time.Hour),
+		}
+		if err := db.CreateContent(content); err != nil {
+			t.Fatalf("CreateContent() error = %v", err)
+		}
+	}
+	// A sponsored item of another source, older than all of them
+	otherSource, err := db.CreateSource(storage.CreateSourceInput{Name: "Other", URL: "https://example.com/other.xml"})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+	other := &storage.RSSContent{
+		SourceID:    otherSource.ID,
+		Title:       "Sponsored elsewhere",
+		Link:        "https://example.com/other",
+		PublishedAt: now.Add(-24 * time.Hour),
+	}
+	if err := db.CreateContent(other); err != nil {
+		t.Fatalf("CreateContent() error = %v", err)
+	}
+
+	router := newTestRouter(editor)
+	router.POST("/filters/test", NewFiltersHandler(db).TestFilterRule)
+	rule := storage.FilterRuleInput{
+		Name:    "Sponsored",
+		Field:   storage.FilterFieldTitle,
+		Pattern: "sponsored",
+		Action:  storage.FilterActionHide,
+	}
+
+	tests := []struct {
+		query         string
+		sourceID      string
+		tested, count int
+	}{
+		// Only the newest limit items are tested
+		{"?limit=1", "", 1, 1},
+		{"?limit=3", "", 3, 1},
+		{"?limit=4", "", 4, 2},
+		{"?limit=1000", "", 6, 3},
+		{"", "", 6, 3},
+		// Per-source rules are tested against that source's items
+		{"?limit=10", source.ID, 5, 2},
+	}
+	for _, tt := range tests {
+		rule.SourceID = tt.sourceID
+		w := serveJSON(t, router, http.MethodPost, "/filters/test"+tt.query, rule)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d, body %s", tt.query, w.Code, w.Body)
+		}
+		var result filterTestResult
+		decodeJSON(t, w, &result)
+		if result.Tested != tt.tested || result.Count != tt.count || len(result.Contents) != tt.count {
+			t.Errorf("%q source %q: tested %d, count %d, want %d, %d", tt.query, tt.sourceID, result.Tested, result.Count, tt.tested, tt.count)
+		}
+		if result.Action != storage.FilterActionHide {
+			t.Errorf("%q: action = %s, want %s", tt.query, result.Action, storage.FilterActionHide)
+		}
+	}
+
+	// Testing neither saves the rule nor hides anything
+	rules, err := db.ListFilterRules()
+	if err != nil || len(rules) != 0 {
+		t.Errorf("ListFilterRules() = %d rules, %v, want none", len(rules), err)
+	}
+	w := serveJSON(t, router, http.MethodPost, "/filters/test?limit=10", rule)
+	var result filterTestResult
+	decodeJSON(t, w, &result)
+	if result.Count != 2 {
+		t.Errorf("a second test matched %d items, want 2", result.Count)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=1001", "?limit=-5", "?limit=ten"} {
+		if w := serveJSON(t, router, http.MethodPost, "/filters/test"+query, rule); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, w.Code)
+		}
+	}
+	rule.Pattern = "("
+	rule.Match = storage.FilterMatchRegex
+	if w := serveJSON(t, router, http.MethodPost, "/filters/test", rule); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid pattern: status = %d, want 400", w.Code)
+	}
+}
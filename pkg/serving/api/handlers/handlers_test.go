@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/api/middleware"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestDB opens a database in a temporary directory that is closed when the
+// test ends
+func newTestDB(t *testing.T) *storage.SQLiteDB {
+	t.Helper()
+	db, err := storage.NewSQLiteDB(filepath.Join(t.TempDir(), "riffle.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestUser creates a user with the given role
+func newTestUser(t *testing.T, db *storage.SQLiteDB, username, role string) *storage.User {
+	t.Helper()
+	user, err := db.CreateUser(storage.CreateUserInput{Username: username, Password: "password123", Role: role})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	return user
+}
+
+// newTestContent creates a source with the given feed URL and one content
+// item of it
+func newTestContent(t *testing.T, db *storage.SQLiteDB, url string) *storage.RSSContent {
+	t.Helper()
+	source, err := db.CreateSource(storage.CreateSourceInput{Name: url, URL: url})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+	content := &storage.RSSContent{
+		SourceID:    source.ID,
+		Title:       url + " item",
+		Link:        url + "/item",
+		PublishedAt: time.Now().UTC(),
+	}
+	if err := db.CreateContent(content); err != nil {
+		t.Fatalf("CreateContent() error = %v", err)
+	}
+	return content
+}
+
+// subscribe subscribes a user to a source
+func subscribe(t *testing.T, db *storage.SQLiteDB, userID, sourceID string) {
+	t.Helper()
+	if _, err := db.CreateSubscription(userID, storage.CreateSubscriptionInput{SourceID: sourceID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+}
+
+// newTestRouter returns a router whose requests are authenticated as user
+func newTestRouter(user *storage.User) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, &middleware.Principal{User: user, Method: middleware.AuthMethodToken})
+		c.Next()
+	})
+	return router
+}
+
+// serveJSON sends a request with a JSON body through a router
+func serveJSON(t *testing.T, router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeJSON decodes a JSON response body
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxInterestsImportSize is the maximum size of an imported interests file
+const maxInterestsImportSize = 1 << 20
+
+// InterestsHandler handles API requests for a user's interest profile
+type InterestsHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewInterestsHandler creates a new InterestsHandler
+func NewInterestsHandler(db *storage.SQLiteDB) *InterestsHandler {
+	return &InterestsHandler{
+		db: db,
+	}
+}
+
+// ListInterests handles GET /users/me/interests
+func (h *InterestsHandler) ListInterests(c *gin.Context) {
+	// Get the user's interests from the database
+	interests, err := h.db.ListInterests(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list interests: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the interests
+	c.JSON(http.StatusOK, gin.H{
+		"interests": interests,
+	})
+}
+
+// CreateInterest handles POST /users/me/interests
+func (h *InterestsHandler) CreateInterest(c *gin.Context) {
+	// Parse the request body
+	var input storage.InterestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create the interest
+	interest, err := h.db.CreateInterest(currentUser(c).ID, input)
+	if errors.Is(err, storage.ErrInvalidInterest) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if errors.Is(err, storage.ErrInterestExists) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create interest: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created interest
+	c.JSON(http.StatusCreated, interest)
+}
+
+// ImportInterests handles POST /users/me/interests/import
+func (h *InterestsHandler) ImportInterests(c *gin.Context) {
+	// Add the interests of the plain text body, one per line
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxInterestsImportSize)
+	interests, err := h.db.ImportInterests(currentUser(c).ID, body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Request body is too large",
+		})
+		return
+	} else if errors.Is(err, storage.ErrInvalidInterest) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to import interests: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the added interests
+	c.JSON(http.StatusOK, gin.H{
+		"interests": interests,
+	})
+}
+
+// GetInterest handles GET /users/me/interests/:id
+func (h *InterestsHandler) GetInterest(c *gin.Context) {
+	// Get the interest from the database
+	interest, err := h.db.GetInterest(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get interest: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the interest exists
+	if interest == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Interest not found",
+		})
+		return
+	}
+
+	// Return the interest
+	c.JSON(http.StatusOK, interest)
+}
+
+// UpdateInterest handles PUT /users/me/interests/:id
+func (h *InterestsHandler) UpdateInterest(c *gin.Context) {
+	// Parse the request body
+	var input storage.InterestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the interest
+	interest, err := h.db.UpdateInterest(currentUser(c).ID, c.Param("id"), input)
+	if errors.Is(err, storage.ErrInvalidInterest) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if errors.Is(err, storage.ErrInterestExists) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update interest: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the interest exists
+	if interest == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Interest not found",
+		})
+		return
+	}
+
+	// Return the updated interest
+	c.JSON(http.StatusOK, interest)
+}
+
+// DeleteInterest handles DELETE /users/me/interests/:id
+func (h *InterestsHandler) DeleteInterest(c *gin.Context) {
+	// Delete the interest
+	deleted, err := h.db.DeleteInterest(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete interest: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the interest existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Interest not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Interest deleted",
+	})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// oidcCookieName is the name of the cookie holding the state of a
+	// sign-in through the identity provider while the browser is away
+	oidcCookieName = "riffle_oidc"
+	// oidcCookiePath limits the sign-in cookie to the OIDC endpoints
+	oidcCookiePath = "/auth/oidc"
+	// oidcLoginTimeout is how long a user has to sign in at the provider, in seconds
+	oidcLoginTimeout = 10 * 60
+)
+
+// OIDCHandler handles signing in through an OpenID Connect identity provider
+type OIDCHandler struct {
+	db     *storage.SQLiteDB
+	config AuthConfig
+}
+
+// oidcLoginState is kept in a cookie between the login redirect and the callback
+type oidcLoginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect,omitempty"`
+}
+
+// NewOIDCHandler creates a new OIDCHandler
+func NewOIDCHandler(db *storage.SQLiteDB, config AuthConfig) *OIDCHandler {
+	return &OIDCHandler{
+		db:     db,
+		config: config,
+	}
+}
+
+// Login handles GET /auth/oidc/login
+func (h *OIDCHandler) Login(c *gin.Context) {
+	// Generate the values that tie the callback to this browser
+	state, err := randomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start sign-in: " + err.Error(),
+		})
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start sign-in: " + err.Error(),
+		})
+		return
+	}
+	login := oidcLoginState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	// Only redirect to paths on this server after signing in
+	if redirect := c.Query("redirect"); isLocalRedirect(redirect) {
+		login.Redirect = redirect
+	}
+
+	data, err := json.Marshal(login)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start sign-in: " + err.Error(),
+		})
+		return
+	}
+	setCookie(c, oidcCookieName, base64.RawURLEncoding.EncodeToString(data), oidcLoginTimeout, oidcCookiePath)
+
+	// Send the browser to the identity provider
+	c.Redirect(http.StatusFound, h.config.OIDC.AuthCodeURL(login.State, login.Nonce, login.Verifier))
+}
+
+// Callback handles GET /auth/oidc/callback
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	// Restore the state of the sign-in and clear it
+	login, err := readLoginState(c)
+	setCookie(c, oidcCookieName, "", -1, oidcCookiePath)
+	if err != nil || login.State == "" || login.State != c.Query("state") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired sign-in state. Start again from /auth/oidc/login",
+		})
+		return
+	}
+
+	// Check whether the provider reported an error
+	if errorCode := c.Query("error"); errorCode != "" {
+		message := errorCode
+		if description := c.Query("error_description"); description != "" {
+			message += ": " + description
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Sign-in failed: " + message,
+		})
+		return
+	}
+
+	// Exchange the code for the user
+	user, err := h.config.OIDC.Exchange(c.Request.Context(), c.Query("code"), login.Verifier, login.Nonce)
+	if errors.Is(err, storage.ErrUsernameTaken) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A local account with the same username already exists",
+		})
+		return
+	} else if err != nil {
+		klog.Errorf("OIDC sign-in failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Sign-in failed: " + err.Error(),
+		})
+		return
+	}
+
+	// Start a session
+	_, secret, err := h.db.CreateSession(user.ID, h.config.SessionTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log in: " + err.Error(),
+		})
+		return
+	}
+	setSessionCookie(c, secret, int(h.config.SessionTTL.Seconds()))
+
+	// Send the browser back to the app
+	redirect := login.Redirect
+	if redirect == "" {
+		redirect = h.config.OIDCPostLoginURL
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// isLocalRedirect reports whether a redirect target is a path on this server.
+// Browsers treat backslashes as slashes and drop tabs and newlines, so
+// "/\evil.example" or "/\t/evil.example" would lead to another host.
+func isLocalRedirect(redirect string) bool {
+	if len(redirect) == 0 || redirect[0] != '/' {
+		return false
+	}
+	if len(redirect) > 1 && (redirect[1] == '/' || redirect[1] == '\\') {
+		return false
+	}
+	if strings.ContainsFunc(redirect, func(r rune) bool { return r == '\\' || unicode.IsControl(r) }) {
+		return false
+	}
+	u, err := url.Parse(redirect)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// readLoginState reads the sign-in state from its cookie
+func readLoginState(c *gin.Context) (*oidcLoginState, error) {
+	cookie, err := c.Cookie(oidcCookieName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return nil, err
+	}
+	var login oidcLoginState
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// randomToken returns a random URL-safe string
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
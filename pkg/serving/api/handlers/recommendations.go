@@ -23,7 +23,7 @@ func NewRecommendationsHandler(db *storage.SQLiteDB) *RecommendationsHandler {
 // GetRecommendations handles GET /recommendations
 func (h *RecommendationsHandler) GetRecommendations(c *gin.Context) {
 	// Parse query parameters
-	userID := c.Query("userId")
+	userID := currentUser(c).ID
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 
 	// Parse source IDs if provided
@@ -37,11 +37,21 @@ func (h *RecommendationsHandler) GetRecommendations(c *gin.Context) {
 		}
 	}
 
+	// Parse whether near-duplicates are collapsed into one item per story
+	collapse, err := strconv.ParseBool(c.DefaultQuery("collapse", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid collapse parameter. Use true or false",
+		})
+		return
+	}
+
 	// Get recommendations from the database
 	input := storage.GetRecommendationsInput{
 		UserID:    userID,
 		SourceIDs: sourceIDs,
 		Limit:     limit,
+		Collapse:  collapse,
 	}
 	recommendations, err := h.db.GetRecommendations(input)
 	if err != nil {
@@ -76,12 +86,6 @@ func (h *RecommendationsHandler) SubmitFeedback(c *gin.Context) {
 		})
 		return
 	}
-	if input.UserID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "userId is required",
-		})
-		return
-	}
 	if input.Rating < 1 || input.Rating > 5 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "rating must be between 1 and 5",
@@ -89,7 +93,13 @@ func (h *RecommendationsHandler) SubmitFeedback(c *gin.Context) {
 		return
 	}
 
-	// Create the feedback
+	// Only content the user can see can be rated
+	if _, ok := getSubscribedContent(c, h.db, input.ContentID); !ok {
+		return
+	}
+
+	// Create the feedback for the authenticated user
+	input.UserID = currentUser(c).ID
 	feedback, err := h.db.CreateRecommendationFeedback(input)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -102,13 +112,10 @@ func (h *RecommendationsHandler) SubmitFeedback(c *gin.Context) {
 	c.JSON(http.StatusCreated, feedback)
 }
 
-// GetUserFeedback handles GET /recommendations/feedback/:userId
+// GetUserFeedback handles GET /recommendations/feedback
 func (h *RecommendationsHandler) GetUserFeedback(c *gin.Context) {
-	// Get the user ID from the URL
-	userID := c.Param("userId")
-
-	// Get the user's feedback from the database
-	feedback, err := h.db.GetUserFeedback(userID)
+	// Get the authenticated user's feedback from the database
+	feedback, err := h.db.GetUserFeedback(currentUser(c).ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get user feedback: " + err.Error(),
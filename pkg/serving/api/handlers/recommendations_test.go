@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+func TestSubmitFeedbackRequiresSubscription(t *testing.T) {
+	db := newTestDB(t)
+	reader := newTestUser(t, db, "reader", storage.RoleReader)
+	subscribed := newTestContent(t, db, "https://example.com/subscribed.xml")
+	other := newTestContent(t, db, "https://example.com/other.xml")
+	subscribe(t, db, reader.ID, subscribed.SourceID)
+
+	router := newTestRouter(reader)
+	router.POST("/recommendations/feedback", NewRecommendationsHandler(db).SubmitFeedback)
+
+	input := map[string]interface{}{"contentId": subscribed.ID, "rating": 5}
+	if w := serveJSON(t, router, http.MethodPost, "/recommendations/feedback", input); w.Code != http.StatusCreated {
+		t.Fatalf("subscribed content: status = %d, body %s", w.Code, w.Body)
+	}
+	for name, id := range map[string]string{"unsubscribed": other.ID, "missing": "missing"} {
+		input["contentId"] = id
+		if w := serveJSON(t, router, http.MethodPost, "/recommendations/feedback", input); w.Code != http.StatusNotFound {
+			t.Errorf("%s content: status = %d, want 404", name, w.Code)
+		}
+	}
+
+	feedback, err := db.GetUserFeedback(reader.ID)
+	if err != nil || len(feedback) != 1 || feedback[0].ContentID != subscribed.ID {
+		t.Errorf("GetUserFeedback() = %+v, %v, want only the subscribed item", feedback, err)
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// RevisionDiff represents the differences between two revisions of a content item
+type RevisionDiff struct {
+	ContentID   string             `json:"contentId"`
+	From        int                `json:"from"`
+	To          int                `json:"to"`
+	Title       []riffle.DiffChunk `json:"title"`
+	Description []riffle.DiffChunk `json:"description"`
+	Content     []riffle.DiffChunk `json:"content"`
+}
+
+// ListContentRevisions handles GET /contents/:id/revisions
+func (h *ContentsHandler) ListContentRevisions(c *gin.Context) {
+	// Get the content ID from the URL
+	id := c.Param("id")
+
+	// Check if the content exists and is visible to the user
+	if _, ok := getSubscribedContent(c, h.db, id); !ok {
+		return
+	}
+
+	// Get the revisions from the database
+	revisions, err := h.db.ListContentRevisions(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list content revisions: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the revisions
+	c.JSON(http.StatusOK, gin.H{
+		"revisions": revisions,
+		"count":     len(revisions),
+	})
+}
+
+// GetContentRevision handles GET /contents/:id/revisions/:revision
+func (h *ContentsHandler) GetContentRevision(c *gin.Context) {
+	// Get the content ID and revision number from the URL
+	id := c.Param("id")
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid revision number",
+		})
+		return
+	}
+
+	// Check if the content exists and is visible to the user
+	if _, ok := getSubscribedContent(c, h.db, id); !ok {
+		return
+	}
+
+	// Get the revision from the database
+	rev, err := h.db.GetContentRevision(id, revision)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get content revision: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the revision exists
+	if rev == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Revision not found",
+		})
+		return
+	}
+
+	// Return the revision
+	c.JSON(http.StatusOK, rev)
+}
+
+// DiffContentRevisions handles GET /contents/:id/revisions/diff
+func (h *ContentsHandler) DiffContentRevisions(c *gin.Context) {
+	// Get the content ID from the URL
+	id := c.Param("id")
+
+	// Parse query parameters
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from parameter must be a revision number",
+		})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "to parameter must be a revision number",
+		})
+		return
+	}
+
+	diff := riffle.DiffLines
+	switch granularity := c.DefaultQuery("granularity", "line"); granularity {
+	case "line":
+	case "word":
+		diff = riffle.DiffWords
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "granularity must be line or word",
+		})
+		return
+	}
+
+	// Check if the content exists and is visible to the user
+	if _, ok := getSubscribedContent(c, h.db, id); !ok {
+		return
+	}
+
+	// Get both revisions from the database
+	revisions := make([]*storage.ContentRevision, 2)
+	for i, number := range []int{from, to} {
+		rev, err := h.db.GetContentRevision(id, number)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to get content revision: " + err.Error(),
+			})
+			return
+		}
+		if rev == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Revision " + strconv.Itoa(number) + " not found",
+			})
+			return
+		}
+		revisions[i] = rev
+	}
+	oldRev, newRev := revisions[0], revisions[1]
+
+	// Return the diff
+	c.JSON(http.StatusOK, RevisionDiff{
+		ContentID:   id,
+		From:        from,
+		To:          to,
+		Title:       diff(oldRev.Title, newRev.Title),
+		Description: diff(oldRev.Description, newRev.Description),
+		Content:     diff(oldRev.Content, newRev.Content),
+	})
+}
+
+// RevertContentRevision handles POST /contents/:id/revisions/:revision/revert
+func (h *ContentsHandler) RevertContentRevision(c *gin.Context) {
+	// Get the content ID and revision number from the URL
+	id := c.Param("id")
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid revision number",
+		})
+		return
+	}
+
+	// Revert the user edit
+	content, err := h.db.RevertContentRevision(id, revision)
+	if errors.Is(err, storage.ErrRevisionNotRevertible) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revert content revision: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the revision exists
+	if content == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Revision not found",
+		})
+		return
+	}
+
+	h.bus.Publish(events.ContentUpdated, content)
+
+	// Return the reverted content
+	c.JSON(http.StatusOK, content)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// RoutingRulesHandler handles API requests for a user's routing rules
+type RoutingRulesHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewRoutingRulesHandler creates a new RoutingRulesHandler
+func NewRoutingRulesHandler(db *storage.SQLiteDB) *RoutingRulesHandler {
+	return &RoutingRulesHandler{
+		db: db,
+	}
+}
+
+// ListRoutingRules handles GET /routing-rules
+func (h *RoutingRulesHandler) ListRoutingRules(c *gin.Context) {
+	// Get the user's routing rules from the database
+	rules, err := h.db.ListRoutingRules(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list routing rules: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the routing rules in the order they are evaluated
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+	})
+}
+
+// CreateRoutingRule handles POST /routing-rules
+func (h *RoutingRulesHandler) CreateRoutingRule(c *gin.Context) {
+	// Parse the request body
+	var input storage.RoutingRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create the routing rule
+	rule, err := h.db.CreateRoutingRule(currentUser(c).ID, input)
+	if errors.Is(err, storage.ErrInvalidRoutingRule) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create routing rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created routing rule
+	c.JSON(http.StatusCreated, rule)
+}
+
+// GetRoutingRule handles GET /routing-rules/:id
+func (h *RoutingRulesHandler) GetRoutingRule(c *gin.Context) {
+	// Get the routing rule from the database
+	rule, err := h.db.GetRoutingRule(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get routing rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the routing rule exists
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Routing rule not found",
+		})
+		return
+	}
+
+	// Return the routing rule
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateRoutingRule handles PUT /routing-rules/:id
+func (h *RoutingRulesHandler) UpdateRoutingRule(c *gin.Context) {
+	// Parse the request body
+	var input storage.RoutingRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the routing rule
+	rule, err := h.db.UpdateRoutingRule(currentUser(c).ID, c.Param("id"), input)
+	if errors.Is(err, storage.ErrInvalidRoutingRule) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update routing rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the routing rule exists
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Routing rule not found",
+		})
+		return
+	}
+
+	// Return the updated routing rule
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRoutingRule handles DELETE /routing-rules/:id
+func (h *RoutingRulesHandler) DeleteRoutingRule(c *gin.Context) {
+	// Delete the routing rule
+	deleted, err := h.db.DeleteRoutingRule(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete routing rule: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the routing rule existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Routing rule not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Routing rule deleted",
+	})
+}
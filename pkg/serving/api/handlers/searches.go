@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// SavedSearchesHandler handles API requests for saved searches
+type SavedSearchesHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewSavedSearchesHandler creates a new SavedSearchesHandler
+func NewSavedSearchesHandler(db *storage.SQLiteDB) *SavedSearchesHandler {
+	return &SavedSearchesHandler{
+		db: db,
+	}
+}
+
+// ListSavedSearches handles GET /saved-searches
+func (h *SavedSearchesHandler) ListSavedSearches(c *gin.Context) {
+	// Get the user's saved searches from the database
+	searches, err := h.db.ListSavedSearches(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list saved searches: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the saved searches
+	c.JSON(http.StatusOK, gin.H{
+		"searches": searches,
+	})
+}
+
+// CreateSavedSearch handles POST /saved-searches
+func (h *SavedSearchesHandler) CreateSavedSearch(c *gin.Context) {
+	// Parse the request body
+	var input storage.SavedSearchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Save the search
+	search, err := h.db.CreateSavedSearch(currentUser(c).ID, input)
+	if isInvalidSavedSearch(err) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create saved search: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created saved search
+	c.JSON(http.StatusCreated, search)
+}
+
+// GetSavedSearch handles GET /saved-searches/:id
+func (h *SavedSearchesHandler) GetSavedSearch(c *gin.Context) {
+	// Get the saved search from the database
+	search, err := h.db.GetSavedSearch(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get saved search: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the saved search exists
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Saved search not found",
+		})
+		return
+	}
+
+	// Return the saved search
+	c.JSON(http.StatusOK, search)
+}
+
+// UpdateSavedSearch handles PUT /saved-searches/:id
+func (h *SavedSearchesHandler) UpdateSavedSearch(c *gin.Context) {
+	// Parse the request body
+	var input storage.SavedSearchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the saved search
+	search, err := h.db.UpdateSavedSearch(currentUser(c).ID, c.Param("id"), input)
+	if isInvalidSavedSearch(err) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update saved search: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the saved search exists
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Saved search not found",
+		})
+		return
+	}
+
+	// Return the updated saved search
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch handles DELETE /saved-searches/:id
+func (h *SavedSearchesHandler) DeleteSavedSearch(c *gin.Context) {
+	// Delete the saved search
+	deleted, err := h.db.DeleteSavedSearch(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete saved search: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the saved search existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Saved search not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Saved search deleted",
+	})
+}
+
+// RunSavedSearch handles GET /saved-searches/:id/results
+func (h *SavedSearchesHandler) RunSavedSearch(c *gin.Context) {
+	// Get the saved search from the database
+	search, err := h.db.GetSavedSearch(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get saved search: " + err.Error(),
+		})
+		return
+	}
+	if search == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Saved search not found",
+		})
+		return
+	}
+
+	// Run the search
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	contents, err := h.db.SearchContents(search.SearchInput(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to search contents: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the search results
+	c.JSON(http.StatusOK, gin.H{
+		"contents": contents,
+		"count":    len(contents),
+	})
+}
+
+// isInvalidSavedSearch reports whether err is a validation error of a saved search
+func isInvalidSavedSearch(err error) bool {
+	return errors.Is(err, storage.ErrInvalidSavedSearch) || errors.Is(err, storage.ErrInvalidTag)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -81,7 +82,9 @@ func (h *SourcesHandler) CreateSource(c *gin.Context) {
 
 	// Create the source
 	source, err := h.db.CreateSource(input)
-	if err != nil {
+	if respondTrashedSource(c, err) {
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create source: " + err.Error(),
 		})
@@ -108,7 +111,9 @@ func (h *SourcesHandler) UpdateSource(c *gin.Context) {
 
 	// Update the source
 	source, err := h.db.UpdateSource(id, input)
-	if err != nil {
+	if respondTrashedSource(c, err) {
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to update source: " + err.Error(),
 		})
@@ -133,7 +138,7 @@ func (h *SourcesHandler) DeleteSource(c *gin.Context) {
 	id := c.Param("id")
 
 	// Delete the source
-	err := h.db.DeleteSource(id)
+	found, err := h.db.DeleteSource(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete source: " + err.Error(),
@@ -141,9 +146,17 @@ func (h *SourcesHandler) DeleteSource(c *gin.Context) {
 		return
 	}
 
+	// Check if the source exists
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Source not found",
+		})
+		return
+	}
+
 	// Return success
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Source deleted successfully",
+		"message": "Source moved to trash",
 	})
 }
 
@@ -161,14 +174,34 @@ func (h *SourcesHandler) BatchCreateSources(c *gin.Context) {
 	// Create the sources
 	result, err := h.db.BatchCreateSources(input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to batch create sources: " + err.Error(),
+		respondBatchError(c, "batch create sources", err)
+		return
+	}
+
+	// Return the result
+	c.JSON(batchStatus(result.RolledBack), result)
+}
+
+// BatchUpdateSources handles PUT /sources/batch
+func (h *SourcesHandler) BatchUpdateSources(c *gin.Context) {
+	// Parse the request body
+	var input storage.BatchUpdateSourcesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
 		})
 		return
 	}
 
+	// Update the sources
+	result, err := h.db.BatchUpdateSources(input)
+	if err != nil {
+		respondBatchError(c, "batch update sources", err)
+		return
+	}
+
 	// Return the result
-	c.JSON(http.StatusOK, result)
+	c.JSON(batchStatus(result.RolledBack), result)
 }
 
 // BatchDeleteSources handles DELETE /sources/batch
@@ -185,12 +218,25 @@ func (h *SourcesHandler) BatchDeleteSources(c *gin.Context) {
 	// Delete the sources
 	result, err := h.db.BatchDeleteSources(input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to batch delete sources: " + err.Error(),
-		})
+		respondBatchError(c, "batch delete sources", err)
 		return
 	}
 
 	// Return the result
-	c.JSON(http.StatusOK, result)
+	c.JSON(batchStatus(result.RolledBack), result)
+}
+
+// respondTrashedSource responds with a conflict if err is a
+// storage.TrashedSourceError, pointing to the restore endpoint of the trashed
+// source. It reports whether it responded.
+func respondTrashedSource(c *gin.Context, err error) bool {
+	var trashed *storage.TrashedSourceError
+	if !errors.As(err, &trashed) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":    err.Error() + "; restore it with POST /trash/sources/" + trashed.SourceID + "/restore or purge it first",
+		"sourceId": trashed.SourceID,
+	})
+	return true
 }
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateContentState handles PUT /contents/:id/state
+func (h *ContentsHandler) UpdateContentState(c *gin.Context) {
+	// Get the content ID from the URL
+	id := c.Param("id")
+
+	// Parse the request body
+	var input storage.UpdateContentStateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Check that the user can see the content
+	if _, ok := getSubscribedContent(c, h.db, id); !ok {
+		return
+	}
+
+	// Update the user's reading state
+	state, err := h.db.UpdateContentState(currentUser(c).ID, id, input)
+	if errors.Is(err, storage.ErrInvalidContentState) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update content state: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the content exists
+	if state == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Content not found",
+		})
+		return
+	}
+
+	// Return the updated state
+	c.JSON(http.StatusOK, state)
+}
+
+// MarkContentsRead handles POST /contents/mark-read
+func (h *ContentsHandler) MarkContentsRead(c *gin.Context) {
+	// Parse the request body
+	var input storage.MarkReadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Check that the user can see the given contents
+	if !h.checkSubscribedContents(c, "mark contents as read", input.ContentIDs) {
+		return
+	}
+
+	// Mark the matching contents as read
+	marked, err := h.db.MarkContentsRead(currentUser(c).ID, input)
+	if err != nil {
+		respondBatchError(c, "mark contents as read", err)
+		return
+	}
+
+	// Return the number of contents marked
+	c.JSON(http.StatusOK, gin.H{
+		"markedCount": marked,
+	})
+}
+
+// GetUnreadCounts handles GET /contents/unread-counts
+func (h *ContentsHandler) GetUnreadCounts(c *gin.Context) {
+	// Count the user's unread contents
+	counts, err := h.db.GetUnreadCounts(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get unread counts: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the counts
+	c.JSON(http.StatusOK, counts)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// newStatesRouter serves the state and tag writes of a reader subscribed to
+// one of two sources. It returns the router, the database, the reader and a
+// content item of each source.
+func newStatesRouter(t *testing.T) (router http.Handler, db *storage.SQLiteDB, reader *storage.User, subscribed, other *storage.RSSContent) {
+	db = newTestDB(t)
+	reader = newTestUser(t, db, "reader", storage.RoleReader)
+	subscribed = newTestContent(t, db, "https://example.com/subscribed.xml")
+	other = newTestContent(t, db, "https://example.com/other.xml")
+	subscribe(t, db, reader.ID, subscribed.SourceID)
+
+	h := NewContentsHandler(db, nil, nil)
+	r := newTestRouter(reader)
+	r.PUT("/contents/:id/state", h.UpdateContentState)
+	r.POST("/contents/mark-read", h.MarkContentsRead)
+	r.POST("/contents/tags", h.BatchTagContents)
+	return r, db, reader, subscribed, other
+}
+
+func TestUpdateContentStateRequiresSubscription(t *testing.T) {
+	router, _, _, subscribed, other := newStatesRouter(t)
+	input := map[string]interface{}{"starred": true}
+
+	if w := serveJSON(t, router, http.MethodPut, "/contents/"+subscribed.ID+"/state", input); w.Code != http.StatusOK {
+		t.Fatalf("subscribed content: status = %d, body %s", w.Code, w.Body)
+	}
+	for name, id := range map[string]string{"unsubscribed": other.ID, "missing": "missing"} {
+		if w := serveJSON(t, router, http.MethodPut, "/contents/"+id+"/state", input); w.Code != http.StatusNotFound {
+			t.Errorf("%s content: status = %d, want 404", name, w.Code)
+		}
+	}
+}
+
+func TestMarkContentsReadRequiresSubscription(t *testing.T) {
+	router, db, reader, subscribed, other := newStatesRouter(t)
+
+	// Any unsubscribed item rejects the whole request
+	w := serveJSON(t, router, http.MethodPost, "/contents/mark-read", map[string]interface{}{
+		"contentIds": []string{subscribed.ID, other.ID},
+	})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	var body struct {
+		ContentIDs []string `json:"contentIds"`
+	}
+	decodeJSON(t, w, &body)
+	if len(body.ContentIDs) != 1 || body.ContentIDs[0] != other.ID {
+		t.Errorf("contentIds = %v, want [%s]", body.ContentIDs, other.ID)
+	}
+	counts, err := db.GetUnreadCounts(reader.ID)
+	if err != nil {
+		t.Fatalf("GetUnreadCounts() error = %v", err)
+	}
+	if counts.Total != 1 {
+		t.Errorf("unread = %d after rejected request, want 1", counts.Total)
+	}
+
+	w = serveJSON(t, router, http.MethodPost, "/contents/mark-read", map[string]interface{}{
+		"contentIds": []string{subscribed.ID},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var result struct {
+		MarkedCount int `json:"markedCount"`
+	}
+	decodeJSON(t, w, &result)
+	if result.MarkedCount != 1 {
+		t.Errorf("markedCount = %d, want 1", result.MarkedCount)
+	}
+}
+
+func TestBatchTagContentsRequiresSubscription(t *testing.T) {
+	router, _, _, subscribed, other := newStatesRouter(t)
+
+	w := serveJSON(t, router, http.MethodPost, "/contents/tags", map[string]interface{}{
+		"contentIds": []string{other.ID},
+		"add":        []string{"later"},
+	})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unsubscribed content: status = %d, want 404", w.Code)
+	}
+
+	w = serveJSON(t, router, http.MethodPost, "/contents/tags", map[string]interface{}{
+		"contentIds": []string{subscribed.ID},
+		"add":        []string{"later"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("subscribed content: status = %d, body %s", w.Code, w.Body)
+	}
+	var result storage.BatchTagContentsResult
+	decodeJSON(t, w, &result)
+	if result.Added != 1 {
+		t.Errorf("added = %d, want 1", result.Added)
+	}
+}
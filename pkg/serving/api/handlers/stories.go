@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxStoriesLimit is the largest number of stories listed at once
+const maxStoriesLimit = 100
+
+// StoriesHandler handles API requests for stories of near-duplicate content
+type StoriesHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewStoriesHandler creates a new StoriesHandler
+func NewStoriesHandler(db *storage.SQLiteDB) *StoriesHandler {
+	return &StoriesHandler{
+		db: db,
+	}
+}
+
+// ListStories handles GET /stories
+func (h *StoriesHandler) ListStories(c *gin.Context) {
+	// Parse the limit
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxStoriesLimit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit. Use a number between 1 and " + strconv.Itoa(maxStoriesLimit),
+		})
+		return
+	}
+
+	// Get the stories among the user's subscriptions
+	stories, err := h.db.ListStories(currentUser(c).ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list stories: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the stories, most recently published first
+	c.JSON(http.StatusOK, gin.H{
+		"stories": stories,
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionsHandler handles API requests for the authenticated user's subscriptions
+type SubscriptionsHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewSubscriptionsHandler creates a new SubscriptionsHandler
+func NewSubscriptionsHandler(db *storage.SQLiteDB) *SubscriptionsHandler {
+	return &SubscriptionsHandler{
+		db: db,
+	}
+}
+
+// ListSubscriptions handles GET /subscriptions
+func (h *SubscriptionsHandler) ListSubscriptions(c *gin.Context) {
+	// Get the user's subscriptions from the database
+	subscriptions, err := h.db.ListSubscriptions(currentUser(c).ID, c.Query("folder"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list subscriptions: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the subscriptions
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subscriptions,
+	})
+}
+
+// GetSubscription handles GET /subscriptions/:id
+func (h *SubscriptionsHandler) GetSubscription(c *gin.Context) {
+	// Get the subscription ID from the URL
+	id := c.Param("id")
+
+	// Get the subscription from the database
+	subscription, err := h.db.GetSubscription(currentUser(c).ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the subscription exists
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Subscription not found",
+		})
+		return
+	}
+
+	// Return the subscription
+	c.JSON(http.StatusOK, subscription)
+}
+
+// CreateSubscription handles POST /subscriptions
+func (h *SubscriptionsHandler) CreateSubscription(c *gin.Context) {
+	// Parse the request body
+	var input storage.CreateSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate required fields
+	if input.SourceID == "" && input.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "sourceId or url is required",
+		})
+		return
+	}
+
+	// Create the subscription. Subscribing to a new URL adds a shared
+	// source, which is reserved to editors like POST /sources.
+	user := currentUser(c)
+	input.AllowNewSource = user.HasRole(storage.RoleEditor)
+	subscription, err := h.db.CreateSubscription(user.ID, input)
+	if respondTrashedSource(c, err) {
+		return
+	} else if errors.Is(err, storage.ErrSourceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Source not found",
+		})
+		return
+	} else if errors.Is(err, storage.ErrNewSourceNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error() + "; ask an editor to add the feed first",
+		})
+		return
+	} else if errors.Is(err, storage.ErrAlreadySubscribed) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created subscription
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// UpdateSubscription handles PUT /subscriptions/:id
+func (h *SubscriptionsHandler) UpdateSubscription(c *gin.Context) {
+	// Get the subscription ID from the URL
+	id := c.Param("id")
+
+	// Parse the request body
+	var input storage.UpdateSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the subscription
+	subscription, err := h.db.UpdateSubscription(currentUser(c).ID, id, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the subscription exists
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Subscription not found",
+		})
+		return
+	}
+
+	// Return the updated subscription
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteSubscription handles DELETE /subscriptions/:id
+func (h *SubscriptionsHandler) DeleteSubscription(c *gin.Context) {
+	// Get the subscription ID from the URL
+	id := c.Param("id")
+
+	// Unsubscribe
+	deleted, err := h.db.DeleteSubscription(currentUser(c).ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete subscription: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the subscription existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Subscription not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Unsubscribed successfully",
+	})
+}
+
+// ExportOPML handles GET /subscriptions/export.opml
+func (h *SubscriptionsHandler) ExportOPML(c *gin.Context) {
+	user := currentUser(c)
+
+	// Get the user's subscriptions from the database
+	subscriptions, err := h.db.ListSubscriptions(user.ID, c.Query("folder"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list subscriptions: " + err.Error(),
+		})
+		return
+	}
+
+	// Render the subscriptions as OPML
+	feeds := make([]riffle.Feed, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		feeds = append(feeds, riffle.Feed{
+			Title:  subscription.Title(),
+			URL:    subscription.Source.URL,
+			Folder: subscription.Folder,
+		})
+	}
+	data, err := riffle.MarshalOPML("Riffle subscriptions of "+user.Username, feeds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export subscriptions: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the OPML document as a download
+	c.Header("Content-Disposition", `attachment; filename="subscriptions.opml"`)
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", data)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+func TestCreateSubscriptionToNewURL(t *testing.T) {
+	db := newTestDB(t)
+	content := newTestContent(t, db, "https://example.com/existing.xml")
+	h := NewSubscriptionsHandler(db)
+
+	tests := []struct {
+		role string
+		url  string
+		want int
+	}{
+		{storage.RoleReader, "https://example.com/existing.xml", http.StatusCreated},
+		{storage.RoleReader, "https://example.com/new.xml", http.StatusForbidden},
+		{storage.RoleEditor, "https://example.com/new.xml", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		router := newTestRouter(newTestUser(t, db, tt.role+"-"+tt.url, tt.role))
+		router.POST("/subscriptions", h.CreateSubscription)
+
+		w := serveJSON(t, router, http.MethodPost, "/subscriptions", map[string]string{"url": tt.url})
+		if w.Code != tt.want {
+			t.Errorf("%s subscribing to %s: status = %d, want %d (body %s)", tt.role, tt.url, w.Code, tt.want, w.Body)
+		}
+	}
+
+	// Subscribing by ID refuses sources in the trash
+	if _, err := db.DeleteSource(content.SourceID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	router := newTestRouter(newTestUser(t, db, "late-reader", storage.RoleReader))
+	router.POST("/subscriptions", h.CreateSubscription)
+	w := serveJSON(t, router, http.MethodPost, "/subscriptions", map[string]string{"sourceId": content.SourceID})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("subscribing to a trashed source: status = %d, want 404", w.Code)
+	}
+}
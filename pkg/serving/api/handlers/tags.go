@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// BatchTagContents handles POST /contents/tags
+func (h *ContentsHandler) BatchTagContents(c *gin.Context) {
+	// Parse the request body
+	var input storage.BatchTagContentsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Check that the user can see the given contents
+	if !h.checkSubscribedContents(c, "tag contents", input.ContentIDs) {
+		return
+	}
+
+	// Add and remove the user's tags
+	result, err := h.db.BatchTagContents(currentUser(c).ID, input)
+	if errors.Is(err, storage.ErrInvalidTag) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		respondBatchError(c, "tag contents", err)
+		return
+	}
+
+	// Return the number of tags added and removed
+	c.JSON(http.StatusOK, result)
+}
+
+// ListTags handles GET /tags
+func (h *ContentsHandler) ListTags(c *gin.Context) {
+	// Get the user's tags from the database
+	tags, err := h.db.ListTags(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list tags: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the tags
+	c.JSON(http.StatusOK, gin.H{
+		"tags": tags,
+	})
+}
+
+// DeleteTag handles DELETE /tags/:tag
+func (h *ContentsHandler) DeleteTag(c *gin.Context) {
+	// Remove the tag from all of the user's contents
+	removed, err := h.db.DeleteTag(currentUser(c).ID, c.Param("tag"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete tag: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the tag exists
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Tag not found",
+		})
+		return
+	}
+
+	// Return the number of contents the tag was removed from
+	c.JSON(http.StatusOK, gin.H{
+		"removedCount": removed,
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// TrashHandler handles API requests for trashed sources and contents
+type TrashHandler struct {
+	db *storage.SQLiteDB
+}
+
+// NewTrashHandler creates a new TrashHandler
+func NewTrashHandler(db *storage.SQLiteDB) *TrashHandler {
+	return &TrashHandler{
+		db: db,
+	}
+}
+
+// ListTrashedSources handles GET /trash/sources
+func (h *TrashHandler) ListTrashedSources(c *gin.Context) {
+	// Parse query parameters
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	nextToken := c.Query("nextToken")
+
+	// Get trashed sources from the database
+	sources, newNextToken, err := h.db.ListTrashedSources(limit, nextToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list trashed sources: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the sources
+	c.JSON(http.StatusOK, gin.H{
+		"sources":   sources,
+		"nextToken": newNextToken,
+	})
+}
+
+// ListTrashedContents handles GET /trash/contents
+func (h *TrashHandler) ListTrashedContents(c *gin.Context) {
+	// Parse query parameters
+	sourceID := c.Query("sourceId")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	nextToken := c.Query("nextToken")
+
+	// Get trashed contents from the database
+	contents, newNextToken, err := h.db.ListTrashedContents(sourceID, limit, nextToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list trashed contents: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the contents
+	c.JSON(http.StatusOK, gin.H{
+		"contents":  contents,
+		"nextToken": newNextToken,
+	})
+}
+
+// RestoreSource handles POST /trash/sources/:id/restore
+func (h *TrashHandler) RestoreSource(c *gin.Context) {
+	// Get the source ID from the URL
+	id := c.Param("id")
+
+	// Restore the source
+	restored, err := h.db.RestoreSource(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to restore source: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the source was in the trash
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Source not found in trash",
+		})
+		return
+	}
+
+	// Return the restored source
+	source, err := h.db.GetSource(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get source: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, source)
+}
+
+// RestoreContent handles POST /trash/contents/:id/restore
+func (h *TrashHandler) RestoreContent(c *gin.Context) {
+	// Get the content ID from the URL
+	id := c.Param("id")
+
+	// Restore the content
+	restored, err := h.db.RestoreContent(id)
+	if errors.Is(err, storage.ErrSourceDeleted) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to restore content: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the content was in the trash
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Content not found in trash",
+		})
+		return
+	}
+
+	// Return the restored content
+	content, err := h.db.GetContent(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get content: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, content)
+}
+
+// PurgeSource handles DELETE /trash/sources/:id
+func (h *TrashHandler) PurgeSource(c *gin.Context) {
+	// Get the source ID from the URL
+	id := c.Param("id")
+
+	// Permanently delete the source
+	purged, err := h.db.PurgeSource(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to purge source: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the source was in the trash
+	if !purged {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Source not found in trash",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Source permanently deleted",
+	})
+}
+
+// PurgeContent handles DELETE /trash/contents/:id
+func (h *TrashHandler) PurgeContent(c *gin.Context) {
+	// Get the content ID from the URL
+	id := c.Param("id")
+
+	// Permanently delete the content
+	purged, err := h.db.PurgeContent(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to purge content: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the content was in the trash
+	if !purged {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Content not found in trash",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Content permanently deleted",
+	})
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// UsersHandler handles API requests for user accounts
+type UsersHandler struct {
+	db *storage.SQLiteDB
+}
+
+// UpdatePasswordInput represents the input for changing a password
+type UpdatePasswordInput struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// NewUsersHandler creates a new UsersHandler
+func NewUsersHandler(db *storage.SQLiteDB) *UsersHandler {
+	return &UsersHandler{
+		db: db,
+	}
+}
+
+// GetCurrentUser handles GET /users/me
+func (h *UsersHandler) GetCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, currentUser(c))
+}
+
+// UpdatePassword handles PUT /users/me/password
+func (h *UsersHandler) UpdatePassword(c *gin.Context) {
+	user := currentUser(c)
+
+	// Parse the request body
+	var input UpdatePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Check the current password
+	authenticated, err := h.db.AuthenticateUser(user.Username, input.CurrentPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update password: " + err.Error(),
+		})
+		return
+	}
+	if authenticated == nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Current password is incorrect",
+		})
+		return
+	}
+
+	// Update the password
+	err = h.db.UpdateUserPassword(user.ID, input.NewPassword)
+	if errors.Is(err, storage.ErrInvalidPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update password: " + err.Error(),
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password updated, all sessions have been signed out",
+	})
+}
+
+// ListAPITokens handles GET /users/me/tokens
+func (h *UsersHandler) ListAPITokens(c *gin.Context) {
+	// Get the user's tokens from the database
+	tokens, err := h.db.ListAPITokens(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list API tokens: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the tokens
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+	})
+}
+
+// CreateAPIToken handles POST /users/me/tokens
+func (h *UsersHandler) CreateAPIToken(c *gin.Context) {
+	// Parse the request body
+	var input storage.CreateAPITokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate required fields
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "name is required",
+		})
+		return
+	}
+
+	// Create the token
+	token, secret, err := h.db.CreateAPIToken(currentUser(c).ID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create API token: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created token; the secret is only shown once
+	c.JSON(http.StatusCreated, gin.H{
+		"token":  token,
+		"secret": secret,
+	})
+}
+
+// DeleteAPIToken handles DELETE /users/me/tokens/:id
+func (h *UsersHandler) DeleteAPIToken(c *gin.Context) {
+	// Get the token ID from the URL
+	id := c.Param("id")
+
+	// Revoke the token
+	deleted, err := h.db.DeleteAPIToken(currentUser(c).ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete API token: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the token existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "API token not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API token deleted",
+	})
+}
+
+// GetFeedToken handles GET /users/me/feed-token
+func (h *UsersHandler) GetFeedToken(c *gin.Context) {
+	// Get the feed token from the database
+	token, err := h.db.GetFeedToken(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get feed token: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the user has a feed token
+	if token == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Feed token not found",
+		})
+		return
+	}
+
+	// Return the token without its secret
+	c.JSON(http.StatusOK, token)
+}
+
+// CreateFeedToken handles POST /users/me/feed-token
+func (h *UsersHandler) CreateFeedToken(c *gin.Context) {
+	// Create the token, replacing the previous one
+	token, secret, err := h.db.CreateFeedToken(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create feed token: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created token; the secret is only shown once
+	c.JSON(http.StatusCreated, gin.H{
+		"token":  token,
+		"secret": secret,
+	})
+}
+
+// DeleteFeedToken handles DELETE /users/me/feed-token
+func (h *UsersHandler) DeleteFeedToken(c *gin.Context) {
+	// Revoke the token
+	deleted, err := h.db.DeleteFeedToken(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete feed token: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the token existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Feed token not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Feed token deleted",
+	})
+}
+
+// UpdateUserRoleInput represents the input for changing a user's role
+type UpdateUserRoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ListUsers handles GET /users
+func (h *UsersHandler) ListUsers(c *gin.Context) {
+	// Get the users from the database
+	users, err := h.db.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list users: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the users
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+	})
+}
+
+// UpdateUserRole handles PUT /users/:id/role
+func (h *UsersHandler) UpdateUserRole(c *gin.Context) {
+	// Parse the request body
+	var input UpdateUserRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the role in the database
+	user, err := h.db.SetUserRole(c.Param("id"), input.Role)
+	if errors.Is(err, storage.ErrInvalidRole) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if errors.Is(err, storage.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update user role: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the user exists
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	// Return the updated user
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /users/:id
+func (h *UsersHandler) DeleteUser(c *gin.Context) {
+	// Delete the user from the database
+	deleted, err := h.db.DeleteUser(c.Param("id"))
+	if errors.Is(err, storage.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete user: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the user exists
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	// Return success message
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User deleted successfully",
+	})
+}
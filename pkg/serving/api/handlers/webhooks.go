@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/flyer103/riffle/pkg/serving/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhooksHandler handles API requests for webhooks
+type WebhooksHandler struct {
+	db         *storage.SQLiteDB
+	dispatcher *webhooks.Dispatcher
+}
+
+// NewWebhooksHandler creates a new WebhooksHandler
+func NewWebhooksHandler(db *storage.SQLiteDB, dispatcher *webhooks.Dispatcher) *WebhooksHandler {
+	return &WebhooksHandler{
+		db:         db,
+		dispatcher: dispatcher,
+	}
+}
+
+// ListWebhooks handles GET /webhooks
+func (h *WebhooksHandler) ListWebhooks(c *gin.Context) {
+	// Get the user's webhooks from the database
+	hooks, err := h.db.ListWebhooks(currentUser(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list webhooks: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the webhooks
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": hooks,
+	})
+}
+
+// CreateWebhook handles POST /webhooks
+func (h *WebhooksHandler) CreateWebhook(c *gin.Context) {
+	// Parse the request body
+	var input storage.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create the webhook
+	webhook, err := h.db.CreateWebhook(currentUser(c).ID, input)
+	if errors.Is(err, storage.ErrInvalidWebhook) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create webhook: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the created webhook; the secret is only shown once
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+// GetWebhook handles GET /webhooks/:id
+func (h *WebhooksHandler) GetWebhook(c *gin.Context) {
+	webhook, ok := h.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	// Return the webhook
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook handles PUT /webhooks/:id
+func (h *WebhooksHandler) UpdateWebhook(c *gin.Context) {
+	// Parse the request body
+	var input storage.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Update the webhook
+	webhook, err := h.db.UpdateWebhook(currentUser(c).ID, c.Param("id"), input)
+	if errors.Is(err, storage.ErrInvalidWebhook) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update webhook: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the webhook exists
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Webhook not found",
+		})
+		return
+	}
+
+	// Return the updated webhook
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/:id
+func (h *WebhooksHandler) DeleteWebhook(c *gin.Context) {
+	// Delete the webhook
+	deleted, err := h.db.DeleteWebhook(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete webhook: " + err.Error(),
+		})
+		return
+	}
+
+	// Check if the webhook existed
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Webhook not found",
+		})
+		return
+	}
+
+	// Return success
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted",
+	})
+}
+
+// ListWebhookDeliveries handles GET /webhooks/:id/deliveries
+func (h *WebhooksHandler) ListWebhookDeliveries(c *gin.Context) {
+	webhook, ok := h.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	// Get the delivery log from the database
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	deliveries, err := h.db.ListWebhookDeliveries(webhook.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list webhook deliveries: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the deliveries
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+	})
+}
+
+// TestWebhook handles POST /webhooks/:id/test
+func (h *WebhooksHandler) TestWebhook(c *gin.Context) {
+	webhook, ok := h.getWebhook(c)
+	if !ok {
+		return
+	}
+
+	// Send a test event and wait for the response
+	delivery, err := h.dispatcher.Test(webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to send test delivery: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the delivery, which records whether the webhook accepted it
+	c.JSON(http.StatusOK, delivery)
+}
+
+// getWebhook gets the current user's webhook named in the URL, responding
+// with an error if it cannot
+func (h *WebhooksHandler) getWebhook(c *gin.Context) (*storage.Webhook, bool) {
+	// Get the webhook from the database
+	webhook, err := h.db.GetWebhook(currentUser(c).ID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get webhook: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	// Check if the webhook exists
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Webhook not found",
+		})
+		return nil, false
+	}
+
+	return webhook, true
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/flyer103/riffle/pkg/serving/webhooks"
+)
+
+func TestTestWebhook(t *testing.T) {
+	status := http.StatusOK
+	var signature string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(webhooks.SignatureHeader)
+		w.WriteHeader(status)
+	}))
+	defer endpoint.Close()
+
+	db := newTestDB(t)
+	alice := newTestUser(t, db, "alice", storage.RoleReader)
+	bob := newTestUser(t, db, "bob", storage.RoleReader)
+	webhook, err := db.CreateWebhook(alice.ID, storage.WebhookInput{URL: endpoint.URL, Events: []string{events.ContentCreated}})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	loopback, err := webhooks.ParseNetworks([]string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("ParseNetworks() error = %v", err)
+	}
+	h := NewWebhooksHandler(db, webhooks.NewDispatcher(webhooks.Config{AllowedNetworks: loopback}, db, events.NewBus(10)))
+	router := newTestRouter(alice)
+	router.POST("/webhooks/:id/test", h.TestWebhook)
+
+	// The response is the signed delivery the endpoint accepted
+	w := serveJSON(t, router, http.MethodPost, "/webhooks/"+webhook.ID+"/test", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var delivery storage.WebhookDelivery
+	decodeJSON(t, w, &delivery)
+	if delivery.Status != storage.DeliverySucceeded || delivery.Event != webhooks.TestEvent || delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("delivery = %+v, want a succeeded %s delivery", delivery, webhooks.TestEvent)
+	}
+	if signature == "" {
+		t.Error("test delivery was not signed")
+	}
+
+	// A rejected test delivery is still a successful request, and is not retried
+	status = http.StatusInternalServerError
+	w = serveJSON(t, router, http.MethodPost, "/webhooks/"+webhook.ID+"/test", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("rejected delivery: status = %d, body %s", w.Code, w.Body)
+	}
+	decodeJSON(t, w, &delivery)
+	if delivery.Status != storage.DeliveryFailed || delivery.ResponseStatus != http.StatusInternalServerError || delivery.NextAttemptAt != nil {
+		t.Errorf("rejected delivery = %+v, want failed without a retry", delivery)
+	}
+	deliveries, err := db.ListWebhookDeliveries(webhook.ID, 10)
+	if err != nil || len(deliveries) != 2 {
+		t.Errorf("ListWebhookDeliveries() = %d deliveries, %v, want 2", len(deliveries), err)
+	}
+
+	// Other users cannot test the webhook
+	other := newTestRouter(bob)
+	other.POST("/webhooks/:id/test", h.TestWebhook)
+	if w := serveJSON(t, other, http.MethodPost, "/webhooks/"+webhook.ID+"/test", nil); w.Code != http.StatusNotFound {
+		t.Errorf("other user: status = %d, want 404", w.Code)
+	}
+	if w := serveJSON(t, router, http.MethodPost, "/webhooks/missing/test", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing webhook: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// SessionCookieName is the name of the cookie holding the session token
+	SessionCookieName = "riffle_session"
+
+	// AuthMethodToken marks a principal authenticated with an API token
+	AuthMethodToken = "token"
+	// AuthMethodSession marks a principal authenticated with a session cookie
+	AuthMethodSession = "session"
+	// AuthMethodOIDC marks a principal authenticated with a JWT from an identity provider
+	AuthMethodOIDC = "oidc"
+	// AuthMethodFeed marks a principal authenticated with a feed token
+	AuthMethodFeed = "feed"
+
+	// FeedTokenParam is the query parameter holding a feed token
+	FeedTokenParam = "token"
+
+	// principalKey is the gin context key the principal is stored under
+	principalKey = "riffle.principal"
+)
+
+// Principal is the authenticated identity making a request
+type Principal struct {
+	User   *storage.User
+	Method string
+}
+
+// GetPrincipal returns the authenticated principal of a request, or nil if
+// the request is not authenticated
+func GetPrincipal(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(*Principal)
+	}
+	return nil
+}
+
+// SetPrincipal stores the authenticated principal of a request
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// BearerTokenVerifier authenticates bearer tokens that are not riffle API
+// tokens, such as JWTs issued by an identity provider. It returns nil if the
+// token is not valid.
+type BearerTokenVerifier interface {
+	VerifyBearerToken(ctx context.Context, token string) (*storage.User, error)
+}
+
+// Auth is a middleware that authenticates requests with either an API token
+// in the Authorization header ("Bearer <token>") or a session cookie. If
+// bearer is not nil, bearer tokens that are not API tokens are passed to it.
+// Requests without valid credentials are rejected with 401.
+func Auth(db *storage.SQLiteDB, bearer BearerTokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user *storage.User
+		var method string
+		var err error
+
+		if header := c.GetHeader("Authorization"); header != "" {
+			// Authenticate with an API token
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				abortUnauthorized(c, "Authorization header must use the Bearer scheme")
+				return
+			}
+			token = strings.TrimSpace(token)
+			if bearer != nil && !strings.HasPrefix(token, storage.APITokenPrefix) {
+				user, err = bearer.VerifyBearerToken(c.Request.Context(), token)
+				method = AuthMethodOIDC
+			} else {
+				user, err = db.GetUserByAPIToken(token)
+				method = AuthMethodToken
+			}
+		} else if cookie, cookieErr := c.Cookie(SessionCookieName); cookieErr == nil && cookie != "" {
+			// Authenticate with a session cookie
+			user, err = db.GetUserBySession(cookie)
+			method = AuthMethodSession
+		} else {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		if err != nil {
+			klog.Errorf("Failed to authenticate request: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to authenticate request",
+			})
+			return
+		}
+		if user == nil {
+			abortUnauthorized(c, "Invalid or expired credentials")
+			return
+		}
+
+		SetPrincipal(c, &Principal{User: user, Method: method})
+		c.Next()
+	}
+}
+
+// FeedAuth is a middleware for the published feeds, which feed readers
+// fetch without other credentials. It authenticates requests with a feed
+// token in the token query parameter and falls back to Auth otherwise.
+func FeedAuth(db *storage.SQLiteDB, bearer BearerTokenVerifier) gin.HandlerFunc {
+	auth := Auth(db, bearer)
+	return func(c *gin.Context) {
+		token := c.Query(FeedTokenParam)
+		if token == "" {
+			auth(c)
+			return
+		}
+
+		user, err := db.GetUserByFeedToken(token)
+		if err != nil {
+			klog.Errorf("Failed to authenticate request: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to authenticate request",
+			})
+			return
+		}
+		if user == nil {
+			abortUnauthorized(c, "Invalid feed token")
+			return
+		}
+
+		SetPrincipal(c, &Principal{User: user, Method: AuthMethodFeed})
+		c.Next()
+	}
+}
+
+// abortUnauthorized rejects a request with 401
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="riffle"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error": message,
+	})
+}
+
+// RequireRole is a middleware that rejects requests with 403 unless the
+// authenticated user has the given role or a more privileged one. It must
+// run after Auth.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		if principal == nil {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+		if !principal.User.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "This operation requires the " + role + " role",
+			})
+			return
+		}
+		c.Next()
+	}
+}
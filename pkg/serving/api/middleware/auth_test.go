@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// testUsers are the users of a test database and their credentials
+type testUsers struct {
+	db            *storage.SQLiteDB
+	readerToken   string
+	readerSession string
+	editorToken   string
+}
+
+// newTestUsers opens a temporary database with a reader and an editor
+func newTestUsers(t *testing.T) *testUsers {
+	t.Helper()
+	db, err := storage.NewSQLiteDB(filepath.Join(t.TempDir(), "riffle.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	users := &testUsers{db: db}
+	for _, role := range []string{storage.RoleReader, storage.RoleEditor} {
+		user, err := db.CreateUser(storage.CreateUserInput{Username: role, Password: "password123", Role: role})
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", role, err)
+		}
+		_, secret, err := db.CreateAPIToken(user.ID, storage.CreateAPITokenInput{Name: "test"})
+		if err != nil {
+			t.Fatalf("failed to create token of %s: %v", role, err)
+		}
+		if role == storage.RoleReader {
+			users.readerToken = secret
+			if _, users.readerSession, err = db.CreateSession(user.ID, time.Hour); err != nil {
+				t.Fatalf("failed to create session: %v", err)
+			}
+		} else {
+			users.editorToken = secret
+		}
+	}
+	return users
+}
+
+// staticVerifier accepts a single bearer token that is not an API token
+type staticVerifier struct {
+	token string
+	user  *storage.User
+}
+
+func (v *staticVerifier) VerifyBearerToken(_ context.Context, token string) (*storage.User, error) {
+	if token == v.token {
+		return v.user, nil
+	}
+	return nil, nil
+}
+
+// newTestRouter serves GET /whoami behind the given middlewares, responding
+// with the principal's username and authentication method
+func newTestRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", append(middlewares, func(c *gin.Context) {
+		principal := GetPrincipal(c)
+		c.String(http.StatusOK, principal.User.Username+" "+principal.Method)
+	})...)
+	return router
+}
+
+// serve sends a GET /whoami request through a router
+func serve(router http.Handler, header, cookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: cookie})
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth(t *testing.T) {
+	users := newTestUsers(t)
+	verifier := &staticVerifier{token: "header.payload.signature", user: &storage.User{Username: "sso", Role: storage.RoleReader}}
+	router := newTestRouter(Auth(users.db, verifier))
+
+	tests := []struct {
+		name     string
+		header   string
+		cookie   string
+		wantCode int
+		wantBody string
+	}{
+		{"api token", "Bearer " + users.readerToken, "", http.StatusOK, "reader token"},
+		{"session cookie", "", users.readerSession, http.StatusOK, "reader session"},
+		{"header wins over cookie", "Bearer " + users.editorToken, users.readerSession, http.StatusOK, "editor token"},
+		{"jwt", "Bearer header.payload.signature", "", http.StatusOK, "sso oidc"},
+		{"no credentials", "", "", http.StatusUnauthorized, ""},
+		{"basic scheme", "Basic YWxpY2U6cGFzc3dvcmQ=", "", http.StatusUnauthorized, ""},
+		{"unknown api token", "Bearer " + storage.APITokenPrefix + "unknown", "", http.StatusUnauthorized, ""},
+		{"unknown jwt", "Bearer other.payload.signature", "", http.StatusUnauthorized, ""},
+		{"unknown session", "", "unknown", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, tt.header, tt.cookie)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("principal = %q, want %q", w.Body, tt.wantBody)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAuthWithoutBearerVerifier(t *testing.T) {
+	users := newTestUsers(t)
+	router := newTestRouter(Auth(users.db, nil))
+
+	// Without single sign-on, every bearer token is looked up as an API token
+	if w := serve(router, "Bearer header.payload.signature", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := serve(router, "Bearer "+users.readerToken, ""); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	users := newTestUsers(t)
+
+	tests := []struct {
+		name     string
+		role     string
+		token    string
+		wantCode int
+	}{
+		{"reader for reader route", storage.RoleReader, users.readerToken, http.StatusOK},
+		{"reader for editor route", storage.RoleEditor, users.readerToken, http.StatusForbidden},
+		{"editor for editor route", storage.RoleEditor, users.editorToken, http.StatusOK},
+		{"editor for admin route", storage.RoleAdmin, users.editorToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(Auth(users.db, nil), RequireRole(tt.role))
+			if w := serve(router, "Bearer "+tt.token, ""); w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+
+	// Without Auth there is no principal to check
+	router := newTestRouter(RequireRole(storage.RoleReader))
+	if w := serve(router, "Bearer "+users.readerToken, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("status without Auth = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestFeedAuth(t *testing.T) {
+	users := newTestUsers(t)
+	reader, err := users.db.GetUserByAPIToken(users.readerToken)
+	if err != nil || reader == nil {
+		t.Fatalf("GetUserByAPIToken() = %v, %v", reader, err)
+	}
+	_, feedToken, err := users.db.CreateFeedToken(reader.ID)
+	if err != nil {
+		t.Fatalf("CreateFeedToken() error = %v", err)
+	}
+	router := newTestRouter(FeedAuth(users.db, nil))
+
+	tests := []struct {
+		name     string
+		query    string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{"feed token", "?" + FeedTokenParam + "=" + feedToken, "", http.StatusOK, "reader feed"},
+		{"api token", "", "Bearer " + users.editorToken, http.StatusOK, "editor token"},
+		{"feed token wins over header", "?" + FeedTokenParam + "=" + feedToken, "Bearer " + users.editorToken, http.StatusOK, "reader feed"},
+		{"unknown feed token", "?" + FeedTokenParam + "=unknown", "Bearer " + users.editorToken, http.StatusUnauthorized, ""},
+		{"api token as feed token", "?" + FeedTokenParam + "=" + users.readerToken, "", http.StatusUnauthorized, ""},
+		{"no credentials", "", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("principal = %q, want %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+
+	// Feed tokens are not accepted by the rest of the API
+	if w := serve(newTestRouter(Auth(users.db, nil)), "Bearer "+feedToken, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("feed token as API token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,11 +31,11 @@ func Logger() gin.HandlerFunc {
 		clientIP := c.ClientIP()
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + redactQuery(raw)
 		}
 
 		// Log the request
-		klog.Infof("[GIN] %s | %3d | %13v | %15s | %-7s %s",
+		klog.Infof("[GIN] %3d | %13v | %15s | %-7s %s",
 			statusCode,
 			latency,
 			clientIP,
@@ -43,6 +45,58 @@ func Logger() gin.HandlerFunc {
 	}
 }
 
+// redactedQueryParams are the query parameters that carry credentials: feed
+// and digest tokens, and the code and state of OIDC callbacks
+var redactedQueryParams = []string{FeedTokenParam, "code", "state"}
+
+// redactQuery returns a raw query string with the values of
+// redactedQueryParams replaced, so that credentials do not end up in logs
+func redactQuery(raw string) string {
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		return "REDACTED"
+	}
+	redacted := false
+	for _, param := range redactedQueryParams {
+		if _, ok := query[param]; ok {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return raw
+	}
+	return query.Encode()
+}
+
+// LogFormatter formats request logs like gin's default formatter, but with
+// the credentials in query strings redacted
+func LogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	path := param.Path
+	if base, raw, ok := strings.Cut(path, "?"); ok {
+		path = base + "?" + redactQuery(raw)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		path,
+		param.ErrorMessage,
+	)
+}
+
 // Recovery is a middleware that recovers from panics
 func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"limit=10", "limit=10"},
+		{"token=rff_secret", "token=REDACTED"},
+		{"limit=10&token=rff_secret", "limit=10&token=REDACTED"},
+		{"code=abc&state=xyz", "code=REDACTED&state=REDACTED"},
+		{"token=a&token=b", "token=REDACTED"},
+		{"token=%zz", "REDACTED"},
+	}
+	for _, tt := range tests {
+		if got := redactQuery(tt.raw); got != tt.want {
+			t.Errorf("redactQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLogFormatterRedactsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	router := gin.New()
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: LogFormatter, Output: &out}))
+	router.GET("/feeds/recommendations.rss", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/feeds/recommendations.rss?limit=10&token=rff_secret", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := out.String()
+	if strings.Contains(line, "rff_secret") {
+		t.Errorf("log line %q contains the feed token", line)
+	}
+	if !strings.Contains(line, `"/feeds/recommendations.rss?limit=10&token=REDACTED"`) || !strings.Contains(line, " 200 ") {
+		t.Errorf("log line %q does not show the request", line)
+	}
+}
@@ -0,0 +1,291 @@
+// Package digest emails users periodic digests of their top recommendations
+// over SMTP.
+package digest
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"k8s.io/klog/v2"
+)
+
+// checkInterval is how often the scheduler looks for digests that are due
+const checkInterval = time.Minute
+
+// Config configures the SMTP server and the digests
+type Config struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// From is the sender address, such as "riffle <riffle@example.com>"
+	From string
+	// PublicURL is the URL the server is reachable at, used to build the
+	// unsubscribe and confirmation links
+	PublicURL string
+	// Items is the maximum number of recommendations in a digest
+	Items int
+	// HTMLTemplate and TextTemplate are paths of templates replacing the
+	// built-in ones
+	HTMLTemplate string
+	TextTemplate string
+}
+
+// Sender builds digests from recommendations and sends them by email
+type Sender struct {
+	config    Config
+	db        *storage.SQLiteDB
+	from      *mail.Address
+	templates *templates
+	// confirmation renders the emails asking to confirm an address
+	confirmation *templates
+}
+
+// NewSender creates a Sender, loading the digest templates
+func NewSender(config Config, db *storage.SQLiteDB) (*Sender, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", config.From, err)
+	}
+	templates, err := loadTemplates("digest", config.HTMLTemplate, config.TextTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load digest templates: %w", err)
+	}
+	confirmation, err := loadTemplates("confirm", "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load confirmation templates: %w", err)
+	}
+	if config.Items <= 0 {
+		config.Items = 10
+	}
+
+	return &Sender{
+		config:       config,
+		db:           db,
+		from:         from,
+		templates:    templates,
+		confirmation: confirmation,
+	}, nil
+}
+
+// Run sends digests as they become due until stopCh is closed
+func (s *Sender) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		s.sendDue(time.Now())
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue sends the digests that have not been sent since they were last scheduled
+func (s *Sender) sendDue(now time.Time) {
+	subscriptions, err := s.db.ListEnabledDigestSubscriptions()
+	if err != nil {
+		klog.Errorf("Failed to list digest subscriptions: %v", err)
+		return
+	}
+
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		due, err := subscription.Due(now)
+		if err != nil {
+			klog.Errorf("Failed to schedule digest for user %s: %v", subscription.UserID, err)
+			continue
+		}
+		if !due {
+			continue
+		}
+
+		digest, err := s.Send(subscription, now)
+		if err != nil {
+			// Try again at the next check
+			klog.Errorf("Failed to send digest to user %s: %v", subscription.UserID, err)
+			continue
+		}
+		if digest == nil {
+			// Nothing new to send; wait for the next scheduled time
+			if err := s.db.MarkDigestScheduled(subscription.UserID, now.UTC()); err != nil {
+				klog.Errorf("Failed to update digest subscription: %v", err)
+			}
+			continue
+		}
+		klog.InfoS("Sent digest", "user", subscription.UserID, "items", digest.ItemCount)
+	}
+}
+
+// Send builds a digest of the user's top recommendations that have not been
+// in an earlier digest and emails it. It returns nil if there is nothing new
+// to send, and ErrDigestNotConfirmed if the address is not confirmed.
+func (s *Sender) Send(subscription *storage.DigestSubscription, now time.Time) (*storage.Digest, error) {
+	if !subscription.Confirmed {
+		return nil, storage.ErrDigestNotConfirmed
+	}
+	user, err := s.db.GetUser(subscription.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", subscription.UserID)
+	}
+
+	// Get the recommendations not sent yet
+	recommendations, err := s.db.GetRecommendations(storage.GetRecommendationsInput{
+		UserID:          user.ID,
+		Limit:           s.config.Items,
+		ExcludeDigested: true,
+		Collapse:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(recommendations) == 0 {
+		return nil, nil
+	}
+
+	// Build the digest
+	data := Data{
+		Subject:        fmt.Sprintf("Your %s riffle digest: %s", subscription.Frequency, recommendations[0].Content.Title),
+		Username:       user.Username,
+		Frequency:      subscription.Frequency,
+		UnsubscribeURL: s.unsubscribeURL(subscription.UnsubscribeToken),
+		GeneratedAt:    now,
+	}
+	sources := map[string]string{}
+	contentIDs := make([]string, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		content := recommendation.Content
+		if _, ok := sources[content.SourceID]; !ok {
+			sources[content.SourceID] = ""
+			if source, err := s.db.GetSource(content.SourceID); err == nil && source != nil {
+				sources[content.SourceID] = source.Name
+			}
+		}
+		data.Items = append(data.Items, Item{
+			Title:       content.Title,
+			Link:        content.Link,
+			Summary:     summarize(content.Description),
+			Source:      sources[content.SourceID],
+			PublishedAt: content.PublishedAt,
+			Score:       recommendation.Score,
+		})
+		contentIDs = append(contentIDs, content.ID)
+	}
+	html, text, err := s.templates.render(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	// Send it
+	msg := &message{
+		From:           s.from,
+		To:             subscription.Email,
+		Subject:        data.Subject,
+		HTML:           html,
+		Text:           text,
+		UnsubscribeURL: data.UnsubscribeURL,
+		Date:           now,
+	}
+	if err := s.send(msg); err != nil {
+		return nil, err
+	}
+
+	// Record what was sent so it is not sent again
+	digest := &storage.Digest{
+		UserID:    user.ID,
+		Email:     subscription.Email,
+		Frequency: subscription.Frequency,
+		Subject:   data.Subject,
+		SentAt:    now.UTC(),
+	}
+	if err := s.db.RecordDigest(digest, contentIDs); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// SendConfirmation emails a link that confirms the address of a digest
+// subscription. It reports false without sending anything if the address is
+// already confirmed or a confirmation was sent less than
+// storage.DigestConfirmationInterval ago.
+func (s *Sender) SendConfirmation(subscription *storage.DigestSubscription, now time.Time) (bool, error) {
+	user, err := s.db.GetUser(subscription.UserID)
+	if err != nil {
+		return false, err
+	}
+	if user == nil {
+		return false, fmt.Errorf("user %s not found", subscription.UserID)
+	}
+
+	// Limit how often an address can be mailed
+	claimed, err := s.db.ClaimDigestConfirmation(subscription.UserID, now)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	data := ConfirmationData{
+		Subject:    "Confirm your riffle digest address",
+		Username:   user.Username,
+		Email:      subscription.Email,
+		ConfirmURL: s.linkURL("/digests/confirm", subscription.ConfirmationToken),
+	}
+	html, text, err := s.confirmation.render(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to render confirmation email: %w", err)
+	}
+
+	msg := &message{
+		From:    s.from,
+		To:      subscription.Email,
+		Subject: data.Subject,
+		HTML:    html,
+		Text:    text,
+		Date:    now,
+	}
+	if err := s.send(msg); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// send delivers a message through the SMTP server, using STARTTLS when the
+// server supports it
+func (s *Sender) send(msg *message) error {
+	data, err := msg.bytes()
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+
+	var auth smtp.Auth
+	if s.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.config.SMTPUsername, s.config.SMTPPassword, s.config.SMTPHost)
+	}
+	addr := net.JoinHostPort(s.config.SMTPHost, strconv.Itoa(s.config.SMTPPort))
+	if err := smtp.SendMail(addr, auth, s.from.Address, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// unsubscribeURL returns the link that disables a digest subscription
+func (s *Sender) unsubscribeURL(token string) string {
+	return s.linkURL("/digests/unsubscribe", token)
+}
+
+// linkURL returns the public URL of an endpoint taking a digest token
+func (s *Sender) linkURL(path, token string) string {
+	return strings.TrimSuffix(s.config.PublicURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,244 @@
+package digest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// fakeSMTP is an SMTP server that accepts every email and hands them to the test
+type fakeSMTP struct {
+	listener net.Listener
+	messages chan *mail.Message
+}
+
+// newFakeSMTP starts an SMTP server on a local port that is closed when the test ends
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &fakeSMTP{listener: listener, messages: make(chan *mail.Message, 10)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// serve speaks just enough SMTP for net/smtp to deliver a message
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.Fields(line + " x")[0])
+		switch command {
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			if msg, err := mail.ReadMessage(strings.NewReader(data.String())); err == nil {
+				s.messages <- msg
+			}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// port returns the port the server listens on
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// next returns the next email the server received, or nil if there is none
+func (s *fakeSMTP) next() *mail.Message {
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+}
+
+// textPart returns the decoded plain text part of an email
+func textPart(t *testing.T, msg *mail.Message) string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("failed to parse content type: %v", err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatalf("email has no plain text part: %v", err)
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+			text, err := io.ReadAll(quotedprintable.NewReader(part))
+			if err != nil {
+				t.Fatalf("failed to decode plain text part: %v", err)
+			}
+			return string(text)
+		}
+	}
+}
+
+// newTestSender opens a temporary database with a user subscribed to digests
+// and creates a Sender delivering to server
+func newTestSender(t *testing.T, server *fakeSMTP) (*Sender, *storage.SQLiteDB, *storage.DigestSubscription) {
+	t.Helper()
+	db, err := storage.NewSQLiteDB(filepath.Join(t.TempDir(), "riffle.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	user, err := db.CreateUser(storage.CreateUserInput{Username: "alice", Password: "password123", Role: storage.RoleReader})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	subscription, err := db.SetDigestSubscription(user.ID, storage.DigestSubscriptionInput{Email: "alice@example.com"}, "07:00", "UTC")
+	if err != nil {
+		t.Fatalf("SetDigestSubscription() error = %v", err)
+	}
+
+	sender, err := NewSender(Config{
+		SMTPHost:  "127.0.0.1",
+		SMTPPort:  server.port(),
+		From:      "riffle <riffle@example.com>",
+		PublicURL: "https://riffle.example.com/",
+	}, db)
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+	return sender, db, subscription
+}
+
+func TestSendConfirmation(t *testing.T) {
+	server := newFakeSMTP(t)
+	sender, _, subscription := newTestSender(t, server)
+	now := time.Now()
+
+	sent, err := sender.SendConfirmation(subscription, now)
+	if err != nil || !sent {
+		t.Fatalf("SendConfirmation() = %v, %v, want true", sent, err)
+	}
+	msg := server.next()
+	if msg == nil {
+		t.Fatal("no confirmation email was sent")
+	}
+	if to := msg.Header.Get("To"); to != "alice@example.com" {
+		t.Errorf("To = %q, want alice@example.com", to)
+	}
+	if msg.Header.Get("List-Unsubscribe") != "" {
+		t.Error("confirmation email has a List-Unsubscribe header")
+	}
+	link := "https://riffle.example.com/digests/confirm?token=" + subscription.ConfirmationToken
+	if text := textPart(t, msg); !strings.Contains(text, link) {
+		t.Errorf("confirmation email does not contain %s:\n%s", link, text)
+	}
+
+	// Confirmations are limited
+	sent, err = sender.SendConfirmation(subscription, now.Add(time.Minute))
+	if err != nil || sent {
+		t.Errorf("second SendConfirmation() = %v, %v, want false", sent, err)
+	}
+	if server.next() != nil {
+		t.Error("a second confirmation email was sent within the interval")
+	}
+}
+
+func TestSendRequiresConfirmation(t *testing.T) {
+	server := newFakeSMTP(t)
+	sender, db, subscription := newTestSender(t, server)
+
+	if _, err := sender.Send(subscription, time.Now()); !errors.Is(err, storage.ErrDigestNotConfirmed) {
+		t.Errorf("Send() error = %v, want %v", err, storage.ErrDigestNotConfirmed)
+	}
+	if server.next() != nil {
+		t.Error("a digest was sent to an unconfirmed address")
+	}
+
+	// Once confirmed, digests go out; there is nothing to recommend yet
+	confirmed, err := db.ConfirmDigestSubscription(subscription.ConfirmationToken)
+	if err != nil {
+		t.Fatalf("ConfirmDigestSubscription() error = %v", err)
+	}
+	digest, err := sender.Send(confirmed, time.Now())
+	if err != nil || digest != nil {
+		t.Errorf("Send() = %v, %v, want nil, nil", digest, err)
+	}
+}
+
+func TestMessageHeaders(t *testing.T) {
+	msg := &message{
+		From:           &mail.Address{Name: "riffle", Address: "riffle@example.com"},
+		To:             "alice@example.com",
+		Subject:        "Your daily riffle digest: Ünïcode",
+		HTML:           "<p>Hi</p>",
+		Text:           "Hi",
+		UnsubscribeURL: "https://riffle.example.com/digests/unsubscribe?token=abc",
+		Date:           time.Date(2026, 1, 2, 7, 0, 0, 0, time.UTC),
+	}
+	data, err := msg.bytes()
+	if err != nil {
+		t.Fatalf("bytes() error = %v", err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("failed to parse email: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q, %v, want %q", subject, err, msg.Subject)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<"+msg.UnsubscribeURL+">" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", got)
+	}
+	if text := textPart(t, parsed); text != "Hi" {
+		t.Errorf("plain text part = %q, want Hi", text)
+	}
+}
@@ -0,0 +1,88 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// message is an email with HTML and plain text alternatives
+type message struct {
+	From    *mail.Address
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+	// UnsubscribeURL is left empty for emails that are not digests
+	UnsubscribeURL string
+	Date           time.Time
+}
+
+// header is a header field of an email
+type header struct {
+	name, value string
+}
+
+// bytes encodes the message in the format sent over SMTP
+func (m *message) bytes() ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		// Alternatives are ordered from the least to the most preferred
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	_, domain, _ := strings.Cut(m.From.Address, "@")
+	var msg bytes.Buffer
+	headers := []header{
+		{"From", m.From.String()},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", m.Date.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)},
+		{"MIME-Version", "1.0"},
+	}
+	if m.UnsubscribeURL != "" {
+		headers = append(headers,
+			header{"List-Unsubscribe", "<" + m.UnsubscribeURL + ">"},
+			header{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"},
+		)
+	}
+	headers = append(headers, header{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()})
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h.name, h.value)
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
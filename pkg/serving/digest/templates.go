@@ -0,0 +1,122 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxSummaryLength is the maximum length of an item summary in characters
+const maxSummaryLength = 280
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Data is the data digest templates are executed with
+type Data struct {
+	Subject   string
+	Username  string
+	Frequency string
+	Items     []Item
+	// UnsubscribeURL disables the recipient's digest when opened
+	UnsubscribeURL string
+	GeneratedAt    time.Time
+}
+
+// ConfirmationData is the data confirmation email templates are executed with
+type ConfirmationData struct {
+	Subject  string
+	Username string
+	Email    string
+	// ConfirmURL confirms the recipient's digest address when opened
+	ConfirmURL string
+}
+
+// Item is a recommended content item in a digest
+type Item struct {
+	Title string
+	Link  string
+	// Summary is the plain text beginning of the item's description
+	Summary     string
+	Source      string
+	PublishedAt time.Time
+	Score       float64
+}
+
+// templates renders the HTML and plain text parts of emails
+type templates struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// loadTemplates parses the templates of an email, such as "digest", using
+// the built-in ones for paths that are empty
+func loadTemplates(name, htmlPath, textPath string) (*templates, error) {
+	htmlSource, err := readTemplate(htmlPath, "templates/"+name+".html")
+	if err != nil {
+		return nil, err
+	}
+	textSource, err := readTemplate(textPath, "templates/"+name+".txt")
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := map[string]interface{}{
+		"inc": func(i int) int { return i + 1 },
+	}
+	html, err := htmltemplate.New(name + ".html").Funcs(funcs).Parse(htmlSource)
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New(name + ".txt").Funcs(funcs).Parse(textSource)
+	if err != nil {
+		return nil, err
+	}
+	return &templates{html: html, text: text}, nil
+}
+
+// readTemplate reads a template file, or the built-in template if path is empty
+func readTemplate(path, builtin string) (string, error) {
+	var data []byte
+	var err error
+	if path != "" {
+		data, err = os.ReadFile(path)
+	} else {
+		data, err = defaultTemplates.ReadFile(builtin)
+	}
+	return string(data), err
+}
+
+// render returns the HTML and plain text bodies of an email
+func (t *templates) render(data interface{}) (string, string, error) {
+	var html, text bytes.Buffer
+	if err := t.html.Execute(&html, data); err != nil {
+		return "", "", err
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return "", "", err
+	}
+	return html.String(), text.String(), nil
+}
+
+// summarize returns the plain text of an HTML description, shortened to
+// maxSummaryLength characters
+func summarize(description string) string {
+	text := description
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(description)); err == nil {
+		text = doc.Text()
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxSummaryLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxSummaryLength])) + "…"
+}
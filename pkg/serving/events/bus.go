@@ -0,0 +1,149 @@
+// Package events is an in-process publish/subscribe bus for things that
+// happen in riffle, such as new content or finished fetch jobs.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Event types
+const (
+	// ContentCreated is published with the storage.RSSContent of a new item
+	ContentCreated = "content.created"
+	// ContentUpdated is published with the storage.RSSContent of an item
+	// that was edited by its publisher or an editor
+	ContentUpdated = "content.updated"
+	// FetchJobProgress is published with a FetchProgress when a fetch job
+	// starts and after each source it fetched
+	FetchJobProgress = "fetch_job.progress"
+	// FetchJobCompleted is published with the storage.FetchJob of a fetch
+	// job that finished, successfully or not
+	FetchJobCompleted = "fetch_job.completed"
+	// SourceFailed is published with a SourceFailure when a source could
+	// not be fetched
+	SourceFailed = "source.failed"
+)
+
+// DefaultHistory is the number of recent events kept for subscribers
+// resuming after a disconnect
+const DefaultHistory = 1000
+
+// Event is something that happened
+type Event struct {
+	// ID increases with every published event. IDs of a restarted server
+	// are larger than all IDs from before the restart.
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// SourceFailure is the data of a SourceFailed event
+type SourceFailure struct {
+	SourceID string `json:"sourceId"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	JobID    string `json:"jobId,omitempty"`
+	Error    string `json:"error"`
+}
+
+// FetchProgress is the data of a FetchJobProgress event
+type FetchProgress struct {
+	JobID          string `json:"jobId"`
+	Status         string `json:"status"`
+	SourcesTotal   int    `json:"sourcesTotal"`
+	SourcesDone    int    `json:"sourcesDone"`
+	ItemsProcessed int    `json:"itemsProcessed"`
+	// SourceID is the source that was just fetched, if any
+	SourceID string `json:"sourceId,omitempty"`
+}
+
+// Bus delivers published events to all subscribers and keeps the most
+// recent ones so subscribers can catch up on what they missed
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	size        int
+	subscribers map[chan Event]struct{}
+}
+
+// NewBus creates a new Bus keeping up to history recent events
+func NewBus(history int) *Bus {
+	return &Bus{
+		// Start from the clock so IDs keep increasing across restarts
+		nextID:      uint64(time.Now().UnixNano()),
+		size:        history,
+		subscribers: map[chan Event]struct{}{},
+	}
+}
+
+// Publish sends an event to all subscribers. It never blocks; a subscriber
+// whose buffer is full is dropped and its channel closed, so it can
+// subscribe again from the last event it received.
+func (b *Bus) Publish(eventType string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{ID: b.nextID, Type: eventType, Time: time.Now().UTC(), Data: data}
+	b.nextID++
+	if b.size > 0 {
+		if len(b.history) == b.size {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			klog.Warningf("Dropped a slow event subscriber at %s event %d", eventType, event.ID)
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving published events, buffering up to
+// size of them, and a function that cancels the subscription. If lastID is
+// not 0, the channel first receives the kept events published after it, and
+// complete reports whether all of them were still kept.
+func (b *Bus) Subscribe(lastID uint64, size int) (ch <-chan Event, complete bool, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Find the events to replay
+	var replay []Event
+	complete = true
+	if lastID != 0 {
+		oldest := b.nextID
+		if len(b.history) > 0 {
+			oldest = b.history[0].ID
+		}
+		complete = lastID+1 >= oldest
+		for _, event := range b.history {
+			if event.ID > lastID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	c := make(chan Event, size+len(replay))
+	for _, event := range replay {
+		c <- event
+	}
+	b.subscribers[c] = struct{}{}
+
+	return c, complete, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[c]; ok {
+			delete(b.subscribers, c)
+			close(c)
+		}
+	}
+}
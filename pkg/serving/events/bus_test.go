@@ -0,0 +1,116 @@
+package events
+
+import (
+	"testing"
+)
+
+// publish publishes count events and returns them as a subscriber got them
+func publish(t *testing.T, bus *Bus, count int) []Event {
+	t.Helper()
+	ch, _, cancel := bus.Subscribe(0, count)
+	defer cancel()
+	for i := 0; i < count; i++ {
+		bus.Publish(FetchJobProgress, i)
+	}
+	published := make([]Event, count)
+	for i := range published {
+		published[i] = <-ch
+	}
+	return published
+}
+
+// receive returns the events waiting in a channel
+func receive(ch <-chan Event) []Event {
+	var received []Event
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return received
+			}
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+func TestSubscribeReplaysKeptEvents(t *testing.T) {
+	bus := NewBus(3)
+	published := publish(t, bus, 5)
+	for i := 1; i < len(published); i++ {
+		if published[i].ID <= published[i-1].ID {
+			t.Fatalf("event IDs %d, %d do not increase", published[i-1].ID, published[i].ID)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		complete bool
+		replayed int
+	}{
+		{"new subscriber", 0, true, 0},
+		{"up to date", published[4].ID, true, 0},
+		{"missed kept events", published[2].ID, true, 2},
+		{"missed the oldest kept event", published[1].ID, true, 3},
+		{"missed dropped events", published[0].ID, false, 3},
+		{"from before a restart", published[0].ID - 1000, false, 3},
+	}
+	for _, tt := range tests {
+		ch, complete, cancel := bus.Subscribe(tt.lastID, 10)
+		replayed := receive(ch)
+		cancel()
+
+		if complete != tt.complete {
+			t.Errorf("%s: complete = %v, want %v", tt.name, complete, tt.complete)
+		}
+		if len(replayed) != tt.replayed {
+			t.Errorf("%s: replayed %d events, want %d", tt.name, len(replayed), tt.replayed)
+			continue
+		}
+		for i, event := range replayed {
+			if want := published[len(published)-tt.replayed+i]; event.ID != want.ID {
+				t.Errorf("%s: replayed event %d has ID %d, want %d", tt.name, i, event.ID, want.ID)
+			}
+		}
+	}
+}
+
+func TestSubscribeAfterReplay(t *testing.T) {
+	bus := NewBus(10)
+	published := publish(t, bus, 2)
+
+	ch, _, cancel := bus.Subscribe(published[0].ID, 10)
+	defer cancel()
+	bus.Publish(FetchJobCompleted, nil)
+
+	received := receive(ch)
+	if len(received) != 2 || received[0].ID != published[1].ID || received[1].Type != FetchJobCompleted {
+		t.Errorf("received %+v, want the replayed event followed by the new one", received)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewBus(10)
+	ch, _, cancel := bus.Subscribe(0, 1)
+	defer cancel()
+
+	bus.Publish(FetchJobProgress, 1)
+	bus.Publish(FetchJobProgress, 2)
+
+	received := receive(ch)
+	if len(received) != 1 {
+		t.Fatalf("received %d events, want 1", len(received))
+	}
+	if _, ok := <-ch; ok {
+		t.Error("the channel of a dropped subscriber is still open")
+	}
+
+	// The dropped subscriber can resume from the last event it received
+	resumed, complete, cancelResumed := bus.Subscribe(received[0].ID, 1)
+	defer cancelResumed()
+	if replayed := receive(resumed); !complete || len(replayed) != 1 || replayed[0].Data != 2 {
+		t.Errorf("resumed with %+v, complete %v, want the missed event", replayed, complete)
+	}
+}
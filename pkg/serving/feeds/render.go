@@ -0,0 +1,316 @@
+// Package feeds renders lists of content items as RSS 2.0, Atom and JSON
+// Feed documents so that riffle views can be subscribed to in feed readers.
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is a feed document format
+type Format string
+
+// Supported feed formats
+const (
+	FormatRSS  Format = "rss"
+	FormatAtom Format = "atom"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format for a file extension such as "atom"
+func ParseFormat(ext string) (Format, error) {
+	switch strings.ToLower(ext) {
+	case "rss", "xml":
+		return FormatRSS, nil
+	case "atom":
+		return FormatAtom, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown feed format %q, use rss, atom or json", ext)
+	}
+}
+
+// ContentType returns the MIME type of documents in the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatAtom:
+		return "application/atom+xml; charset=utf-8"
+	case FormatJSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/rss+xml; charset=utf-8"
+	}
+}
+
+// Feed is a feed to render
+type Feed struct {
+	// ID identifies the feed and must not change between renderings
+	ID          string
+	Title       string
+	Description string
+	// Link is the URL the feed is served at
+	Link    string
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is an entry of a feed
+type Item struct {
+	// ID identifies the item and must not change between renderings
+	ID          string
+	Title       string
+	Link        string
+	Summary     string
+	Content     string
+	Author      string
+	Categories  []string
+	PublishedAt time.Time
+	UpdatedAt   time.Time
+}
+
+// Render writes the feed in the given format
+func Render(w io.Writer, format Format, feed Feed) error {
+	switch format {
+	case FormatRSS:
+		return renderRSS(w, feed)
+	case FormatAtom:
+		return renderAtom(w, feed)
+	case FormatJSON:
+		return renderJSON(w, feed)
+	default:
+		return fmt.Errorf("unknown feed format %q", format)
+	}
+}
+
+// rssDocument is an RSS 2.0 document
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description,omitempty"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func renderRSS(w io.Writer, feed Feed) error {
+	doc := rssDocument{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Description,
+			SelfLink:      atomLink{Href: feed.Link, Rel: "self", Type: FormatRSS.mediaType()},
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range feed.Items {
+		description := item.Content
+		if description == "" {
+			description = item.Summary
+		}
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID},
+			Description: description,
+			Categories:  item.Categories,
+			PubDate:     item.PublishedAt.UTC().Format(time.RFC1123Z),
+		}
+		// RSS 2.0 authors are email addresses
+		if strings.Contains(item.Author, "@") {
+			entry.Author = item.Author
+		}
+		doc.Channel.Items = append(doc.Channel.Items, entry)
+	}
+	return writeXML(w, doc)
+}
+
+// atomDocument is an Atom 1.0 document
+type atomDocument struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderAtom(w io.Writer, feed Feed) error {
+	doc := atomDocument{
+		ID:       feed.ID,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Updated:  feed.Updated.UTC().Format(time.RFC3339),
+		Links:    []atomLink{{Href: feed.Link, Rel: "self", Type: FormatAtom.mediaType()}},
+	}
+	for _, item := range feed.Items {
+		updated := item.UpdatedAt
+		if updated.IsZero() {
+			updated = item.PublishedAt
+		}
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Published: item.PublishedAt.UTC().Format(time.RFC3339),
+			Updated:   updated.UTC().Format(time.RFC3339),
+		}
+		if item.Link != "" {
+			entry.Links = []atomLink{{Href: item.Link, Rel: "alternate"}}
+		}
+		if item.Author != "" {
+			entry.Author = &atomAuthor{Name: item.Author}
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "html", Value: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: item.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+// jsonFeed is a JSON Feed 1.1 document
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	FeedURL     string     `json:"feed_url,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url,omitempty"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func renderJSON(w io.Writer, feed Feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		Description: feed.Description,
+		FeedURL:     feed.Link,
+		Items:       []jsonItem{},
+	}
+	for _, item := range feed.Items {
+		entry := jsonItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentHTML:   item.Content,
+			Summary:       item.Summary,
+			DatePublished: item.PublishedAt.UTC().Format(time.RFC3339),
+			Tags:          item.Categories,
+		}
+		if entry.ContentHTML == "" {
+			// JSON Feed items need content, so fall back to the summary
+			entry.ContentHTML = item.Summary
+		}
+		if !item.UpdatedAt.IsZero() {
+			entry.DateModified = item.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		if item.Author != "" {
+			entry.Authors = []jsonAuthor{{Name: item.Author}}
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// mediaType returns the MIME type of the format without parameters
+func (f Format) mediaType() string {
+	mediaType, _, _ := strings.Cut(f.ContentType(), ";")
+	return mediaType
+}
+
+// writeXML writes an XML document with its header
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to render feed: %w", err)
+	}
+	return nil
+}
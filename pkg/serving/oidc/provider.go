@@ -0,0 +1,210 @@
+// Package oidc signs users in through an external OpenID Connect identity
+// provider and maps the accounts and groups there to riffle users and roles.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"golang.org/x/oauth2"
+	"k8s.io/klog/v2"
+)
+
+// Config configures the identity provider and how its claims map to users
+type Config struct {
+	// IssuerURL is the URL of the identity provider, which must serve
+	// /.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the browser back to, which
+	// is the /auth/oidc/callback endpoint of this server
+	RedirectURL string
+	Scopes      []string
+	// Audience is the audience bearer tokens must be issued for. It
+	// defaults to the client ID.
+	Audience string
+	// UsernameClaim is the claim holding the username of new users. If the
+	// claim is missing, the email and then the subject are used.
+	UsernameClaim string
+	// RolesClaim is the claim holding the user's groups or roles
+	RolesClaim string
+	// RoleMapping maps values of the roles claim to riffle roles. If it is
+	// set, the user's role is updated on every sign-in.
+	RoleMapping map[string]string
+	// DefaultRole is the role of users none of whose groups are mapped
+	DefaultRole string
+}
+
+// Provider signs users in through an OpenID Connect identity provider
+type Provider struct {
+	config         Config
+	db             *storage.SQLiteDB
+	oauth2         oauth2.Config
+	idTokenChecker *gooidc.IDTokenVerifier
+	bearerChecker  *gooidc.IDTokenVerifier
+}
+
+// NewProvider discovers the identity provider's endpoints and keys and
+// creates a Provider for it
+func NewProvider(ctx context.Context, config Config, db *storage.SQLiteDB) (*Provider, error) {
+	if config.DefaultRole == "" {
+		config.DefaultRole = storage.RoleReader
+	}
+	if !storage.ValidRole(config.DefaultRole) {
+		return nil, fmt.Errorf("invalid default role %q: %w", config.DefaultRole, storage.ErrInvalidRole)
+	}
+	for value, role := range config.RoleMapping {
+		if !storage.ValidRole(role) {
+			return nil, fmt.Errorf("invalid role %q for %q: %w", role, value, storage.ErrInvalidRole)
+		}
+	}
+	if config.Audience == "" {
+		config.Audience = config.ClientID
+	}
+
+	// Discover the provider
+	discovered, err := gooidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", config.IssuerURL, err)
+	}
+
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+	}
+
+	return &Provider{
+		config: config,
+		db:     db,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       scopes,
+		},
+		idTokenChecker: discovered.Verifier(&gooidc.Config{ClientID: config.ClientID}),
+		bearerChecker:  discovered.Verifier(&gooidc.Config{ClientID: config.Audience}),
+	}, nil
+}
+
+// AuthCodeURL returns the URL of the provider's login page. The state and
+// nonce are checked on the way back, and the PKCE verifier must be passed to
+// Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades an authorization code for an ID token and returns the
+// riffle user it belongs to, creating the user on first sign-in
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*storage.User, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	idToken, err := p.idTokenChecker.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("ID token nonce does not match")
+	}
+
+	return p.userForToken(idToken)
+}
+
+// VerifyBearerToken checks a JWT issued by the provider and returns the
+// riffle user it belongs to, creating the user on first use. It returns nil
+// if the token is not valid.
+func (p *Provider) VerifyBearerToken(ctx context.Context, raw string) (*storage.User, error) {
+	token, err := p.bearerChecker.Verify(ctx, raw)
+	if err != nil {
+		klog.V(2).InfoS("Rejected bearer token", "err", err)
+		return nil, nil
+	}
+	return p.userForToken(token)
+}
+
+// userForToken returns the riffle user for the verified token's claims
+func (p *Provider) userForToken(token *gooidc.IDToken) (*storage.User, error) {
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse token claims: %w", err)
+	}
+	role := p.roleForClaims(claims)
+
+	// Find the linked user, or create one
+	user, err := p.db.GetUserByIdentity(token.Issuer, token.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return p.db.CreateIdentityUser(storage.CreateIdentityUserInput{
+			Issuer:   token.Issuer,
+			Subject:  token.Subject,
+			Username: p.usernameForClaims(claims, token.Subject),
+			Role:     role,
+		})
+	}
+
+	// Keep the role in sync with the provider's groups
+	if len(p.config.RoleMapping) > 0 && user.Role != role {
+		updated, err := p.db.SetUserRole(user.ID, role)
+		if errors.Is(err, storage.ErrLastAdmin) {
+			klog.Warningf("Not demoting %s, the last admin, to %s", user.Username, role)
+			return user, nil
+		} else if err != nil {
+			return nil, err
+		}
+		klog.InfoS("Updated role from OIDC claims", "user", user.Username, "role", role)
+		return updated, nil
+	}
+
+	return user, nil
+}
+
+// usernameForClaims returns the username for a new user
+func (p *Provider) usernameForClaims(claims map[string]interface{}, subject string) string {
+	for _, claim := range []string{p.config.UsernameClaim, "email"} {
+		if value, ok := claims[claim].(string); ok && value != "" {
+			return value
+		}
+	}
+	return subject
+}
+
+// roleForClaims returns the most privileged role mapped from the roles
+// claim, or the default role if none is mapped
+func (p *Provider) roleForClaims(claims map[string]interface{}) string {
+	var values []string
+	switch v := claims[p.config.RolesClaim].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	role := p.config.DefaultRole
+	for _, value := range values {
+		mapped, ok := p.config.RoleMapping[value]
+		if ok && storage.RoleIncludes(mapped, role) {
+			role = mapped
+		}
+	}
+	return role
+}
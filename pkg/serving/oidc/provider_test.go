@@ -0,0 +1,365 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+const (
+	testClientID     = "riffle"
+	testClientSecret = "secret"
+	testKeyID        = "test-key"
+)
+
+// authorization is a sign-in the mock issuer has granted a code for
+type authorization struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+// mockIssuer is an identity provider that serves discovery, its keys and a
+// token endpoint that checks PKCE
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// newMockIssuer starts a mock identity provider that is closed when the test ends
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	issuer := &mockIssuer{key: key, codes: map[string]authorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": testKeyID,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", issuer.serveToken)
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+// serveToken trades a code for an ID token if the PKCE verifier matches the
+// challenge of the authorization request
+func (m *mockIssuer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     m.sign(auth.claims),
+	})
+}
+
+// authorize simulates the user signing in at the URL returned by
+// AuthCodeURL and returns the code the browser would be redirected with. The
+// ID token carries the nonce of the request and the given claims.
+func (m *mockIssuer) authorize(t *testing.T, authURL string, claims map[string]interface{}) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("failed to parse authorization URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL %s has no S256 code challenge", authURL)
+	}
+	if query.Get("nonce") == "" {
+		t.Fatalf("authorization URL %s has no nonce", authURL)
+	}
+
+	claims = m.claims(testClientID, time.Hour, claims)
+	claims["nonce"] = query.Get("nonce")
+	code := base64.RawURLEncoding.EncodeToString([]byte(query.Get("state")))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code] = authorization{challenge: query.Get("code_challenge"), claims: claims}
+	return code
+}
+
+// claims returns the standard claims of a token for the audience that
+// expires after ttl, together with extra
+func (m *mockIssuer) claims(audience string, ttl time.Duration, extra map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": m.URL,
+		"sub": "user-1",
+		"aud": audience,
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	return claims
+}
+
+// sign returns a JWT of the claims signed with the issuer's key
+func (m *mockIssuer) sign(claims map[string]interface{}) string {
+	return signJWT(m.key, claims)
+}
+
+// signJWT returns a JWT of the claims signed with RS256
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": testKeyID})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestProvider opens a temporary database and creates a Provider for the
+// issuer that maps the riffle-editors and riffle-admins groups
+func newTestProvider(t *testing.T, issuer *mockIssuer) (*Provider, *storage.SQLiteDB) {
+	t.Helper()
+	db, err := storage.NewSQLiteDB(filepath.Join(t.TempDir(), "riffle.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := NewProvider(context.Background(), Config{
+		IssuerURL:    issuer.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "https://riffle.example.com/auth/oidc/callback",
+		Audience:     "riffle-api",
+		RolesClaim:   "groups",
+		RoleMapping: map[string]string{
+			"riffle-editors": storage.RoleEditor,
+			"riffle-admins":  storage.RoleAdmin,
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	return provider, db
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider, _ := newTestProvider(t, issuer)
+	ctx := context.Background()
+	claims := map[string]interface{}{
+		"email":  "alice@example.com",
+		"groups": []string{"staff", "riffle-editors"},
+	}
+
+	code := issuer.authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"), claims)
+	user, err := provider.Exchange(ctx, code, "verifier", "nonce")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if user.Username != "alice@example.com" || user.Role != storage.RoleEditor {
+		t.Errorf("Exchange() = %s with role %s, want alice@example.com with role editor", user.Username, user.Role)
+	}
+
+	// Signing in again finds the same user
+	code = issuer.authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"), claims)
+	again, err := provider.Exchange(ctx, code, "verifier", "nonce")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second sign-in returned user %s, want %s", again.ID, user.ID)
+	}
+}
+
+func TestExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider, _ := newTestProvider(t, issuer)
+	ctx := context.Background()
+
+	code := issuer.authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"), nil)
+	if _, err := provider.Exchange(ctx, code, "other-verifier", "nonce"); err == nil {
+		t.Error("Exchange() with the wrong PKCE verifier succeeded")
+	}
+
+	code = issuer.authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"), nil)
+	if _, err := provider.Exchange(ctx, code, "verifier", "other-nonce"); err == nil {
+		t.Error("Exchange() with the wrong nonce succeeded")
+	}
+}
+
+func TestRoleForClaims(t *testing.T) {
+	provider := &Provider{config: Config{
+		RolesClaim:  "groups",
+		DefaultRole: storage.RoleReader,
+		RoleMapping: map[string]string{
+			"riffle-editors": storage.RoleEditor,
+			"riffle-admins":  storage.RoleAdmin,
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		groups interface{}
+		want   string
+	}{
+		{"missing claim", nil, storage.RoleReader},
+		{"unmapped groups", []interface{}{"staff"}, storage.RoleReader},
+		{"mapped group", []interface{}{"staff", "riffle-editors"}, storage.RoleEditor},
+		{"most privileged group", []interface{}{"riffle-admins", "riffle-editors"}, storage.RoleAdmin},
+		{"space-separated string", "staff riffle-editors", storage.RoleEditor},
+		{"non-string values", []interface{}{1, true}, storage.RoleReader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{}
+			if tt.groups != nil {
+				claims["groups"] = tt.groups
+			}
+			if got := provider.roleForClaims(claims); got != tt.want {
+				t.Errorf("roleForClaims() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyBearerToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider, _ := newTestProvider(t, issuer)
+	ctx := context.Background()
+
+	token := issuer.sign(issuer.claims("riffle-api", time.Hour, map[string]interface{}{
+		"email":  "bob@example.com",
+		"groups": []string{"staff"},
+	}))
+	user, err := provider.VerifyBearerToken(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyBearerToken() error = %v", err)
+	}
+	if user == nil || user.Username != "bob@example.com" || user.Role != storage.RoleReader {
+		t.Fatalf("VerifyBearerToken() = %+v, want bob@example.com with role reader", user)
+	}
+
+	// The role follows the groups of later tokens
+	token = issuer.sign(issuer.claims("riffle-api", time.Hour, map[string]interface{}{
+		"groups": "riffle-editors",
+	}))
+	updated, err := provider.VerifyBearerToken(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyBearerToken() error = %v", err)
+	}
+	if updated == nil || updated.ID != user.ID || updated.Role != storage.RoleEditor {
+		t.Errorf("VerifyBearerToken() = %+v, want %s with role editor", updated, user.ID)
+	}
+}
+
+func TestVerifyBearerTokenRejectsInvalidTokens(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider, db := newTestProvider(t, issuer)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	foreign := issuer.claims("riffle-api", time.Hour, nil)
+	foreign["iss"] = "https://other.example.com"
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", issuer.sign(issuer.claims("riffle-api", -time.Minute, nil))},
+		{"wrong audience", issuer.sign(issuer.claims("another-app", time.Hour, nil))},
+		{"ID token audience", issuer.sign(issuer.claims(testClientID, time.Hour, nil))},
+		{"other issuer", issuer.sign(foreign)},
+		{"unknown key", signJWT(otherKey, issuer.claims("riffle-api", time.Hour, nil))},
+		{"malformed", "not-a-jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := provider.VerifyBearerToken(ctx, tt.token)
+			if err != nil || user != nil {
+				t.Errorf("VerifyBearerToken() = %v, %v, want nil, nil", user, err)
+			}
+		})
+	}
+
+	// Rejected tokens do not create users
+	users, err := db.ListUsers()
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("got %d users, want none", len(users))
+	}
+}
@@ -2,38 +2,120 @@ package serving
 
 import (
 	"fmt"
+	"net/mail"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/flyer103/riffle/pkg/serving/digest"
+	"github.com/flyer103/riffle/pkg/serving/oidc"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/flyer103/riffle/pkg/serving/webhooks"
 	"github.com/spf13/pflag"
 )
 
 // ServerOptions contains the options for the server
 type ServerOptions struct {
-	Port         int           `json:"port"`
-	DBPath       string        `json:"dbPath"`
-	LogLevel     string        `json:"logLevel"`
-	EnablePprof  bool          `json:"enablePprof"`
-	MetricsPort  int           `json:"metricsPort"`
-	RateLimit    int           `json:"rateLimit"`
-	EnableCORS   bool          `json:"enableCORS"`
-	CORSOrigins  []string      `json:"corsOrigins"`
-	ReadTimeout  time.Duration `json:"readTimeout"`
-	WriteTimeout time.Duration `json:"writeTimeout"`
+	Port           int           `json:"port"`
+	DBPath         string        `json:"dbPath"`
+	DBJournalMode  string        `json:"dbJournalMode"`
+	DBBusyTimeout  time.Duration `json:"dbBusyTimeout"`
+	DBForeignKeys  bool          `json:"dbForeignKeys"`
+	DBMaxReadConns int           `json:"dbMaxReadConns"`
+	TrashRetention time.Duration `json:"trashRetention"`
+	AllowSignup    bool          `json:"allowSignup"`
+	SessionTTL     time.Duration `json:"sessionTTL"`
+	// OIDC settings; signing in through an identity provider is enabled
+	// when OIDCIssuerURL is set
+	OIDCIssuerURL     string            `json:"oidcIssuerURL"`
+	OIDCClientID      string            `json:"oidcClientID"`
+	OIDCClientSecret  string            `json:"-"`
+	OIDCRedirectURL   string            `json:"oidcRedirectURL"`
+	OIDCScopes        []string          `json:"oidcScopes"`
+	OIDCAudience      string            `json:"oidcAudience"`
+	OIDCUsernameClaim string            `json:"oidcUsernameClaim"`
+	OIDCRolesClaim    string            `json:"oidcRolesClaim"`
+	OIDCRoleMapping   map[string]string `json:"oidcRoleMapping"`
+	OIDCDefaultRole   string            `json:"oidcDefaultRole"`
+	OIDCPostLoginURL  string            `json:"oidcPostLoginURL"`
+	LogLevel          string            `json:"logLevel"`
+	EnablePprof       bool              `json:"enablePprof"`
+	MetricsPort       int               `json:"metricsPort"`
+	RateLimit         int               `json:"rateLimit"`
+	EnableCORS        bool              `json:"enableCORS"`
+	CORSOrigins       []string          `json:"corsOrigins"`
+	ReadTimeout       time.Duration     `json:"readTimeout"`
+	WriteTimeout      time.Duration     `json:"writeTimeout"`
+
+	// SMTP settings; email digests are sent when SMTPHost is set
+	SMTPHost           string `json:"smtpHost"`
+	SMTPPort           int    `json:"smtpPort"`
+	SMTPUsername       string `json:"smtpUsername"`
+	SMTPPassword       string `json:"-"`
+	SMTPFrom           string `json:"smtpFrom"`
+	PublicURL          string `json:"publicURL"`
+	DigestItems        int    `json:"digestItems"`
+	DigestSendTime     string `json:"digestSendTime"`
+	DigestTimezone     string `json:"digestTimezone"`
+	DigestHTMLTemplate string `json:"digestHTMLTemplate"`
+	DigestTextTemplate string `json:"digestTextTemplate"`
+
+	// Webhook delivery settings
+	WebhookTimeout     time.Duration `json:"webhookTimeout"`
+	WebhookMaxAttempts int           `json:"webhookMaxAttempts"`
+	// WebhookAllowedNetworks are the loopback, private and link-local
+	// networks webhooks may be delivered to
+	WebhookAllowedNetworks []string `json:"webhookAllowedNetworks"`
+
+	// Link canonicalization settings
+	CanonicalStripParams    []string      `json:"canonicalStripParams"`
+	CanonicalResolveHosts   []string      `json:"canonicalResolveHosts"`
+	CanonicalResolveTimeout time.Duration `json:"canonicalResolveTimeout"`
+
+	// Scoring is the path of a YAML file configuring the scorers of
+	// recommendations; only interest matches are scored when it is empty
+	Scoring string `json:"scoring"`
 }
 
 // NewServerOptions creates a new ServerOptions with default values
 func NewServerOptions() *ServerOptions {
+	dbDefaults := storage.NewOptions("./riffle.db")
+
 	return &ServerOptions{
-		Port:         8080,
-		DBPath:       "./riffle.db",
-		LogLevel:     "info",
-		EnablePprof:  false,
-		MetricsPort:  0,
-		RateLimit:    100,
-		EnableCORS:   false,
-		CORSOrigins:  []string{"*"},
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		Port:              8080,
+		DBPath:            dbDefaults.Path,
+		DBJournalMode:     dbDefaults.JournalMode,
+		DBBusyTimeout:     dbDefaults.BusyTimeout,
+		DBForeignKeys:     dbDefaults.ForeignKeys,
+		DBMaxReadConns:    dbDefaults.MaxReadConns,
+		TrashRetention:    30 * 24 * time.Hour,
+		AllowSignup:       true,
+		SessionTTL:        7 * 24 * time.Hour,
+		OIDCScopes:        []string{"openid", "profile", "email"},
+		OIDCUsernameClaim: "preferred_username",
+		OIDCRolesClaim:    "groups",
+		OIDCDefaultRole:   storage.RoleReader,
+		OIDCPostLoginURL:  "/",
+		SMTPPort:          587,
+		DigestItems:       10,
+		DigestSendTime:    "08:00",
+		DigestTimezone:    "UTC",
+		LogLevel:          "info",
+		EnablePprof:       false,
+		MetricsPort:       0,
+		RateLimit:         100,
+		EnableCORS:        false,
+		CORSOrigins:       []string{"*"},
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+
+		WebhookTimeout:     10 * time.Second,
+		WebhookMaxAttempts: 5,
+
+		CanonicalStripParams:    riffle.DefaultStrippedParams,
+		CanonicalResolveHosts:   riffle.DefaultResolvedHosts,
+		CanonicalResolveTimeout: 5 * time.Second,
 	}
 }
 
@@ -41,6 +123,42 @@ func NewServerOptions() *ServerOptions {
 func (o *ServerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&o.Port, "port", o.Port, "Port to listen on")
 	fs.StringVar(&o.DBPath, "db-path", o.DBPath, "Path to the SQLite database file")
+	fs.StringVar(&o.DBJournalMode, "db-journal-mode", o.DBJournalMode, "SQLite journal mode (WAL, DELETE, TRUNCATE, PERSIST, MEMORY, OFF)")
+	fs.DurationVar(&o.DBBusyTimeout, "db-busy-timeout", o.DBBusyTimeout, "How long SQLite waits for a lock before failing with \"database is locked\"")
+	fs.BoolVar(&o.DBForeignKeys, "db-foreign-keys", o.DBForeignKeys, "Enforce SQLite foreign keys; deletes clean up dependent rows either way")
+	fs.IntVar(&o.DBMaxReadConns, "db-max-read-conns", o.DBMaxReadConns, "Maximum number of pooled read-only SQLite connections")
+	fs.DurationVar(&o.TrashRetention, "trash-retention", o.TrashRetention, "How long deleted sources and contents are kept in the trash before being purged (0 to keep forever)")
+	fs.BoolVar(&o.AllowSignup, "allow-signup", o.AllowSignup, "Allow anyone to create an account through POST /auth/signup")
+	fs.DurationVar(&o.SessionTTL, "session-ttl", o.SessionTTL, "How long a login session lasts")
+	fs.StringVar(&o.OIDCIssuerURL, "oidc-issuer-url", o.OIDCIssuerURL, "URL of the OpenID Connect identity provider; enables single sign-on when set")
+	fs.StringVar(&o.OIDCClientID, "oidc-client-id", o.OIDCClientID, "OIDC client ID")
+	fs.StringVar(&o.OIDCClientSecret, "oidc-client-secret", o.OIDCClientSecret, "OIDC client secret (defaults to the RIFFLE_OIDC_CLIENT_SECRET environment variable)")
+	fs.StringVar(&o.OIDCRedirectURL, "oidc-redirect-url", o.OIDCRedirectURL, "Public URL of this server's /auth/oidc/callback endpoint")
+	fs.StringSliceVar(&o.OIDCScopes, "oidc-scopes", o.OIDCScopes, "OIDC scopes to request")
+	fs.StringVar(&o.OIDCAudience, "oidc-audience", o.OIDCAudience, "Audience bearer JWTs must be issued for (defaults to the client ID)")
+	fs.StringVar(&o.OIDCUsernameClaim, "oidc-username-claim", o.OIDCUsernameClaim, "Claim used as the username of new users, falling back to email and then the subject")
+	fs.StringVar(&o.OIDCRolesClaim, "oidc-roles-claim", o.OIDCRolesClaim, "Claim holding the user's groups or roles")
+	fs.StringToStringVar(&o.OIDCRoleMapping, "oidc-role-mapping", o.OIDCRoleMapping, "Map values of the roles claim to roles, e.g. riffle-admins=admin,riffle-editors=editor; roles are synced on every sign-in when set")
+	fs.StringVar(&o.OIDCDefaultRole, "oidc-default-role", o.OIDCDefaultRole, "Role of OIDC users none of whose groups are mapped")
+	fs.StringVar(&o.OIDCPostLoginURL, "oidc-post-login-url", o.OIDCPostLoginURL, "Where to send the browser after signing in through the identity provider")
+	fs.StringVar(&o.SMTPHost, "smtp-host", o.SMTPHost, "SMTP server for sending email digests; digests are sent when set")
+	fs.IntVar(&o.SMTPPort, "smtp-port", o.SMTPPort, "SMTP server port")
+	fs.StringVar(&o.SMTPUsername, "smtp-username", o.SMTPUsername, "SMTP username; no authentication is used when empty")
+	fs.StringVar(&o.SMTPPassword, "smtp-password", o.SMTPPassword, "SMTP password (defaults to the RIFFLE_SMTP_PASSWORD environment variable)")
+	fs.StringVar(&o.SMTPFrom, "smtp-from", o.SMTPFrom, "Sender address of email digests, e.g. \"riffle <riffle@example.com>\"")
+	fs.StringVar(&o.PublicURL, "public-url", o.PublicURL, "Public URL of this server, used for links in emails")
+	fs.IntVar(&o.DigestItems, "digest-items", o.DigestItems, "Maximum number of recommendations in an email digest")
+	fs.StringVar(&o.DigestSendTime, "digest-send-time", o.DigestSendTime, "Default time of day (HH:MM) digests are sent at")
+	fs.StringVar(&o.DigestTimezone, "digest-timezone", o.DigestTimezone, "Default IANA time zone of the digest send time")
+	fs.StringVar(&o.DigestHTMLTemplate, "digest-html-template", o.DigestHTMLTemplate, "Path of an html/template file replacing the built-in HTML digest template")
+	fs.StringVar(&o.DigestTextTemplate, "digest-text-template", o.DigestTextTemplate, "Path of a text/template file replacing the built-in plain text digest template")
+	fs.DurationVar(&o.WebhookTimeout, "webhook-timeout", o.WebhookTimeout, "How long to wait for a webhook to respond")
+	fs.IntVar(&o.WebhookMaxAttempts, "webhook-max-attempts", o.WebhookMaxAttempts, "How often a webhook delivery is tried before giving up")
+	fs.StringSliceVar(&o.WebhookAllowedNetworks, "webhook-allowed-networks", o.WebhookAllowedNetworks, "Loopback, private and link-local networks (CIDRs or IPs) webhooks may be delivered to; other such addresses are refused")
+	fs.StringSliceVar(&o.CanonicalStripParams, "canonical-strip-params", o.CanonicalStripParams, "Query parameters removed from links to identify articles; a trailing * matches any parameter with that prefix")
+	fs.StringSliceVar(&o.CanonicalResolveHosts, "canonical-resolve-hosts", o.CanonicalResolveHosts, "Hosts of feed proxies whose links are followed to the article they redirect to")
+	fs.DurationVar(&o.CanonicalResolveTimeout, "canonical-resolve-timeout", o.CanonicalResolveTimeout, "How long to wait for a feed proxy to redirect")
+	fs.StringVar(&o.Scoring, "scoring", o.Scoring, "Path to a YAML file configuring the scorers, their weights and keywords for recommendations (defaults to interest matches only)")
 	fs.StringVar(&o.LogLevel, "log-level", o.LogLevel, "Log level (debug, info, warn, error)")
 	fs.BoolVar(&o.EnablePprof, "enable-pprof", o.EnablePprof, "Enable pprof debugging endpoints")
 	fs.IntVar(&o.MetricsPort, "metrics-port", o.MetricsPort, "Port for Prometheus metrics (0 to disable)")
@@ -53,6 +171,12 @@ func (o *ServerOptions) AddFlags(fs *pflag.FlagSet) {
 
 // Complete completes the options
 func (o *ServerOptions) Complete() error {
+	if o.OIDCClientSecret == "" {
+		o.OIDCClientSecret = os.Getenv("RIFFLE_OIDC_CLIENT_SECRET")
+	}
+	if o.SMTPPassword == "" {
+		o.SMTPPassword = os.Getenv("RIFFLE_SMTP_PASSWORD")
+	}
 	return nil
 }
 
@@ -62,6 +186,80 @@ func (o *ServerOptions) Validate() error {
 		return fmt.Errorf("port must be between 1 and 65535")
 	}
 
+	switch strings.ToUpper(o.DBJournalMode) {
+	case "WAL", "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "OFF":
+	default:
+		return fmt.Errorf("db journal mode must be one of WAL, DELETE, TRUNCATE, PERSIST, MEMORY, OFF")
+	}
+
+	if o.DBBusyTimeout < 0 {
+		return fmt.Errorf("db busy timeout must be greater than or equal to 0")
+	}
+
+	if o.DBMaxReadConns < 1 {
+		return fmt.Errorf("db max read conns must be greater than 0")
+	}
+
+	if o.TrashRetention < 0 {
+		return fmt.Errorf("trash retention must be greater than or equal to 0")
+	}
+
+	if o.SessionTTL <= 0 {
+		return fmt.Errorf("session ttl must be greater than 0")
+	}
+
+	if o.OIDCIssuerURL != "" {
+		if o.OIDCClientID == "" {
+			return fmt.Errorf("oidc client id is required when an oidc issuer url is set")
+		}
+		if o.OIDCRedirectURL == "" {
+			return fmt.Errorf("oidc redirect url is required when an oidc issuer url is set")
+		}
+		if !storage.ValidRole(o.OIDCDefaultRole) {
+			return fmt.Errorf("oidc default role: %w", storage.ErrInvalidRole)
+		}
+		for value, role := range o.OIDCRoleMapping {
+			if !storage.ValidRole(role) {
+				return fmt.Errorf("oidc role mapping for %q: %w", value, storage.ErrInvalidRole)
+			}
+		}
+	}
+
+	if _, _, err := storage.ParseSendTime(o.DigestSendTime); err != nil {
+		return fmt.Errorf("digest send time must be given as HH:MM")
+	}
+	if _, err := time.LoadLocation(o.DigestTimezone); err != nil {
+		return fmt.Errorf("unknown digest timezone %q", o.DigestTimezone)
+	}
+	if o.SMTPHost != "" {
+		if o.SMTPPort < 1 || o.SMTPPort > 65535 {
+			return fmt.Errorf("smtp port must be between 1 and 65535")
+		}
+		if _, err := mail.ParseAddress(o.SMTPFrom); err != nil {
+			return fmt.Errorf("smtp from must be an email address when an smtp host is set")
+		}
+		if o.PublicURL == "" {
+			return fmt.Errorf("public url is required when an smtp host is set")
+		}
+		if o.DigestItems < 1 {
+			return fmt.Errorf("digest items must be greater than 0")
+		}
+	}
+
+	if o.WebhookTimeout <= 0 {
+		return fmt.Errorf("webhook timeout must be greater than 0")
+	}
+	if o.WebhookMaxAttempts < 1 {
+		return fmt.Errorf("webhook max attempts must be greater than 0")
+	}
+	if _, err := webhooks.ParseNetworks(o.WebhookAllowedNetworks); err != nil {
+		return fmt.Errorf("webhook allowed networks: %w", err)
+	}
+
+	if o.CanonicalResolveTimeout <= 0 {
+		return fmt.Errorf("canonical resolve timeout must be greater than 0")
+	}
+
 	if o.MetricsPort < 0 || o.MetricsPort > 65535 {
 		return fmt.Errorf("metrics port must be between 0 and 65535")
 	}
@@ -80,3 +278,70 @@ func (o *ServerOptions) Validate() error {
 
 	return nil
 }
+
+// OIDCConfig returns the identity provider settings derived from the server options
+func (o *ServerOptions) OIDCConfig() oidc.Config {
+	return oidc.Config{
+		IssuerURL:     o.OIDCIssuerURL,
+		ClientID:      o.OIDCClientID,
+		ClientSecret:  o.OIDCClientSecret,
+		RedirectURL:   o.OIDCRedirectURL,
+		Scopes:        o.OIDCScopes,
+		Audience:      o.OIDCAudience,
+		UsernameClaim: o.OIDCUsernameClaim,
+		RolesClaim:    o.OIDCRolesClaim,
+		RoleMapping:   o.OIDCRoleMapping,
+		DefaultRole:   o.OIDCDefaultRole,
+	}
+}
+
+// DigestConfig returns the email digest settings derived from the server options
+func (o *ServerOptions) DigestConfig() digest.Config {
+	return digest.Config{
+		SMTPHost:     o.SMTPHost,
+		SMTPPort:     o.SMTPPort,
+		SMTPUsername: o.SMTPUsername,
+		SMTPPassword: o.SMTPPassword,
+		From:         o.SMTPFrom,
+		PublicURL:    o.PublicURL,
+		Items:        o.DigestItems,
+		HTMLTemplate: o.DigestHTMLTemplate,
+		TextTemplate: o.DigestTextTemplate,
+	}
+}
+
+// WebhookConfig returns the webhook delivery settings derived from the server options
+func (o *ServerOptions) WebhookConfig() webhooks.Config {
+	// The networks were checked by Validate
+	networks, _ := webhooks.ParseNetworks(o.WebhookAllowedNetworks)
+	return webhooks.Config{
+		Timeout:         o.WebhookTimeout,
+		MaxAttempts:     o.WebhookMaxAttempts,
+		AllowedNetworks: networks,
+	}
+}
+
+// Canonicalizer returns the link canonicalizer configured by the server options
+func (o *ServerOptions) Canonicalizer() *riffle.Canonicalizer {
+	return riffle.NewCanonicalizer(o.CanonicalStripParams, o.CanonicalResolveHosts, o.CanonicalResolveTimeout)
+}
+
+// ScoringConfig returns the scoring configuration of recommendations, or nil
+// if only interest matches are scored
+func (o *ServerOptions) ScoringConfig() (*riffle.ScoringConfig, error) {
+	if o.Scoring == "" {
+		return nil, nil
+	}
+	return riffle.LoadScoringConfig(o.Scoring)
+}
+
+// StorageOptions returns the SQLite options derived from the server options
+func (o *ServerOptions) StorageOptions() storage.Options {
+	return storage.Options{
+		Path:         o.DBPath,
+		JournalMode:  strings.ToUpper(o.DBJournalMode),
+		BusyTimeout:  o.DBBusyTimeout,
+		ForeignKeys:  o.DBForeignKeys,
+		MaxReadConns: o.DBMaxReadConns,
+	}
+}
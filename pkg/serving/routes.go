@@ -4,45 +4,229 @@ import (
 	"net/http/pprof"
 
 	"github.com/flyer103/riffle/pkg/serving/api/handlers"
+	"github.com/flyer103/riffle/pkg/serving/api/middleware"
+	"github.com/flyer103/riffle/pkg/serving/storage"
 	"github.com/gin-gonic/gin"
 )
 
 // setupRoutes sets up the API routes
 func (s *Server) setupRoutes() {
 	// Create the handler factory
-	factory := handlers.NewFactory(s.db, "1.0.0") // TODO: Get version from build info
+	authConfig := handlers.AuthConfig{
+		AllowSignup:      s.options.AllowSignup,
+		SessionTTL:       s.options.SessionTTL,
+		OIDC:             s.oidc,
+		OIDCPostLoginURL: s.options.OIDCPostLoginURL,
+	}
+	digestConfig := handlers.DigestConfig{
+		Sender:          s.digest,
+		DefaultSendTime: s.options.DigestSendTime,
+		DefaultTimezone: s.options.DigestTimezone,
+	}
+	factory := handlers.NewFactory(s.db, "1.0.0", s.bus, s.webhooks, authConfig, digestConfig, s.options.Canonicalizer()) // TODO: Get version from build info
+
+	// Authentication routes
+	auth := s.router.Group("/auth")
+	{
+		auth.GET("/methods", factory.Auth.GetAuthMethods)
+		auth.POST("/signup", factory.Auth.Signup)
+		auth.POST("/login", factory.Auth.Login)
+		auth.POST("/logout", factory.Auth.Logout)
+		if factory.OIDC != nil {
+			auth.GET("/oidc/login", factory.OIDC.Login)
+			auth.GET("/oidc/callback", factory.OIDC.Callback)
+		}
+	}
+
+	// Unsubscribe and confirmation links in digest emails work without
+	// signing in
+	s.router.GET("/digests/unsubscribe", factory.Digests.UnsubscribePage)
+	s.router.POST("/digests/unsubscribe", factory.Digests.Unsubscribe)
+	s.router.GET("/digests/confirm", factory.Digests.ConfirmPage)
+	s.router.POST("/digests/confirm", factory.Digests.Confirm)
+
+	// Bearer tokens that are not API tokens are JWTs from the identity provider
+	var bearer middleware.BearerTokenVerifier
+	if s.oidc != nil {
+		bearer = s.oidc
+	}
+
+	// All other API routes require an authenticated user. Readers can use
+	// everything that only affects their own account; changing shared
+	// sources and content needs an editor, and deleting them for good or
+	// managing users needs an admin.
+	api := s.router.Group("", middleware.Auth(s.db, bearer))
+	editor := middleware.RequireRole(storage.RoleEditor)
+	admin := middleware.RequireRole(storage.RoleAdmin)
+
+	// Current user routes
+	users := api.Group("/users/me")
+	{
+		users.GET("", factory.Users.GetCurrentUser)
+		users.PUT("/password", factory.Users.UpdatePassword)
+		users.GET("/tokens", factory.Users.ListAPITokens)
+		users.POST("/tokens", factory.Users.CreateAPIToken)
+		users.DELETE("/tokens/:id", factory.Users.DeleteAPIToken)
+		users.GET("/feed-token", factory.Users.GetFeedToken)
+		users.POST("/feed-token", factory.Users.CreateFeedToken)
+		users.DELETE("/feed-token", factory.Users.DeleteFeedToken)
+		users.GET("/digest", factory.Digests.GetDigestSubscription)
+		users.PUT("/digest", factory.Digests.SetDigestSubscription)
+		users.DELETE("/digest", factory.Digests.DeleteDigestSubscription)
+		users.GET("/digest/history", factory.Digests.ListDigests)
+		users.POST("/digest/send", factory.Digests.SendDigest)
+		users.POST("/digest/confirm", factory.Digests.SendConfirmation)
+		users.GET("/interests", factory.Interests.ListInterests)
+		users.POST("/interests", factory.Interests.CreateInterest)
+		users.POST("/interests/import", factory.Interests.ImportInterests)
+		users.GET("/interests/:id", factory.Interests.GetInterest)
+		users.PUT("/interests/:id", factory.Interests.UpdateInterest)
+		users.DELETE("/interests/:id", factory.Interests.DeleteInterest)
+	}
+
+	// User management routes
+	userAdmin := api.Group("/users", admin)
+	{
+		userAdmin.GET("", factory.Users.ListUsers)
+		userAdmin.PUT("/:id/role", factory.Users.UpdateUserRole)
+		userAdmin.DELETE("/:id", factory.Users.DeleteUser)
+	}
 
 	// RSS Sources routes
-	sources := s.router.Group("/sources")
+	sources := api.Group("/sources")
 	{
 		sources.GET("", factory.Sources.ListSources)
 		sources.GET("/:id", factory.Sources.GetSource)
-		sources.POST("", factory.Sources.CreateSource)
-		sources.PUT("/:id", factory.Sources.UpdateSource)
-		sources.DELETE("/:id", factory.Sources.DeleteSource)
-		sources.POST("/batch", factory.Sources.BatchCreateSources)
-		sources.DELETE("/batch", factory.Sources.BatchDeleteSources)
+		sources.POST("", editor, factory.Sources.CreateSource)
+		sources.PUT("/:id", editor, factory.Sources.UpdateSource)
+		sources.DELETE("/:id", admin, factory.Sources.DeleteSource)
+		sources.POST("/batch", editor, factory.Sources.BatchCreateSources)
+		sources.PUT("/batch", editor, factory.Sources.BatchUpdateSources)
+		sources.DELETE("/batch", admin, factory.Sources.BatchDeleteSources)
+	}
+
+	// Subscriptions routes
+	subscriptions := api.Group("/subscriptions")
+	{
+		subscriptions.GET("", factory.Subscriptions.ListSubscriptions)
+		subscriptions.POST("", factory.Subscriptions.CreateSubscription)
+		subscriptions.GET("/export.opml", factory.Subscriptions.ExportOPML)
+		subscriptions.GET("/:id", factory.Subscriptions.GetSubscription)
+		subscriptions.PUT("/:id", factory.Subscriptions.UpdateSubscription)
+		subscriptions.DELETE("/:id", factory.Subscriptions.DeleteSubscription)
 	}
 
 	// RSS Contents routes
-	contents := s.router.Group("/contents")
+	contents := api.Group("/contents")
 	{
 		contents.GET("", factory.Contents.ListContents)
 		contents.GET("/:id", factory.Contents.GetContent)
-		contents.PUT("/:id", factory.Contents.UpdateContent)
-		contents.DELETE("/:id", factory.Contents.DeleteContent)
-		contents.DELETE("/batch", factory.Contents.BatchDeleteContents)
-		contents.POST("/fetch", factory.Contents.FetchContents)
+		contents.PUT("/:id", editor, factory.Contents.UpdateContent)
+		contents.DELETE("/:id", editor, factory.Contents.DeleteContent)
+		contents.GET("/:id/revisions", factory.Contents.ListContentRevisions)
+		contents.GET("/:id/revisions/diff", factory.Contents.DiffContentRevisions)
+		contents.GET("/:id/revisions/:revision", factory.Contents.GetContentRevision)
+		contents.POST("/:id/revisions/:revision/revert", editor, factory.Contents.RevertContentRevision)
+		contents.PUT("/batch", editor, factory.Contents.BatchUpdateContents)
+		contents.DELETE("/batch", admin, factory.Contents.BatchDeleteContents)
+		contents.POST("/fetch", editor, factory.Contents.FetchContents)
 		contents.GET("/fetch/:jobId", factory.Contents.GetFetchStatus)
 		contents.GET("/search", factory.Contents.SearchContents)
+		contents.PUT("/:id/state", factory.Contents.UpdateContentState)
+		contents.POST("/mark-read", factory.Contents.MarkContentsRead)
+		contents.GET("/unread-counts", factory.Contents.GetUnreadCounts)
+		contents.POST("/tags", factory.Contents.BatchTagContents)
+	}
+
+	// Tags routes
+	tags := api.Group("/tags")
+	{
+		tags.GET("", factory.Contents.ListTags)
+		tags.DELETE("/:tag", factory.Contents.DeleteTag)
+	}
+
+	// Trash routes
+	trash := api.Group("/trash", editor)
+	{
+		trash.GET("/sources", factory.Trash.ListTrashedSources)
+		trash.POST("/sources/:id/restore", factory.Trash.RestoreSource)
+		trash.DELETE("/sources/:id", admin, factory.Trash.PurgeSource)
+		trash.GET("/contents", factory.Trash.ListTrashedContents)
+		trash.POST("/contents/:id/restore", factory.Trash.RestoreContent)
+		trash.DELETE("/contents/:id", admin, factory.Trash.PurgeContent)
 	}
 
 	// Recommendations routes
-	recommendations := s.router.Group("/recommendations")
+	recommendations := api.Group("/recommendations")
 	{
 		recommendations.GET("", factory.Recommendations.GetRecommendations)
 		recommendations.POST("/feedback", factory.Recommendations.SubmitFeedback)
-		recommendations.GET("/feedback/:userId", factory.Recommendations.GetUserFeedback)
+		recommendations.GET("/feedback", factory.Recommendations.GetUserFeedback)
+	}
+
+	// Saved searches routes
+	searches := api.Group("/saved-searches")
+	{
+		searches.GET("", factory.SavedSearches.ListSavedSearches)
+		searches.POST("", factory.SavedSearches.CreateSavedSearch)
+		searches.GET("/:id", factory.SavedSearches.GetSavedSearch)
+		searches.PUT("/:id", factory.SavedSearches.UpdateSavedSearch)
+		searches.DELETE("/:id", factory.SavedSearches.DeleteSavedSearch)
+		searches.GET("/:id/results", factory.SavedSearches.RunSavedSearch)
+	}
+
+	// Routing rules routes
+	routing := api.Group("/routing-rules")
+	{
+		routing.GET("", factory.RoutingRules.ListRoutingRules)
+		routing.POST("", factory.RoutingRules.CreateRoutingRule)
+		routing.GET("/:id", factory.RoutingRules.GetRoutingRule)
+		routing.PUT("/:id", factory.RoutingRules.UpdateRoutingRule)
+		routing.DELETE("/:id", factory.RoutingRules.DeleteRoutingRule)
+	}
+
+	// Stories routes
+	stories := api.Group("/stories")
+	{
+		stories.GET("", factory.Stories.ListStories)
+	}
+
+	// Event stream route
+	api.GET("/events", factory.Events.StreamEvents)
+
+	// Webhooks routes
+	hooks := api.Group("/webhooks", editor)
+	{
+		hooks.GET("", factory.Webhooks.ListWebhooks)
+		hooks.POST("", factory.Webhooks.CreateWebhook)
+		hooks.GET("/:id", factory.Webhooks.GetWebhook)
+		hooks.PUT("/:id", factory.Webhooks.UpdateWebhook)
+		hooks.DELETE("/:id", factory.Webhooks.DeleteWebhook)
+		hooks.GET("/:id/deliveries", factory.Webhooks.ListWebhookDeliveries)
+		hooks.POST("/:id/test", factory.Webhooks.TestWebhook)
+	}
+
+	// Filter rules routes
+	filters := api.Group("/filters", editor)
+	{
+		filters.GET("", factory.Filters.ListFilterRules)
+		filters.POST("", factory.Filters.CreateFilterRule)
+		filters.POST("/test", factory.Filters.TestFilterRule)
+		filters.POST("/apply", factory.Filters.ApplyFilterRules)
+		filters.GET("/:id", factory.Filters.GetFilterRule)
+		filters.PUT("/:id", factory.Filters.UpdateFilterRule)
+		filters.DELETE("/:id", factory.Filters.DeleteFilterRule)
+		filters.GET("/:id/test", factory.Filters.TestSavedFilterRule)
+	}
+
+	// Published feed routes, which feed readers can fetch with the user's
+	// feed token in the URL instead of other credentials
+	feeds := s.router.Group("/feeds", middleware.FeedAuth(s.db, bearer))
+	{
+		feeds.GET("/:file", factory.Feeds.GetRecommendationsFeed)
+		feeds.GET("/folders/:file", factory.Feeds.GetFolderFeed)
+		feeds.GET("/tags/:file", factory.Feeds.GetTagFeed)
+		feeds.GET("/searches/:file", factory.Feeds.GetSavedSearchFeed)
 	}
 
 	// System routes
@@ -51,7 +235,7 @@ func (s *Server) setupRoutes() {
 
 	// Add pprof routes if enabled
 	if s.options.EnablePprof {
-		pprofGroup := s.router.Group("/debug/pprof")
+		pprofGroup := api.Group("/debug/pprof", admin)
 		{
 			pprofGroup.GET("/", gin.WrapF(pprof.Index))
 			pprofGroup.GET("/cmdline", gin.WrapF(pprof.Cmdline))
@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/flyer103/riffle/pkg/serving/api/middleware"
+	"github.com/flyer103/riffle/pkg/serving/digest"
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/oidc"
 	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/flyer103/riffle/pkg/serving/webhooks"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -16,12 +22,23 @@ import (
 type Server struct {
 	router        *gin.Engine
 	db            *storage.SQLiteDB
+	oidc          *oidc.Provider
+	digest        *digest.Sender
+	bus           *events.Bus
+	webhooks      *webhooks.Dispatcher
 	options       *ServerOptions
 	metricsRouter *gin.Engine
 	httpServer    *http.Server
 	metricsServer *http.Server
+	stopCh        chan struct{}
 }
 
+// trashPurgeInterval is how often expired items are purged from the trash
+const trashPurgeInterval = time.Hour
+
+// oidcDiscoveryTimeout is how long to wait for the identity provider at startup
+const oidcDiscoveryTimeout = 30 * time.Second
+
 // NewServer creates a new server instance
 func NewServer(options *ServerOptions) (*Server, error) {
 	// Set Gin mode based on log level
@@ -35,21 +52,60 @@ func NewServer(options *ServerOptions) (*Server, error) {
 	router := gin.New()
 
 	// Initialize the database
-	db, err := storage.NewSQLiteDB(options.DBPath)
+	db, err := storage.NewSQLiteDBWithOptions(options.StorageOptions())
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
+	// Configure the scorers of recommendations
+	scoring, err := options.ScoringConfig()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load scoring configuration: %w", err)
+	}
+	if scoring != nil {
+		if err := db.SetScoringConfig(scoring); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
 	// Create the server
+	bus := events.NewBus(events.DefaultHistory)
 	server := &Server{
-		router:  router,
-		db:      db,
-		options: options,
+		router:   router,
+		db:       db,
+		bus:      bus,
+		webhooks: webhooks.NewDispatcher(options.WebhookConfig(), db, bus),
+		options:  options,
+		stopCh:   make(chan struct{}),
+	}
+
+	// Discover the identity provider if single sign-on is enabled
+	if options.OIDCIssuerURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+		defer cancel()
+		server.oidc, err = oidc.NewProvider(ctx, options.OIDCConfig(), db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize OIDC: %w", err)
+		}
+		klog.InfoS("Enabled OIDC sign-in", "issuer", options.OIDCIssuerURL)
+	}
+
+	// Set up email digests if an SMTP server is configured
+	if options.SMTPHost != "" {
+		server.digest, err = digest.NewSender(options.DigestConfig(), db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize email digests: %w", err)
+		}
+		klog.InfoS("Enabled email digests", "smtpHost", options.SMTPHost)
 	}
 
 	// Add middleware
 	router.Use(gin.Recovery())
-	router.Use(gin.Logger())
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: middleware.LogFormatter}))
 
 	// Add rate limiting if enabled
 	if options.RateLimit > 0 {
@@ -86,6 +142,12 @@ func NewServer(options *ServerOptions) (*Server, error) {
 	return server, nil
 }
 
+// Handler returns the handler of the API routes, to serve them from another
+// http.Server such as an httptest.Server
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
 // Run starts the server
 func (s *Server) Run() error {
 	// Create the HTTP server
@@ -105,6 +167,19 @@ func (s *Server) Run() error {
 		}()
 	}
 
+	// Start purging expired items from the trash if enabled
+	if s.options.TrashRetention > 0 {
+		go s.runTrashPurger()
+	}
+
+	// Start sending email digests if enabled
+	if s.digest != nil {
+		go s.digest.Run(s.stopCh)
+	}
+
+	// Start delivering events to webhooks
+	go s.webhooks.Run(s.stopCh)
+
 	// Start the main server
 	klog.Infof("Starting server on port %d", s.options.Port)
 	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -116,6 +191,9 @@ func (s *Server) Run() error {
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
+	// Stop background workers
+	close(s.stopCh)
+
 	// Shutdown the main server
 	if s.httpServer != nil {
 		if err := s.httpServer.Shutdown(ctx); err != nil {
@@ -156,3 +234,25 @@ func (s *Server) startMetricsServer() error {
 	klog.Infof("Starting metrics server on port %d", s.options.MetricsPort)
 	return s.metricsServer.ListenAndServe()
 }
+
+// runTrashPurger periodically purges items that have been in the trash for
+// longer than the configured retention until the server is shut down
+func (s *Server) runTrashPurger() {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.db.PurgeTrash(time.Now().Add(-s.options.TrashRetention))
+		if err != nil {
+			klog.Errorf("Failed to purge trash: %v", err)
+		} else if result.PurgedSources > 0 || result.PurgedContents > 0 {
+			klog.Infof("Purged %d sources and %d contents from the trash", result.PurgedSources, result.PurgedContents)
+		}
+
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
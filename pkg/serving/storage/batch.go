@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxBatchSize is the maximum number of items accepted by a single batch operation
+const MaxBatchSize = 100
+
+// ErrBatchTooLarge is returned when a batch operation exceeds MaxBatchSize
+var ErrBatchTooLarge = errors.New("batch too large")
+
+// checkBatchSize returns an error wrapping ErrBatchTooLarge if size exceeds MaxBatchSize
+func checkBatchSize(size int) error {
+	if size > MaxBatchSize {
+		return fmt.Errorf("%w: got %d items, maximum is %d", ErrBatchTooLarge, size, MaxBatchSize)
+	}
+	return nil
+}
+
+// runAtomicBatch runs fn for each of the n items of a batch inside a single
+// transaction. It stops at the first failing item, rolls back everything that
+// was applied so far and returns that item's BatchError. A nil BatchError
+// means the whole batch was committed, after which committed is called with
+// the index of every item.
+func (s *SQLiteDB) runAtomicBatch(n int, fn func(tx queryer, i int) *BatchError, committed func(i int)) (*BatchError, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < n; i++ {
+		if batchErr := fn(tx, i); batchErr != nil {
+			return batchErr, nil
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Only report the items once they are stored
+	for i := 0; i < n; i++ {
+		committed(i)
+	}
+	return nil, nil
+}
+
+// runBatchItems runs fn for each of the n items of a non-atomic batch, each
+// in its own transaction, so that a failing item is rolled back without
+// affecting the others. committed is called with the index of every item
+// whose transaction was committed, and failed builds the BatchError of an
+// item whose transaction could not be run. It returns the errors of the
+// failed items.
+func (s *SQLiteDB) runBatchItems(n int, fn func(tx queryer, i int) *BatchError, committed func(i int), failed func(i int, err error) BatchError) []BatchError {
+	errs := []BatchError{}
+	for i := 0; i < n; i++ {
+		batchErr, err := s.runAtomicBatch(1, func(tx queryer, _ int) *BatchError {
+			return fn(tx, i)
+		}, func(int) {
+			committed(i)
+		})
+		if err != nil {
+			errs = append(errs, failed(i, err))
+		} else if batchErr != nil {
+			errs = append(errs, *batchErr)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// countSources counts the sources in the database
+func countSources(t *testing.T, db *SQLiteDB) int {
+	t.Helper()
+	sources, _, err := db.ListSources(MaxBatchSize, "")
+	if err != nil {
+		t.Fatalf("ListSources() error = %v", err)
+	}
+	return len(sources)
+}
+
+// sourceInputs returns inputs for n new sources, followed by one whose URL
+// is taken by an existing source
+func sourceInputs(t *testing.T, db *SQLiteDB, n int) []CreateSourceInput {
+	t.Helper()
+	existing := newTestSource(t, db, "https://example.com/existing.xml")
+	inputs := make([]CreateSourceInput, 0, n+1)
+	for i := 0; i < n; i++ {
+		url := fmt.Sprintf("https://example.com/%d.xml", i)
+		inputs = append(inputs, CreateSourceInput{Name: url, URL: url})
+	}
+	return append(inputs, CreateSourceInput{Name: "Duplicate", URL: existing.URL})
+}
+
+func TestBatchCreateSourcesAtomic(t *testing.T) {
+	db := newTestDB(t)
+	inputs := sourceInputs(t, db, 3)
+
+	result, err := db.BatchCreateSources(BatchCreateSourcesInput{Sources: inputs, Atomic: true})
+	if err != nil {
+		t.Fatalf("BatchCreateSources() error = %v", err)
+	}
+	if !result.RolledBack || len(result.Sources) != 0 {
+		t.Errorf("rolled back %v with %d sources, want a rollback without sources", result.RolledBack, len(result.Sources))
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Index != 3 {
+		t.Errorf("errors = %+v, want one for item 3", result.Errors)
+	}
+	if n := countSources(t, db); n != 1 {
+		t.Errorf("%d sources stored, want only the existing one", n)
+	}
+}
+
+func TestBatchCreateSourcesPartial(t *testing.T) {
+	db := newTestDB(t)
+	inputs := sourceInputs(t, db, 3)
+
+	result, err := db.BatchCreateSources(BatchCreateSourcesInput{Sources: inputs})
+	if err != nil {
+		t.Fatalf("BatchCreateSources() error = %v", err)
+	}
+	if result.RolledBack || len(result.Sources) != 3 {
+		t.Errorf("rolled back %v with %d sources, want the 3 new ones", result.RolledBack, len(result.Sources))
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Index != 3 || result.Errors[0].ErrorType != "CreateError" {
+		t.Errorf("errors = %+v, want a CreateError for item 3", result.Errors)
+	}
+	if n := countSources(t, db); n != 4 {
+		t.Errorf("%d sources stored, want 4", n)
+	}
+}
+
+func TestBatchDeleteSourcesAtomic(t *testing.T) {
+	db := newTestDB(t)
+	first := newTestSource(t, db, "https://example.com/first.xml")
+	second := newTestSource(t, db, "https://example.com/second.xml")
+
+	result, err := db.BatchDeleteSources(BatchDeleteSourcesInput{SourceIDs: []string{first.ID, second.ID, "missing"}, Atomic: true})
+	if err != nil {
+		t.Fatalf("BatchDeleteSources() error = %v", err)
+	}
+	if !result.RolledBack || result.DeletedCount != 0 {
+		t.Errorf("rolled back %v with %d deleted, want a rollback", result.RolledBack, result.DeletedCount)
+	}
+	if n := countSources(t, db); n != 2 {
+		t.Errorf("%d sources left, want 2", n)
+	}
+}
+
+func TestBatchTooLarge(t *testing.T) {
+	db := newTestDB(t)
+	inputs := make([]CreateSourceInput, MaxBatchSize+1)
+	if _, err := db.BatchCreateSources(BatchCreateSourcesInput{Sources: inputs}); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("BatchCreateSources() of %d items error = %v, want ErrBatchTooLarge", len(inputs), err)
+	}
+	ids := make([]string, MaxBatchSize+1)
+	if _, err := db.BatchDeleteContents(BatchDeleteContentsInput{ContentIDs: ids}); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("BatchDeleteContents() of %d items error = %v, want ErrBatchTooLarge", len(ids), err)
+	}
+	if err := checkBatchSize(MaxBatchSize); err != nil {
+		t.Errorf("checkBatchSize(%d) error = %v", MaxBatchSize, err)
+	}
+}
+
+func TestBatchItemsReportedAfterCommit(t *testing.T) {
+	db := newTestDB(t)
+
+	// A deferred foreign key makes the commit fail after the item ran
+	for _, stmt := range []string{
+		"CREATE TABLE batch_parents (id TEXT PRIMARY KEY)",
+		"CREATE TABLE batch_children (parent_id TEXT REFERENCES batch_parents(id) DEFERRABLE INITIALLY DEFERRED)",
+		"INSERT INTO batch_parents (id) VALUES ('parent')",
+	} {
+		if _, err := db.db.Exec(stmt); err != nil {
+			t.Fatalf("failed to set up tables: %v", err)
+		}
+	}
+	parents := []string{"parent", "missing", "parent"}
+
+	var committed []int
+	errs := db.runBatchItems(len(parents), func(tx queryer, i int) *BatchError {
+		if _, err := tx.Exec("INSERT INTO batch_children (parent_id) VALUES (?)", parents[i]); err != nil {
+			return &BatchError{Index: i, ErrorType: "CreateError", Message: err.Error()}
+		}
+		return nil
+	}, func(i int) {
+		committed = append(committed, i)
+	}, func(i int, err error) BatchError {
+		return BatchError{Index: i, ErrorType: "CreateError", Message: err.Error()}
+	})
+
+	if len(committed) != 2 || committed[0] != 0 || committed[1] != 2 {
+		t.Errorf("committed items = %v, want [0 2]", committed)
+	}
+	if len(errs) != 1 || errs[0].Index != 1 {
+		t.Errorf("errors = %+v, want one for item 1", errs)
+	}
+}
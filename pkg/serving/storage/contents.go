@@ -11,17 +11,37 @@ import (
 
 // RSSContent represents an RSS content item
 type RSSContent struct {
-	ID          string     `json:"id"`
-	SourceID    string     `json:"sourceId"`
-	Title       string     `json:"title"`
-	Link        string     `json:"link"`
-	Description string     `json:"description"`
-	Content     string     `json:"content,omitempty"`
-	PublishedAt time.Time  `json:"publishedAt"`
-	FetchedAt   time.Time  `json:"fetchedAt"`
-	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
-	Author      string     `json:"author,omitempty"`
-	Categories  []string   `json:"categories,omitempty"`
+	ID       string `json:"id"`
+	SourceID string `json:"sourceId"`
+	Title    string `json:"title"`
+	Link     string `json:"link"`
+	// CanonicalLink is the link without tracking parameters and redirects,
+	// which identifies the article
+	CanonicalLink string     `json:"canonicalLink,omitempty"`
+	Description   string     `json:"description"`
+	Content       string     `json:"content,omitempty"`
+	PublishedAt   time.Time  `json:"publishedAt"`
+	FetchedAt     time.Time  `json:"fetchedAt"`
+	UpdatedAt     *time.Time `json:"updatedAt,omitempty"`
+	Author        string     `json:"author,omitempty"`
+	Categories    []string   `json:"categories,omitempty"`
+	// Tags are the requesting user's personal tags, when there is a user
+	Tags []string `json:"tags,omitempty"`
+	// State is the requesting user's reading state, when there is one
+	State *ContentState `json:"state,omitempty"`
+	// Hidden is set for items hidden by a filter rule, which are left out of
+	// listings, searches and recommendations
+	Hidden bool `json:"hidden,omitempty"`
+	// StoryID is set for items with near-duplicates, which together make up
+	// a story
+	StoryID string `json:"storyId,omitempty"`
+	// Language is the ISO 639-1 code of the language of the item's text,
+	// detected from the text or, if it is too short to tell, declared by
+	// the feed
+	Language string `json:"language,omitempty"`
+	// SimHash is the signature of the item's text used to find its
+	// near-duplicates, or 0 if it has none
+	SimHash uint64 `json:"-"`
 }
 
 // UpdateContentInput represents the input for updating an RSS content item
@@ -32,15 +52,83 @@ type UpdateContentInput struct {
 	Categories  []string `json:"categories"`
 }
 
+// BatchUpdateContentInput represents a single content update in a batch
+type BatchUpdateContentInput struct {
+	ID string `json:"id"`
+	UpdateContentInput
+}
+
+// BatchUpdateContentsInput represents the input for batch updating RSS content items
+type BatchUpdateContentsInput struct {
+	Contents []BatchUpdateContentInput `json:"contents"`
+	// Atomic runs the whole batch in one transaction and rolls back on any error
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// BatchUpdateContentsResult represents the result of batch updating RSS content items
+type BatchUpdateContentsResult struct {
+	Contents   []RSSContent `json:"contents"`
+	Errors     []BatchError `json:"errors"`
+	RolledBack bool         `json:"rolledBack,omitempty"`
+}
+
 // BatchDeleteContentsInput represents the input for batch deleting RSS content items
 type BatchDeleteContentsInput struct {
 	ContentIDs []string `json:"contentIds"`
+	// Atomic runs the whole batch in one transaction and rolls back on any error
+	Atomic bool `json:"atomic,omitempty"`
 }
 
 // BatchDeleteContentsResult represents the result of batch deleting RSS content items
 type BatchDeleteContentsResult struct {
 	DeletedCount int          `json:"deletedCount"`
 	Errors       []BatchError `json:"errors"`
+	RolledBack   bool         `json:"rolledBack,omitempty"`
+}
+
+// ListContentsInput represents the filters and pagination for listing RSS content items
+type ListContentsInput struct {
+	// UserID limits the results to sources the user is subscribed to
+	UserID string
+	// Folder limits the results to the user's items in this folder
+	Folder string
+	// MinPriority limits the results to items of at least this priority
+	MinPriority *int
+	// Collapse lists only the first item the user can see of each story
+	Collapse bool
+	// Language limits the results to items in this language
+	Language string
+	// Read, Starred and ReadLater filter on the user's reading state when set
+	Read      *bool
+	Starred   *bool
+	ReadLater *bool
+	// Tags limits the results to items carrying all of these user tags
+	Tags      []string
+	SourceID  string
+	StartDate time.Time
+	EndDate   time.Time
+	Limit     int
+	NextToken string
+	// Newest orders the results by publish date, newest first, instead of
+	// paginating through them by ID; NextToken is ignored
+	Newest bool
+	// Hidden lists only the items hidden by filter rules instead of leaving
+	// them out
+	Hidden bool
+}
+
+// SearchContentsInput represents the query and filters for searching RSS content items
+type SearchContentsInput struct {
+	// Keywords is a comma-separated list of keywords, any of which must match
+	Keywords string
+	// UserID limits the results to sources the user is subscribed to
+	UserID string
+	// Tags limits the results to items carrying all of these user tags
+	Tags     []string
+	SourceID string
+	// Language limits the results to items in this language
+	Language string
+	Limit    int
 }
 
 // FetchJob represents an RSS content fetch job
@@ -57,6 +145,30 @@ type FetchJob struct {
 
 // CreateContent creates a new RSS content item
 func (s *SQLiteDB) CreateContent(content *RSSContent) error {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	terms, err := createContent(tx, content)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.addToCorpus(terms)
+	return nil
+}
+
+// createContent creates a new RSS content item using the given queryer and
+// returns its search terms
+func createContent(q queryer, content *RSSContent) (string, error) {
 	// Generate a new UUID if not provided
 	if content.ID == "" {
 		content.ID = uuid.New().String()
@@ -67,68 +179,88 @@ func (s *SQLiteDB) CreateContent(content *RSSContent) error {
 		content.FetchedAt = time.Now().UTC()
 	}
 
-	// Begin transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
 	// Insert the content into the database
-	_, err = tx.Exec(
-		`INSERT INTO rss_contents (id, source_id, title, link, description, content, published_at, fetched_at, author)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		content.ID, content.SourceID, content.Title, content.Link, content.Description,
-		content.Content, content.PublishedAt, content.FetchedAt, content.Author,
+	var hiddenAt interface{}
+	if content.Hidden {
+		hiddenAt = content.FetchedAt
+	}
+	if content.CanonicalLink == "" {
+		content.CanonicalLink = content.Link
+	}
+	language, terms := indexText(content.Title, content.Description, content.Content, content.Language)
+	content.Language = language
+	_, err := q.Exec(
+		`INSERT INTO rss_contents (id, source_id, title, link, canonical_link, description, content, published_at, fetched_at,
+			author, hidden_at, simhash, language, search_terms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		content.ID, content.SourceID, content.Title, content.Link, content.CanonicalLink, content.Description,
+		content.Content, content.PublishedAt, content.FetchedAt, content.Author, hiddenAt, nullSimHash(content.SimHash),
+		nullString(language), terms,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create RSS content: %w", err)
+		return "", fmt.Errorf("failed to create RSS content: %w", err)
 	}
 
-	// Insert categories if provided
-	if len(content.Categories) > 0 {
-		for _, category := range content.Categories {
-			_, err = tx.Exec(
-				"INSERT INTO content_categories (content_id, category) VALUES (?, ?)",
-				content.ID, category,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to insert category: %w", err)
-			}
+	// Group the content with its near-duplicates
+	if content.SimHash != 0 {
+		if err := assignStory(q, content); err != nil {
+			return "", err
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Record the published text as the first revision
+	_, err = insertRevision(q, content.ID, content.Title, content.Description, content.Content, RevisionOriginFeed, nil)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	// Insert categories if provided
+	for _, category := range content.Categories {
+		_, err = q.Exec(
+			"INSERT INTO content_categories (content_id, category) VALUES (?, ?)",
+			content.ID, category,
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert category: %w", err)
+		}
+	}
+
+	return terms, nil
 }
 
 // GetContent retrieves an RSS content item by ID
 func (s *SQLiteDB) GetContent(id string) (*RSSContent, error) {
+	return getContent(s.readDB, id)
+}
+
+// getContent retrieves an RSS content item by ID using the given queryer
+func getContent(q queryer, id string) (*RSSContent, error) {
 	// Query the content
 	var content RSSContent
-	var updatedAt sql.NullTime
+	var updatedAt, hiddenAt sql.NullTime
 	var author sql.NullString
 	var contentText sql.NullString
 
-	err := s.db.QueryRow(
-		`SELECT id, source_id, title, link, description, content, published_at, fetched_at, updated_at, author
-		FROM rss_contents WHERE id = ?`,
+	err := q.QueryRow(
+		`SELECT id, source_id, title, link, COALESCE(canonical_link, ''), description, content, published_at, fetched_at,
+			updated_at, author, hidden_at, COALESCE(story_id, ''), COALESCE(language, '')
+		FROM rss_contents WHERE id = ? AND deleted_at IS NULL`,
 		id,
 	).Scan(
 		&content.ID,
 		&content.SourceID,
 		&content.Title,
 		&content.Link,
+		&content.CanonicalLink,
 		&content.Description,
 		&contentText,
 		&content.PublishedAt,
 		&content.FetchedAt,
 		&updatedAt,
 		&author,
+		&hiddenAt,
+		&content.StoryID,
+		&content.Language,
 	)
 
 	if err == sql.ErrNoRows {
@@ -147,9 +279,10 @@ func (s *SQLiteDB) GetContent(id string) (*RSSContent, error) {
 	if contentText.Valid {
 		content.Content = contentText.String
 	}
+	content.Hidden = hiddenAt.Valid
 
 	// Query categories
-	rows, err := s.db.Query(
+	rows, err := q.Query(
 		"SELECT category FROM content_categories WHERE content_id = ?",
 		id,
 	)
@@ -177,21 +310,37 @@ func (s *SQLiteDB) GetContent(id string) (*RSSContent, error) {
 
 // UpdateContent updates an RSS content item
 func (s *SQLiteDB) UpdateContent(id string, input UpdateContentInput) (*RSSContent, error) {
-	// Check if the content exists
-	content, err := s.GetContent(id)
+	// Begin transaction
+	tx, err := s.db.Begin()
 	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	content, err := updateContent(tx, id, input)
+	if err != nil || content == nil {
 		return nil, err
 	}
-	if content == nil {
-		return nil, nil // Content not found
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Begin transaction
-	tx, err := s.db.Begin()
+	return content, nil
+}
+
+// updateContent updates an RSS content item and its categories. The caller
+// is responsible for running it inside a transaction.
+func updateContent(tx queryer, id string, input UpdateContentInput) (*RSSContent, error) {
+	// Check if the content exists
+	content, err := getContent(tx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
+	}
+	if content == nil {
+		return nil, nil // Content not found
 	}
-	defer tx.Rollback()
 
 	// Update the content
 	now := time.Now().UTC()
@@ -204,6 +353,20 @@ func (s *SQLiteDB) UpdateContent(id string, input UpdateContentInput) (*RSSConte
 	if err != nil {
 		return nil, fmt.Errorf("failed to update RSS content: %w", err)
 	}
+	if err := reindexContent(tx, id, input.Title, input.Description, input.Content); err != nil {
+		return nil, err
+	}
+
+	// Record a user revision if the text changed
+	if input.Title != content.Title || input.Description != content.Description || input.Content != content.Content {
+		if err := ensureBaselineRevision(tx, content); err != nil {
+			return nil, err
+		}
+		_, err = insertRevision(tx, id, input.Title, input.Description, input.Content, RevisionOriginUser, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Delete existing categories
 	_, err = tx.Exec("DELETE FROM content_categories WHERE content_id = ?", id)
@@ -222,11 +385,6 @@ func (s *SQLiteDB) UpdateContent(id string, input UpdateContentInput) (*RSSConte
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	// Update the content object
 	content.Title = input.Title
 	content.Description = input.Description
@@ -237,65 +395,138 @@ func (s *SQLiteDB) UpdateContent(id string, input UpdateContentInput) (*RSSConte
 	return content, nil
 }
 
-// DeleteContent deletes an RSS content item
+// DeleteContent moves an RSS content item to the trash
 func (s *SQLiteDB) DeleteContent(id string) error {
-	// Check if the content exists
-	content, err := s.GetContent(id)
-	if err != nil {
-		return err
-	}
-	if content == nil {
-		return nil // Content not found
+	deleted, err := deleteContent(s.db, id)
+	if deleted {
+		s.resetCorpus()
 	}
+	return err
+}
 
-	// Delete the content (categories will be deleted via CASCADE)
-	_, err = s.db.Exec("DELETE FROM rss_contents WHERE id = ?", id)
+// deleteContent moves an RSS content item to the trash using the given
+// queryer and reports whether a content item with the given ID existed
+func deleteContent(q queryer, id string) (bool, error) {
+	res, err := q.Exec(
+		"UPDATE rss_contents SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UTC(), id,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to delete RSS content: %w", err)
+		return false, fmt.Errorf("failed to delete RSS content: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil // Content not found
 	}
 
+	return true, nil
+}
+
+// contentDataTables are the tables holding data about RSS contents, keyed by
+// content_id
+var contentDataTables = []string{
+	"content_categories", "content_revisions", "content_states", "content_tags", "digest_items", "recommendation_feedback",
+}
+
+// deleteContentData deletes the data about the contents matching the given
+// condition
+func deleteContentData(q queryer, condition string, args ...interface{}) error {
+	for _, table := range contentDataTables {
+		_, err := q.Exec("DELETE FROM "+table+" WHERE content_id IN (SELECT id FROM rss_contents WHERE "+condition+")", args...)
+		if err != nil {
+			return fmt.Errorf("failed to delete content data from %s: %w", table, err)
+		}
+	}
 	return nil
 }
 
 // ListContents lists RSS content items with filtering and pagination
-func (s *SQLiteDB) ListContents(sourceID string, startDate, endDate time.Time, limit int, nextToken string) ([]RSSContent, string, error) {
+func (s *SQLiteDB) ListContents(input ListContentsInput) ([]RSSContent, string, error) {
 	// Default limit if not specified
+	limit := input.Limit
 	if limit <= 0 {
 		limit = 50
 	}
 
-	// Build the query
-	query := `
-		SELECT c.id, c.source_id, c.title, c.link, c.description, c.published_at, c.fetched_at
-		FROM rss_contents c
-		WHERE 1=1
-	`
+	// Build the query, including the user's reading state if there is a user
+	query := "SELECT c.id, c.source_id, c.title, c.link, COALESCE(c.canonical_link, ''), c.description, c.published_at, c.fetched_at, " +
+		"COALESCE(c.story_id, ''), COALESCE(c.language, '')"
 	args := []interface{}{}
+	if input.UserID != "" {
+		query += ", " + stateColumns + " FROM rss_contents c" + stateJoin
+		args = append(args, input.UserID)
+	} else {
+		query += " FROM rss_contents c"
+	}
+	query += " WHERE c.deleted_at IS NULL"
+	if input.Hidden {
+		query += " AND c.hidden_at IS NOT NULL"
+	} else {
+		query += " AND c.hidden_at IS NULL"
+	}
 
 	// Add filters
-	if sourceID != "" {
+	if input.UserID != "" {
+		if input.Read != nil {
+			query += stateCondition("read_at", *input.Read)
+		}
+		if input.Starred != nil {
+			query += stateCondition("starred_at", *input.Starred)
+		}
+		if input.ReadLater != nil {
+			query += stateCondition("read_later_at", *input.ReadLater)
+		}
+		query += " AND c.source_id IN (" + subscribedSourcesQuery + ")"
+		args = append(args, input.UserID)
+		if input.Folder != "" {
+			condition, folderArgs := folderCondition(input.UserID, input.Folder)
+			query += condition
+			args = append(args, folderArgs...)
+		}
+		if input.MinPriority != nil {
+			query += " AND COALESCE(st.priority, 0) >= ?"
+			args = append(args, *input.MinPriority)
+		}
+		if input.Collapse {
+			condition, collapseArgs := collapseCondition(input.UserID)
+			query += condition
+			args = append(args, collapseArgs...)
+		}
+		conditions, tagArgs := tagConditions(input.UserID, input.Tags)
+		query += conditions
+		args = append(args, tagArgs...)
+	}
+	if input.SourceID != "" {
 		query += " AND c.source_id = ?"
-		args = append(args, sourceID)
+		args = append(args, input.SourceID)
 	}
-	if !startDate.IsZero() {
+	if input.Language != "" {
+		query += " AND c.language = ?"
+		args = append(args, input.Language)
+	}
+	if !input.StartDate.IsZero() {
 		query += " AND c.published_at >= ?"
-		args = append(args, startDate)
+		args = append(args, input.StartDate)
 	}
-	if !endDate.IsZero() {
+	if !input.EndDate.IsZero() {
 		query += " AND c.published_at <= ?"
-		args = append(args, endDate)
-	}
-	if nextToken != "" {
-		query += " AND c.id > ?"
-		args = append(args, nextToken)
+		args = append(args, input.EndDate)
 	}
+	if input.Newest {
+		query += " ORDER BY c.published_at DESC, c.id ASC LIMIT ?"
+		args = append(args, limit)
+	} else {
+		if input.NextToken != "" {
+			query += " AND c.id > ?"
+			args = append(args, input.NextToken)
+		}
 
-	// Add ordering and limit
-	query += " ORDER BY c.id ASC LIMIT ?"
-	args = append(args, limit+1) // Fetch one extra to determine if there are more results
+		// Add ordering and limit
+		query += " ORDER BY c.id ASC LIMIT ?"
+		args = append(args, limit+1) // Fetch one extra to determine if there are more results
+	}
 
 	// Execute the query
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to list RSS contents: %w", err)
 	}
@@ -305,15 +536,23 @@ func (s *SQLiteDB) ListContents(sourceID string, startDate, endDate time.Time, l
 	var contents []RSSContent
 	for rows.Next() {
 		var content RSSContent
-		err := rows.Scan(
+		dest := []interface{}{
 			&content.ID,
 			&content.SourceID,
 			&content.Title,
 			&content.Link,
+			&content.CanonicalLink,
 			&content.Description,
 			&content.PublishedAt,
 			&content.FetchedAt,
-		)
+			&content.StoryID,
+			&content.Language,
+		}
+		if input.UserID != "" {
+			content.State = &ContentState{}
+			dest = append(dest, stateDest(content.State)...)
+		}
+		err := rows.Scan(dest...)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to scan RSS content: %w", err)
 		}
@@ -332,30 +571,130 @@ func (s *SQLiteDB) ListContents(sourceID string, startDate, endDate time.Time, l
 		contents = contents[:limit] // Remove the extra item
 	}
 
+	// Attach the user's tags
+	if input.UserID != "" {
+		if err := s.loadContentTags(input.UserID, contents); err != nil {
+			return nil, "", err
+		}
+	}
+
 	return contents, newNextToken, nil
 }
 
+// BatchUpdateContents updates multiple RSS content items
+func (s *SQLiteDB) BatchUpdateContents(input BatchUpdateContentsInput) (*BatchUpdateContentsResult, error) {
+	if err := checkBatchSize(len(input.Contents)); err != nil {
+		return nil, err
+	}
+
+	contents := make([]*RSSContent, len(input.Contents))
+	result := &BatchUpdateContentsResult{
+		Contents: []RSSContent{},
+		Errors:   []BatchError{},
+	}
+
+	updateOne := func(tx queryer, i int) *BatchError {
+		item := input.Contents[i]
+		content, err := updateContent(tx, item.ID, item.UpdateContentInput)
+		if err != nil {
+			return &BatchError{
+				Index:     i,
+				ContentID: item.ID,
+				ErrorType: "UpdateError",
+				Message:   err.Error(),
+			}
+		}
+		if content == nil {
+			return &BatchError{
+				Index:     i,
+				ContentID: item.ID,
+				ErrorType: "NotFound",
+				Message:   "Content not found",
+			}
+		}
+		contents[i] = content
+		return nil
+	}
+	report := func(i int) {
+		result.Contents = append(result.Contents, *contents[i])
+	}
+
+	// Run the whole batch in one transaction if requested
+	if input.Atomic {
+		batchErr, err := s.runAtomicBatch(len(input.Contents), updateOne, report)
+		if err != nil {
+			return nil, err
+		}
+		if batchErr != nil {
+			result.Errors = append(result.Errors, *batchErr)
+			result.RolledBack = true
+		}
+		return result, nil
+	}
+
+	// Each content item is updated in its own transaction
+	result.Errors = s.runBatchItems(len(input.Contents), updateOne, report, func(i int, err error) BatchError {
+		return BatchError{Index: i, ContentID: input.Contents[i].ID, ErrorType: "UpdateError", Message: err.Error()}
+	})
+
+	return result, nil
+}
+
 // BatchDeleteContents deletes multiple RSS content items
 func (s *SQLiteDB) BatchDeleteContents(input BatchDeleteContentsInput) (*BatchDeleteContentsResult, error) {
+	if err := checkBatchSize(len(input.ContentIDs)); err != nil {
+		return nil, err
+	}
+
 	result := &BatchDeleteContentsResult{
 		DeletedCount: 0,
 		Errors:       []BatchError{},
 	}
 
-	// Process each content ID
-	for _, id := range input.ContentIDs {
-		err := s.DeleteContent(id)
+	deleteOne := func(q queryer, i int) *BatchError {
+		id := input.ContentIDs[i]
+		deleted, err := deleteContent(q, id)
 		if err != nil {
-			result.Errors = append(result.Errors, BatchError{
-				SourceID:  id, // Reusing SourceID field for ContentID
+			return &BatchError{
+				Index:     i,
+				ContentID: id,
 				ErrorType: "DeleteError",
 				Message:   err.Error(),
-			})
-		} else {
-			result.DeletedCount++
+			}
+		}
+		if !deleted {
+			return &BatchError{
+				Index:     i,
+				ContentID: id,
+				ErrorType: "NotFound",
+				Message:   "Content not found",
+			}
 		}
+		return nil
+	}
+	report := func(int) {
+		result.DeletedCount++
+		s.resetCorpus()
 	}
 
+	// Run the whole batch in one transaction if requested
+	if input.Atomic {
+		batchErr, err := s.runAtomicBatch(len(input.ContentIDs), deleteOne, report)
+		if err != nil {
+			return nil, err
+		}
+		if batchErr != nil {
+			result.Errors = append(result.Errors, *batchErr)
+			result.RolledBack = true
+		}
+		return result, nil
+	}
+
+	// Each content item is deleted in its own transaction
+	result.Errors = s.runBatchItems(len(input.ContentIDs), deleteOne, report, func(i int, err error) BatchError {
+		return BatchError{Index: i, ContentID: input.ContentIDs[i], ErrorType: "DeleteError", Message: err.Error()}
+	})
+
 	return result, nil
 }
 
@@ -394,7 +733,7 @@ func (s *SQLiteDB) GetFetchJob(id string) (*FetchJob, error) {
 	var completedAt sql.NullTime
 
 	// Query the job
-	err := s.db.QueryRow(
+	err := s.readDB.QueryRow(
 		`SELECT id, status, started_at, completed_at, items_processed, source_id, days
 		FROM fetch_jobs WHERE id = ?`,
 		id,
@@ -423,7 +762,7 @@ func (s *SQLiteDB) GetFetchJob(id string) (*FetchJob, error) {
 	}
 
 	// Query job errors
-	rows, err := s.db.Query(
+	rows, err := s.readDB.Query(
 		"SELECT error_message FROM job_errors WHERE job_id = ? ORDER BY timestamp",
 		id,
 	)
@@ -499,10 +838,14 @@ func (s *SQLiteDB) UpdateFetchJobStatus(id string, status string, itemsProcessed
 	return nil
 }
 
-// GetContentByURL retrieves an RSS content item by URL
-func (s *SQLiteDB) GetContentByURL(url string) (*RSSContent, error) {
+// GetContentByURL retrieves an RSS content item by its link or canonical
+// link. Items stored before links were canonicalized only match their link.
+func (s *SQLiteDB) GetContentByURL(link, canonicalLink string) (*RSSContent, error) {
 	var id string
-	err := s.db.QueryRow("SELECT id FROM rss_contents WHERE link = ?", url).Scan(&id)
+	err := s.readDB.QueryRow(
+		"SELECT id FROM rss_contents WHERE (canonical_link = ? OR link = ?) AND deleted_at IS NULL ORDER BY fetched_at ASC LIMIT 1",
+		canonicalLink, link,
+	).Scan(&id)
 	if err == sql.ErrNoRows {
 		return nil, nil // Content not found
 	} else if err != nil {
@@ -513,42 +856,54 @@ func (s *SQLiteDB) GetContentByURL(url string) (*RSSContent, error) {
 }
 
 // SearchContents searches for RSS content items by keywords
-func (s *SQLiteDB) SearchContents(keywords string, sourceID string, limit int) ([]RSSContent, error) {
+func (s *SQLiteDB) SearchContents(input SearchContentsInput) ([]RSSContent, error) {
 	// Default limit if not specified
+	limit := input.Limit
 	if limit <= 0 {
 		limit = 50
 	}
 
 	// Split keywords
-	keywordList := strings.Split(keywords, ",")
+	keywordList := strings.Split(input.Keywords, ",")
 	for i, k := range keywordList {
 		keywordList[i] = strings.TrimSpace(k)
 	}
 
 	// Build the query
 	query := `
-		SELECT c.id, c.source_id, c.title, c.link, c.description, c.published_at, c.fetched_at
+		SELECT c.id, c.source_id, c.title, c.link, c.description, c.published_at, c.fetched_at, COALESCE(c.language, '')
 		FROM rss_contents c
-		WHERE 1=1
+		WHERE c.deleted_at IS NULL AND c.hidden_at IS NULL
 	`
 	args := []interface{}{}
 
-	// Add source filter if provided
-	if sourceID != "" {
+	// Add subscription and source filters if provided
+	if input.UserID != "" {
+		query += " AND c.source_id IN (" + subscribedSourcesQuery + ")"
+		args = append(args, input.UserID)
+		conditions, tagArgs := tagConditions(input.UserID, input.Tags)
+		query += conditions
+		args = append(args, tagArgs...)
+	}
+	if input.SourceID != "" {
 		query += " AND c.source_id = ?"
-		args = append(args, sourceID)
+		args = append(args, input.SourceID)
+	}
+	if input.Language != "" {
+		query += " AND c.language = ?"
+		args = append(args, input.Language)
 	}
 
-	// Add keyword filters
+	// Add keyword filters, matching the terms of each keyword
 	if len(keywordList) > 0 {
 		query += " AND ("
 		for i, keyword := range keywordList {
 			if i > 0 {
 				query += " OR "
 			}
-			query += "c.title LIKE ? OR c.description LIKE ? OR c.content LIKE ?"
-			likePattern := "%" + keyword + "%"
-			args = append(args, likePattern, likePattern, likePattern)
+			condition, keywordArgs := keywordCondition(keyword)
+			query += condition
+			args = append(args, keywordArgs...)
 		}
 		query += ")"
 	}
@@ -558,7 +913,7 @@ func (s *SQLiteDB) SearchContents(keywords string, sourceID string, limit int) (
 	args = append(args, limit)
 
 	// Execute the query
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search RSS contents: %w", err)
 	}
@@ -576,6 +931,7 @@ func (s *SQLiteDB) SearchContents(keywords string, sourceID string, limit int) (
 			&content.Description,
 			&content.PublishedAt,
 			&content.FetchedAt,
+			&content.Language,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan RSS content: %w", err)
@@ -588,5 +944,12 @@ func (s *SQLiteDB) SearchContents(keywords string, sourceID string, limit int) (
 		return nil, fmt.Errorf("error iterating over RSS contents: %w", err)
 	}
 
+	// Attach the user's tags
+	if input.UserID != "" {
+		if err := s.loadContentTags(input.UserID, contents); err != nil {
+			return nil, err
+		}
+	}
+
 	return contents, nil
 }
@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Digest frequencies
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestConfirmationInterval is the least time between two emails asking a
+// user to confirm their digest address
+const DigestConfirmationInterval = 10 * time.Minute
+
+var (
+	// ErrInvalidDigestSubscription is returned for digest settings that are not valid
+	ErrInvalidDigestSubscription = errors.New("invalid digest subscription")
+	// ErrDigestNotConfirmed is returned when sending a digest to an address
+	// that has not been confirmed
+	ErrDigestNotConfirmed = errors.New("the digest address has not been confirmed")
+)
+
+// DigestSubscription is a user's schedule for email digests of their top
+// recommendations
+type DigestSubscription struct {
+	UserID    string `json:"userId"`
+	Email     string `json:"email"`
+	Frequency string `json:"frequency"`
+	// SendTime is the local time of day the digest is sent at, as HH:MM
+	SendTime string `json:"sendTime"`
+	// Timezone is the IANA time zone SendTime is in
+	Timezone string `json:"timezone"`
+	// Weekday is the day weekly digests are sent on, 0 being Sunday
+	Weekday          time.Weekday `json:"weekday"`
+	Enabled          bool         `json:"enabled"`
+	UnsubscribeToken string       `json:"-"`
+	// Confirmed reports whether the owner of the address followed the
+	// confirmation link; digests are only sent to confirmed addresses
+	Confirmed          bool       `json:"confirmed"`
+	ConfirmationToken  string     `json:"-"`
+	ConfirmationSentAt *time.Time `json:"confirmationSentAt,omitempty"`
+	LastSentAt         *time.Time `json:"lastSentAt,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+// DigestSubscriptionInput represents the input for setting a user's digest schedule
+type DigestSubscriptionInput struct {
+	Email     string       `json:"email" binding:"required"`
+	Frequency string       `json:"frequency"`
+	SendTime  string       `json:"sendTime"`
+	Timezone  string       `json:"timezone"`
+	Weekday   time.Weekday `json:"weekday"`
+}
+
+// Digest records a digest sent to a user
+type Digest struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Email     string    `json:"email"`
+	Frequency string    `json:"frequency"`
+	Subject   string    `json:"subject"`
+	ItemCount int       `json:"itemCount"`
+	SentAt    time.Time `json:"sentAt"`
+}
+
+// ParseSendTime parses a time of day given as HH:MM and returns the hour and minute
+func ParseSendTime(value string) (int, int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: send time must be given as HH:MM", ErrInvalidDigestSubscription)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// LastScheduled returns the most recent time at or before now the digest
+// was scheduled to be sent
+func (d *DigestSubscription) LastScheduled(now time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load time zone %s: %w", d.Timezone, err)
+	}
+	hour, minute, err := ParseSendTime(d.SendTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := now.In(location)
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, location)
+	if scheduled.After(local) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	if d.Frequency == DigestWeekly {
+		for scheduled.Weekday() != d.Weekday {
+			scheduled = scheduled.AddDate(0, 0, -1)
+		}
+	}
+	return scheduled, nil
+}
+
+// Due reports whether the digest has not been sent since it was last scheduled
+func (d *DigestSubscription) Due(now time.Time) (bool, error) {
+	if !d.Enabled || !d.Confirmed {
+		return false, nil
+	}
+	scheduled, err := d.LastScheduled(now)
+	if err != nil {
+		return false, err
+	}
+
+	// Only send digests scheduled after the subscription was set up
+	since := d.CreatedAt
+	if d.LastSentAt != nil {
+		since = *d.LastSentAt
+	}
+	return scheduled.After(since), nil
+}
+
+// normalizeDigestSubscription validates a digest subscription input and
+// fills in the defaults
+func normalizeDigestSubscription(input *DigestSubscriptionInput, defaultSendTime, defaultTimezone string) error {
+	address, err := mail.ParseAddress(strings.TrimSpace(input.Email))
+	if err != nil {
+		return fmt.Errorf("%w: invalid email address", ErrInvalidDigestSubscription)
+	}
+	input.Email = address.Address
+
+	if input.Frequency == "" {
+		input.Frequency = DigestDaily
+	}
+	if input.Frequency != DigestDaily && input.Frequency != DigestWeekly {
+		return fmt.Errorf("%w: frequency must be %s or %s", ErrInvalidDigestSubscription, DigestDaily, DigestWeekly)
+	}
+
+	if input.SendTime == "" {
+		input.SendTime = defaultSendTime
+	}
+	hour, minute, err := ParseSendTime(input.SendTime)
+	if err != nil {
+		return err
+	}
+	input.SendTime = fmt.Sprintf("%02d:%02d", hour, minute)
+
+	if input.Timezone == "" {
+		input.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(input.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidDigestSubscription, input.Timezone)
+	}
+
+	if input.Weekday < time.Sunday || input.Weekday > time.Saturday {
+		return fmt.Errorf("%w: weekday must be between 0 (Sunday) and 6 (Saturday)", ErrInvalidDigestSubscription)
+	}
+	return nil
+}
+
+// generateDigestToken generates the token of a user's unsubscribe or
+// confirmation links
+func generateDigestToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate digest token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// digestSubscriptionColumns is the column list used to scan digest subscriptions
+const digestSubscriptionColumns = `user_id, email, frequency, send_time, timezone, weekday, enabled,
+	unsubscribe_token, confirm_token, confirmed_at IS NOT NULL, confirm_sent_at, last_sent_at, created_at, updated_at`
+
+// scanDigestSubscription scans a digest subscription from a row
+func scanDigestSubscription(scan func(dest ...interface{}) error) (*DigestSubscription, error) {
+	var d DigestSubscription
+	var confirmationToken sql.NullString
+	var confirmationSentAt, lastSentAt sql.NullTime
+	err := scan(&d.UserID, &d.Email, &d.Frequency, &d.SendTime, &d.Timezone, &d.Weekday, &d.Enabled,
+		&d.UnsubscribeToken, &confirmationToken, &d.Confirmed, &confirmationSentAt, &lastSentAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.ConfirmationToken = confirmationToken.String
+	if confirmationSentAt.Valid {
+		d.ConfirmationSentAt = &confirmationSentAt.Time
+	}
+	if lastSentAt.Valid {
+		d.LastSentAt = &lastSentAt.Time
+	}
+	return &d, nil
+}
+
+// GetDigestSubscription retrieves a user's digest subscription. It returns
+// nil if the user has none.
+func (s *SQLiteDB) GetDigestSubscription(userID string) (*DigestSubscription, error) {
+	row := s.readDB.QueryRow("SELECT "+digestSubscriptionColumns+" FROM digest_subscriptions WHERE user_id = ?", userID)
+	d, err := scanDigestSubscription(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // No digest subscription
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get digest subscription: %w", err)
+	}
+	return d, nil
+}
+
+// SetDigestSubscription creates or replaces a user's digest subscription and
+// enables it. The send time and time zone default to the given values. A new
+// address has to be confirmed before digests are sent to it.
+func (s *SQLiteDB) SetDigestSubscription(userID string, input DigestSubscriptionInput, defaultSendTime, defaultTimezone string) (*DigestSubscription, error) {
+	if err := normalizeDigestSubscription(&input, defaultSendTime, defaultTimezone); err != nil {
+		return nil, err
+	}
+	unsubscribeToken, err := generateDigestToken()
+	if err != nil {
+		return nil, err
+	}
+	confirmationToken, err := generateDigestToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the unsubscribe token and send history of an existing
+	// subscription, and its confirmation unless the address changed
+	now := time.Now().UTC()
+	_, err = s.db.Exec(
+		`INSERT INTO digest_subscriptions (user_id, email, frequency, send_time, timezone, weekday, enabled,
+			unsubscribe_token, confirm_token, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET email = excluded.email, frequency = excluded.frequency,
+			send_time = excluded.send_time, timezone = excluded.timezone, weekday = excluded.weekday,
+			enabled = 1, updated_at = excluded.updated_at,
+			confirm_token = CASE WHEN email = excluded.email AND confirm_token IS NOT NULL THEN confirm_token ELSE excluded.confirm_token END,
+			confirmed_at = CASE WHEN email = excluded.email THEN confirmed_at ELSE NULL END`,
+		userID, input.Email, input.Frequency, input.SendTime, input.Timezone, input.Weekday,
+		unsubscribeToken, confirmationToken, now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set digest subscription: %w", err)
+	}
+
+	return s.GetDigestSubscription(userID)
+}
+
+// DeleteDigestSubscription removes a user's digest subscription. It reports
+// whether there was one.
+func (s *SQLiteDB) DeleteDigestSubscription(userID string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM digest_subscriptions WHERE user_id = ?", userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete digest subscription: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// UnsubscribeDigest disables the digest subscription an unsubscribe token
+// belongs to. It returns nil if the token is not valid.
+func (s *SQLiteDB) UnsubscribeDigest(token string) (*DigestSubscription, error) {
+	if token == "" {
+		return nil, nil
+	}
+	res, err := s.db.Exec(
+		"UPDATE digest_subscriptions SET enabled = 0, updated_at = ? WHERE unsubscribe_token = ?",
+		time.Now().UTC(), token,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unsubscribe from digest: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Token not valid
+	}
+
+	return s.GetDigestSubscriptionByToken(token)
+}
+
+// GetDigestSubscriptionByToken retrieves the digest subscription an
+// unsubscribe token belongs to. It returns nil if the token is not valid.
+func (s *SQLiteDB) GetDigestSubscriptionByToken(token string) (*DigestSubscription, error) {
+	if token == "" {
+		return nil, nil
+	}
+	row := s.db.QueryRow("SELECT "+digestSubscriptionColumns+" FROM digest_subscriptions WHERE unsubscribe_token = ?", token)
+	d, err := scanDigestSubscription(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Token not valid
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get digest subscription: %w", err)
+	}
+	return d, nil
+}
+
+// ClaimDigestConfirmation records that an email asking to confirm a user's
+// digest address is being sent. It reports false without recording anything
+// if the address is already confirmed or the last such email was sent less
+// than DigestConfirmationInterval ago.
+func (s *SQLiteDB) ClaimDigestConfirmation(userID string, now time.Time) (bool, error) {
+	now = now.UTC()
+	res, err := s.db.Exec(
+		`UPDATE digest_subscriptions SET confirm_sent_at = ?
+		WHERE user_id = ? AND confirmed_at IS NULL AND (confirm_sent_at IS NULL OR confirm_sent_at <= ?)`,
+		now, userID, now.Add(-DigestConfirmationInterval),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update digest subscription: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetDigestSubscriptionByConfirmToken retrieves the digest subscription a
+// confirmation token belongs to. It returns nil if the token is not valid.
+func (s *SQLiteDB) GetDigestSubscriptionByConfirmToken(token string) (*DigestSubscription, error) {
+	if token == "" {
+		return nil, nil
+	}
+	row := s.db.QueryRow("SELECT "+digestSubscriptionColumns+" FROM digest_subscriptions WHERE confirm_token = ?", token)
+	d, err := scanDigestSubscription(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Token not valid
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get digest subscription: %w", err)
+	}
+	return d, nil
+}
+
+// ConfirmDigestSubscription confirms the address of the digest subscription
+// a confirmation token belongs to. It returns nil if the token is not valid.
+func (s *SQLiteDB) ConfirmDigestSubscription(token string) (*DigestSubscription, error) {
+	if token == "" {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	_, err := s.db.Exec(
+		"UPDATE digest_subscriptions SET confirmed_at = ?, updated_at = ? WHERE confirm_token = ? AND confirmed_at IS NULL",
+		now, now, token,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm digest subscription: %w", err)
+	}
+
+	return s.GetDigestSubscriptionByConfirmToken(token)
+}
+
+// ListEnabledDigestSubscriptions lists the digest subscriptions that are
+// enabled and whose address is confirmed
+func (s *SQLiteDB) ListEnabledDigestSubscriptions() ([]DigestSubscription, error) {
+	rows, err := s.readDB.Query("SELECT " + digestSubscriptionColumns + " FROM digest_subscriptions WHERE enabled = 1 AND confirmed_at IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digest subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	var subscriptions []DigestSubscription
+	for rows.Next() {
+		d, err := scanDigestSubscription(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan digest subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, *d)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over digest subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// MarkDigestScheduled records that a user's digest was handled at the given
+// time, whether or not there was anything to send
+func (s *SQLiteDB) MarkDigestScheduled(userID string, at time.Time) error {
+	_, err := s.db.Exec("UPDATE digest_subscriptions SET last_sent_at = ? WHERE user_id = ?", at, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update digest subscription: %w", err)
+	}
+	return nil
+}
+
+// RecordDigest records a sent digest together with the content items it
+// contained, so that they are not sent to the user again
+func (s *SQLiteDB) RecordDigest(digest *Digest, contentIDs []string) error {
+	if digest.ID == "" {
+		digest.ID = uuid.New().String()
+	}
+	digest.ItemCount = len(contentIDs)
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO digests (id, user_id, email, frequency, subject, item_count, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		digest.ID, digest.UserID, digest.Email, digest.Frequency, digest.Subject, digest.ItemCount, digest.SentAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record digest: %w", err)
+	}
+
+	for _, contentID := range contentIDs {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO digest_items (user_id, content_id, digest_id) VALUES (?, ?, ?)",
+			digest.UserID, contentID, digest.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record digest item: %w", err)
+		}
+	}
+
+	_, err = tx.Exec("UPDATE digest_subscriptions SET last_sent_at = ? WHERE user_id = ?", digest.SentAt, digest.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update digest subscription: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListDigests lists the digests sent to a user, newest first
+func (s *SQLiteDB) ListDigests(userID string, limit int) ([]Digest, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.readDB.Query(
+		`SELECT id, user_id, email, frequency, subject, item_count, sent_at
+		FROM digests WHERE user_id = ? ORDER BY sent_at DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digests: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	digests := []Digest{}
+	for rows.Next() {
+		var d Digest
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Email, &d.Frequency, &d.Subject, &d.ItemCount, &d.SentAt); err != nil {
+			return nil, fmt.Errorf("failed to scan digest: %w", err)
+		}
+		digests = append(digests, d)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over digests: %w", err)
+	}
+
+	return digests, nil
+}
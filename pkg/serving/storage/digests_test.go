@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// setTestDigest subscribes a user to daily digests at an address
+func setTestDigest(t *testing.T, db *SQLiteDB, userID, email string) *DigestSubscription {
+	t.Helper()
+	subscription, err := db.SetDigestSubscription(userID, DigestSubscriptionInput{Email: email}, "07:00", "UTC")
+	if err != nil {
+		t.Fatalf("SetDigestSubscription() error = %v", err)
+	}
+	return subscription
+}
+
+// countEnabledDigests returns the number of digests that would be sent
+func countEnabledDigests(t *testing.T, db *SQLiteDB) int {
+	t.Helper()
+	subscriptions, err := db.ListEnabledDigestSubscriptions()
+	if err != nil {
+		t.Fatalf("ListEnabledDigestSubscriptions() error = %v", err)
+	}
+	return len(subscriptions)
+}
+
+func TestDigestAddressConfirmation(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", RoleReader)
+
+	// New addresses are not sent digests
+	subscription := setTestDigest(t, db, user.ID, "alice@example.com")
+	if subscription.Confirmed || subscription.ConfirmationToken == "" {
+		t.Fatalf("new subscription confirmed = %v, token %q, want unconfirmed with a token", subscription.Confirmed, subscription.ConfirmationToken)
+	}
+	if due, _ := subscription.Due(time.Now().Add(48 * time.Hour)); due {
+		t.Error("unconfirmed digest is due")
+	}
+	if n := countEnabledDigests(t, db); n != 0 {
+		t.Errorf("got %d enabled digests before confirming, want 0", n)
+	}
+
+	// The unsubscribe token does not confirm
+	if confirmed, _ := db.ConfirmDigestSubscription(subscription.UnsubscribeToken); confirmed != nil {
+		t.Error("unsubscribe token confirmed the address")
+	}
+	confirmed, err := db.ConfirmDigestSubscription(subscription.ConfirmationToken)
+	if err != nil {
+		t.Fatalf("ConfirmDigestSubscription() error = %v", err)
+	}
+	if confirmed == nil || !confirmed.Confirmed {
+		t.Fatalf("ConfirmDigestSubscription() = %+v, want a confirmed subscription", confirmed)
+	}
+	if n := countEnabledDigests(t, db); n != 1 {
+		t.Errorf("got %d enabled digests after confirming, want 1", n)
+	}
+
+	// Changing the schedule keeps the confirmation
+	subscription = setTestDigest(t, db, user.ID, "alice@example.com")
+	if !subscription.Confirmed {
+		t.Error("rescheduling lost the confirmation")
+	}
+
+	// Changing the address needs a new confirmation with a new token
+	subscription = setTestDigest(t, db, user.ID, "someone-else@example.com")
+	if subscription.Confirmed {
+		t.Error("new address is confirmed")
+	}
+	if subscription.ConfirmationToken == confirmed.ConfirmationToken {
+		t.Error("new address has the token of the old one")
+	}
+	if old, _ := db.ConfirmDigestSubscription(confirmed.ConfirmationToken); old != nil {
+		t.Error("token of the old address confirmed the new one")
+	}
+	if n := countEnabledDigests(t, db); n != 0 {
+		t.Errorf("got %d enabled digests after changing the address, want 0", n)
+	}
+}
+
+func TestClaimDigestConfirmation(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", RoleReader)
+	now := time.Now()
+
+	if claimed, _ := db.ClaimDigestConfirmation(user.ID, now); claimed {
+		t.Error("claimed a confirmation without a digest subscription")
+	}
+
+	subscription := setTestDigest(t, db, user.ID, "alice@example.com")
+	if claimed, err := db.ClaimDigestConfirmation(user.ID, now); err != nil || !claimed {
+		t.Fatalf("first ClaimDigestConfirmation() = %v, %v, want true", claimed, err)
+	}
+	if claimed, _ := db.ClaimDigestConfirmation(user.ID, now.Add(time.Minute)); claimed {
+		t.Error("claimed a second confirmation within the interval")
+	}
+
+	// Changing the address does not reset the limit
+	setTestDigest(t, db, user.ID, "bob@example.com")
+	if claimed, _ := db.ClaimDigestConfirmation(user.ID, now.Add(2*time.Minute)); claimed {
+		t.Error("changing the address allowed another confirmation within the interval")
+	}
+	if claimed, _ := db.ClaimDigestConfirmation(user.ID, now.Add(DigestConfirmationInterval)); !claimed {
+		t.Error("could not claim a confirmation after the interval")
+	}
+
+	// Confirmed addresses need no confirmation
+	subscription, _ = db.GetDigestSubscription(user.ID)
+	if _, err := db.ConfirmDigestSubscription(subscription.ConfirmationToken); err != nil {
+		t.Fatalf("ConfirmDigestSubscription() error = %v", err)
+	}
+	if claimed, _ := db.ClaimDigestConfirmation(user.ID, now.Add(time.Hour)); claimed {
+		t.Error("claimed a confirmation for a confirmed address")
+	}
+}
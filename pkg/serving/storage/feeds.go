@@ -0,0 +1,353 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidSavedSearch is returned for saved searches without a name or keywords
+var ErrInvalidSavedSearch = errors.New("invalid saved search")
+
+// FeedToken describes a user's secret for the published feeds. The token
+// itself is only returned once, when it is created.
+type FeedToken struct {
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SavedSearch is a search a user saved to run again or publish as a feed
+type SavedSearch struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Keywords  string    `json:"keywords"`
+	SourceID  string    `json:"sourceId,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SavedSearchInput represents the input for creating or updating a saved search
+type SavedSearchInput struct {
+	Name     string   `json:"name" binding:"required"`
+	Keywords string   `json:"keywords" binding:"required"`
+	SourceID string   `json:"sourceId,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// SearchInput returns the input for running the saved search for its user
+func (s *SavedSearch) SearchInput(limit int) SearchContentsInput {
+	return SearchContentsInput{
+		Keywords: s.Keywords,
+		UserID:   s.UserID,
+		Tags:     s.Tags,
+		SourceID: s.SourceID,
+		Limit:    limit,
+	}
+}
+
+// CreateFeedToken creates a new feed token for a user, replacing any
+// previous one. It returns the secret token, which cannot be retrieved again.
+func (s *SQLiteDB) CreateFeedToken(userID string) (*FeedToken, string, error) {
+	secret, err := generateSecret(FeedTokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &FeedToken{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO feed_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		token.UserID, hashSecret(secret), token.CreatedAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create feed token: %w", err)
+	}
+
+	return token, secret, nil
+}
+
+// GetFeedToken retrieves a user's feed token. It returns nil if the user
+// has none.
+func (s *SQLiteDB) GetFeedToken(userID string) (*FeedToken, error) {
+	token := &FeedToken{UserID: userID}
+	err := s.readDB.QueryRow("SELECT created_at FROM feed_tokens WHERE user_id = ?", userID).Scan(&token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil // No feed token
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get feed token: %w", err)
+	}
+	return token, nil
+}
+
+// DeleteFeedToken revokes a user's feed token. It reports whether there was one.
+func (s *SQLiteDB) DeleteFeedToken(userID string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM feed_tokens WHERE user_id = ?", userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete feed token: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetUserByFeedToken returns the user owning a feed token. It returns nil if
+// the token is not valid.
+func (s *SQLiteDB) GetUserByFeedToken(secret string) (*User, error) {
+	row := s.readDB.QueryRow(
+		`SELECT `+userColumns+`
+		FROM feed_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ?`,
+		hashSecret(secret),
+	)
+	user, err := scanUser(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Token not valid
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get feed token: %w", err)
+	}
+	return user, nil
+}
+
+// normalizeSavedSearch validates a saved search input and normalizes its tags
+func normalizeSavedSearch(input *SavedSearchInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Keywords = strings.TrimSpace(input.Keywords)
+	if input.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSavedSearch)
+	}
+	if input.Keywords == "" {
+		return fmt.Errorf("%w: keywords are required", ErrInvalidSavedSearch)
+	}
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return err
+	}
+	input.Tags = tags
+	return nil
+}
+
+// encodeTags encodes tags for storage in a TEXT column
+func encodeTags(tags []string) (interface{}, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return string(data), nil
+}
+
+// scanSavedSearch scans a saved search from a row
+func scanSavedSearch(scan func(dest ...interface{}) error) (*SavedSearch, error) {
+	var search SavedSearch
+	var sourceID, tags sql.NullString
+	err := scan(&search.ID, &search.UserID, &search.Name, &search.Keywords, &sourceID, &tags, &search.CreatedAt, &search.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	search.SourceID = sourceID.String
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &search.Tags); err != nil {
+			return nil, fmt.Errorf("failed to decode tags: %w", err)
+		}
+	}
+	return &search, nil
+}
+
+// savedSearchColumns is the column list used to scan saved searches
+const savedSearchColumns = "id, user_id, name, keywords, source_id, tags, created_at, updated_at"
+
+// CreateSavedSearch saves a search for a user
+func (s *SQLiteDB) CreateSavedSearch(userID string, input SavedSearchInput) (*SavedSearch, error) {
+	if err := normalizeSavedSearch(&input); err != nil {
+		return nil, err
+	}
+	tags, err := encodeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	search := &SavedSearch{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      input.Name,
+		Keywords:  input.Keywords,
+		SourceID:  input.SourceID,
+		Tags:      input.Tags,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO saved_searches (`+savedSearchColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		search.ID, search.UserID, search.Name, search.Keywords, search.SourceID, tags,
+		search.CreatedAt, search.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create saved search: %w", err)
+	}
+
+	return search, nil
+}
+
+// GetSavedSearch retrieves one of a user's saved searches
+func (s *SQLiteDB) GetSavedSearch(userID, id string) (*SavedSearch, error) {
+	row := s.readDB.QueryRow(
+		"SELECT "+savedSearchColumns+" FROM saved_searches WHERE id = ? AND user_id = ?",
+		id, userID,
+	)
+	search, err := scanSavedSearch(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Saved search not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get saved search: %w", err)
+	}
+	return search, nil
+}
+
+// ListSavedSearches lists a user's saved searches ordered by name
+func (s *SQLiteDB) ListSavedSearches(userID string) ([]SavedSearch, error) {
+	rows, err := s.readDB.Query(
+		"SELECT "+savedSearchColumns+" FROM saved_searches WHERE user_id = ? ORDER BY name COLLATE NOCASE ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	searches := []SavedSearch{}
+	for rows.Next() {
+		search, err := scanSavedSearch(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		searches = append(searches, *search)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over saved searches: %w", err)
+	}
+
+	return searches, nil
+}
+
+// UpdateSavedSearch replaces one of a user's saved searches. It returns nil
+// if the saved search does not exist.
+func (s *SQLiteDB) UpdateSavedSearch(userID, id string, input SavedSearchInput) (*SavedSearch, error) {
+	if err := normalizeSavedSearch(&input); err != nil {
+		return nil, err
+	}
+	tags, err := encodeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE saved_searches SET name = ?, keywords = ?, source_id = ?, tags = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?`,
+		input.Name, input.Keywords, input.SourceID, tags, time.Now().UTC(),
+		id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update saved search: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Saved search not found
+	}
+
+	return s.GetSavedSearch(userID, id)
+}
+
+// DeleteSavedSearch deletes one of a user's saved searches. It reports
+// whether the saved search existed.
+func (s *SQLiteDB) DeleteSavedSearch(userID, id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM saved_searches WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// LoadContentDetails fills in the full text, author, categories and update
+// time of content items returned by the list and search queries, which leave
+// them out
+func (s *SQLiteDB) LoadContentDetails(contents []RSSContent) error {
+	if len(contents) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(contents))
+	index := map[string]int{}
+	for i, content := range contents {
+		args[i] = content.ID
+		index[content.ID] = i
+	}
+	placeholders := createPlaceholders(len(contents))
+
+	// Query the text and author of all items at once
+	rows, err := s.readDB.Query(
+		"SELECT id, content, author, updated_at FROM rss_contents WHERE id IN ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get content details: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var text, author sql.NullString
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&id, &text, &author, &updatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan content details: %w", err)
+		}
+		content := &contents[index[id]]
+		content.Content = text.String
+		content.Author = author.String
+		if updatedAt.Valid {
+			content.UpdatedAt = &updatedAt.Time
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("error iterating over content details: %w", err)
+	}
+
+	// Query the categories of all items at once
+	rows, err = s.readDB.Query(
+		"SELECT content_id, category FROM content_categories WHERE content_id IN ("+placeholders+")",
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, category string
+		if err := rows.Scan(&id, &category); err != nil {
+			return fmt.Errorf("failed to scan category: %w", err)
+		}
+		content := &contents[index[id]]
+		content.Categories = append(content.Categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over categories: %w", err)
+	}
+
+	return nil
+}
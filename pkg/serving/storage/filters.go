@@ -0,0 +1,596 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Filter rule actions
+const (
+	// FilterActionDrop keeps matching items out of the database
+	FilterActionDrop = "drop"
+	// FilterActionHide stores matching items but leaves them out of
+	// listings, searches and recommendations
+	FilterActionHide = "hide"
+	// FilterActionMarkRead marks matching items read for every subscriber
+	FilterActionMarkRead = "mark-read"
+)
+
+// Filter rule fields
+const (
+	FilterFieldTitle    = "title"
+	FilterFieldContent  = "content"
+	FilterFieldAuthor   = "author"
+	FilterFieldCategory = "category"
+	// FilterFieldAny matches the title, content, author or any category
+	FilterFieldAny = "any"
+)
+
+// Filter rule match types
+const (
+	// FilterMatchKeyword matches any of the comma-separated keywords of the
+	// pattern, ignoring case
+	FilterMatchKeyword = "keyword"
+	// FilterMatchRegex matches the pattern as a Go regular expression
+	FilterMatchRegex = "regex"
+)
+
+// FilterActions, FilterFields and FilterMatchTypes are the valid values of
+// the fields of a filter rule
+var (
+	FilterActions    = []string{FilterActionDrop, FilterActionHide, FilterActionMarkRead}
+	FilterFields     = []string{FilterFieldTitle, FilterFieldContent, FilterFieldAuthor, FilterFieldCategory, FilterFieldAny}
+	FilterMatchTypes = []string{FilterMatchKeyword, FilterMatchRegex}
+)
+
+// ErrInvalidFilterRule is returned for filter rules with an invalid field,
+// match type, pattern, action or source
+var ErrInvalidFilterRule = errors.New("invalid filter rule")
+
+// FilterRule is a rule applied to content as it is ingested, and to existing
+// content when the rules are re-applied
+type FilterRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// SourceID limits the rule to one source; the rule applies to all
+	// sources if it is empty
+	SourceID string `json:"sourceId,omitempty"`
+	Field    string `json:"field"`
+	Match    string `json:"match"`
+	Pattern  string `json:"pattern"`
+	// Invert applies the action to the items that do not match, so that
+	// only matching items are kept
+	Invert    bool      `json:"invert"`
+	Action    string    `json:"action"`
+	Enabled   bool      `json:"enabled"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// The compiled pattern
+	matcher textMatcher
+}
+
+// FilterRuleInput represents the input for creating, updating or testing a
+// filter rule
+type FilterRuleInput struct {
+	Name     string `json:"name"`
+	SourceID string `json:"sourceId,omitempty"`
+	// Field defaults to any
+	Field string `json:"field,omitempty"`
+	// Match defaults to keyword
+	Match   string `json:"match,omitempty"`
+	Pattern string `json:"pattern" binding:"required"`
+	Invert  bool   `json:"invert,omitempty"`
+	Action  string `json:"action" binding:"required"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+// FilterDecision is what the filter rules do with a content item
+type FilterDecision struct {
+	Drop     bool
+	Hide     bool
+	MarkRead bool
+}
+
+// FilterResult reports what re-applying the filter rules to existing content
+// changed
+type FilterResult struct {
+	Checked int `json:"checked"`
+	Dropped int `json:"dropped"`
+	Hidden  int `json:"hidden"`
+	// Unhidden counts items that no hide rule matches anymore
+	Unhidden int `json:"unhidden"`
+	// MarkedRead counts the items marked read, once per subscriber
+	MarkedRead int `json:"markedRead"`
+}
+
+// textMatcher is a compiled rule pattern
+type textMatcher struct {
+	re       *regexp.Regexp
+	keywords []string
+}
+
+// newTextMatcher compiles a pattern of the given match type
+func newTextMatcher(match, pattern string) (textMatcher, error) {
+	var m textMatcher
+	switch match {
+	case FilterMatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return m, err
+		}
+		m.re = re
+	default:
+		for _, keyword := range strings.Split(pattern, ",") {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				m.keywords = append(m.keywords, keyword)
+			}
+		}
+		if len(m.keywords) == 0 {
+			return m, errors.New("pattern must contain a keyword")
+		}
+	}
+	return m, nil
+}
+
+// matchText reports whether the pattern matches a text
+func (m textMatcher) matchText(text string) bool {
+	if m.re != nil {
+		return m.re.MatchString(text)
+	}
+	text = strings.ToLower(text)
+	for _, keyword := range m.keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchField reports whether the pattern matches a field of a content item
+func (m textMatcher) matchField(field string, content *RSSContent) bool {
+	var matched bool
+	if field == FilterFieldTitle || field == FilterFieldAny {
+		matched = matched || m.matchText(content.Title)
+	}
+	if field == FilterFieldContent || field == FilterFieldAny {
+		matched = matched || m.matchText(content.Description) || m.matchText(content.Content)
+	}
+	if field == FilterFieldAuthor || field == FilterFieldAny {
+		matched = matched || (content.Author != "" && m.matchText(content.Author))
+	}
+	if field == FilterFieldCategory || field == FilterFieldAny {
+		for _, category := range content.Categories {
+			matched = matched || m.matchText(category)
+		}
+	}
+	return matched
+}
+
+// compile prepares the rule's pattern for matching
+func (r *FilterRule) compile() error {
+	matcher, err := newTextMatcher(r.Match, r.Pattern)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFilterRule, err)
+	}
+	r.matcher = matcher
+	return nil
+}
+
+// Matches reports whether the rule applies to a content item, regardless of
+// whether it is enabled
+func (r *FilterRule) Matches(content *RSSContent) bool {
+	if r.SourceID != "" && r.SourceID != content.SourceID {
+		return false
+	}
+	return r.matcher.matchField(r.Field, content) != r.Invert
+}
+
+// EvaluateFilterRules decides what the enabled rules do with a content item
+func EvaluateFilterRules(rules []FilterRule, content *RSSContent) FilterDecision {
+	var decision FilterDecision
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Enabled || !rule.Matches(content) {
+			continue
+		}
+		switch rule.Action {
+		case FilterActionDrop:
+			decision.Drop = true
+		case FilterActionHide:
+			decision.Hide = true
+		case FilterActionMarkRead:
+			decision.MarkRead = true
+		}
+	}
+	return decision
+}
+
+// oneOf reports whether value is one of values
+func oneOf(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFilterRule validates an input and builds the rule it describes, without
+// storing it
+func NewFilterRule(input FilterRuleInput) (*FilterRule, error) {
+	rule := &FilterRule{
+		Name:     strings.TrimSpace(input.Name),
+		SourceID: strings.TrimSpace(input.SourceID),
+		Field:    input.Field,
+		Match:    input.Match,
+		Pattern:  input.Pattern,
+		Invert:   input.Invert,
+		Action:   input.Action,
+		Enabled:  input.Enabled == nil || *input.Enabled,
+	}
+	if rule.Field == "" {
+		rule.Field = FilterFieldAny
+	}
+	if rule.Match == "" {
+		rule.Match = FilterMatchKeyword
+	}
+
+	if !oneOf(rule.Field, FilterFields) {
+		return nil, fmt.Errorf("%w: unknown field %q, use one of %s", ErrInvalidFilterRule, rule.Field, strings.Join(FilterFields, ", "))
+	}
+	if !oneOf(rule.Match, FilterMatchTypes) {
+		return nil, fmt.Errorf("%w: unknown match type %q, use one of %s", ErrInvalidFilterRule, rule.Match, strings.Join(FilterMatchTypes, ", "))
+	}
+	if !oneOf(rule.Action, FilterActions) {
+		return nil, fmt.Errorf("%w: unknown action %q, use one of %s", ErrInvalidFilterRule, rule.Action, strings.Join(FilterActions, ", "))
+	}
+	if err := rule.compile(); err != nil {
+		return nil, err
+	}
+	if rule.Name == "" {
+		rule.Name = rule.Action + " " + rule.Field + " " + rule.Pattern
+	}
+	return rule, nil
+}
+
+// checkRuleSource makes sure the source a rule is limited to exists,
+// returning invalid wrapped if it does not
+func (s *SQLiteDB) checkRuleSource(sourceID string, invalid error) error {
+	if sourceID == "" {
+		return nil
+	}
+	source, err := s.GetSource(sourceID)
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		return fmt.Errorf("%w: source %s not found", invalid, sourceID)
+	}
+	return nil
+}
+
+// filterRuleColumns is the column list used to scan filter rules
+const filterRuleColumns = "id, name, source_id, field, match_type, pattern, invert, action, enabled, created_by, created_at, updated_at"
+
+// scanFilterRule scans a filter rule from a row and compiles its pattern
+func scanFilterRule(scan func(dest ...interface{}) error) (*FilterRule, error) {
+	var r FilterRule
+	var sourceID, createdBy sql.NullString
+	err := scan(&r.ID, &r.Name, &sourceID, &r.Field, &r.Match, &r.Pattern, &r.Invert, &r.Action, &r.Enabled,
+		&createdBy, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.SourceID = sourceID.String
+	r.CreatedBy = createdBy.String
+	if err := r.compile(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// CreateFilterRule creates a filter rule on behalf of a user
+func (s *SQLiteDB) CreateFilterRule(userID string, input FilterRuleInput) (*FilterRule, error) {
+	rule, err := NewFilterRule(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRuleSource(rule.SourceID, ErrInvalidFilterRule); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	rule.ID = uuid.New().String()
+	rule.CreatedBy = userID
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	_, err = s.db.Exec(
+		`INSERT INTO filter_rules (`+filterRuleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, rule.Name, nullString(rule.SourceID), rule.Field, rule.Match, rule.Pattern, rule.Invert,
+		rule.Action, rule.Enabled, nullString(rule.CreatedBy), rule.CreatedAt, rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filter rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// GetFilterRule retrieves a filter rule by ID
+func (s *SQLiteDB) GetFilterRule(id string) (*FilterRule, error) {
+	row := s.readDB.QueryRow("SELECT "+filterRuleColumns+" FROM filter_rules WHERE id = ?", id)
+	rule, err := scanFilterRule(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Filter rule not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get filter rule: %w", err)
+	}
+	return rule, nil
+}
+
+// ListFilterRules lists all filter rules, oldest first
+func (s *SQLiteDB) ListFilterRules() ([]FilterRule, error) {
+	rows, err := s.readDB.Query("SELECT " + filterRuleColumns + " FROM filter_rules ORDER BY created_at ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filter rules: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	rules := []FilterRule{}
+	for rows.Next() {
+		rule, err := scanFilterRule(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan filter rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over filter rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// UpdateFilterRule replaces a filter rule. It returns nil if the rule does
+// not exist.
+func (s *SQLiteDB) UpdateFilterRule(id string, input FilterRuleInput) (*FilterRule, error) {
+	rule, err := NewFilterRule(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRuleSource(rule.SourceID, ErrInvalidFilterRule); err != nil {
+		return nil, err
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE filter_rules SET name = ?, source_id = ?, field = ?, match_type = ?, pattern = ?, invert = ?,
+			action = ?, enabled = ?, updated_at = ?
+		WHERE id = ?`,
+		rule.Name, nullString(rule.SourceID), rule.Field, rule.Match, rule.Pattern, rule.Invert,
+		rule.Action, rule.Enabled, time.Now().UTC(), id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update filter rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Filter rule not found
+	}
+
+	return s.GetFilterRule(id)
+}
+
+// DeleteFilterRule deletes a filter rule and reports whether it existed.
+// Items it hid stay hidden until the rules are re-applied.
+func (s *SQLiteDB) DeleteFilterRule(id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM filter_rules WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete filter rule: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// TestFilterRule returns the items among the newest limit items of the
+// rule's sources that the rule matches, whether or not it is enabled, and
+// how many items it was tested against
+func (s *SQLiteDB) TestFilterRule(rule *FilterRule, limit int) ([]RSSContent, int, error) {
+	condition := "deleted_at IS NULL"
+	args := []interface{}{}
+	if rule.SourceID != "" {
+		condition += " AND source_id = ?"
+		args = append(args, rule.SourceID)
+	}
+	contents, err := s.listFilterContents(condition+" ORDER BY published_at DESC, id ASC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matching := []RSSContent{}
+	for _, content := range contents {
+		if rule.Matches(&content) {
+			matching = append(matching, content)
+		}
+	}
+	return matching, len(contents), nil
+}
+
+// ApplyFilterRules re-applies the enabled filter rules to all existing
+// content: matching items are moved to the trash, hidden or marked read, and
+// hidden items that no hide rule matches anymore are shown again
+func (s *SQLiteDB) ApplyFilterRules() (*FilterResult, error) {
+	rules, err := s.ListFilterRules()
+	if err != nil {
+		return nil, err
+	}
+
+	// Go through the content a page at a time
+	result := &FilterResult{}
+	lastID := ""
+	for {
+		contents, err := s.listFilterContents("deleted_at IS NULL AND id > ? ORDER BY id ASC LIMIT ?", lastID, 500)
+		if err != nil {
+			return nil, err
+		}
+		if len(contents) == 0 {
+			break
+		}
+		lastID = contents[len(contents)-1].ID
+
+		if err := s.applyFilterDecisions(rules, contents, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// applyFilterDecisions applies the rules to a page of content
+func (s *SQLiteDB) applyFilterDecisions(rules []FilterRule, contents []RSSContent, result *FilterResult) error {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for i := range contents {
+		content := &contents[i]
+		decision := EvaluateFilterRules(rules, content)
+		result.Checked++
+
+		switch {
+		case decision.Drop:
+			if _, err := deleteContent(tx, content.ID); err != nil {
+				return err
+			}
+			result.Dropped++
+			continue
+		case decision.Hide && !content.Hidden:
+			if _, err := tx.Exec("UPDATE rss_contents SET hidden_at = ? WHERE id = ?", now, content.ID); err != nil {
+				return fmt.Errorf("failed to hide content: %w", err)
+			}
+			result.Hidden++
+		case !decision.Hide && content.Hidden:
+			if _, err := tx.Exec("UPDATE rss_contents SET hidden_at = NULL WHERE id = ?", content.ID); err != nil {
+				return fmt.Errorf("failed to unhide content: %w", err)
+			}
+			result.Unhidden++
+		}
+
+		if decision.MarkRead {
+			n, err := markReadForSubscribers(tx, content, now)
+			if err != nil {
+				return err
+			}
+			result.MarkedRead += n
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// markReadForSubscribers marks a content item read for every user subscribed
+// to its source using the given queryer and returns how many were marked
+func markReadForSubscribers(q queryer, content *RSSContent, now time.Time) (int, error) {
+	res, err := q.Exec(
+		`INSERT INTO content_states (user_id, content_id, read_at)
+		SELECT user_id, ?, ? FROM subscriptions WHERE source_id = ?
+		ON CONFLICT (user_id, content_id) DO UPDATE SET read_at = excluded.read_at
+		WHERE content_states.read_at IS NULL`,
+		content.ID, now, content.SourceID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark content as read: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// listFilterContents lists content items with everything filter rules match
+// on. The condition is appended to the WHERE clause and may order and limit
+// the results.
+func (s *SQLiteDB) listFilterContents(condition string, args ...interface{}) ([]RSSContent, error) {
+	rows, err := s.readDB.Query(
+		`SELECT id, source_id, title, link, description, COALESCE(content, ''), published_at, fetched_at,
+			COALESCE(author, ''), hidden_at IS NOT NULL
+		FROM rss_contents WHERE `+condition,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list RSS contents: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	var contents []RSSContent
+	index := map[string]int{}
+	for rows.Next() {
+		var content RSSContent
+		err := rows.Scan(&content.ID, &content.SourceID, &content.Title, &content.Link, &content.Description,
+			&content.Content, &content.PublishedAt, &content.FetchedAt, &content.Author, &content.Hidden)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan RSS content: %w", err)
+		}
+		index[content.ID] = len(contents)
+		contents = append(contents, content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over RSS contents: %w", err)
+	}
+	if len(contents) == 0 {
+		return contents, nil
+	}
+
+	// Query the categories of all items at once
+	ids := make([]interface{}, 0, len(contents))
+	for _, content := range contents {
+		ids = append(ids, content.ID)
+	}
+	categoryRows, err := s.readDB.Query(
+		"SELECT content_id, category FROM content_categories WHERE content_id IN ("+createPlaceholders(len(ids))+")",
+		ids...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer categoryRows.Close()
+	for categoryRows.Next() {
+		var contentID, category string
+		if err := categoryRows.Scan(&contentID, &category); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		i := index[contentID]
+		contents[i].Categories = append(contents[i].Categories, category)
+	}
+	if err := categoryRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over categories: %w", err)
+	}
+
+	return contents, nil
+}
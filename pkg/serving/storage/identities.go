@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateIdentityUserInput represents the input for creating a user that
+// signs in through an external identity provider
+type CreateIdentityUserInput struct {
+	// Issuer and Subject identify the account at the identity provider
+	Issuer  string
+	Subject string
+	// Username is the name of the new riffle user
+	Username string
+	// Role defaults to reader
+	Role string
+}
+
+// GetUserByIdentity retrieves the user linked to an account at an identity
+// provider. It returns nil if no user is linked.
+func (s *SQLiteDB) GetUserByIdentity(issuer, subject string) (*User, error) {
+	row := s.readDB.QueryRow(
+		`SELECT `+userColumns+`
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = ? AND i.subject = ?`,
+		issuer, subject,
+	)
+	user, err := scanUser(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // No linked user
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+	return user, nil
+}
+
+// CreateIdentityUser creates a user linked to an account at an identity
+// provider. The user has no password and can only sign in through the
+// provider or with API tokens.
+func (s *SQLiteDB) CreateIdentityUser(input CreateIdentityUserInput) (*User, error) {
+	input.Username = strings.TrimSpace(input.Username)
+	if input.Username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if input.Issuer == "" || input.Subject == "" {
+		return nil, fmt.Errorf("issuer and subject are required")
+	}
+	if input.Role == "" {
+		input.Role = RoleReader
+	} else if !ValidRole(input.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Check if the username is taken
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)", input.Username).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+	if exists {
+		return nil, ErrUsernameTaken
+	}
+
+	now := time.Now().UTC()
+	user := &User{
+		ID:        uuid.New().String(),
+		Username:  input.Username,
+		Role:      input.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Insert the user without a usable password
+	_, err = tx.Exec(
+		`INSERT INTO users (id, username, password_hash, role, created_at, updated_at)
+		VALUES (?, ?, '', ?, ?, ?)`,
+		user.ID, user.Username, user.Role, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Link the user to the identity
+	_, err = tx.Exec(
+		"INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES (?, ?, ?, ?)",
+		input.Issuer, input.Subject, user.ID, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user identity: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
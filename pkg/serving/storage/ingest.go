@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// IngestContent stores a new content item from a feed. The filter rules
+// decide whether it is stored, hidden or marked read for the subscribers of
+// its source, whose routing rules then tag, star, prioritize and file it.
+// It reports whether the item was stored; items dropped by a filter rule
+// are not.
+func (s *SQLiteDB) IngestContent(content *RSSContent) (bool, error) {
+	filters, err := s.ListFilterRules()
+	if err != nil {
+		return false, err
+	}
+	routes, err := s.ListEnabledRoutingRules()
+	if err != nil {
+		return false, err
+	}
+
+	// Apply the filter rules; dropped content is not stored
+	decision := EvaluateFilterRules(filters, content)
+	if decision.Drop {
+		return false, nil
+	}
+	content.Hidden = decision.Hide
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	terms, err := createContent(tx, content)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UTC()
+	if decision.MarkRead {
+		if _, err := markReadForSubscribers(tx, content, now); err != nil {
+			return false, err
+		}
+	}
+	if err := routeContent(tx, routes, content, now); err != nil {
+		return false, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.addToCorpus(terms)
+	return true, nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ingest ingests a new item of a source, failing the test on errors, and
+// reports whether it was stored
+func ingest(t *testing.T, db *SQLiteDB, sourceID, link, title string) (*RSSContent, bool) {
+	t.Helper()
+	content := &RSSContent{
+		SourceID:    sourceID,
+		Title:       title,
+		Link:        link,
+		PublishedAt: time.Now().UTC(),
+	}
+	stored, err := db.IngestContent(content)
+	if err != nil {
+		t.Fatalf("IngestContent() error = %v", err)
+	}
+	return content, stored
+}
+
+func TestIngestContentFilters(t *testing.T) {
+	db := newTestDB(t)
+	editor := newTestUser(t, db, "editor", RoleEditor)
+	reader := newTestUser(t, db, "reader", RoleReader)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.CreateSubscription(reader.ID, CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	for _, input := range []FilterRuleInput{
+		{Name: "Ads", Field: FilterFieldTitle, Pattern: "ad", Action: FilterActionDrop},
+		{Name: "Sponsored", Field: FilterFieldTitle, Pattern: "sponsored", Action: FilterActionHide},
+		{Name: "Digest", Field: FilterFieldTitle, Pattern: "digest", Action: FilterActionMarkRead},
+	} {
+		if _, err := db.CreateFilterRule(editor.ID, input); err != nil {
+			t.Fatalf("CreateFilterRule() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		title                string
+		stored, hidden, read bool
+	}{
+		{"An ad", false, false, false},
+		{"Sponsored post", true, true, false},
+		{"Weekly digest", true, false, true},
+		{"Release notes", true, false, false},
+	}
+	for i, tt := range tests {
+		content, stored := ingest(t, db, source.ID, "https://example.com/"+string(rune('a'+i)), tt.title)
+		if stored != tt.stored {
+			t.Errorf("%q: stored = %v, want %v", tt.title, stored, tt.stored)
+		}
+		if !stored {
+			if got, _ := db.GetContentByURL(content.Link, content.Link); got != nil {
+				t.Errorf("%q: dropped item is in the database", tt.title)
+			}
+			continue
+		}
+		got, err := db.GetContent(content.ID)
+		if err != nil || got == nil {
+			t.Fatalf("%q: GetContent() = %v, error = %v", tt.title, got, err)
+		}
+		if got.Hidden != tt.hidden {
+			t.Errorf("%q: hidden = %v, want %v", tt.title, got.Hidden, tt.hidden)
+		}
+		state, err := db.GetContentState(reader.ID, content.ID)
+		if err != nil {
+			t.Fatalf("GetContentState() error = %v", err)
+		}
+		if state.Read != tt.read {
+			t.Errorf("%q: read = %v, want %v", tt.title, state.Read, tt.read)
+		}
+	}
+}
+
+func TestIngestContentRoutes(t *testing.T) {
+	db := newTestDB(t)
+	reader := newTestUser(t, db, "reader", RoleReader)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.CreateSubscription(reader.ID, CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if _, err := db.CreateRoutingRule(reader.ID, RoutingRuleInput{Pattern: "go", Tags: []string{"golang"}}); err != nil {
+		t.Fatalf("CreateRoutingRule() error = %v", err)
+	}
+
+	// Items stored by CreateContent are not routed; ingested items are
+	created := newTestContent(t, db, source.ID, "https://example.com/created", "Go release")
+	ingested, _ := ingest(t, db, source.ID, "https://example.com/ingested", "Go tooling")
+	for content, want := range map[*RSSContent][]string{created: nil, ingested: {"golang"}} {
+		tags, err := db.GetContentTags(reader.ID, content.ID)
+		if err != nil {
+			t.Fatalf("GetContentTags() error = %v", err)
+		}
+		if !reflect.DeepEqual(tags, want) {
+			t.Errorf("%s tags = %v, want %v", content.Link, tags, want)
+		}
+	}
+}
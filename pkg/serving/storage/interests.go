@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/google/uuid"
+)
+
+// Interest profile limits
+const (
+	// maxInterestLength is the maximum length of the terms and synonyms of
+	// an interest
+	maxInterestLength = 200
+	// maxInterestWeight is the maximum absolute weight of an interest
+	maxInterestWeight = 10
+)
+
+var (
+	// ErrInvalidInterest is returned for interests without terms or with an
+	// invalid weight, synonym or pattern
+	ErrInvalidInterest = errors.New("invalid interest")
+	// ErrInterestExists is returned when adding an interest a user already
+	// has
+	ErrInterestExists = errors.New("interest already exists")
+)
+
+// Interest is a topic in a user's interest profile. Recommendations and
+// the run command promote content matching interests of positive weight and
+// demote content matching interests of negative weight.
+type Interest struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	// Terms are the words or phrase of the interest, unique per user
+	Terms  string  `json:"terms"`
+	Weight float64 `json:"weight"`
+	// Synonyms are other words or phrases matched like the terms
+	Synonyms []string `json:"synonyms,omitempty"`
+	// Pattern is an optional regular expression matched against the title
+	// and text
+	Pattern   string    `json:"pattern,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// InterestInput represents the input for creating or updating an interest
+type InterestInput struct {
+	Terms string `json:"terms"`
+	// Weight defaults to 1
+	Weight   *float64 `json:"weight,omitempty"`
+	Synonyms []string `json:"synonyms,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+}
+
+// newInterest validates an input and builds the interest it describes,
+// without storing it
+func newInterest(input InterestInput) (*Interest, error) {
+	interest := &Interest{
+		Terms:   strings.TrimSpace(input.Terms),
+		Weight:  1,
+		Pattern: input.Pattern,
+	}
+	if input.Weight != nil {
+		interest.Weight = *input.Weight
+	}
+
+	if interest.Terms == "" {
+		return nil, fmt.Errorf("%w: terms are required", ErrInvalidInterest)
+	}
+	if utf8.RuneCountInString(interest.Terms) > maxInterestLength {
+		return nil, fmt.Errorf("%w: terms are longer than %d characters", ErrInvalidInterest, maxInterestLength)
+	}
+	if interest.Weight == 0 || interest.Weight < -maxInterestWeight || interest.Weight > maxInterestWeight {
+		return nil, fmt.Errorf("%w: weight must be between -%d and %d and not 0", ErrInvalidInterest, maxInterestWeight, maxInterestWeight)
+	}
+	seen := map[string]bool{strings.ToLower(interest.Terms): true}
+	for _, synonym := range input.Synonyms {
+		synonym = strings.TrimSpace(synonym)
+		if synonym == "" {
+			return nil, fmt.Errorf("%w: synonyms must not be empty", ErrInvalidInterest)
+		}
+		if utf8.RuneCountInString(synonym) > maxInterestLength {
+			return nil, fmt.Errorf("%w: synonym %q is longer than %d characters", ErrInvalidInterest, synonym, maxInterestLength)
+		}
+		if !seen[strings.ToLower(synonym)] {
+			seen[strings.ToLower(synonym)] = true
+			interest.Synonyms = append(interest.Synonyms, synonym)
+		}
+	}
+	if interest.Pattern != "" {
+		if _, err := regexp.Compile(interest.Pattern); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInterest, err)
+		}
+	}
+	return interest, nil
+}
+
+// riffleInterest converts the interest for scoring articles
+func (i *Interest) riffleInterest() (riffle.Interest, error) {
+	interest := riffle.Interest{Terms: i.Terms, Weight: i.Weight, Synonyms: i.Synonyms}
+	if i.Pattern != "" {
+		pattern, err := regexp.Compile(i.Pattern)
+		if err != nil {
+			return interest, fmt.Errorf("invalid pattern of interest %q: %w", i.Terms, err)
+		}
+		interest.Pattern = pattern
+	}
+	return interest, nil
+}
+
+// interestColumns is the column list used to scan interests
+const interestColumns = "id, user_id, terms, weight, synonyms, pattern, created_at, updated_at"
+
+// scanInterest scans an interest from a row
+func scanInterest(scan func(dest ...interface{}) error) (*Interest, error) {
+	var i Interest
+	var synonyms sql.NullString
+	err := scan(&i.ID, &i.UserID, &i.Terms, &i.Weight, &synonyms, &i.Pattern, &i.CreatedAt, &i.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if i.Synonyms, err = decodeStrings(synonyms); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// interestExists reports whether a user has an interest with the same terms,
+// ignoring case, other than the interest with the given ID
+func interestExists(q queryer, userID, terms, exceptID string) (bool, error) {
+	var exists bool
+	err := q.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM user_interests WHERE user_id = ? AND LOWER(terms) = LOWER(?) AND id != ?)",
+		userID, terms, exceptID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check interest: %w", err)
+	}
+	return exists, nil
+}
+
+// insertInterest stores a new interest of a user
+func insertInterest(q queryer, userID string, interest *Interest) error {
+	synonyms, err := encodeStrings(interest.Synonyms)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	interest.ID = uuid.New().String()
+	interest.UserID = userID
+	interest.CreatedAt = now
+	interest.UpdatedAt = now
+	_, err = q.Exec(
+		"INSERT INTO user_interests ("+interestColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		interest.ID, interest.UserID, interest.Terms, interest.Weight, synonyms, interest.Pattern,
+		interest.CreatedAt, interest.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create interest: %w", err)
+	}
+	return nil
+}
+
+// CreateInterest adds an interest to a user's profile
+func (s *SQLiteDB) CreateInterest(userID string, input InterestInput) (*Interest, error) {
+	interest, err := newInterest(input)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := interestExists(s.db, userID, interest.Terms, "")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrInterestExists
+	}
+
+	if err := insertInterest(s.db, userID, interest); err != nil {
+		return nil, err
+	}
+	return interest, nil
+}
+
+// GetInterest retrieves one of a user's interests
+func (s *SQLiteDB) GetInterest(userID, id string) (*Interest, error) {
+	row := s.readDB.QueryRow("SELECT "+interestColumns+" FROM user_interests WHERE id = ? AND user_id = ?", id, userID)
+	interest, err := scanInterest(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Interest not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get interest: %w", err)
+	}
+	return interest, nil
+}
+
+// ListInterests lists a user's interests, the most weighted first
+func (s *SQLiteDB) ListInterests(userID string) ([]Interest, error) {
+	rows, err := s.readDB.Query(
+		"SELECT "+interestColumns+" FROM user_interests WHERE user_id = ? ORDER BY weight DESC, terms ASC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interests: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	interests := []Interest{}
+	for rows.Next() {
+		interest, err := scanInterest(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan interest: %w", err)
+		}
+		interests = append(interests, *interest)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over interests: %w", err)
+	}
+
+	return interests, nil
+}
+
+// UpdateInterest replaces one of a user's interests. It returns nil if the
+// interest does not exist.
+func (s *SQLiteDB) UpdateInterest(userID, id string, input InterestInput) (*Interest, error) {
+	interest, err := newInterest(input)
+	if err != nil {
+		return nil, err
+	}
+	synonyms, err := encodeStrings(interest.Synonyms)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := interestExists(s.db, userID, interest.Terms, id)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrInterestExists
+	}
+
+	res, err := s.db.Exec(
+		"UPDATE user_interests SET terms = ?, weight = ?, synonyms = ?, pattern = ?, updated_at = ? WHERE id = ? AND user_id = ?",
+		interest.Terms, interest.Weight, synonyms, interest.Pattern, time.Now().UTC(), id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update interest: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Interest not found
+	}
+
+	return s.GetInterest(userID, id)
+}
+
+// DeleteInterest removes one of a user's interests and reports whether it
+// existed
+func (s *SQLiteDB) DeleteInterest(userID, id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM user_interests WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete interest: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ImportInterests adds the interests of a text with one interest per line,
+// as read by the run command, to a user's profile with weight 1. Interests
+// the user already has are skipped. It returns the added interests.
+func (s *SQLiteDB) ImportInterests(userID string, r io.Reader) ([]Interest, error) {
+	lines, err := riffle.ReadInterests(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read interests: %w", err)
+	}
+
+	// Validate all interests before adding any
+	var interests []*Interest
+	for _, line := range lines {
+		interest, err := newInterest(InterestInput{Terms: line.Terms})
+		if err != nil {
+			return nil, err
+		}
+		interests = append(interests, interest)
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	imported := []Interest{}
+	for _, interest := range interests {
+		exists, err := interestExists(tx, userID, interest.Terms, "")
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+		if err := insertInterest(tx, userID, interest); err != nil {
+			return nil, err
+		}
+		imported = append(imported, *interest)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return imported, nil
+}
+
+// UserInterests returns a user's interest profile for scoring articles
+func (s *SQLiteDB) UserInterests(userID string) ([]riffle.Interest, error) {
+	interests, err := s.ListInterests(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile := make([]riffle.Interest, 0, len(interests))
+	for i := range interests {
+		interest, err := interests[i].riffleInterest()
+		if err != nil {
+			return nil, err
+		}
+		profile = append(profile, interest)
+	}
+	return profile, nil
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+	"k8s.io/klog/v2"
+)
+
+// indexText returns the language of a content item's text, or the declared
+// language if it cannot be detected, and the search terms of the text
+func indexText(title, description, content, declared string) (string, string) {
+	text := title + "\n" + riffle.PlainText(description) + "\n" + riffle.PlainText(content)
+	language := riffle.DetectLanguage(text)
+	if language == "" {
+		language = riffle.NormalizeLanguage(declared)
+	}
+	return language, searchTerms(riffle.Tokenize(text))
+}
+
+// searchTerms stores the distinct terms of a text between spaces, so that a
+// term is matched with LIKE '% term %'
+func searchTerms(terms []string) string {
+	seen := map[string]bool{}
+	var b strings.Builder
+	b.WriteString(" ")
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			b.WriteString(term + " ")
+		}
+	}
+	return b.String()
+}
+
+// reindexContent updates the language and search terms of a content item
+// after its text changed, keeping the language if it cannot be detected
+func reindexContent(q queryer, id, title, description, content string) error {
+	language, terms := indexText(title, description, content, "")
+	_, err := q.Exec(
+		"UPDATE rss_contents SET language = COALESCE(?, language), search_terms = ? WHERE id = ?",
+		nullString(language), terms, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to index RSS content: %w", err)
+	}
+	return nil
+}
+
+// keywordCondition returns a SQL condition matching the items that contain
+// all the terms of a keyword, together with its arguments. Keywords without
+// terms, such as punctuation, are matched as substrings of the text.
+func keywordCondition(keyword string) (string, []interface{}) {
+	terms := riffle.Tokenize(keyword)
+	if len(terms) == 0 {
+		pattern := "%" + keyword + "%"
+		return "(c.title LIKE ? OR c.description LIKE ? OR c.content LIKE ?)", []interface{}{pattern, pattern, pattern}
+	}
+
+	conditions := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	for i, term := range terms {
+		conditions[i] = "c.search_terms LIKE ?"
+		args[i] = "% " + term + " %"
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", args
+}
+
+// indexContents detects the language and stores the search terms of the
+// content fetched before they were introduced
+func indexContents(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, title, COALESCE(description, ''), COALESCE(content, '') FROM rss_contents WHERE search_terms IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to query RSS contents to index: %w", err)
+	}
+	type text struct{ id, title, description, content string }
+	var texts []text
+	for rows.Next() {
+		var t text
+		if err := rows.Scan(&t.id, &t.title, &t.description, &t.content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan RSS content to index: %w", err)
+		}
+		texts = append(texts, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over RSS contents to index: %w", err)
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, t := range texts {
+		if err := reindexContent(tx, t.id, t.title, t.description, t.content); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	klog.InfoS("Indexed the language and search terms of existing content", "count", len(texts))
+	return nil
+}
+
+// LoadCorpus counts the stored content items containing each term, so that
+// the terms of interests are weighed by their rarity in the stored content
+func (s *SQLiteDB) LoadCorpus() (*riffle.Corpus, error) {
+	rows, err := s.readDB.Query("SELECT COALESCE(search_terms, '') FROM rss_contents WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query search terms: %w", err)
+	}
+	defer rows.Close()
+
+	corpus := riffle.NewCorpus()
+	for rows.Next() {
+		var terms string
+		if err := rows.Scan(&terms); err != nil {
+			return nil, fmt.Errorf("failed to scan search terms: %w", err)
+		}
+		corpus.Add(strings.Fields(terms))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over search terms: %w", err)
+	}
+	return corpus, nil
+}
+
+// withCorpus calls fn with the corpus of the stored content, loading it
+// first if it is not loaded yet. fn must not keep the corpus, which is
+// updated as content is stored.
+func (s *SQLiteDB) withCorpus(fn func(corpus *riffle.Corpus) error) error {
+	for {
+		s.corpusMu.RLock()
+		if s.corpus != nil {
+			defer s.corpusMu.RUnlock()
+			return fn(s.corpus)
+		}
+		s.corpusMu.RUnlock()
+
+		s.corpusMu.Lock()
+		if s.corpus == nil {
+			corpus, err := s.LoadCorpus()
+			if err != nil {
+				s.corpusMu.Unlock()
+				return err
+			}
+			s.corpus = corpus
+		}
+		s.corpusMu.Unlock()
+	}
+}
+
+// addToCorpus adds the search terms of a stored content item to the corpus,
+// if it is loaded
+func (s *SQLiteDB) addToCorpus(terms string) {
+	s.corpusMu.Lock()
+	defer s.corpusMu.Unlock()
+	if s.corpus != nil {
+		s.corpus.Add(strings.Fields(terms))
+	}
+}
+
+// resetCorpus drops the corpus after content is deleted or restored, so
+// that it is loaded again when next needed
+func (s *SQLiteDB) resetCorpus() {
+	s.corpusMu.Lock()
+	defer s.corpusMu.Unlock()
+	s.corpus = nil
+}
@@ -2,8 +2,12 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+	"github.com/google/uuid"
 )
 
 // RecommendationFeedback represents user feedback on a recommended content item
@@ -16,10 +20,11 @@ type RecommendationFeedback struct {
 	Comment   string    `json:"comment,omitempty"`
 }
 
-// CreateRecommendationFeedbackInput represents the input for creating recommendation feedback
+// CreateRecommendationFeedbackInput represents the input for creating recommendation feedback.
+// UserID is set from the authenticated user rather than the request body.
 type CreateRecommendationFeedbackInput struct {
 	ContentID string `json:"contentId"`
-	UserID    string `json:"userId"`
+	UserID    string `json:"-"`
 	Rating    int    `json:"rating"`
 	Comment   string `json:"comment,omitempty"`
 }
@@ -29,6 +34,12 @@ type RecommendationResult struct {
 	Content      RSSContent `json:"content"`
 	Score        float64    `json:"score"`
 	RecommendFor string     `json:"recommendFor,omitempty"`
+	// StorySize is the number of near-duplicate items this item stands for
+	// when recommendations are collapsed
+	StorySize int `json:"storySize,omitempty"`
+	// Components are the scores of the configured scorers, whose weighted
+	// sum is part of the score
+	Components []riffle.ComponentScore `json:"components,omitempty"`
 }
 
 // GetRecommendationsInput represents the input for getting recommendations
@@ -36,6 +47,10 @@ type GetRecommendationsInput struct {
 	UserID    string   `json:"userId,omitempty"`
 	SourceIDs []string `json:"sourceIds,omitempty"`
 	Limit     int      `json:"limit"`
+	// ExcludeDigested leaves out content already sent to the user in a digest
+	ExcludeDigested bool `json:"-"`
+	// Collapse recommends only the best scored item of each story
+	Collapse bool `json:"-"`
 }
 
 // CreateRecommendationFeedback creates a new recommendation feedback entry
@@ -55,7 +70,7 @@ func (s *SQLiteDB) CreateRecommendationFeedback(input CreateRecommendationFeedba
 	}
 
 	// Generate a new ID for the feedback
-	id := generateUUID()
+	id := uuid.New().String()
 	now := time.Now().UTC()
 
 	// Insert the feedback into the database
@@ -86,19 +101,65 @@ func (s *SQLiteDB) GetRecommendations(input GetRecommendationsInput) ([]Recommen
 		input.Limit = 10
 	}
 
+	// Select recent content (last 7 days) the user has not rated yet from
+	// the sources they are subscribed to
+	conditions := `
+			c.published_at >= datetime('now', '-7 day')
+			AND c.deleted_at IS NULL
+			AND c.hidden_at IS NULL
+	`
+	var conditionArgs []interface{}
+
+	// Only recommend content from sources the user is subscribed to
+	if input.UserID != "" {
+		conditions += " AND c.source_id IN (" + subscribedSourcesQuery + ")"
+		conditionArgs = append(conditionArgs, input.UserID)
+	}
+
+	// Add filter for specific sources if provided
+	if len(input.SourceIDs) > 0 {
+		conditions += " AND c.source_id IN (" + createPlaceholders(len(input.SourceIDs)) + ")"
+		for _, sourceID := range input.SourceIDs {
+			conditionArgs = append(conditionArgs, sourceID)
+		}
+	}
+
+	// Exclude content the user has already rated
+	if input.UserID != "" {
+		conditions += `
+			AND c.id NOT IN (
+				SELECT content_id FROM recommendation_feedback WHERE user_id = ?
+			)
+		`
+		conditionArgs = append(conditionArgs, input.UserID)
+	}
+
+	// Exclude content already sent to the user in a digest
+	if input.ExcludeDigested {
+		conditions += " AND c.id NOT IN (SELECT content_id FROM digest_items WHERE user_id = ?)"
+		conditionArgs = append(conditionArgs, input.UserID)
+	}
+
+	// Rate the content with the configured scorers
+	contentScores, components, err := s.contentScores(input.UserID, conditions, conditionArgs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build the query
 	// This is a simplified recommendation algorithm that:
-	// 1. Gets recent content (last 7 days)
-	// 2. Prioritizes content from sources with higher average ratings (if user has given feedback)
-	// 3. Excludes content the user has already rated
-	// 4. Sorts by a combination of recency and source popularity
+	// 1. Prioritizes content from sources with higher average ratings (if user has given feedback)
+	// 2. Adds the score of the configured scorers, such as how well the
+	//    content matches the user's interests, between 0 and 1
+	// 3. Sorts by a combination of recency, source popularity and interests
 	query := `
 		SELECT 
 			c.id, c.source_id, c.title, c.link, c.description, c.published_at, c.fetched_at,
+			COALESCE(c.story_id, '') AS story_id,
 			CASE
 				WHEN avg_ratings.avg_rating IS NOT NULL THEN avg_ratings.avg_rating * 0.7 + (1.0 - ((JULIANDAY('now') - JULIANDAY(c.published_at)) / 7.0)) * 0.3
 				ELSE (1.0 - ((JULIANDAY('now') - JULIANDAY(c.published_at)) / 7.0))
-			END as score
+			END + COALESCE(scores.value, 0) as score
 		FROM 
 			rss_contents c
 		LEFT JOIN (
@@ -116,27 +177,22 @@ func (s *SQLiteDB) GetRecommendations(input GetRecommendationsInput) ([]Recommen
 			GROUP BY 
 				s.id
 		) avg_ratings ON c.source_id = avg_ratings.source_id
-		WHERE 
-			c.published_at >= datetime('now', '-7 day')
-	`
-	args := []interface{}{input.UserID}
+		LEFT JOIN json_each(?) scores ON scores.key = c.id
+		WHERE ` + conditions
+	args := append([]interface{}{input.UserID, contentScores}, conditionArgs...)
 
-	// Add filter for specific sources if provided
-	if len(input.SourceIDs) > 0 {
-		query += " AND c.source_id IN (" + createPlaceholders(len(input.SourceIDs)) + ")"
-		for _, sourceID := range input.SourceIDs {
-			args = append(args, sourceID)
-		}
-	}
-
-	// Exclude content the user has already rated
-	if input.UserID != "" {
-		query += `
-			AND c.id NOT IN (
-				SELECT content_id FROM recommendation_feedback WHERE user_id = ?
-			)
-		`
-		args = append(args, input.UserID)
+	// Keep the best scored item of each story, counting the others
+	columns := "id, source_id, title, link, description, published_at, fetched_at, story_id, score"
+	if input.Collapse {
+		story := "CASE WHEN story_id = '' THEN id ELSE story_id END"
+		query = `SELECT ` + columns + `, story_size FROM (
+			SELECT r.*,
+				ROW_NUMBER() OVER (PARTITION BY ` + story + ` ORDER BY score DESC, id) AS story_rank,
+				COUNT(*) OVER (PARTITION BY ` + story + `) AS story_size
+			FROM (` + query + `) r
+		) WHERE story_rank = 1`
+	} else {
+		query = "SELECT " + columns + ", 1 FROM (" + query + ")"
 	}
 
 	// Add ordering and limit
@@ -144,7 +200,7 @@ func (s *SQLiteDB) GetRecommendations(input GetRecommendationsInput) ([]Recommen
 	args = append(args, input.Limit)
 
 	// Execute the query
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recommendations: %w", err)
 	}
@@ -155,6 +211,7 @@ func (s *SQLiteDB) GetRecommendations(input GetRecommendationsInput) ([]Recommen
 	for rows.Next() {
 		var content RSSContent
 		var score float64
+		var storySize int
 		err := rows.Scan(
 			&content.ID,
 			&content.SourceID,
@@ -163,17 +220,24 @@ func (s *SQLiteDB) GetRecommendations(input GetRecommendationsInput) ([]Recommen
 			&content.Description,
 			&content.PublishedAt,
 			&content.FetchedAt,
+			&content.StoryID,
 			&score,
+			&storySize,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan recommendation: %w", err)
 		}
 
-		recommendations = append(recommendations, RecommendationResult{
+		recommendation := RecommendationResult{
 			Content:      content,
 			Score:        score,
 			RecommendFor: input.UserID,
-		})
+			Components:   components[content.ID],
+		}
+		if storySize > 1 {
+			recommendation.StorySize = storySize
+		}
+		recommendations = append(recommendations, recommendation)
 	}
 
 	// Check for errors from iterating over rows
@@ -184,10 +248,108 @@ func (s *SQLiteDB) GetRecommendations(input GetRecommendationsInput) ([]Recommen
 	return recommendations, nil
 }
 
+// SetScoringConfig configures the scorers whose weighted score is added to
+// the score of recommendations, instead of only how well content matches
+// the user's interests. It returns an error if the configuration is invalid.
+func (s *SQLiteDB) SetScoringConfig(config *riffle.ScoringConfig) error {
+	if _, err := riffle.NewContentAnalyzer("", config); err != nil {
+		return fmt.Errorf("invalid scoring configuration: %w", err)
+	}
+	s.scoring = config
+	return nil
+}
+
+// contentScores rates the content matching conditions for a user with the
+// configured scorers, weighing terms by their frequency in the stored
+// content, and returns the scores as a JSON object keyed by content ID
+// together with the scores of the individual scorers. The items are scored
+// by their title and description rather than their full text. Without a
+// user there are no scores.
+func (s *SQLiteDB) contentScores(userID, conditions string, args []interface{}) (string, map[string][]riffle.ComponentScore, error) {
+	if userID == "" {
+		return "{}", nil, nil
+	}
+	profile, err := s.UserInterests(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := s.readDB.Query(
+		`SELECT c.id, c.title, COALESCE(c.description, ''), c.link, c.published_at,
+			COALESCE(c.language, ''), COALESCE(src.url, '')
+		FROM rss_contents c LEFT JOIN rss_sources src ON src.id = c.source_id
+		WHERE `+conditions,
+		args...,
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to query content to score: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	var articles []*riffle.Article
+	for rows.Next() {
+		var id string
+		var article riffle.Article
+		err := rows.Scan(&id, &article.Title, &article.Summary, &article.URL, &article.PublishedAt,
+			&article.Language, &article.Source)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to scan content to score: %w", err)
+		}
+		ids = append(ids, id)
+		articles = append(articles, &article)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, fmt.Errorf("error iterating over content to score: %w", err)
+	}
+
+	// Score only interest matches unless configured otherwise
+	config := s.scoring
+	if config == nil {
+		if config, err = riffle.ParseScoringConfig([]byte(interestScoringConfig)); err != nil {
+			return "", nil, err
+		}
+	}
+	analyzer, err := riffle.NewContentAnalyzer("", config)
+	if err != nil {
+		return "", nil, err
+	}
+	analyzer.SetInterests(profile)
+	var analyzed []riffle.ArticleScore
+	err = s.withCorpus(func(corpus *riffle.Corpus) error {
+		analyzer.SetCorpus(corpus)
+		analyzed, err = analyzer.AnalyzeArticles(articles)
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to score content: %w", err)
+	}
+
+	scores := make(map[string]float64, len(ids))
+	components := make(map[string][]riffle.ComponentScore, len(ids))
+	for i, score := range analyzed {
+		scores[ids[i]] = score.Score
+		components[ids[i]] = score.Components
+	}
+	data, err := json.Marshal(scores)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode content scores: %w", err)
+	}
+	return string(data), components, nil
+}
+
+// interestScoringConfig is the default scoring configuration of
+// recommendations, which scores content only by how well it matches the
+// user's interests
+const interestScoringConfig = `scorers:
+  - name: interests
+    weight: 1
+`
+
 // GetUserFeedback retrieves all feedback given by a user
 func (s *SQLiteDB) GetUserFeedback(userID string) ([]RecommendationFeedback, error) {
 	// Query the feedback
-	rows, err := s.db.Query(
+	rows, err := s.readDB.Query(
 		`SELECT id, content_id, user_id, rating, timestamp, comment
 		FROM recommendation_feedback
 		WHERE user_id = ?
@@ -243,10 +405,3 @@ func createPlaceholders(count int) string {
 	}
 	return placeholders
 }
-
-// Helper function to generate a UUID
-func generateUUID() string {
-	// Using a simple implementation since we can't import uuid package yet
-	// This should be replaced with a proper UUID implementation
-	return fmt.Sprintf("%d", time.Now().UnixNano())
-}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+)
+
+func TestRecommendationComponents(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "reader", RoleReader)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.CreateSubscription(user.ID, CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if _, err := db.CreateInterest(user.ID, InterestInput{Terms: "golang"}); err != nil {
+		t.Fatalf("CreateInterest() error = %v", err)
+	}
+	matching := newTestContent(t, db, source.ID, "https://example.com/go", "Golang release")
+	newTestContent(t, db, source.ID, "https://example.com/rust", "Rust release")
+
+	recommend := func() []RecommendationResult {
+		t.Helper()
+		recommendations, err := db.GetRecommendations(GetRecommendationsInput{UserID: user.ID})
+		if err != nil {
+			t.Fatalf("GetRecommendations() error = %v", err)
+		}
+		if len(recommendations) != 2 {
+			t.Fatalf("GetRecommendations() = %d items, want 2", len(recommendations))
+		}
+		return recommendations
+	}
+
+	// Only interest matches are scored by default
+	recommendations := recommend()
+	if recommendations[0].Content.ID != matching.ID {
+		t.Errorf("first recommendation = %q, want the item matching the interest", recommendations[0].Content.Title)
+	}
+	for _, recommendation := range recommendations {
+		components := recommendation.Components
+		if len(components) != 1 || components[0].Name != "interests" || components[0].Weight != 1 {
+			t.Errorf("%q components = %+v, want only interests", recommendation.Content.Title, components)
+		}
+	}
+
+	// Configured scorers are listed with their normalized weights
+	config, err := riffle.ParseScoringConfig([]byte("scorers:\n  - name: interests\n    weight: 3\n  - name: length\n    weight: 1\n"))
+	if err != nil {
+		t.Fatalf("ParseScoringConfig() error = %v", err)
+	}
+	if err := db.SetScoringConfig(config); err != nil {
+		t.Fatalf("SetScoringConfig() error = %v", err)
+	}
+	for _, recommendation := range recommend() {
+		components := recommendation.Components
+		if len(components) != 2 || components[0].Weight != 0.75 || components[1].Name != "length" || components[1].Weight != 0.25 {
+			t.Errorf("%q components = %+v, want interests and length weighted 3:1", recommendation.Content.Title, components)
+		}
+	}
+}
+
+func TestSetScoringConfigInvalid(t *testing.T) {
+	db := newTestDB(t)
+	config := &riffle.ScoringConfig{Scorers: []riffle.ScorerConfig{{Name: "unknown", Weight: 1}}}
+	if err := db.SetScoringConfig(config); err == nil {
+		t.Error("SetScoringConfig() of an unknown scorer succeeded")
+	}
+	if db.scoring != nil {
+		t.Errorf("scoring = %+v after an invalid configuration, want nil", db.scoring)
+	}
+}
+
+func TestRecommendationsWeighTermsByStoredContent(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "reader", RoleReader)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.CreateSubscription(user.ID, CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	for _, terms := range []string{"golang", "kubernetes"} {
+		if _, err := db.CreateInterest(user.ID, InterestInput{Terms: terms}); err != nil {
+			t.Fatalf("CreateInterest() error = %v", err)
+		}
+	}
+	golang := newTestContent(t, db, source.ID, "https://example.com/golang", "Golang notes")
+	kubernetes := newTestContent(t, db, source.ID, "https://example.com/kubernetes", "Kubernetes notes")
+
+	// Content of other sources makes the interests' terms common
+	other := newTestSource(t, db, "https://example.com/other.xml")
+	addOther := func(title string, n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			newTestContent(t, db, other.ID, "https://example.com/"+title+"/"+string(rune('a'+i)), title)
+		}
+	}
+	// interestScores returns the interest score of the two items
+	interestScores := func() (float64, float64) {
+		t.Helper()
+		recommendations, err := db.GetRecommendations(GetRecommendationsInput{UserID: user.ID})
+		if err != nil {
+			t.Fatalf("GetRecommendations() error = %v", err)
+		}
+		scores := map[string]float64{}
+		for _, recommendation := range recommendations {
+			scores[recommendation.Content.ID] = recommendation.Components[0].Score
+		}
+		return scores[golang.ID], scores[kubernetes.ID]
+	}
+
+	// A term common in the stored content counts less, even if it is as
+	// common as the other among the recommended items
+	addOther("Kubernetes", 5)
+	if g, k := interestScores(); g <= k {
+		t.Errorf("interest scores of golang %v and kubernetes %v, want golang higher", g, k)
+	}
+
+	// Stored content is counted as it is added
+	addOther("Golang", 10)
+	if g, k := interestScores(); g >= k {
+		t.Errorf("interest scores of golang %v and kubernetes %v after storing golang content, want kubernetes higher", g, k)
+	}
+
+	// and no longer once deleted
+	contents, _, err := db.ListContents(ListContentsInput{SourceID: other.ID, Limit: 100})
+	if err != nil {
+		t.Fatalf("ListContents() error = %v", err)
+	}
+	for _, content := range contents {
+		if strings.HasPrefix(content.Title, "Golang") {
+			if err := db.DeleteContent(content.ID); err != nil {
+				t.Fatalf("DeleteContent() error = %v", err)
+			}
+		}
+	}
+	if g, k := interestScores(); g <= k {
+		t.Errorf("interest scores of golang %v and kubernetes %v after deleting golang content, want golang higher", g, k)
+	}
+}
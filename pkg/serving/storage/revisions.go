@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RevisionOriginFeed marks a revision imported from the publisher's feed
+	RevisionOriginFeed = "feed"
+	// RevisionOriginUser marks a revision created through the API
+	RevisionOriginUser = "user"
+)
+
+// ErrRevisionNotRevertible is returned when reverting a revision that is not a user edit
+var ErrRevisionNotRevertible = errors.New("only user edits can be reverted")
+
+// ContentRevision represents a stored version of a content item's text
+type ContentRevision struct {
+	ID          string    `json:"id"`
+	ContentID   string    `json:"contentId"`
+	Revision    int       `json:"revision"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Content     string    `json:"content,omitempty"`
+	Origin      string    `json:"origin"`
+	RevertOf    *int      `json:"revertOf,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// FeedContentInput represents the text of a content item as published in its feed
+type FeedContentInput struct {
+	Title       string
+	Description string
+	Content     string
+}
+
+// insertRevision records a new revision of a content item using the given queryer
+func insertRevision(q queryer, contentID, title, description, content, origin string, revertOf *int) (*ContentRevision, error) {
+	// Get the next revision number for this content item
+	var next int
+	err := q.QueryRow(
+		"SELECT COALESCE(MAX(revision), 0) + 1 FROM content_revisions WHERE content_id = ?",
+		contentID,
+	).Scan(&next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next revision number: %w", err)
+	}
+
+	revision := &ContentRevision{
+		ID:          uuid.New().String(),
+		ContentID:   contentID,
+		Revision:    next,
+		Title:       title,
+		Description: description,
+		Content:     content,
+		Origin:      origin,
+		RevertOf:    revertOf,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	_, err = q.Exec(
+		`INSERT INTO content_revisions (id, content_id, revision, title, description, content, origin, revert_of, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		revision.ID, revision.ContentID, revision.Revision, revision.Title, revision.Description,
+		revision.Content, revision.Origin, revision.RevertOf, revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert content revision: %w", err)
+	}
+
+	return revision, nil
+}
+
+// ensureBaselineRevision records the current text of a content item as a feed
+// revision if it has no revisions yet, which is the case for items stored
+// before revision history existed
+func ensureBaselineRevision(q queryer, content *RSSContent) error {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM content_revisions WHERE content_id = ?", content.ID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count content revisions: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = insertRevision(q, content.ID, content.Title, content.Description, content.Content, RevisionOriginFeed, nil)
+	return err
+}
+
+// scanRevision scans a content revision from a row
+func scanRevision(scan func(dest ...interface{}) error) (*ContentRevision, error) {
+	var revision ContentRevision
+	var description, content sql.NullString
+	var revertOf sql.NullInt64
+
+	err := scan(
+		&revision.ID,
+		&revision.ContentID,
+		&revision.Revision,
+		&revision.Title,
+		&description,
+		&content,
+		&revision.Origin,
+		&revertOf,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	revision.Description = description.String
+	revision.Content = content.String
+	if revertOf.Valid {
+		r := int(revertOf.Int64)
+		revision.RevertOf = &r
+	}
+
+	return &revision, nil
+}
+
+// ListContentRevisions lists all revisions of a content item, oldest first
+func (s *SQLiteDB) ListContentRevisions(contentID string) ([]ContentRevision, error) {
+	rows, err := s.readDB.Query(
+		`SELECT id, content_id, revision, title, description, content, origin, revert_of, created_at
+		FROM content_revisions
+		WHERE content_id = ?
+		ORDER BY revision ASC`,
+		contentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list content revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []ContentRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan content revision: %w", err)
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over content revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetContentRevision retrieves a single revision of a content item
+func (s *SQLiteDB) GetContentRevision(contentID string, revision int) (*ContentRevision, error) {
+	return getContentRevision(s.readDB, contentID, revision)
+}
+
+// getContentRevision retrieves a single revision of a content item using the given queryer
+func getContentRevision(q queryer, contentID string, revision int) (*ContentRevision, error) {
+	row := q.QueryRow(
+		`SELECT id, content_id, revision, title, description, content, origin, revert_of, created_at
+		FROM content_revisions
+		WHERE content_id = ? AND revision = ?`,
+		contentID, revision,
+	)
+
+	rev, err := scanRevision(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Revision not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get content revision: %w", err)
+	}
+
+	return rev, nil
+}
+
+// getLastFeedRevision retrieves the latest feed revision of a content item
+// using the given queryer, or nil if it has none
+func getLastFeedRevision(q queryer, contentID string) (*ContentRevision, error) {
+	row := q.QueryRow(
+		`SELECT id, content_id, revision, title, description, content, origin, revert_of, created_at
+		FROM content_revisions
+		WHERE content_id = ? AND origin = ?
+		ORDER BY revision DESC LIMIT 1`,
+		contentID, RevisionOriginFeed,
+	)
+
+	rev, err := scanRevision(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // No feed revision
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get last feed revision: %w", err)
+	}
+
+	return rev, nil
+}
+
+// UpdateContentFromFeed applies the text of a content item as currently
+// published in its feed. If it differs from the last feed revision the
+// publisher has edited the article, so a new feed revision is recorded and
+// the content is updated. User edits take precedence: if the current text was
+// edited through the API, that is it differs from the last feed revision, the
+// feed revision is only recorded and the user's text is kept. It reports
+// whether the content's text changed.
+func (s *SQLiteDB) UpdateContentFromFeed(id string, input FeedContentInput) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	content, err := getContent(tx, id)
+	if err != nil {
+		return false, err
+	}
+	if content == nil {
+		return false, nil // Content not found
+	}
+
+	if err := ensureBaselineRevision(tx, content); err != nil {
+		return false, err
+	}
+
+	// Compare against what the feed published last time, not the current
+	// text, so that user edits are not treated as publisher changes
+	last, err := getLastFeedRevision(tx, id)
+	if err != nil {
+		return false, err
+	}
+	if last != nil && last.Title == input.Title && last.Description == input.Description && last.Content == input.Content {
+		return false, nil // Unchanged
+	}
+
+	if _, err := insertRevision(tx, id, input.Title, input.Description, input.Content, RevisionOriginFeed, nil); err != nil {
+		return false, err
+	}
+
+	// Keep the text if the user edited it
+	if last != nil && (last.Title != content.Title || last.Description != content.Description || last.Content != content.Content) {
+		if err := tx.Commit(); err != nil {
+			return false, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return false, nil
+	}
+
+	// Update the content
+	now := time.Now().UTC()
+	_, err = tx.Exec(
+		`UPDATE rss_contents
+		SET title = ?, description = ?, content = ?, updated_at = ?
+		WHERE id = ?`,
+		input.Title, input.Description, input.Content, now, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update RSS content: %w", err)
+	}
+	if err := reindexContent(tx, id, input.Title, input.Description, input.Content); err != nil {
+		return false, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// RevertContentRevision reverts a user edit by restoring the text of the
+// revision that preceded it. If the publisher changed the article since, the
+// text of the latest feed revision is restored instead, so that the item
+// follows its feed again. The revert is itself recorded as a new user
+// revision. It returns nil if the content item or revision does not exist.
+func (s *SQLiteDB) RevertContentRevision(contentID string, revision int) (*RSSContent, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Get the revision to revert
+	target, err := getContentRevision(tx, contentID, revision)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, nil // Revision not found
+	}
+	if target.Origin != RevisionOriginUser {
+		return nil, ErrRevisionNotRevertible
+	}
+
+	// Get the revision that preceded the user edit
+	row := tx.QueryRow(
+		`SELECT id, content_id, revision, title, description, content, origin, revert_of, created_at
+		FROM content_revisions
+		WHERE content_id = ? AND revision < ?
+		ORDER BY revision DESC LIMIT 1`,
+		contentID, revision,
+	)
+	previous, err := scanRevision(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotRevertible
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get previous content revision: %w", err)
+	}
+
+	// Restoring older text than the feed last published would leave the
+	// item looking edited, so later publisher changes would never apply
+	lastFeed, err := getLastFeedRevision(tx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	if lastFeed != nil && lastFeed.Revision > previous.Revision {
+		previous = lastFeed
+	}
+
+	// Restore the previous text
+	now := time.Now().UTC()
+	_, err = tx.Exec(
+		`UPDATE rss_contents
+		SET title = ?, description = ?, content = ?, updated_at = ?
+		WHERE id = ?`,
+		previous.Title, previous.Description, previous.Content, now, contentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update RSS content: %w", err)
+	}
+	if err := reindexContent(tx, contentID, previous.Title, previous.Description, previous.Content); err != nil {
+		return nil, err
+	}
+
+	_, err = insertRevision(tx, contentID, previous.Title, previous.Description, previous.Content, RevisionOriginUser, &revision)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := getContent(tx, contentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import "testing"
+
+func TestRevertUserEditAfterPublisherChange(t *testing.T) {
+	db := newTestDB(t)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	content := newTestContent(t, db, source.ID, "https://example.com/post", "Original title")
+
+	// A user edits the item, then the publisher changes it
+	_, err := db.UpdateContent(content.ID, UpdateContentInput{Title: "Edited title", Description: content.Description})
+	if err != nil {
+		t.Fatalf("UpdateContent() error = %v", err)
+	}
+	changed, err := db.UpdateContentFromFeed(content.ID, FeedContentInput{Title: "Publisher title", Description: content.Description})
+	if err != nil {
+		t.Fatalf("UpdateContentFromFeed() error = %v", err)
+	}
+	if changed {
+		t.Fatalf("UpdateContentFromFeed() replaced the user's edit")
+	}
+
+	// Reverting the edit restores what the feed published last
+	revisions, err := db.ListContentRevisions(content.ID)
+	if err != nil {
+		t.Fatalf("ListContentRevisions() error = %v", err)
+	}
+	var edit int
+	for _, rev := range revisions {
+		if rev.Origin == RevisionOriginUser {
+			edit = rev.Revision
+		}
+	}
+	reverted, err := db.RevertContentRevision(content.ID, edit)
+	if err != nil {
+		t.Fatalf("RevertContentRevision() error = %v", err)
+	}
+	if reverted.Title != "Publisher title" {
+		t.Errorf("title after revert = %q, want %q", reverted.Title, "Publisher title")
+	}
+
+	// The item follows its feed again
+	changed, err = db.UpdateContentFromFeed(content.ID, FeedContentInput{Title: "Corrected title", Description: content.Description})
+	if err != nil {
+		t.Fatalf("UpdateContentFromFeed() error = %v", err)
+	}
+	got, err := db.GetContent(content.ID)
+	if err != nil {
+		t.Fatalf("GetContent() error = %v", err)
+	}
+	if !changed || got.Title != "Corrected title" {
+		t.Errorf("after a later publisher change: changed = %v, title = %q, want the feed's title", changed, got.Title)
+	}
+}
+
+func TestRevertUserEditWithoutPublisherChange(t *testing.T) {
+	db := newTestDB(t)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	content := newTestContent(t, db, source.ID, "https://example.com/post", "Original title")
+
+	for _, title := range []string{"First edit", "Second edit"} {
+		if _, err := db.UpdateContent(content.ID, UpdateContentInput{Title: title, Description: content.Description}); err != nil {
+			t.Fatalf("UpdateContent() error = %v", err)
+		}
+	}
+
+	// Revisions are the baseline, then the two edits
+	reverted, err := db.RevertContentRevision(content.ID, 3)
+	if err != nil {
+		t.Fatalf("RevertContentRevision() error = %v", err)
+	}
+	if reverted.Title != "First edit" {
+		t.Errorf("title after revert = %q, want %q", reverted.Title, "First edit")
+	}
+
+	if _, err := db.RevertContentRevision(content.ID, 1); err != ErrRevisionNotRevertible {
+		t.Errorf("RevertContentRevision() of a feed revision error = %v, want ErrRevisionNotRevertible", err)
+	}
+}
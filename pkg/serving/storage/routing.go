@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidRoutingRule is returned for routing rules with an invalid
+// condition, action, position or source
+var ErrInvalidRoutingRule = errors.New("invalid routing rule")
+
+// RoutingRule is one of a user's rules that organize new content as it is
+// ingested. A user's rules are evaluated in order of position, and every
+// matching rule applies its actions until one stops processing.
+type RoutingRule struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	// SourceID limits the rule to one source; the rule applies to all
+	// sources if it is empty
+	SourceID string `json:"sourceId,omitempty"`
+	Field    string `json:"field"`
+	Match    string `json:"match"`
+	// Pattern is matched against the field; a rule without a pattern
+	// matches every item of its source
+	Pattern string `json:"pattern,omitempty"`
+	// Tags are added to matching items
+	Tags []string `json:"tags,omitempty"`
+	// Star stars matching items
+	Star bool `json:"star"`
+	// Priority sets the priority of matching items
+	Priority *int `json:"priority,omitempty"`
+	// Folder moves matching items into a folder
+	Folder string `json:"folder,omitempty"`
+	// StopProcessing skips the user's later rules for items this rule matches
+	StopProcessing bool       `json:"stopProcessing"`
+	Enabled        bool       `json:"enabled"`
+	MatchCount     int        `json:"matchCount"`
+	LastMatchedAt  *time.Time `json:"lastMatchedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// The compiled pattern
+	matcher textMatcher
+}
+
+// RoutingRuleInput represents the input for creating or updating a routing
+// rule
+type RoutingRuleInput struct {
+	Name string `json:"name"`
+	// Position defaults to after the user's other rules when creating a
+	// rule, and to the current position when updating one
+	Position *int   `json:"position,omitempty"`
+	SourceID string `json:"sourceId,omitempty"`
+	// Field defaults to any
+	Field string `json:"field,omitempty"`
+	// Match defaults to keyword
+	Match          string   `json:"match,omitempty"`
+	Pattern        string   `json:"pattern,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Star           bool     `json:"star,omitempty"`
+	Priority       *int     `json:"priority,omitempty"`
+	Folder         string   `json:"folder,omitempty"`
+	StopProcessing bool     `json:"stopProcessing,omitempty"`
+	Enabled        *bool    `json:"enabled,omitempty"`
+}
+
+// compile prepares the rule's pattern for matching
+func (r *RoutingRule) compile() error {
+	if r.Pattern == "" {
+		return nil
+	}
+	matcher, err := newTextMatcher(r.Match, r.Pattern)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRoutingRule, err)
+	}
+	r.matcher = matcher
+	return nil
+}
+
+// Matches reports whether the rule applies to a content item, regardless of
+// whether it is enabled
+func (r *RoutingRule) Matches(content *RSSContent) bool {
+	if r.SourceID != "" && r.SourceID != content.SourceID {
+		return false
+	}
+	return r.Pattern == "" || r.matcher.matchField(r.Field, content)
+}
+
+// newRoutingRule validates an input and builds the rule it describes,
+// without storing it
+func newRoutingRule(input RoutingRuleInput) (*RoutingRule, error) {
+	rule := &RoutingRule{
+		Name:           strings.TrimSpace(input.Name),
+		SourceID:       strings.TrimSpace(input.SourceID),
+		Field:          input.Field,
+		Match:          input.Match,
+		Pattern:        input.Pattern,
+		Star:           input.Star,
+		Priority:       input.Priority,
+		Folder:         strings.TrimSpace(input.Folder),
+		StopProcessing: input.StopProcessing,
+		Enabled:        input.Enabled == nil || *input.Enabled,
+	}
+	if rule.Field == "" {
+		rule.Field = FilterFieldAny
+	}
+	if rule.Match == "" {
+		rule.Match = FilterMatchKeyword
+	}
+
+	if !oneOf(rule.Field, FilterFields) {
+		return nil, fmt.Errorf("%w: unknown field %q, use one of %s", ErrInvalidRoutingRule, rule.Field, strings.Join(FilterFields, ", "))
+	}
+	if !oneOf(rule.Match, FilterMatchTypes) {
+		return nil, fmt.Errorf("%w: unknown match type %q, use one of %s", ErrInvalidRoutingRule, rule.Match, strings.Join(FilterMatchTypes, ", "))
+	}
+	if rule.SourceID == "" && rule.Pattern == "" {
+		return nil, fmt.Errorf("%w: a source or a pattern is required", ErrInvalidRoutingRule)
+	}
+	if input.Position != nil && *input.Position < 1 {
+		return nil, fmt.Errorf("%w: position must be at least 1", ErrInvalidRoutingRule)
+	}
+	if utf8.RuneCountInString(rule.Folder) > maxFolderLength {
+		return nil, fmt.Errorf("%w: folder is longer than %d characters", ErrInvalidRoutingRule, maxFolderLength)
+	}
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRoutingRule, err)
+	}
+	rule.Tags = tags
+	if len(rule.Tags) == 0 && !rule.Star && rule.Priority == nil && rule.Folder == "" {
+		return nil, fmt.Errorf("%w: at least one of tags, star, priority or folder is required", ErrInvalidRoutingRule)
+	}
+	if err := rule.compile(); err != nil {
+		return nil, err
+	}
+	if rule.Name == "" {
+		if rule.Pattern != "" {
+			rule.Name = rule.Field + " " + rule.Pattern
+		} else {
+			rule.Name = "source " + rule.SourceID
+		}
+	}
+	return rule, nil
+}
+
+// routingRuleColumns is the column list used to scan routing rules
+const routingRuleColumns = "id, user_id, name, position, source_id, field, match_type, pattern, tags, star, priority, folder, " +
+	"stop_processing, enabled, match_count, last_matched_at, created_at, updated_at"
+
+// scanRoutingRule scans a routing rule from a row and compiles its pattern
+func scanRoutingRule(scan func(dest ...interface{}) error) (*RoutingRule, error) {
+	var r RoutingRule
+	var sourceID, tags, folder sql.NullString
+	var priority sql.NullInt64
+	var lastMatchedAt sql.NullTime
+	err := scan(&r.ID, &r.UserID, &r.Name, &r.Position, &sourceID, &r.Field, &r.Match, &r.Pattern, &tags, &r.Star,
+		&priority, &folder, &r.StopProcessing, &r.Enabled, &r.MatchCount, &lastMatchedAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.SourceID = sourceID.String
+	r.Folder = folder.String
+	if priority.Valid {
+		p := int(priority.Int64)
+		r.Priority = &p
+	}
+	if lastMatchedAt.Valid {
+		r.LastMatchedAt = &lastMatchedAt.Time
+	}
+	if r.Tags, err = decodeStrings(tags); err != nil {
+		return nil, err
+	}
+	if err := r.compile(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateRoutingRule creates a routing rule for a user
+func (s *SQLiteDB) CreateRoutingRule(userID string, input RoutingRuleInput) (*RoutingRule, error) {
+	rule, err := newRoutingRule(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRuleSource(rule.SourceID, ErrInvalidRoutingRule); err != nil {
+		return nil, err
+	}
+	tags, err := encodeStrings(rule.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put the rule after the user's other rules unless a position is given
+	if input.Position != nil {
+		rule.Position = *input.Position
+	} else {
+		err := s.db.QueryRow("SELECT COALESCE(MAX(position), 0) + 1 FROM routing_rules WHERE user_id = ?", userID).Scan(&rule.Position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get routing rule position: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	rule.ID = uuid.New().String()
+	rule.UserID = userID
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	_, err = s.db.Exec(
+		`INSERT INTO routing_rules (`+routingRuleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, rule.UserID, rule.Name, rule.Position, nullString(rule.SourceID), rule.Field, rule.Match, rule.Pattern,
+		tags, rule.Star, rule.Priority, nullString(rule.Folder), rule.StopProcessing, rule.Enabled, 0, nil,
+		rule.CreatedAt, rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create routing rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// GetRoutingRule retrieves one of a user's routing rules
+func (s *SQLiteDB) GetRoutingRule(userID, id string) (*RoutingRule, error) {
+	row := s.readDB.QueryRow("SELECT "+routingRuleColumns+" FROM routing_rules WHERE id = ? AND user_id = ?", id, userID)
+	rule, err := scanRoutingRule(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Routing rule not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get routing rule: %w", err)
+	}
+	return rule, nil
+}
+
+// listRoutingRules lists the routing rules matching a condition in the order
+// they are evaluated
+func (s *SQLiteDB) listRoutingRules(condition string, args ...interface{}) ([]RoutingRule, error) {
+	rows, err := s.readDB.Query(
+		"SELECT "+routingRuleColumns+" FROM routing_rules WHERE "+condition+" ORDER BY user_id, position ASC, created_at ASC",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routing rules: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	rules := []RoutingRule{}
+	for rows.Next() {
+		rule, err := scanRoutingRule(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over routing rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// ListRoutingRules lists a user's routing rules in the order they are
+// evaluated
+func (s *SQLiteDB) ListRoutingRules(userID string) ([]RoutingRule, error) {
+	return s.listRoutingRules("user_id = ?", userID)
+}
+
+// ListEnabledRoutingRules lists the enabled routing rules of all users,
+// grouped by user in the order they are evaluated
+func (s *SQLiteDB) ListEnabledRoutingRules() ([]RoutingRule, error) {
+	return s.listRoutingRules("enabled = 1")
+}
+
+// UpdateRoutingRule replaces one of a user's routing rules, keeping its
+// match count. It returns nil if the rule does not exist.
+func (s *SQLiteDB) UpdateRoutingRule(userID, id string, input RoutingRuleInput) (*RoutingRule, error) {
+	rule, err := newRoutingRule(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRuleSource(rule.SourceID, ErrInvalidRoutingRule); err != nil {
+		return nil, err
+	}
+	tags, err := encodeStrings(rule.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE routing_rules SET name = ?, position = COALESCE(?, position), source_id = ?, field = ?, match_type = ?,
+			pattern = ?, tags = ?, star = ?, priority = ?, folder = ?, stop_processing = ?, enabled = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?`,
+		rule.Name, input.Position, nullString(rule.SourceID), rule.Field, rule.Match, rule.Pattern, tags, rule.Star,
+		rule.Priority, nullString(rule.Folder), rule.StopProcessing, rule.Enabled, time.Now().UTC(), id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update routing rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Routing rule not found
+	}
+
+	return s.GetRoutingRule(userID, id)
+}
+
+// DeleteRoutingRule deletes one of a user's routing rules and reports
+// whether it existed. Items it routed keep their tags, stars, priority and
+// folder.
+func (s *SQLiteDB) DeleteRoutingRule(userID, id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM routing_rules WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete routing rule: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// routeContent applies the routing rules of the users subscribed to a new
+// content item's source, given rules as listed by ListEnabledRoutingRules,
+// and counts the matches of each rule
+func routeContent(q queryer, rules []RoutingRule, content *RSSContent, now time.Time) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// Only the subscribers' rules apply
+	subscribers, err := sourceSubscribers(q, content.SourceID)
+	if err != nil {
+		return err
+	}
+
+	stopped := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		if !subscribers[rule.UserID] || stopped[rule.UserID] || !rule.Matches(content) {
+			continue
+		}
+		if err := applyRoutingRule(q, rule, content.ID, now); err != nil {
+			return err
+		}
+		stopped[rule.UserID] = rule.StopProcessing
+	}
+
+	return nil
+}
+
+// sourceSubscribers returns the IDs of the users subscribed to a source
+func sourceSubscribers(q queryer, sourceID string) (map[string]bool, error) {
+	rows, err := q.Query("SELECT user_id FROM subscriptions WHERE source_id = ?", sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	subscribers := map[string]bool{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		subscribers[userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over subscribers: %w", err)
+	}
+	return subscribers, nil
+}
+
+// applyRoutingRule applies a rule's actions to a content item for the rule's
+// user and counts the match
+func applyRoutingRule(q queryer, rule *RoutingRule, contentID string, now time.Time) error {
+	for _, tag := range rule.Tags {
+		_, err := q.Exec(
+			"INSERT OR IGNORE INTO content_tags (user_id, content_id, tag, created_at) VALUES (?, ?, ?, ?)",
+			rule.UserID, contentID, tag, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add tag %q: %w", tag, err)
+		}
+	}
+
+	// Later rules override the priority and folder of earlier ones
+	if rule.Star || rule.Priority != nil || rule.Folder != "" {
+		var starredAt interface{}
+		if rule.Star {
+			starredAt = now
+		}
+		_, err := q.Exec(
+			`INSERT INTO content_states (user_id, content_id, starred_at, priority, folder)
+			VALUES (?, ?, ?, COALESCE(?, 0), ?)
+			ON CONFLICT (user_id, content_id) DO UPDATE SET
+				starred_at = COALESCE(content_states.starred_at, excluded.starred_at),
+				priority = COALESCE(?, content_states.priority),
+				folder = COALESCE(excluded.folder, content_states.folder)`,
+			rule.UserID, contentID, starredAt, rule.Priority, nullString(rule.Folder), rule.Priority,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update content state: %w", err)
+		}
+	}
+
+	_, err := q.Exec(
+		"UPDATE routing_rules SET match_count = match_count + 1, last_matched_at = ? WHERE id = ?",
+		now, rule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to count routing rule match: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+// routingFixture is a source with two subscribed readers and a user who is
+// not subscribed
+type routingFixture struct {
+	db           *SQLiteDB
+	source       *RSSSource
+	first        *User
+	second       *User
+	unsubscribed *User
+}
+
+func newRoutingFixture(t *testing.T) *routingFixture {
+	t.Helper()
+	db := newTestDB(t)
+	f := &routingFixture{
+		db:           db,
+		source:       newTestSource(t, db, "https://example.com/feed.xml"),
+		first:        newTestUser(t, db, "first", RoleReader),
+		second:       newTestUser(t, db, "second", RoleReader),
+		unsubscribed: newTestUser(t, db, "unsubscribed", RoleReader),
+	}
+	for _, user := range []*User{f.first, f.second} {
+		if _, err := db.CreateSubscription(user.ID, CreateSubscriptionInput{SourceID: f.source.ID}); err != nil {
+			t.Fatalf("CreateSubscription() error = %v", err)
+		}
+	}
+	return f
+}
+
+// rule creates a routing rule, failing the test on errors
+func (f *routingFixture) rule(t *testing.T, user *User, input RoutingRuleInput) *RoutingRule {
+	t.Helper()
+	rule, err := f.db.CreateRoutingRule(user.ID, input)
+	if err != nil {
+		t.Fatalf("CreateRoutingRule(%+v) error = %v", input, err)
+	}
+	return rule
+}
+
+// tags gets a user's tags of a content item
+func (f *routingFixture) tags(t *testing.T, user *User, content *RSSContent) []string {
+	t.Helper()
+	tags, err := f.db.GetContentTags(user.ID, content.ID)
+	if err != nil {
+		t.Fatalf("GetContentTags() error = %v", err)
+	}
+	return tags
+}
+
+// matches gets the match count of a rule
+func (f *routingFixture) matches(t *testing.T, rule *RoutingRule) int {
+	t.Helper()
+	got, err := f.db.GetRoutingRule(rule.UserID, rule.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetRoutingRule() = %v, error = %v", got, err)
+	}
+	return got.MatchCount
+}
+
+func TestRoutingRulesByPosition(t *testing.T) {
+	f := newRoutingFixture(t)
+	low, high := 1, 3
+	last, first := 5, 1
+
+	// Rules run by position, not by creation, so the rule at position 5
+	// overrides the folder and priority of the one at position 1, which
+	// still stars the item
+	f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Position: &last, Folder: "last", Priority: &low})
+	f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Position: &first, Folder: "first", Priority: &high, Star: true})
+	f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Tags: []string{"golang"}})
+	content, _ := ingest(t, f.db, f.source.ID, "https://example.com/go", "Go release")
+
+	state, err := f.db.GetContentState(f.first.ID, content.ID)
+	if err != nil {
+		t.Fatalf("GetContentState() error = %v", err)
+	}
+	want := &ContentState{Starred: true, Priority: low, Folder: "last"}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("state = %+v, want %+v", state, want)
+	}
+	if tags := f.tags(t, f.first, content); !reflect.DeepEqual(tags, []string{"golang"}) {
+		t.Errorf("tags = %v, want [golang]", tags)
+	}
+}
+
+func TestRoutingRulesStopProcessing(t *testing.T) {
+	f := newRoutingFixture(t)
+	stop := f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Tags: []string{"stopped"}, StopProcessing: true})
+	skipped := f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Tags: []string{"skipped"}})
+	other := f.rule(t, f.second, RoutingRuleInput{Pattern: "go", Tags: []string{"other"}})
+	notMatching := f.rule(t, f.first, RoutingRuleInput{Pattern: "rust", Tags: []string{"rust"}, StopProcessing: true})
+	content, _ := ingest(t, f.db, f.source.ID, "https://example.com/go", "Go release")
+
+	// Stopping skips only the same user's later rules
+	if tags := f.tags(t, f.first, content); !reflect.DeepEqual(tags, []string{"stopped"}) {
+		t.Errorf("first user's tags = %v, want [stopped]", tags)
+	}
+	if tags := f.tags(t, f.second, content); !reflect.DeepEqual(tags, []string{"other"}) {
+		t.Errorf("second user's tags = %v, want [other]", tags)
+	}
+	for rule, want := range map[*RoutingRule]int{stop: 1, skipped: 0, other: 1, notMatching: 0} {
+		if n := f.matches(t, rule); n != want {
+			t.Errorf("%s matched %d times, want %d", rule.Name, n, want)
+		}
+	}
+
+	// Rules that do not match do not stop processing
+	content, _ = ingest(t, f.db, f.source.ID, "https://example.com/rust", "Rust release")
+	if tags := f.tags(t, f.first, content); !reflect.DeepEqual(tags, []string{"rust"}) {
+		t.Errorf("first user's tags = %v, want [rust]", tags)
+	}
+}
+
+func TestRoutingRulesOnlySubscribers(t *testing.T) {
+	f := newRoutingFixture(t)
+	unsubscribed := f.rule(t, f.unsubscribed, RoutingRuleInput{SourceID: f.source.ID, Tags: []string{"feed"}})
+	subscribed := f.rule(t, f.first, RoutingRuleInput{SourceID: f.source.ID, Tags: []string{"feed"}})
+	content, _ := ingest(t, f.db, f.source.ID, "https://example.com/a", "Anything")
+
+	if tags := f.tags(t, f.unsubscribed, content); len(tags) != 0 {
+		t.Errorf("unsubscribed user's tags = %v, want none", tags)
+	}
+	if n := f.matches(t, unsubscribed); n != 0 {
+		t.Errorf("unsubscribed user's rule matched %d times, want 0", n)
+	}
+	if tags := f.tags(t, f.first, content); !reflect.DeepEqual(tags, []string{"feed"}) {
+		t.Errorf("subscribed user's tags = %v, want [feed]", tags)
+	}
+	if n := f.matches(t, subscribed); n != 1 {
+		t.Errorf("subscribed user's rule matched %d times, want 1", n)
+	}
+}
+
+func TestRoutingRulesKeepStateAndCountMatches(t *testing.T) {
+	f := newRoutingFixture(t)
+	rule := f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Folder: "golang"})
+	disabled := false
+	off := f.rule(t, f.first, RoutingRuleInput{Pattern: "go", Star: true, Enabled: &disabled})
+
+	for i, title := range []string{"Go release", "Go tooling", "Rust release"} {
+		content, _ := ingest(t, f.db, f.source.ID, "https://example.com/"+string(rune('a'+i)), title)
+		state, err := f.db.GetContentState(f.first.ID, content.ID)
+		if err != nil {
+			t.Fatalf("GetContentState() error = %v", err)
+		}
+		want := &ContentState{}
+		if i < 2 {
+			want.Folder = "golang"
+		}
+		if !reflect.DeepEqual(state, want) {
+			t.Errorf("%q state = %+v, want %+v", title, state, want)
+		}
+	}
+
+	got, err := f.db.GetRoutingRule(f.first.ID, rule.ID)
+	if err != nil {
+		t.Fatalf("GetRoutingRule() error = %v", err)
+	}
+	if got.MatchCount != 2 || got.LastMatchedAt == nil {
+		t.Errorf("match count = %d, last matched %v, want 2 matches", got.MatchCount, got.LastMatchedAt)
+	}
+	if n := f.matches(t, off); n != 0 {
+		t.Errorf("disabled rule matched %d times, want 0", n)
+	}
+}
@@ -36,41 +36,102 @@ type UpdateSourceInput struct {
 // BatchCreateSourcesInput represents the input for batch creating RSS sources
 type BatchCreateSourcesInput struct {
 	Sources []CreateSourceInput `json:"sources"`
+	// Atomic runs the whole batch in one transaction and rolls back on any error
+	Atomic bool `json:"atomic,omitempty"`
 }
 
 // BatchCreateSourcesResult represents the result of batch creating RSS sources
 type BatchCreateSourcesResult struct {
-	Sources []RSSSource  `json:"sources"`
-	Errors  []BatchError `json:"errors"`
+	Sources    []RSSSource  `json:"sources"`
+	Errors     []BatchError `json:"errors"`
+	RolledBack bool         `json:"rolledBack,omitempty"`
+}
+
+// BatchUpdateSourceInput represents a single source update in a batch
+type BatchUpdateSourceInput struct {
+	ID string `json:"id"`
+	UpdateSourceInput
+}
+
+// BatchUpdateSourcesInput represents the input for batch updating RSS sources
+type BatchUpdateSourcesInput struct {
+	Sources []BatchUpdateSourceInput `json:"sources"`
+	// Atomic runs the whole batch in one transaction and rolls back on any error
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// BatchUpdateSourcesResult represents the result of batch updating RSS sources
+type BatchUpdateSourcesResult struct {
+	Sources    []RSSSource  `json:"sources"`
+	Errors     []BatchError `json:"errors"`
+	RolledBack bool         `json:"rolledBack,omitempty"`
 }
 
 // BatchDeleteSourcesInput represents the input for batch deleting RSS sources
 type BatchDeleteSourcesInput struct {
 	SourceIDs []string `json:"sourceIds"`
+	// Atomic runs the whole batch in one transaction and rolls back on any error
+	Atomic bool `json:"atomic,omitempty"`
 }
 
 // BatchDeleteSourcesResult represents the result of batch deleting RSS sources
 type BatchDeleteSourcesResult struct {
 	DeletedCount int          `json:"deletedCount"`
 	Errors       []BatchError `json:"errors"`
+	RolledBack   bool         `json:"rolledBack,omitempty"`
 }
 
 // BatchError represents an error in a batch operation
 type BatchError struct {
 	Index     int    `json:"index,omitempty"`
 	SourceID  string `json:"sourceId,omitempty"`
+	ContentID string `json:"contentId,omitempty"`
 	ErrorType string `json:"errorType"`
 	Message   string `json:"message"`
 }
 
+// TrashedSourceError is returned when creating a source, or changing its
+// URL, with the URL of a source in the trash. The trashed source has to be
+// restored or purged first.
+type TrashedSourceError struct {
+	// SourceID is the ID of the trashed source
+	SourceID string
+}
+
+func (e *TrashedSourceError) Error() string {
+	return "a source with this URL is in the trash"
+}
+
+// checkTrashedURL returns a TrashedSourceError if a source in the trash has
+// the given URL
+func checkTrashedURL(q queryer, url string) error {
+	var id string
+	err := q.QueryRow("SELECT id FROM rss_sources WHERE url = ? AND deleted_at IS NOT NULL", url).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check trashed RSS sources: %w", err)
+	}
+	return &TrashedSourceError{SourceID: id}
+}
+
 // CreateSource creates a new RSS source
 func (s *SQLiteDB) CreateSource(input CreateSourceInput) (*RSSSource, error) {
+	return createSource(s.db, input)
+}
+
+// createSource inserts a new RSS source using the given queryer
+func createSource(q queryer, input CreateSourceInput) (*RSSSource, error) {
+	if err := checkTrashedURL(q, input.URL); err != nil {
+		return nil, err
+	}
+
 	// Generate a new UUID for the source
 	id := uuid.New().String()
 	now := time.Now().UTC()
 
 	// Insert the source into the database
-	_, err := s.db.Exec(
+	_, err := q.Exec(
 		`INSERT INTO rss_sources (id, name, url, description, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?)`,
 		id, input.Name, input.URL, input.Description, now, now,
@@ -92,12 +153,17 @@ func (s *SQLiteDB) CreateSource(input CreateSourceInput) (*RSSSource, error) {
 
 // GetSource retrieves an RSS source by ID
 func (s *SQLiteDB) GetSource(id string) (*RSSSource, error) {
+	return getSource(s.readDB, id)
+}
+
+// getSource retrieves an RSS source by ID using the given queryer
+func getSource(q queryer, id string) (*RSSSource, error) {
 	var source RSSSource
 	var lastFetchedAt sql.NullTime
 
-	err := s.db.QueryRow(
+	err := q.QueryRow(
 		`SELECT id, name, url, description, created_at, updated_at, last_fetched_at
-		FROM rss_sources WHERE id = ?`,
+		FROM rss_sources WHERE id = ? AND deleted_at IS NULL`,
 		id,
 	).Scan(
 		&source.ID,
@@ -124,18 +190,28 @@ func (s *SQLiteDB) GetSource(id string) (*RSSSource, error) {
 
 // UpdateSource updates an RSS source
 func (s *SQLiteDB) UpdateSource(id string, input UpdateSourceInput) (*RSSSource, error) {
+	return updateSource(s.db, id, input)
+}
+
+// updateSource updates an RSS source using the given queryer
+func updateSource(q queryer, id string, input UpdateSourceInput) (*RSSSource, error) {
 	// Check if the source exists
-	source, err := s.GetSource(id)
+	source, err := getSource(q, id)
 	if err != nil {
 		return nil, err
 	}
 	if source == nil {
 		return nil, nil // Source not found
 	}
+	if input.URL != source.URL {
+		if err := checkTrashedURL(q, input.URL); err != nil {
+			return nil, err
+		}
+	}
 
 	// Update the source
 	now := time.Now().UTC()
-	_, err = s.db.Exec(
+	_, err = q.Exec(
 		`UPDATE rss_sources
 		SET name = ?, url = ?, description = ?, updated_at = ?
 		WHERE id = ?`,
@@ -154,24 +230,59 @@ func (s *SQLiteDB) UpdateSource(id string, input UpdateSourceInput) (*RSSSource,
 	return source, nil
 }
 
-// DeleteSource deletes an RSS source
-func (s *SQLiteDB) DeleteSource(id string) error {
-	// Check if the source exists
-	source, err := s.GetSource(id)
+// DeleteSource moves an RSS source and its contents to the trash. It reports
+// whether the source existed.
+func (s *SQLiteDB) DeleteSource(id string) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if source == nil {
-		return nil // Source not found
+	defer tx.Rollback()
+
+	found, err := deleteSource(tx, id)
+	if err != nil || !found {
+		return false, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Delete the source
-	_, err = s.db.Exec("DELETE FROM rss_sources WHERE id = ?", id)
+	s.resetCorpus()
+	return true, nil
+}
+
+// deleteSource moves an RSS source and its contents to the trash using the
+// given queryer and reports whether a source with the given ID existed. The
+// contents share the source's deletion time so that restoring the source
+// only brings back the contents trashed along with it.
+func deleteSource(q queryer, id string) (bool, error) {
+	now := time.Now().UTC()
+
+	// Soft-delete the source
+	res, err := q.Exec(
+		"UPDATE rss_sources SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		now, id,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to delete RSS source: %w", err)
+		return false, fmt.Errorf("failed to delete RSS source: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil // Source not found
 	}
 
-	return nil
+	// Soft-delete the source's contents
+	_, err = q.Exec(
+		"UPDATE rss_contents SET deleted_at = ? WHERE source_id = ? AND deleted_at IS NULL",
+		now, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete RSS source contents: %w", err)
+	}
+
+	return true, nil
 }
 
 // ListSources lists RSS sources with pagination
@@ -185,12 +296,13 @@ func (s *SQLiteDB) ListSources(limit int, nextToken string) ([]RSSSource, string
 	query := `
 		SELECT id, name, url, description, created_at, updated_at, last_fetched_at
 		FROM rss_sources
+		WHERE deleted_at IS NULL
 	`
 	args := []interface{}{}
 
 	// Add pagination if nextToken is provided
 	if nextToken != "" {
-		query += " WHERE id > ?"
+		query += " AND id > ?"
 		args = append(args, nextToken)
 	}
 
@@ -199,7 +311,7 @@ func (s *SQLiteDB) ListSources(limit int, nextToken string) ([]RSSSource, string
 	args = append(args, limit+1) // Fetch one extra to determine if there are more results
 
 	// Execute the query
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to list RSS sources: %w", err)
 	}
@@ -248,49 +360,167 @@ func (s *SQLiteDB) ListSources(limit int, nextToken string) ([]RSSSource, string
 
 // BatchCreateSources creates multiple RSS sources
 func (s *SQLiteDB) BatchCreateSources(input BatchCreateSourcesInput) (*BatchCreateSourcesResult, error) {
+	if err := checkBatchSize(len(input.Sources)); err != nil {
+		return nil, err
+	}
+
+	sources := make([]*RSSSource, len(input.Sources))
 	result := &BatchCreateSourcesResult{
 		Sources: []RSSSource{},
 		Errors:  []BatchError{},
 	}
 
-	// Process each source
-	for i, sourceInput := range input.Sources {
-		source, err := s.CreateSource(sourceInput)
+	createOne := func(q queryer, i int) *BatchError {
+		source, err := createSource(q, input.Sources[i])
 		if err != nil {
-			result.Errors = append(result.Errors, BatchError{
+			return &BatchError{
 				Index:     i,
 				ErrorType: "CreateError",
 				Message:   err.Error(),
-			})
-		} else {
-			result.Sources = append(result.Sources, *source)
+			}
 		}
+		sources[i] = source
+		return nil
 	}
+	report := func(i int) {
+		result.Sources = append(result.Sources, *sources[i])
+	}
+
+	// Run the whole batch in one transaction if requested
+	if input.Atomic {
+		batchErr, err := s.runAtomicBatch(len(input.Sources), createOne, report)
+		if err != nil {
+			return nil, err
+		}
+		if batchErr != nil {
+			result.Errors = append(result.Errors, *batchErr)
+			result.RolledBack = true
+		}
+		return result, nil
+	}
+
+	// Each source is created in its own transaction
+	result.Errors = s.runBatchItems(len(input.Sources), createOne, report, func(i int, err error) BatchError {
+		return BatchError{Index: i, ErrorType: "CreateError", Message: err.Error()}
+	})
+
+	return result, nil
+}
+
+// BatchUpdateSources updates multiple RSS sources
+func (s *SQLiteDB) BatchUpdateSources(input BatchUpdateSourcesInput) (*BatchUpdateSourcesResult, error) {
+	if err := checkBatchSize(len(input.Sources)); err != nil {
+		return nil, err
+	}
+
+	sources := make([]*RSSSource, len(input.Sources))
+	result := &BatchUpdateSourcesResult{
+		Sources: []RSSSource{},
+		Errors:  []BatchError{},
+	}
+
+	updateOne := func(q queryer, i int) *BatchError {
+		item := input.Sources[i]
+		source, err := updateSource(q, item.ID, item.UpdateSourceInput)
+		if err != nil {
+			return &BatchError{
+				Index:     i,
+				SourceID:  item.ID,
+				ErrorType: "UpdateError",
+				Message:   err.Error(),
+			}
+		}
+		if source == nil {
+			return &BatchError{
+				Index:     i,
+				SourceID:  item.ID,
+				ErrorType: "NotFound",
+				Message:   "Source not found",
+			}
+		}
+		sources[i] = source
+		return nil
+	}
+	report := func(i int) {
+		result.Sources = append(result.Sources, *sources[i])
+	}
+
+	// Run the whole batch in one transaction if requested
+	if input.Atomic {
+		batchErr, err := s.runAtomicBatch(len(input.Sources), updateOne, report)
+		if err != nil {
+			return nil, err
+		}
+		if batchErr != nil {
+			result.Errors = append(result.Errors, *batchErr)
+			result.RolledBack = true
+		}
+		return result, nil
+	}
+
+	// Each source is updated in its own transaction
+	result.Errors = s.runBatchItems(len(input.Sources), updateOne, report, func(i int, err error) BatchError {
+		return BatchError{Index: i, SourceID: input.Sources[i].ID, ErrorType: "UpdateError", Message: err.Error()}
+	})
 
 	return result, nil
 }
 
 // BatchDeleteSources deletes multiple RSS sources
 func (s *SQLiteDB) BatchDeleteSources(input BatchDeleteSourcesInput) (*BatchDeleteSourcesResult, error) {
+	if err := checkBatchSize(len(input.SourceIDs)); err != nil {
+		return nil, err
+	}
+
 	result := &BatchDeleteSourcesResult{
 		DeletedCount: 0,
 		Errors:       []BatchError{},
 	}
 
-	// Process each source ID
-	for _, id := range input.SourceIDs {
-		err := s.DeleteSource(id)
+	deleteOne := func(q queryer, i int) *BatchError {
+		id := input.SourceIDs[i]
+		deleted, err := deleteSource(q, id)
 		if err != nil {
-			result.Errors = append(result.Errors, BatchError{
+			return &BatchError{
+				Index:     i,
 				SourceID:  id,
 				ErrorType: "DeleteError",
 				Message:   err.Error(),
-			})
-		} else {
-			result.DeletedCount++
+			}
+		}
+		if !deleted {
+			return &BatchError{
+				Index:     i,
+				SourceID:  id,
+				ErrorType: "NotFound",
+				Message:   "Source not found",
+			}
 		}
+		return nil
+	}
+	report := func(int) {
+		result.DeletedCount++
+		s.resetCorpus()
 	}
 
+	// Run the whole batch in one transaction if requested
+	if input.Atomic {
+		batchErr, err := s.runAtomicBatch(len(input.SourceIDs), deleteOne, report)
+		if err != nil {
+			return nil, err
+		}
+		if batchErr != nil {
+			result.Errors = append(result.Errors, *batchErr)
+			result.RolledBack = true
+		}
+		return result, nil
+	}
+
+	// Each source and its contents are trashed in their own transaction
+	result.Errors = s.runBatchItems(len(input.SourceIDs), deleteOne, report, func(i int, err error) BatchError {
+		return BatchError{Index: i, SourceID: input.SourceIDs[i], ErrorType: "DeleteError", Message: err.Error()}
+	})
+
 	return result, nil
 }
 
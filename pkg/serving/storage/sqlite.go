@@ -3,33 +3,101 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/flyer103/riffle/pkg/riffle"
 	_ "github.com/mattn/go-sqlite3"
 	"k8s.io/klog/v2"
 )
 
+// Options configures how the SQLite database is opened
+type Options struct {
+	// Path is the path to the SQLite database file
+	Path string
+	// JournalMode is the SQLite journal mode (WAL, DELETE, TRUNCATE, PERSIST, MEMORY or OFF)
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock before returning "database is locked"
+	BusyTimeout time.Duration
+	// ForeignKeys enables foreign key enforcement. Deletes remove dependent
+	// rows explicitly, so they do not rely on it.
+	ForeignKeys bool
+	// MaxReadConns is the size of the read-only connection pool
+	MaxReadConns int
+}
+
+// NewOptions creates Options for the given path with default values
+func NewOptions(path string) Options {
+	return Options{
+		Path:         path,
+		JournalMode:  "WAL",
+		BusyTimeout:  5 * time.Second,
+		ForeignKeys:  true,
+		MaxReadConns: 4,
+	}
+}
+
 // SQLiteDB represents a SQLite database connection
 type SQLiteDB struct {
+	// db is a single writer connection; SQLite only allows one writer at a time
 	db *sql.DB
+	// readDB is a pool of read-only connections used by queries
+	readDB *sql.DB
+	// scoring configures the scorers of recommendations, or is nil to
+	// score only interest matches
+	scoring *riffle.ScoringConfig
+	// corpusMu guards corpus, the frequencies of the terms of the stored
+	// content that recommendations are weighed against, which is loaded
+	// when first needed and then kept up to date
+	corpusMu sync.RWMutex
+	corpus   *riffle.Corpus
 }
 
-// NewSQLiteDB creates a new SQLite database connection
+// queryer is implemented by both *sql.DB and *sql.Tx, so helpers can run
+// either standalone or as part of a larger transaction
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLiteDB creates a new SQLite database connection with default options
 func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
+	return NewSQLiteDBWithOptions(NewOptions(dbPath))
+}
+
+// NewSQLiteDBWithOptions creates a new SQLite database connection. It opens a
+// dedicated single-connection writer and a pool of read-only connections so
+// that background writes do not block API reads.
+func NewSQLiteDBWithOptions(opts Options) (*SQLiteDB, error) {
+	dbPath := opts.Path
+	name, _, err := parsePath(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
 	// Ensure directory exists
-	dir := filepath.Dir(dbPath)
-	if dir != "." {
+	dir := filepath.Dir(name)
+	if dir != "." && !isMemoryPath(dbPath) {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create database directory: %w", err)
 		}
 	}
 
-	// Open database connection
-	db, err := sql.Open("sqlite3", dbPath)
+	// Open the writer connection
+	dsn, err := buildDSN(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(1)
 
 	// Test connection
 	if err := db.Ping(); err != nil {
@@ -43,12 +111,109 @@ func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	klog.InfoS("Connected to SQLite database", "path", dbPath)
-	return &SQLiteDB{db: db}, nil
+	// An in-memory database only exists within its connection, so reads
+	// have to share the writer
+	readDB := db
+	if !isMemoryPath(dbPath) {
+		dsn, err := buildDSN(opts, true)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		readDB, err = sql.Open("sqlite3", dsn)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open read-only database: %w", err)
+		}
+		readDB.SetMaxOpenConns(opts.MaxReadConns)
+
+		if err := readDB.Ping(); err != nil {
+			readDB.Close()
+			db.Close()
+			return nil, fmt.Errorf("failed to ping read-only database: %w", err)
+		}
+	}
+
+	klog.InfoS("Connected to SQLite database",
+		"path", dbPath,
+		"journalMode", opts.JournalMode,
+		"busyTimeout", opts.BusyTimeout,
+		"foreignKeys", opts.ForeignKeys,
+		"maxReadConns", opts.MaxReadConns)
+	return &SQLiteDB{db: db, readDB: readDB}, nil
+}
+
+// uriPathEscaper escapes the characters that would end the file name of a
+// "file:" URI
+var uriPathEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// buildDSN builds the go-sqlite3 connection string for the given options
+func buildDSN(opts Options, readOnly bool) (string, error) {
+	// Keep the parameters of a "file:" URI path; the options take precedence
+	name, params, err := parsePath(opts.Path)
+	if err != nil {
+		return "", err
+	}
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", opts.JournalMode)
+	}
+	params.Set("_busy_timeout", fmt.Sprintf("%d", opts.BusyTimeout.Milliseconds()))
+	if opts.ForeignKeys {
+		params.Set("_foreign_keys", "1")
+	} else {
+		params.Set("_foreign_keys", "0")
+	}
+
+	if readOnly {
+		params.Set("mode", "ro")
+		params.Set("_query_only", "1")
+	} else {
+		// Take the write lock when a transaction starts instead of upgrading
+		// later, which avoids deadlocks between concurrent transactions
+		params.Set("_txlock", "immediate")
+	}
+
+	return "file:" + uriPathEscaper.Replace(name) + "?" + params.Encode(), nil
+}
+
+// parsePath splits a database path into the file name and its URI
+// parameters. Paths starting with "file:" are URIs whose name is
+// percent-encoded; other paths are file names as they are.
+func parsePath(path string) (string, url.Values, error) {
+	uri, ok := strings.CutPrefix(path, "file:")
+	if !ok {
+		return path, url.Values{}, nil
+	}
+
+	uri, _, _ = strings.Cut(uri, "#")
+	name, query, _ := strings.Cut(uri, "?")
+	name, err := url.PathUnescape(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid database path %q: %w", path, err)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid database path %q: %w", path, err)
+	}
+	return name, params, nil
+}
+
+// isMemoryPath reports whether the path refers to an in-memory database
+func isMemoryPath(path string) bool {
+	name, params, err := parsePath(path)
+	if err != nil {
+		return false
+	}
+	return name == ":memory:" || params.Get("mode") == "memory"
 }
 
-// Close closes the database connection
+// Close closes the database connections
 func (s *SQLiteDB) Close() error {
+	if s.readDB != nil && s.readDB != s.db {
+		if err := s.readDB.Close(); err != nil {
+			return err
+		}
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -66,7 +231,8 @@ func initializeDatabase(db *sql.DB) error {
 			description TEXT,
 			created_at TIMESTAMP NOT NULL,
 			updated_at TIMESTAMP NOT NULL,
-			last_fetched_at TIMESTAMP
+			last_fetched_at TIMESTAMP,
+			deleted_at TIMESTAMP
 		)
 	`)
 	if err != nil {
@@ -86,6 +252,13 @@ func initializeDatabase(db *sql.DB) error {
 			fetched_at TIMESTAMP NOT NULL,
 			updated_at TIMESTAMP,
 			author TEXT,
+			deleted_at TIMESTAMP,
+			hidden_at TIMESTAMP,
+			canonical_link TEXT,
+			simhash INTEGER,
+			story_id TEXT,
+			language TEXT,
+			search_terms TEXT,
 			FOREIGN KEY (source_id) REFERENCES rss_sources(id) ON DELETE CASCADE
 		)
 	`)
@@ -93,6 +266,77 @@ func initializeDatabase(db *sql.DB) error {
 		return fmt.Errorf("failed to create rss_contents table: %w", err)
 	}
 
+	// Content fetched before languages were detected has to be indexed
+	contentsWithoutLanguage, err := tableExistsWithoutColumn(db, "rss_contents", "language")
+	if err != nil {
+		return err
+	}
+
+	// Add columns introduced after the tables were first created
+	migrations := []struct {
+		table, column, definition string
+	}{
+		{"rss_sources", "deleted_at", "TIMESTAMP"},
+		{"rss_contents", "deleted_at", "TIMESTAMP"},
+		{"rss_contents", "hidden_at", "TIMESTAMP"},
+		{"rss_contents", "canonical_link", "TEXT"},
+		{"rss_contents", "simhash", "INTEGER"},
+		{"rss_contents", "story_id", "TEXT"},
+		{"rss_contents", "language", "TEXT"},
+		{"rss_contents", "search_terms", "TEXT"},
+	}
+	for _, m := range migrations {
+		if err := addColumnIfNotExists(db, m.table, m.column, m.definition); err != nil {
+			return err
+		}
+	}
+	if contentsWithoutLanguage {
+		if err := indexContents(db); err != nil {
+			return err
+		}
+	}
+
+	// Create index used to deduplicate content by link
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_rss_contents_link ON rss_contents(link)")
+	if err != nil {
+		return fmt.Errorf("failed to create rss_contents link index: %w", err)
+	}
+
+	// Create index used to deduplicate content by canonical link
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_rss_contents_canonical_link ON rss_contents(canonical_link)")
+	if err != nil {
+		return fmt.Errorf("failed to create rss_contents canonical link index: %w", err)
+	}
+
+	// Create indexes used to find near-duplicates and list stories
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_rss_contents_simhash ON rss_contents(published_at) WHERE simhash IS NOT NULL")
+	if err != nil {
+		return fmt.Errorf("failed to create rss_contents simhash index: %w", err)
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_rss_contents_story ON rss_contents(story_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create rss_contents story index: %w", err)
+	}
+
+	// Create index used to filter content by language
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_rss_contents_language ON rss_contents(language)")
+	if err != nil {
+		return fmt.Errorf("failed to create rss_contents language index: %w", err)
+	}
+
+	// Create content tombstones table, which remembers the links of purged
+	// content so that it is not ingested again
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS content_tombstones (
+			link TEXT PRIMARY KEY,
+			source_id TEXT,
+			deleted_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create content_tombstones table: %w", err)
+	}
+
 	// Create content categories table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS content_categories (
@@ -106,6 +350,26 @@ func initializeDatabase(db *sql.DB) error {
 		return fmt.Errorf("failed to create content_categories table: %w", err)
 	}
 
+	// Create content revisions table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS content_revisions (
+			id TEXT PRIMARY KEY,
+			content_id TEXT NOT NULL,
+			revision INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT,
+			content TEXT,
+			origin TEXT NOT NULL,
+			revert_of INTEGER,
+			created_at TIMESTAMP NOT NULL,
+			UNIQUE (content_id, revision),
+			FOREIGN KEY (content_id) REFERENCES rss_contents(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create content_revisions table: %w", err)
+	}
+
 	// Create fetch jobs table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS fetch_jobs (
@@ -136,15 +400,443 @@ func initializeDatabase(db *sql.DB) error {
 		return fmt.Errorf("failed to create job_errors table: %w", err)
 	}
 
+	// Check whether the users table predates roles before creating it
+	usersWithoutRole, err := tableExistsWithoutColumn(db, "users", "role")
+	if err != nil {
+		return err
+	}
+
+	// Create users table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			username TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'reader',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create users table: %w", err)
+	}
+
+	// Before roles existed every user could manage everything. Keep the
+	// oldest user in charge so that an upgraded installation has an admin.
+	if usersWithoutRole {
+		if err := addColumnIfNotExists(db, "users", "role", "TEXT NOT NULL DEFAULT 'reader'"); err != nil {
+			return err
+		}
+		_, err = db.Exec(
+			"UPDATE users SET role = ? WHERE id = (SELECT id FROM users ORDER BY created_at ASC LIMIT 1)",
+			RoleAdmin,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to promote the first user to admin: %w", err)
+		}
+	}
+
+	// Create user identities table, which links users to accounts at an
+	// external identity provider
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_identities (
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (issuer, subject),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create user_identities table: %w", err)
+	}
+
+	// Create API tokens table; only a hash of each token is stored
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create api_tokens table: %w", err)
+	}
+
+	// Create sessions table; only a hash of each session token is stored
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create sessions table: %w", err)
+	}
+
+	// Create feed tokens table, which holds each user's secret for the
+	// published feeds; only a hash of each token is stored
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS feed_tokens (
+			user_id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create feed_tokens table: %w", err)
+	}
+
+	// Create saved searches table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS saved_searches (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			keywords TEXT NOT NULL,
+			source_id TEXT,
+			tags TEXT,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create saved_searches table: %w", err)
+	}
+
+	// Create digest subscriptions table, which holds each user's email
+	// digest schedule
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS digest_subscriptions (
+			user_id TEXT PRIMARY KEY,
+			email TEXT NOT NULL,
+			frequency TEXT NOT NULL,
+			send_time TEXT NOT NULL,
+			timezone TEXT NOT NULL,
+			weekday INTEGER NOT NULL DEFAULT 1,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			unsubscribe_token TEXT NOT NULL UNIQUE,
+			confirm_token TEXT,
+			confirmed_at TIMESTAMP,
+			confirm_sent_at TIMESTAMP,
+			last_sent_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create digest_subscriptions table: %w", err)
+	}
+
+	// Addresses set before they had to be confirmed stay unconfirmed, so
+	// that nothing is sent to them until their owner clicks a link
+	digestMigrations := []struct {
+		column, definition string
+	}{
+		{"confirm_token", "TEXT"},
+		{"confirmed_at", "TIMESTAMP"},
+		{"confirm_sent_at", "TIMESTAMP"},
+	}
+	for _, m := range digestMigrations {
+		if err := addColumnIfNotExists(db, "digest_subscriptions", m.column, m.definition); err != nil {
+			return err
+		}
+	}
+
+	// Create digests table, which records the digests sent to each user
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS digests (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			email TEXT NOT NULL,
+			frequency TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			item_count INTEGER NOT NULL,
+			sent_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create digests table: %w", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_digests_user ON digests(user_id, sent_at)")
+	if err != nil {
+		return fmt.Errorf("failed to create digests user index: %w", err)
+	}
+
+	// Create digest items table, which records the content items sent in
+	// digests so that no item is sent to a user twice
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS digest_items (
+			user_id TEXT NOT NULL,
+			content_id TEXT NOT NULL,
+			digest_id TEXT NOT NULL,
+			PRIMARY KEY (user_id, content_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (content_id) REFERENCES rss_contents(id) ON DELETE CASCADE,
+			FOREIGN KEY (digest_id) REFERENCES digests(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create digest_items table: %w", err)
+	}
+
+	// Create webhooks table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL,
+			source_ids TEXT,
+			query TEXT NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create webhooks table: %w", err)
+	}
+
+	// Create webhook deliveries table, which is both the retry queue and
+	// the delivery log
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER,
+			error TEXT,
+			created_at TIMESTAMP NOT NULL,
+			next_attempt_at TIMESTAMP,
+			last_attempt_at TIMESTAMP,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook_deliveries table: %w", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at)")
+	if err != nil {
+		return fmt.Errorf("failed to create webhook deliveries webhook index: %w", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)")
+	if err != nil {
+		return fmt.Errorf("failed to create webhook deliveries due index: %w", err)
+	}
+
+	// Create filter rules table
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS filter_rules (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			source_id TEXT,
+			field TEXT NOT NULL,
+			match_type TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			invert BOOLEAN NOT NULL DEFAULT 0,
+			action TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (source_id) REFERENCES rss_sources(id) ON DELETE CASCADE,
+			FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create filter_rules table: %w", err)
+	}
+
+	// Create subscriptions table, which links users to the shared sources
+	subscriptionColumns, err := tableColumns(db, "subscriptions")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			source_id TEXT NOT NULL,
+			display_name TEXT,
+			folder TEXT,
+			notify_new_content BOOLEAN NOT NULL DEFAULT 0,
+			notify_fetch_errors BOOLEAN NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			UNIQUE (user_id, source_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (source_id) REFERENCES rss_sources(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create subscriptions table: %w", err)
+	}
+
+	// Before subscriptions existed every user saw every source, so subscribe
+	// existing users to all existing sources to keep what they see unchanged
+	if len(subscriptionColumns) == 0 {
+		now := time.Now().UTC()
+		_, err = db.Exec(`
+			INSERT INTO subscriptions (id, user_id, source_id, created_at, updated_at)
+			SELECT lower(hex(randomblob(16))), u.id, s.id, ?, ?
+			FROM users u CROSS JOIN rss_sources s
+			WHERE s.deleted_at IS NULL
+		`, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe existing users to existing sources: %w", err)
+		}
+	}
+
+	// Create content states table, which holds each user's reading state.
+	// Content without a row is unread.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS content_states (
+			user_id TEXT NOT NULL,
+			content_id TEXT NOT NULL,
+			read_at TIMESTAMP,
+			starred_at TIMESTAMP,
+			read_later_at TIMESTAMP,
+			priority INTEGER NOT NULL DEFAULT 0,
+			folder TEXT,
+			PRIMARY KEY (user_id, content_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (content_id) REFERENCES rss_contents(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create content_states table: %w", err)
+	}
+	if err := addColumnIfNotExists(db, "content_states", "priority", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(db, "content_states", "folder", "TEXT"); err != nil {
+		return err
+	}
+
+	// Create routing rules table, which holds the rules that organize each
+	// user's new content
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS routing_rules (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			source_id TEXT,
+			field TEXT NOT NULL,
+			match_type TEXT NOT NULL,
+			pattern TEXT NOT NULL DEFAULT '',
+			tags TEXT,
+			star BOOLEAN NOT NULL DEFAULT 0,
+			priority INTEGER,
+			folder TEXT,
+			stop_processing BOOLEAN NOT NULL DEFAULT 0,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			match_count INTEGER NOT NULL DEFAULT 0,
+			last_matched_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (source_id) REFERENCES rss_sources(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create routing_rules table: %w", err)
+	}
+
+	// Create user interests table, which holds each user's interest profile
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_interests (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			terms TEXT NOT NULL,
+			weight REAL NOT NULL,
+			synonyms TEXT,
+			pattern TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create user_interests table: %w", err)
+	}
+
+	// Create index used to load a user's interests
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_user_interests_user ON user_interests(user_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create user_interests user index: %w", err)
+	}
+
+	// Create content tags table, which holds each user's personal tags.
+	// These are separate from the categories published in the feed.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS content_tags (
+			user_id TEXT NOT NULL,
+			content_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, content_id, tag),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (content_id) REFERENCES rss_contents(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create content_tags table: %w", err)
+	}
+
+	// Create index used to filter content by tag
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_content_tags_tag ON content_tags(user_id, tag)")
+	if err != nil {
+		return fmt.Errorf("failed to create content_tags tag index: %w", err)
+	}
+
+	// Older versions created the recommendation feedback table with columns
+	// that did not match the queries, so no feedback could ever be stored in
+	// it. Recreate it with the current schema.
+	legacy, err := tableExistsWithoutColumn(db, "recommendation_feedback", "rating")
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if _, err := db.Exec("DROP TABLE recommendation_feedback"); err != nil {
+			return fmt.Errorf("failed to drop legacy recommendation_feedback table: %w", err)
+		}
+		klog.InfoS("Migrated database schema", "table", "recommendation_feedback")
+	}
+
 	// Create recommendation feedback table
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS recommendation_feedback (
 			id TEXT PRIMARY KEY,
 			content_id TEXT NOT NULL,
-			feedback_type TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			rating INTEGER NOT NULL,
 			comment TEXT,
-			created_at TIMESTAMP NOT NULL,
-			FOREIGN KEY (content_id) REFERENCES rss_contents(id) ON DELETE CASCADE
+			timestamp TIMESTAMP NOT NULL,
+			FOREIGN KEY (content_id) REFERENCES rss_contents(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 		)
 	`)
 	if err != nil {
@@ -153,3 +845,57 @@ func initializeDatabase(db *sql.DB) error {
 
 	return nil
 }
+
+// addColumnIfNotExists adds a column to a table created by an older version.
+// CREATE TABLE IF NOT EXISTS leaves existing tables untouched, so columns
+// added later have to be migrated explicitly.
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	if columns[column] {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
+	klog.InfoS("Migrated database schema", "table", table, "column", column)
+	return nil
+}
+
+// tableExistsWithoutColumn reports whether a table exists but lacks the given column
+func tableExistsWithoutColumn(db *sql.DB, table, column string) (bool, error) {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return false, err
+	}
+	return len(columns) > 0 && !columns[column], nil
+}
+
+// tableColumns returns the set of column names of a table, which is empty if
+// the table does not exist
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over columns of %s: %w", table, err)
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDB opens a database in a temporary directory that is closed when the
+// test ends
+func newTestDB(t *testing.T) *SQLiteDB {
+	t.Helper()
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "riffle.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestSource creates a source with the given feed URL
+func newTestSource(t *testing.T, db *SQLiteDB, url string) *RSSSource {
+	t.Helper()
+	source, err := db.CreateSource(CreateSourceInput{Name: url, URL: url})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+	return source
+}
+
+// newTestContent creates a content item of a source, as fetched from its feed
+func newTestContent(t *testing.T, db *SQLiteDB, sourceID, link, title string) *RSSContent {
+	t.Helper()
+	content := &RSSContent{
+		SourceID:    sourceID,
+		Title:       title,
+		Link:        link,
+		Description: title + " description",
+		PublishedAt: time.Now().UTC(),
+	}
+	if err := db.CreateContent(content); err != nil {
+		t.Fatalf("CreateContent() error = %v", err)
+	}
+	return content
+}
+
+// mustBuildDSN builds a connection string, failing the test on errors
+func mustBuildDSN(t *testing.T, opts Options, readOnly bool) string {
+	t.Helper()
+	dsn, err := buildDSN(opts, readOnly)
+	if err != nil {
+		t.Fatalf("buildDSN(%q) error = %v", opts.Path, err)
+	}
+	return dsn
+}
+
+func TestBuildDSN(t *testing.T) {
+	opts := NewOptions("/data/riffle.db")
+
+	writer := mustBuildDSN(t, opts, false)
+	if !strings.HasPrefix(writer, "file:/data/riffle.db?") {
+		t.Errorf("writer DSN %q does not start with the path", writer)
+	}
+	for _, want := range []string{"_journal_mode=WAL", "_busy_timeout=5000", "_foreign_keys=1", "_txlock=immediate"} {
+		if !strings.Contains(writer, want) {
+			t.Errorf("writer DSN %q does not contain %q", writer, want)
+		}
+	}
+	if strings.Contains(writer, "mode=ro") {
+		t.Errorf("writer DSN %q is read-only", writer)
+	}
+
+	reader := mustBuildDSN(t, opts, true)
+	for _, want := range []string{"mode=ro", "_query_only=1"} {
+		if !strings.Contains(reader, want) {
+			t.Errorf("reader DSN %q does not contain %q", reader, want)
+		}
+	}
+
+	opts.ForeignKeys = false
+	if dsn := mustBuildDSN(t, opts, false); !strings.Contains(dsn, "_foreign_keys=0") {
+		t.Errorf("DSN without foreign keys %q does not disable them", dsn)
+	}
+}
+
+func TestBuildDSNPaths(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		params map[string]string
+		memory bool
+	}{
+		{"/data/riffle.db", "file:/data/riffle.db?", nil, false},
+		{"/data/what?.db", "file:/data/what%3f.db?", nil, false},
+		{"/data/#1/100%.db", "file:/data/%231/100%25.db?", nil, false},
+		{"file:/data/riffle.db", "file:/data/riffle.db?", nil, false},
+		{"file:/data/my%20feeds.db?cache=shared", "file:/data/my feeds.db?", map[string]string{"cache": "shared"}, false},
+		{"file:/data/riffle.db?_busy_timeout=1", "file:/data/riffle.db?", map[string]string{"_busy_timeout": "5000"}, false},
+		{":memory:", "file::memory:?", nil, true},
+		{"file::memory:?mode=memory&cache=shared", "file::memory:?", map[string]string{"mode": "memory", "cache": "shared"}, true},
+		{"file:test.db?mode=memory", "file:test.db?", map[string]string{"mode": "memory"}, true},
+	}
+	for _, tt := range tests {
+		dsn := mustBuildDSN(t, NewOptions(tt.path), false)
+		if !strings.HasPrefix(dsn, tt.prefix) || strings.Count(dsn, "?") != 1 {
+			t.Errorf("buildDSN(%q) = %q, want a single query after %q", tt.path, dsn, tt.prefix)
+			continue
+		}
+		_, query, _ := strings.Cut(dsn, "?")
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			t.Errorf("buildDSN(%q) = %q has an invalid query: %v", tt.path, dsn, err)
+			continue
+		}
+		for key, want := range tt.params {
+			if got := params.Get(key); got != want {
+				t.Errorf("buildDSN(%q) has %s=%q, want %q", tt.path, key, got, want)
+			}
+		}
+		if isMemoryPath(tt.path) != tt.memory {
+			t.Errorf("isMemoryPath(%q) = %v, want %v", tt.path, !tt.memory, tt.memory)
+		}
+	}
+
+	if _, err := buildDSN(NewOptions("file:/data/%zz.db"), false); err == nil {
+		t.Error("buildDSN() of an invalid URI succeeded")
+	}
+}
+
+func TestOpenEscapedPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds?#1", "riffle?.db")
+	db, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	newTestSource(t, db, "https://example.com/feed.xml")
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database file at %s: %v", path, err)
+	}
+}
+
+func TestSharedMemoryDatabase(t *testing.T) {
+	db, err := NewSQLiteDB("file::memory:?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if db.readDB != db.db {
+		t.Fatal("an in-memory database has a separate read pool")
+	}
+	newTestSource(t, db, "https://example.com/feed.xml")
+	if sources, _, err := db.ListSources(10, ""); err != nil || len(sources) != 1 {
+		t.Errorf("ListSources() = %v, %v, want the created source", sources, err)
+	}
+}
+
+func TestReadsDoNotWaitForWriter(t *testing.T) {
+	db := newTestDB(t)
+	newTestSource(t, db, "https://example.com/committed.xml")
+
+	// Hold the write lock with an uncommitted source
+	tx, err := db.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := createSource(tx, CreateSourceInput{Name: "Pending", URL: "https://example.com/pending.xml"}); err != nil {
+		t.Fatalf("createSource() error = %v", err)
+	}
+
+	done := make(chan []RSSSource, 1)
+	go func() {
+		sources, _, err := db.ListSources(10, "")
+		if err != nil {
+			t.Errorf("ListSources() error = %v", err)
+		}
+		done <- sources
+	}()
+
+	select {
+	case sources := <-done:
+		if len(sources) != 1 || sources[0].URL != "https://example.com/committed.xml" {
+			t.Errorf("ListSources() = %+v, want only the committed source", sources)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ListSources() waited for the write transaction")
+	}
+}
+
+func TestReadPoolIsReadOnly(t *testing.T) {
+	db := newTestDB(t)
+	if db.readDB == db.db {
+		t.Fatal("the read pool shares the writer connection")
+	}
+
+	_, err := db.readDB.Exec(
+		"INSERT INTO rss_sources (id, name, url, created_at, updated_at) VALUES ('id', 'name', 'url', ?, ?)",
+		time.Now(), time.Now(),
+	)
+	if err == nil {
+		t.Error("writing through the read pool succeeded")
+	}
+}
+
+func TestMemoryDatabaseSharesWriter(t *testing.T) {
+	db, err := NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if db.readDB != db.db {
+		t.Fatal("an in-memory database has a separate read pool")
+	}
+	newTestSource(t, db, "https://example.com/feed.xml")
+	if sources, _, err := db.ListSources(10, ""); err != nil || len(sources) != 1 {
+		t.Errorf("ListSources() = %v, %v, want the created source", sources, err)
+	}
+}
+
+// newTestUser creates a user with the given role
+func newTestUser(t *testing.T, db *SQLiteDB, username, role string) *User {
+	t.Helper()
+	user, err := db.CreateUser(CreateUserInput{Username: username, Password: "password123", Role: role})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	return user
+}
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentState represents a user's reading state for a content item
+type ContentState struct {
+	Read      bool `json:"read"`
+	Starred   bool `json:"starred"`
+	ReadLater bool `json:"readLater"`
+	// Priority orders items that matter more; items are 0 unless a routing
+	// rule or the user sets it
+	Priority int `json:"priority,omitempty"`
+	// Folder moves the item out of its subscription's folder into another one
+	Folder string `json:"folder,omitempty"`
+}
+
+// UpdateContentStateInput represents a partial update of a user's reading
+// state for a content item. Fields left nil are not changed.
+type UpdateContentStateInput struct {
+	Read      *bool `json:"read,omitempty"`
+	Starred   *bool `json:"starred,omitempty"`
+	ReadLater *bool `json:"readLater,omitempty"`
+	Priority  *int  `json:"priority,omitempty"`
+	// Folder moves the item into a folder; an empty folder moves it back to
+	// its subscription's folder
+	Folder *string `json:"folder,omitempty"`
+}
+
+// maxFolderLength is the maximum length of a folder name in characters
+const maxFolderLength = 100
+
+// ErrInvalidContentState is returned for state updates with an invalid folder
+var ErrInvalidContentState = errors.New("invalid content state")
+
+// MarkReadInput selects the unread content items of a user's subscriptions to mark as read
+type MarkReadInput struct {
+	// SourceID limits marking to one source
+	SourceID string `json:"sourceId,omitempty"`
+	// Folder limits marking to the items in a folder
+	Folder string `json:"folder,omitempty"`
+	// Before limits marking to items published at or before this time
+	Before *time.Time `json:"before,omitempty"`
+	// ContentIDs limits marking to the given content items
+	ContentIDs []string `json:"contentIds,omitempty"`
+}
+
+// SourceUnreadCount is the number of unread content items of a subscribed source
+type SourceUnreadCount struct {
+	SourceID string `json:"sourceId"`
+	Folder   string `json:"folder,omitempty"`
+	Unread   int    `json:"unread"`
+}
+
+// FolderUnreadCount is the number of unread content items in a folder
+type FolderUnreadCount struct {
+	Folder string `json:"folder"`
+	Unread int    `json:"unread"`
+}
+
+// UnreadCounts represents the unread content counts of a user's subscriptions
+type UnreadCounts struct {
+	Total   int                 `json:"total"`
+	Sources []SourceUnreadCount `json:"sources"`
+	Folders []FolderUnreadCount `json:"folders"`
+}
+
+// stateColumns selects a user's reading state of content c from the joined
+// content_states table st
+const stateColumns = "st.read_at IS NOT NULL, st.starred_at IS NOT NULL, st.read_later_at IS NOT NULL, " +
+	"COALESCE(st.priority, 0), COALESCE(st.folder, '')"
+
+// stateDest returns the scan destinations of stateColumns
+func stateDest(state *ContentState) []interface{} {
+	return []interface{}{&state.Read, &state.Starred, &state.ReadLater, &state.Priority, &state.Folder}
+}
+
+// stateJoin joins a user's reading state onto content c. It takes the user ID as its only argument.
+const stateJoin = " LEFT JOIN content_states st ON st.content_id = c.id AND st.user_id = ?"
+
+// stateCondition returns a SQL condition on the joined content_states column
+// that matches a tri-state filter
+func stateCondition(column string, want bool) string {
+	if want {
+		return " AND st." + column + " IS NOT NULL"
+	}
+	return " AND st." + column + " IS NULL"
+}
+
+// folderCondition returns a SQL condition that matches content c in one of a
+// user's folders, together with its arguments. Items are in the folder of
+// their subscription unless they were moved into another one, so it needs the
+// joined content_states table st.
+func folderCondition(userID, folder string) (string, []interface{}) {
+	return " AND (st.folder = ? OR (COALESCE(st.folder, '') = '' AND c.source_id IN (" + subscribedSourcesQuery + " AND folder = ?)))",
+		[]interface{}{folder, userID, folder}
+}
+
+// GetContentState retrieves a user's reading state for a content item
+func (s *SQLiteDB) GetContentState(userID, contentID string) (*ContentState, error) {
+	var state ContentState
+	err := s.readDB.QueryRow(
+		`SELECT `+stateColumns+`
+		FROM rss_contents c`+stateJoin+`
+		WHERE c.id = ?`,
+		userID, contentID,
+	).Scan(stateDest(&state)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content state: %w", err)
+	}
+	return &state, nil
+}
+
+// UpdateContentState updates a user's reading state for a content item. It
+// returns nil if the content item does not exist.
+func (s *SQLiteDB) UpdateContentState(userID, contentID string, input UpdateContentStateInput) (*ContentState, error) {
+	if input.Folder != nil {
+		folder := strings.TrimSpace(*input.Folder)
+		if utf8.RuneCountInString(folder) > maxFolderLength {
+			return nil, fmt.Errorf("%w: folder is longer than %d characters", ErrInvalidContentState, maxFolderLength)
+		}
+		input.Folder = &folder
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Check if the content exists
+	content, err := getContent(tx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, nil // Content not found
+	}
+
+	// Make sure there is a state row to update
+	_, err = tx.Exec(
+		"INSERT OR IGNORE INTO content_states (user_id, content_id) VALUES (?, ?)",
+		userID, contentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content state: %w", err)
+	}
+
+	// Set or clear each timestamp that was given, keeping the original time
+	// if it was already set
+	now := time.Now().UTC()
+	for column, value := range map[string]*bool{
+		"read_at":       input.Read,
+		"starred_at":    input.Starred,
+		"read_later_at": input.ReadLater,
+	} {
+		if value == nil {
+			continue
+		}
+		var arg interface{}
+		if *value {
+			arg = now
+		}
+		_, err = tx.Exec(
+			fmt.Sprintf("UPDATE content_states SET %[1]s = CASE WHEN ? IS NULL THEN NULL ELSE COALESCE(%[1]s, ?) END WHERE user_id = ? AND content_id = ?", column),
+			arg, arg, userID, contentID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update content state: %w", err)
+		}
+	}
+
+	// Set the priority and folder if they were given
+	if input.Priority != nil {
+		_, err = tx.Exec(
+			"UPDATE content_states SET priority = ? WHERE user_id = ? AND content_id = ?",
+			*input.Priority, userID, contentID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update content state: %w", err)
+		}
+	}
+	if input.Folder != nil {
+		_, err = tx.Exec(
+			"UPDATE content_states SET folder = ? WHERE user_id = ? AND content_id = ?",
+			nullString(*input.Folder), userID, contentID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update content state: %w", err)
+		}
+	}
+
+	// Read back the resulting state
+	var state ContentState
+	err = tx.QueryRow(
+		`SELECT `+stateColumns+`
+		FROM content_states st WHERE st.user_id = ? AND st.content_id = ?`,
+		userID, contentID,
+	).Scan(stateDest(&state)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content state: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &state, nil
+}
+
+// MarkContentsRead marks the unread content items of a user's subscriptions
+// that match the input as read and returns how many were marked
+func (s *SQLiteDB) MarkContentsRead(userID string, input MarkReadInput) (int, error) {
+	if err := checkBatchSize(len(input.ContentIDs)); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO content_states (user_id, content_id, read_at)
+		SELECT ?, c.id, ?
+		FROM rss_contents c` + stateJoin + `
+		WHERE c.deleted_at IS NULL
+	`
+	args := []interface{}{userID, now, userID}
+
+	// Only mark content from subscribed sources
+	query += " AND c.source_id IN (" + subscribedSourcesQuery + ")"
+	args = append(args, userID)
+	if input.Folder != "" {
+		condition, folderArgs := folderCondition(userID, input.Folder)
+		query += condition
+		args = append(args, folderArgs...)
+	}
+
+	// Add filters
+	if input.SourceID != "" {
+		query += " AND c.source_id = ?"
+		args = append(args, input.SourceID)
+	}
+	if input.Before != nil {
+		query += " AND c.published_at <= ?"
+		args = append(args, input.Before.UTC())
+	}
+	if len(input.ContentIDs) > 0 {
+		query += " AND c.id IN (" + createPlaceholders(len(input.ContentIDs)) + ")"
+		for _, id := range input.ContentIDs {
+			args = append(args, id)
+		}
+	}
+
+	// Items that are already read keep their original read time
+	query += `
+		ON CONFLICT (user_id, content_id) DO UPDATE SET read_at = excluded.read_at
+		WHERE content_states.read_at IS NULL
+	`
+
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark contents as read: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// GetUnreadCounts counts the unread content items of a user's subscriptions
+// per source and per folder
+func (s *SQLiteDB) GetUnreadCounts(userID string) (*UnreadCounts, error) {
+	rows, err := s.readDB.Query(
+		`SELECT sub.source_id, COALESCE(sub.folder, ''), COUNT(c.id)
+		FROM subscriptions sub
+		JOIN rss_sources src ON src.id = sub.source_id AND src.deleted_at IS NULL
+		LEFT JOIN rss_contents c ON c.source_id = sub.source_id AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM content_states st
+				WHERE st.content_id = c.id AND st.user_id = sub.user_id AND st.read_at IS NOT NULL
+			)
+		WHERE sub.user_id = ?
+		GROUP BY sub.source_id, sub.folder
+		ORDER BY sub.folder, sub.source_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread contents: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	counts := &UnreadCounts{
+		Sources: []SourceUnreadCount{},
+		Folders: []FolderUnreadCount{},
+	}
+	for rows.Next() {
+		var count SourceUnreadCount
+		if err := rows.Scan(&count.SourceID, &count.Folder, &count.Unread); err != nil {
+			return nil, fmt.Errorf("failed to scan unread count: %w", err)
+		}
+		counts.Sources = append(counts.Sources, count)
+		counts.Total += count.Unread
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over unread counts: %w", err)
+	}
+
+	// Count the folders separately, as items moved into another folder count
+	// there instead of in their subscription's folder
+	folderRows, err := s.readDB.Query(
+		`SELECT COALESCE(NULLIF(st.folder, ''), sub.folder) AS item_folder, COUNT(c.id)
+		FROM subscriptions sub
+		JOIN rss_sources src ON src.id = sub.source_id AND src.deleted_at IS NULL
+		JOIN rss_contents c ON c.source_id = sub.source_id AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+		LEFT JOIN content_states st ON st.content_id = c.id AND st.user_id = sub.user_id
+		WHERE sub.user_id = ? AND st.read_at IS NULL AND COALESCE(NULLIF(st.folder, ''), sub.folder, '') != ''
+		GROUP BY item_folder
+		ORDER BY item_folder`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread contents: %w", err)
+	}
+	defer folderRows.Close()
+	for folderRows.Next() {
+		var count FolderUnreadCount
+		if err := folderRows.Scan(&count.Folder, &count.Unread); err != nil {
+			return nil, fmt.Errorf("failed to scan unread count: %w", err)
+		}
+		counts.Folders = append(counts.Folders, count)
+	}
+	if err := folderRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over unread counts: %w", err)
+	}
+
+	return counts, nil
+}
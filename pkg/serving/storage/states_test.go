@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// stateFixture is a reader subscribed to a source in the "news" folder and
+// one outside any folder, with three items in each published an hour apart
+type stateFixture struct {
+	db     *SQLiteDB
+	user   *User
+	news   *RSSSource
+	other  *RSSSource
+	items  map[string][]*RSSContent
+	oldest time.Time
+}
+
+func newStateFixture(t *testing.T) *stateFixture {
+	t.Helper()
+	db := newTestDB(t)
+	f := &stateFixture{
+		db:     db,
+		user:   newTestUser(t, db, "reader", RoleReader),
+		news:   newTestSource(t, db, "https://example.com/news.xml"),
+		other:  newTestSource(t, db, "https://example.com/other.xml"),
+		items:  map[string][]*RSSContent{},
+		oldest: time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Second),
+	}
+	for _, sub := range []CreateSubscriptionInput{
+		{SourceID: f.news.ID, UpdateSubscriptionInput: UpdateSubscriptionInput{Folder: "news"}},
+		{SourceID: f.other.ID},
+	} {
+		if _, err := db.CreateSubscription(f.user.ID, sub); err != nil {
+			t.Fatalf("CreateSubscription() error = %v", err)
+		}
+	}
+	for _, source := range []*RSSSource{f.news, f.other} {
+		for i := 0; i < 3; i++ {
+			content := &RSSContent{
+				SourceID:    source.ID,
+				Title:       source.Name,
+				Link:        source.URL + "/" + string(rune('a'+i)),
+				PublishedAt: f.oldest.Add(time.Duration(i) * time.Hour),
+			}
+			if err := db.CreateContent(content); err != nil {
+				t.Fatalf("CreateContent() error = %v", err)
+			}
+			f.items[source.ID] = append(f.items[source.ID], content)
+		}
+	}
+	return f
+}
+
+// markRead marks contents read, failing the test on errors
+func (f *stateFixture) markRead(t *testing.T, input MarkReadInput) int {
+	t.Helper()
+	n, err := f.db.MarkContentsRead(f.user.ID, input)
+	if err != nil {
+		t.Fatalf("MarkContentsRead(%+v) error = %v", input, err)
+	}
+	return n
+}
+
+// isRead reports whether the user read a content item
+func (f *stateFixture) isRead(t *testing.T, content *RSSContent) bool {
+	t.Helper()
+	state, err := f.db.GetContentState(f.user.ID, content.ID)
+	if err != nil {
+		t.Fatalf("GetContentState() error = %v", err)
+	}
+	return state.Read
+}
+
+// moveToFolder moves a content item into a folder of the user
+func (f *stateFixture) moveToFolder(t *testing.T, content *RSSContent, folder string) {
+	t.Helper()
+	if _, err := f.db.UpdateContentState(f.user.ID, content.ID, UpdateContentStateInput{Folder: &folder}); err != nil {
+		t.Fatalf("UpdateContentState() error = %v", err)
+	}
+}
+
+func TestMarkContentsReadBefore(t *testing.T) {
+	f := newStateFixture(t)
+
+	// The cut-off includes items published at that time
+	before := f.oldest.Add(time.Hour)
+	if n := f.markRead(t, MarkReadInput{Before: &before}); n != 4 {
+		t.Errorf("MarkContentsRead() = %d, want 4", n)
+	}
+	for _, items := range f.items {
+		for i, content := range items {
+			if read := f.isRead(t, content); read != (i < 2) {
+				t.Errorf("item %d of %s read = %v, want %v", i, content.SourceID, read, i < 2)
+			}
+		}
+	}
+}
+
+func TestMarkContentsReadFolder(t *testing.T) {
+	f := newStateFixture(t)
+	moved := f.items[f.other.ID][0]
+	movedOut := f.items[f.news.ID][0]
+	f.moveToFolder(t, moved, "news")
+	f.moveToFolder(t, movedOut, "later")
+
+	// The folder holds the subscription's items and those moved into it
+	if n := f.markRead(t, MarkReadInput{Folder: "news"}); n != 3 {
+		t.Errorf("MarkContentsRead() = %d, want 3", n)
+	}
+	want := map[*RSSContent]bool{
+		f.items[f.news.ID][1]:  true,
+		f.items[f.news.ID][2]:  true,
+		moved:                  true,
+		movedOut:               false,
+		f.items[f.other.ID][1]: false,
+	}
+	for content, read := range want {
+		if got := f.isRead(t, content); got != read {
+			t.Errorf("%s read = %v, want %v", content.Link, got, read)
+		}
+	}
+}
+
+func TestMarkContentsReadKeepsReadTime(t *testing.T) {
+	f := newStateFixture(t)
+	first := f.items[f.news.ID][0]
+	if n := f.markRead(t, MarkReadInput{ContentIDs: []string{first.ID}}); n != 1 {
+		t.Fatalf("MarkContentsRead() = %d, want 1", n)
+	}
+	readAt := func() time.Time {
+		var at time.Time
+		err := f.db.db.QueryRow("SELECT read_at FROM content_states WHERE user_id = ? AND content_id = ?", f.user.ID, first.ID).Scan(&at)
+		if err != nil {
+			t.Fatalf("failed to get read time: %v", err)
+		}
+		return at
+	}
+	firstRead := readAt()
+
+	// Already read items are neither counted nor marked again
+	time.Sleep(10 * time.Millisecond)
+	if n := f.markRead(t, MarkReadInput{SourceID: f.news.ID}); n != 2 {
+		t.Errorf("MarkContentsRead() = %d, want the 2 unread items", n)
+	}
+	if !readAt().Equal(firstRead) {
+		t.Errorf("read time changed from %v to %v", firstRead, readAt())
+	}
+}
+
+func TestMarkContentsReadOnlySubscribed(t *testing.T) {
+	f := newStateFixture(t)
+	unsubscribed := newTestSource(t, f.db, "https://example.com/unsubscribed.xml")
+	content := newTestContent(t, f.db, unsubscribed.ID, "https://example.com/unsubscribed", "Unsubscribed")
+
+	if n := f.markRead(t, MarkReadInput{}); n != 6 {
+		t.Errorf("MarkContentsRead() = %d, want the 6 subscribed items", n)
+	}
+	if f.isRead(t, content) {
+		t.Error("an item of an unsubscribed source was marked read")
+	}
+}
+
+func TestGetUnreadCounts(t *testing.T) {
+	f := newStateFixture(t)
+	f.moveToFolder(t, f.items[f.other.ID][0], "news")
+	f.moveToFolder(t, f.items[f.news.ID][0], "later")
+	f.markRead(t, MarkReadInput{ContentIDs: []string{f.items[f.news.ID][1].ID}})
+
+	counts, err := f.db.GetUnreadCounts(f.user.ID)
+	if err != nil {
+		t.Fatalf("GetUnreadCounts() error = %v", err)
+	}
+	if counts.Total != 5 {
+		t.Errorf("Total = %d, want 5", counts.Total)
+	}
+
+	// Sources count their own items wherever they were moved
+	sources := map[string]int{}
+	for _, count := range counts.Sources {
+		sources[count.SourceID] = count.Unread
+	}
+	if sources[f.news.ID] != 2 || sources[f.other.ID] != 3 {
+		t.Errorf("source counts = %v, want news 2 and other 3", sources)
+	}
+
+	// Folders count the items moved into them instead of out of them
+	folders := map[string]int{}
+	for _, count := range counts.Folders {
+		folders[count.Folder] = count.Unread
+	}
+	want := map[string]int{"news": 2, "later": 1}
+	if len(folders) != len(want) || folders["news"] != want["news"] || folders["later"] != want["later"] {
+		t.Errorf("folder counts = %v, want %v", folders, want)
+	}
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/riffle"
+)
+
+// storyWindow is how far apart near-duplicate items may be published to be
+// grouped into a story
+const storyWindow = 3 * 24 * time.Hour
+
+// Story is a group of near-duplicate content items, usually the same news
+// published by several sources
+type Story struct {
+	ID               string    `json:"id"`
+	Size             int       `json:"size"`
+	FirstPublishedAt time.Time `json:"firstPublishedAt"`
+	LastPublishedAt  time.Time `json:"lastPublishedAt"`
+	// Contents are the items of the story, the representative first
+	Contents []RSSContent `json:"contents"`
+}
+
+// assignStory groups a new content item with the most similar item
+// published around the same time, if any is similar enough
+func assignStory(q queryer, content *RSSContent) error {
+	rows, err := q.Query(
+		`SELECT id, simhash, COALESCE(story_id, '') FROM rss_contents
+		WHERE simhash IS NOT NULL AND published_at BETWEEN ? AND ? AND id != ? AND deleted_at IS NULL`,
+		content.PublishedAt.Add(-storyWindow), content.PublishedAt.Add(storyWindow), content.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to find similar contents: %w", err)
+	}
+	defer rows.Close()
+
+	// Find the closest item
+	var matchID, matchStory string
+	best := riffle.MaxNearDuplicateDistance + 1
+	for rows.Next() {
+		var id, storyID string
+		var simhash int64
+		if err := rows.Scan(&id, &simhash, &storyID); err != nil {
+			return fmt.Errorf("failed to scan similar content: %w", err)
+		}
+		if distance := riffle.SimHashDistance(uint64(simhash), content.SimHash); distance < best {
+			best, matchID, matchStory = distance, id, storyID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over similar contents: %w", err)
+	}
+	rows.Close()
+	if matchID == "" {
+		return nil
+	}
+
+	// Join the item's story, which is named after its first item
+	if matchStory == "" {
+		matchStory = matchID
+		if _, err := q.Exec("UPDATE rss_contents SET story_id = ? WHERE id = ?", matchStory, matchID); err != nil {
+			return fmt.Errorf("failed to create story: %w", err)
+		}
+	}
+	if _, err := q.Exec("UPDATE rss_contents SET story_id = ? WHERE id = ?", matchStory, content.ID); err != nil {
+		return fmt.Errorf("failed to add content to story: %w", err)
+	}
+	content.StoryID = matchStory
+	return nil
+}
+
+// collapseCondition returns a SQL condition that leaves out the items of a
+// story except its representative, the first published item the user can
+// see, together with its arguments
+func collapseCondition(userID string) (string, []interface{}) {
+	return ` AND (c.story_id IS NULL OR NOT EXISTS (
+			SELECT 1 FROM rss_contents d
+			WHERE d.story_id = c.story_id AND d.deleted_at IS NULL AND d.hidden_at IS NULL
+				AND d.source_id IN (` + subscribedSourcesQuery + `)
+				AND (d.published_at < c.published_at OR (d.published_at = c.published_at AND d.id < c.id))
+		))`,
+		[]interface{}{userID}
+}
+
+// ListStories lists the stories of more than one item among a user's
+// subscriptions, most recently published first
+func (s *SQLiteDB) ListStories(userID string, limit int) ([]Story, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	// Find the stories with the newest items
+	rows, err := s.readDB.Query(
+		`SELECT c.story_id FROM rss_contents c
+		WHERE c.story_id IS NOT NULL AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+			AND c.source_id IN (`+subscribedSourcesQuery+`)
+		GROUP BY c.story_id
+		HAVING COUNT(*) > 1
+		ORDER BY MAX(c.published_at) DESC
+		LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stories: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []interface{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan story: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stories: %w", err)
+	}
+	rows.Close()
+
+	stories := []Story{}
+	if len(ids) == 0 {
+		return stories, nil
+	}
+
+	// Query the items of all stories at once
+	memberRows, err := s.readDB.Query(
+		`SELECT c.story_id, c.id, c.source_id, c.title, c.link, COALESCE(c.canonical_link, ''), c.description,
+			c.published_at, c.fetched_at
+		FROM rss_contents c
+		WHERE c.story_id IN (`+createPlaceholders(len(ids))+`) AND c.deleted_at IS NULL AND c.hidden_at IS NULL
+			AND c.source_id IN (`+subscribedSourcesQuery+`)
+		ORDER BY c.published_at ASC, c.id ASC`,
+		append(ids, userID)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list story contents: %w", err)
+	}
+	defer memberRows.Close()
+
+	members := map[string][]RSSContent{}
+	for memberRows.Next() {
+		var content RSSContent
+		err := memberRows.Scan(&content.StoryID, &content.ID, &content.SourceID, &content.Title, &content.Link,
+			&content.CanonicalLink, &content.Description, &content.PublishedAt, &content.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan story content: %w", err)
+		}
+		members[content.StoryID] = append(members[content.StoryID], content)
+	}
+	if err := memberRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over story contents: %w", err)
+	}
+
+	// Keep the order of the stories
+	for _, id := range ids {
+		contents := members[id.(string)]
+		if len(contents) < 2 {
+			continue
+		}
+		stories = append(stories, Story{
+			ID:               id.(string),
+			Size:             len(contents),
+			FirstPublishedAt: contents[0].PublishedAt,
+			LastPublishedAt:  contents[len(contents)-1].PublishedAt,
+			Contents:         contents,
+		})
+	}
+
+	return stories, nil
+}
+
+// nullSimHash stores a SimHash signature as a signed integer, and missing
+// signatures as NULL
+func nullSimHash(simhash uint64) interface{} {
+	if simhash == 0 {
+		return nil
+	}
+	return int64(simhash)
+}
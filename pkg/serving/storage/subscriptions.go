@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSourceNotFound is returned when subscribing to a source that does not exist
+	ErrSourceNotFound = errors.New("source not found")
+	// ErrAlreadySubscribed is returned when subscribing to a source twice
+	ErrAlreadySubscribed = errors.New("already subscribed to this source")
+	// ErrNewSourceNotAllowed is returned when subscribing to a URL that no
+	// source has without being allowed to add sources
+	ErrNewSourceNotAllowed = errors.New("no source has this URL and only editors can add sources")
+)
+
+// Subscription links a user to a shared RSS source together with the user's
+// own settings for it
+type Subscription struct {
+	ID                string    `json:"id"`
+	UserID            string    `json:"userId"`
+	SourceID          string    `json:"sourceId"`
+	DisplayName       string    `json:"displayName,omitempty"`
+	Folder            string    `json:"folder,omitempty"`
+	NotifyNewContent  bool      `json:"notifyNewContent"`
+	NotifyFetchErrors bool      `json:"notifyFetchErrors"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	Source            RSSSource `json:"source"`
+}
+
+// Title returns the name the user sees for the subscription
+func (s *Subscription) Title() string {
+	if s.DisplayName != "" {
+		return s.DisplayName
+	}
+	return s.Source.Name
+}
+
+// UpdateSubscriptionInput represents the input for updating a subscription
+type UpdateSubscriptionInput struct {
+	DisplayName       string `json:"displayName"`
+	Folder            string `json:"folder"`
+	NotifyNewContent  bool   `json:"notifyNewContent"`
+	NotifyFetchErrors bool   `json:"notifyFetchErrors"`
+}
+
+// CreateSubscriptionInput represents the input for subscribing to a source.
+// Either SourceID or URL must be set; subscribing to a URL reuses the
+// existing source for it, or creates one if there is none and AllowNewSource
+// is set.
+type CreateSubscriptionInput struct {
+	SourceID string `json:"sourceId,omitempty"`
+	URL      string `json:"url,omitempty"`
+	UpdateSubscriptionInput
+	// AllowNewSource permits creating a source, which only editors may do
+	AllowNewSource bool `json:"-"`
+}
+
+// subscriptionColumns is the column list used to scan subscriptions joined with their source
+const subscriptionColumns = `
+	sub.id, sub.user_id, sub.source_id, sub.display_name, sub.folder,
+	sub.notify_new_content, sub.notify_fetch_errors, sub.created_at, sub.updated_at,
+	s.id, s.name, s.url, s.description, s.created_at, s.updated_at, s.last_fetched_at`
+
+// scanSubscription scans a subscription joined with its source from a row
+func scanSubscription(scan func(dest ...interface{}) error) (*Subscription, error) {
+	var sub Subscription
+	var displayName, folder sql.NullString
+	var lastFetchedAt sql.NullTime
+
+	err := scan(
+		&sub.ID,
+		&sub.UserID,
+		&sub.SourceID,
+		&displayName,
+		&folder,
+		&sub.NotifyNewContent,
+		&sub.NotifyFetchErrors,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+		&sub.Source.ID,
+		&sub.Source.Name,
+		&sub.Source.URL,
+		&sub.Source.Description,
+		&sub.Source.CreatedAt,
+		&sub.Source.UpdatedAt,
+		&lastFetchedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.DisplayName = displayName.String
+	sub.Folder = folder.String
+	if lastFetchedAt.Valid {
+		sub.Source.LastFetchedAt = &lastFetchedAt.Time
+	}
+
+	return &sub, nil
+}
+
+// subscribedSourcesQuery selects the IDs of the sources a user is subscribed
+// to. It takes the user ID as its only argument.
+const subscribedSourcesQuery = "SELECT source_id FROM subscriptions WHERE user_id = ?"
+
+// IsSubscribed reports whether a user is subscribed to a source
+func (s *SQLiteDB) IsSubscribed(userID, sourceID string) (bool, error) {
+	var exists bool
+	err := s.readDB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM subscriptions WHERE user_id = ? AND source_id = ?)",
+		userID, sourceID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	return exists, nil
+}
+
+// UnsubscribedContentIDs returns those of the given content item IDs that do
+// not exist or belong to a source the user is not subscribed to
+func (s *SQLiteDB) UnsubscribedContentIDs(userID string, ids []string) ([]string, error) {
+	if err := checkBatchSize(len(ids)); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	// Find the items the user can see
+	args := []interface{}{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := s.readDB.Query(
+		`SELECT id FROM rss_contents
+		WHERE deleted_at IS NULL AND source_id IN (`+subscribedSourcesQuery+`)
+		AND id IN (`+createPlaceholders(len(ids))+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subscribed contents: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan content ID: %w", err)
+		}
+		found[id] = true
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over subscribed contents: %w", err)
+	}
+
+	missing := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true
+		}
+	}
+	return missing, nil
+}
+
+// CreateSubscription subscribes a user to a source
+func (s *SQLiteDB) CreateSubscription(userID string, input CreateSubscriptionInput) (*Subscription, error) {
+	input.URL = strings.TrimSpace(input.URL)
+	if input.SourceID == "" && input.URL == "" {
+		return nil, fmt.Errorf("sourceId or url is required")
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Find the source to subscribe to, creating it if needed
+	var source *RSSSource
+	if input.SourceID != "" {
+		source, err = getSource(tx, input.SourceID)
+		if err != nil {
+			return nil, err
+		}
+		if source == nil {
+			return nil, ErrSourceNotFound
+		}
+	} else {
+		var sourceID string
+		err = tx.QueryRow("SELECT id FROM rss_sources WHERE url = ? AND deleted_at IS NULL", input.URL).Scan(&sourceID)
+		if err == sql.ErrNoRows && !input.AllowNewSource {
+			return nil, ErrNewSourceNotAllowed
+		} else if err == sql.ErrNoRows {
+			name := input.DisplayName
+			if name == "" {
+				name = input.URL
+			}
+			source, err = createSource(tx, CreateSourceInput{Name: name, URL: input.URL})
+		} else if err == nil {
+			source, err = getSource(tx, sourceID)
+		} else {
+			err = fmt.Errorf("failed to get RSS source by URL: %w", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Check if the user is already subscribed
+	var exists bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM subscriptions WHERE user_id = ? AND source_id = ?)",
+		userID, source.ID,
+	).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subscription: %w", err)
+	}
+	if exists {
+		return nil, ErrAlreadySubscribed
+	}
+
+	// Insert the subscription
+	now := time.Now().UTC()
+	sub := &Subscription{
+		ID:                uuid.New().String(),
+		UserID:            userID,
+		SourceID:          source.ID,
+		DisplayName:       input.DisplayName,
+		Folder:            input.Folder,
+		NotifyNewContent:  input.NotifyNewContent,
+		NotifyFetchErrors: input.NotifyFetchErrors,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		Source:            *source,
+	}
+	_, err = tx.Exec(
+		`INSERT INTO subscriptions (id, user_id, source_id, display_name, folder, notify_new_content, notify_fetch_errors, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sub.ID, sub.UserID, sub.SourceID, sub.DisplayName, sub.Folder,
+		sub.NotifyNewContent, sub.NotifyFetchErrors, sub.CreatedAt, sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return sub, nil
+}
+
+// GetSubscription retrieves one of a user's subscriptions
+func (s *SQLiteDB) GetSubscription(userID, id string) (*Subscription, error) {
+	return getSubscription(s.readDB, userID, id)
+}
+
+// getSubscription retrieves one of a user's subscriptions using the given queryer
+func getSubscription(q queryer, userID, id string) (*Subscription, error) {
+	row := q.QueryRow(
+		`SELECT `+subscriptionColumns+`
+		FROM subscriptions sub JOIN rss_sources s ON s.id = sub.source_id
+		WHERE sub.id = ? AND sub.user_id = ? AND s.deleted_at IS NULL`,
+		id, userID,
+	)
+
+	sub, err := scanSubscription(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Subscription not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+// ListSubscriptions lists a user's subscriptions, optionally only those in a
+// folder, ordered by folder and name
+func (s *SQLiteDB) ListSubscriptions(userID, folder string) ([]Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions sub JOIN rss_sources s ON s.id = sub.source_id
+		WHERE sub.user_id = ? AND s.deleted_at IS NULL
+	`
+	args := []interface{}{userID}
+
+	// Add folder filter if provided
+	if folder != "" {
+		query += " AND sub.folder = ?"
+		args = append(args, folder)
+	}
+
+	query += " ORDER BY sub.folder ASC, COALESCE(NULLIF(sub.display_name, ''), s.name) COLLATE NOCASE ASC"
+
+	// Execute the query
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, *sub)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// UpdateSubscription updates a user's settings for one of their subscriptions
+func (s *SQLiteDB) UpdateSubscription(userID, id string, input UpdateSubscriptionInput) (*Subscription, error) {
+	res, err := s.db.Exec(
+		`UPDATE subscriptions
+		SET display_name = ?, folder = ?, notify_new_content = ?, notify_fetch_errors = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?`,
+		input.DisplayName, input.Folder, input.NotifyNewContent, input.NotifyFetchErrors, time.Now().UTC(),
+		id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Subscription not found
+	}
+
+	return getSubscription(s.db, userID, id)
+}
+
+// DeleteSubscription unsubscribes a user from a source. The source itself
+// is shared and stays in place. It reports whether the subscription existed.
+func (s *SQLiteDB) DeleteSubscription(userID, id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM subscriptions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
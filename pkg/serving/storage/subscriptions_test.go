@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCreateSubscriptionByURL(t *testing.T) {
+	db := newTestDB(t)
+	reader := newTestUser(t, db, "reader", RoleReader)
+	source := newTestSource(t, db, "https://example.com/existing.xml")
+
+	// Known URLs reuse their source
+	sub, err := db.CreateSubscription(reader.ID, CreateSubscriptionInput{URL: source.URL})
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if sub.SourceID != source.ID {
+		t.Errorf("SourceID = %s, want %s", sub.SourceID, source.ID)
+	}
+
+	// New URLs need permission to add a source
+	_, err = db.CreateSubscription(reader.ID, CreateSubscriptionInput{URL: "https://example.com/new.xml"})
+	if !errors.Is(err, ErrNewSourceNotAllowed) {
+		t.Fatalf("CreateSubscription() error = %v, want %v", err, ErrNewSourceNotAllowed)
+	}
+	sources, _, err := db.ListSources(10, "")
+	if err != nil {
+		t.Fatalf("ListSources() error = %v", err)
+	}
+	if len(sources) != 1 {
+		t.Errorf("got %d sources, want 1", len(sources))
+	}
+
+	sub, err = db.CreateSubscription(reader.ID, CreateSubscriptionInput{URL: "https://example.com/new.xml", AllowNewSource: true})
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if sub.Source.URL != "https://example.com/new.xml" {
+		t.Errorf("Source.URL = %s, want the new URL", sub.Source.URL)
+	}
+}
+
+func TestCreateSubscriptionToTrashedSource(t *testing.T) {
+	db := newTestDB(t)
+	reader := newTestUser(t, db, "reader", RoleReader)
+	source := newTestSource(t, db, "https://example.com/trashed.xml")
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+
+	_, err := db.CreateSubscription(reader.ID, CreateSubscriptionInput{SourceID: source.ID})
+	if !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("CreateSubscription() error = %v, want %v", err, ErrSourceNotFound)
+	}
+}
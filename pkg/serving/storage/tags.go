@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the maximum length of a tag in characters
+const MaxTagLength = 64
+
+// ErrInvalidTag is returned for tags that are empty or too long
+var ErrInvalidTag = errors.New("invalid tag")
+
+// TagCount is a user's tag together with the number of content items carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// BatchTagContentsInput represents the input for adding and removing tags on
+// multiple content items at once
+type BatchTagContentsInput struct {
+	ContentIDs []string `json:"contentIds"`
+	Add        []string `json:"add,omitempty"`
+	Remove     []string `json:"remove,omitempty"`
+}
+
+// BatchTagContentsResult represents the result of adding and removing tags
+type BatchTagContentsResult struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// NormalizeTag trims and lowercases a tag so that tags differing only in case
+// or surrounding whitespace are the same tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes a list of tags, dropping duplicates, and returns
+// an error for empty or overlong tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("%w: tags must not be empty", ErrInvalidTag)
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, tag, MaxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// tagConditions returns SQL conditions matching content c that carries all
+// of the given tags of a user, together with their arguments
+func tagConditions(userID string, tags []string) (string, []interface{}) {
+	var conditions string
+	var args []interface{}
+	for _, tag := range tags {
+		conditions += " AND EXISTS (SELECT 1 FROM content_tags t WHERE t.content_id = c.id AND t.user_id = ? AND t.tag = ?)"
+		args = append(args, userID, NormalizeTag(tag))
+	}
+	return conditions, args
+}
+
+// loadContentTags fills in the user's tags of the given content items
+func (s *SQLiteDB) loadContentTags(userID string, contents []RSSContent) error {
+	if len(contents) == 0 {
+		return nil
+	}
+
+	// Query the tags of all items at once
+	args := []interface{}{userID}
+	index := map[string]int{}
+	for i, content := range contents {
+		args = append(args, content.ID)
+		index[content.ID] = i
+	}
+	rows, err := s.readDB.Query(
+		`SELECT content_id, tag FROM content_tags
+		WHERE user_id = ? AND content_id IN (`+createPlaceholders(len(contents))+`)
+		ORDER BY tag ASC`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get content tags: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	for rows.Next() {
+		var contentID, tag string
+		if err := rows.Scan(&contentID, &tag); err != nil {
+			return fmt.Errorf("failed to scan content tag: %w", err)
+		}
+		i := index[contentID]
+		contents[i].Tags = append(contents[i].Tags, tag)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over content tags: %w", err)
+	}
+
+	return nil
+}
+
+// GetContentTags retrieves a user's tags of a content item
+func (s *SQLiteDB) GetContentTags(userID, contentID string) ([]string, error) {
+	contents := []RSSContent{{ID: contentID}}
+	if err := s.loadContentTags(userID, contents); err != nil {
+		return nil, err
+	}
+	return contents[0].Tags, nil
+}
+
+// BatchTagContents adds and removes a user's tags on multiple content items.
+// Content items that do not exist or belong to a source the user is not
+// subscribed to are skipped.
+func (s *SQLiteDB) BatchTagContents(userID string, input BatchTagContentsInput) (*BatchTagContentsResult, error) {
+	if err := checkBatchSize(len(input.ContentIDs)); err != nil {
+		return nil, err
+	}
+
+	add, err := normalizeTags(input.Add)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := normalizeTags(input.Remove)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchTagContentsResult{}
+	if len(input.ContentIDs) == 0 {
+		return result, nil
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	placeholders := createPlaceholders(len(input.ContentIDs))
+	now := time.Now().UTC()
+
+	// Remove tags first so that a tag both added and removed ends up added
+	for _, tag := range remove {
+		args := []interface{}{userID, tag}
+		for _, id := range input.ContentIDs {
+			args = append(args, id)
+		}
+		res, err := tx.Exec(
+			"DELETE FROM content_tags WHERE user_id = ? AND tag = ? AND content_id IN ("+placeholders+")",
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove tag %q: %w", tag, err)
+		}
+		n, _ := res.RowsAffected()
+		result.Removed += int(n)
+	}
+
+	// Add tags to the content items the user can see
+	for _, tag := range add {
+		args := []interface{}{userID, tag, now, userID}
+		for _, id := range input.ContentIDs {
+			args = append(args, id)
+		}
+		res, err := tx.Exec(
+			`INSERT OR IGNORE INTO content_tags (user_id, content_id, tag, created_at)
+			SELECT ?, id, ?, ? FROM rss_contents
+			WHERE deleted_at IS NULL AND source_id IN (`+subscribedSourcesQuery+`) AND id IN (`+placeholders+`)`,
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add tag %q: %w", tag, err)
+		}
+		n, _ := res.RowsAffected()
+		result.Added += int(n)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListTags lists a user's tags with the number of content items carrying each
+func (s *SQLiteDB) ListTags(userID string) ([]TagCount, error) {
+	rows, err := s.readDB.Query(
+		`SELECT t.tag, COUNT(*)
+		FROM content_tags t JOIN rss_contents c ON c.id = t.content_id
+		WHERE t.user_id = ? AND c.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY t.tag ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// DeleteTag removes a user's tag from all content items and returns how
+// many items carried it
+func (s *SQLiteDB) DeleteTag(userID, tag string) (int, error) {
+	res, err := s.db.Exec("DELETE FROM content_tags WHERE user_id = ? AND tag = ?", userID, NormalizeTag(tag))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete tag: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tagFixture is a reader subscribed to a source with three items, and an
+// item of a source the reader is not subscribed to
+type tagFixture struct {
+	db           *SQLiteDB
+	user         *User
+	items        []*RSSContent
+	unsubscribed *RSSContent
+}
+
+func newTagFixture(t *testing.T) *tagFixture {
+	t.Helper()
+	db := newTestDB(t)
+	f := &tagFixture{db: db, user: newTestUser(t, db, "reader", RoleReader)}
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.CreateSubscription(f.user.ID, CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	for _, title := range []string{"Go release", "Go tooling", "Rust release"} {
+		link := "https://example.com/" + strings.ReplaceAll(strings.ToLower(title), " ", "-")
+		f.items = append(f.items, newTestContent(t, db, source.ID, link, title))
+	}
+	other := newTestSource(t, db, "https://example.com/other.xml")
+	f.unsubscribed = newTestContent(t, db, other.ID, "https://example.com/other", "Go elsewhere")
+	return f
+}
+
+// tag adds and removes tags, failing the test on errors
+func (f *tagFixture) tag(t *testing.T, input BatchTagContentsInput) *BatchTagContentsResult {
+	t.Helper()
+	result, err := f.db.BatchTagContents(f.user.ID, input)
+	if err != nil {
+		t.Fatalf("BatchTagContents(%+v) error = %v", input, err)
+	}
+	return result
+}
+
+// ids returns the IDs of content items
+func ids(contents []RSSContent) []string {
+	var ids []string
+	for _, content := range contents {
+		ids = append(ids, content.ID)
+	}
+	return ids
+}
+
+func TestBatchTagContents(t *testing.T) {
+	f := newTagFixture(t)
+	all := []string{f.items[0].ID, f.items[1].ID, f.items[2].ID, f.unsubscribed.ID, "missing"}
+
+	// Tags are normalized and only added to items the user can see
+	result := f.tag(t, BatchTagContentsInput{ContentIDs: all, Add: []string{" Go ", "go", "Later"}})
+	if result.Added != 6 || result.Removed != 0 {
+		t.Errorf("added %d and removed %d, want 6 added", result.Added, result.Removed)
+	}
+	if tags, _ := f.db.GetContentTags(f.user.ID, f.unsubscribed.ID); len(tags) != 0 {
+		t.Errorf("item of an unsubscribed source tagged %v", tags)
+	}
+
+	// Adding an existing tag again is not counted
+	result = f.tag(t, BatchTagContentsInput{ContentIDs: all[:1], Add: []string{"go"}})
+	if result.Added != 0 {
+		t.Errorf("re-adding a tag added %d, want 0", result.Added)
+	}
+
+	// Removing runs before adding, so a tag in both ends up added
+	result = f.tag(t, BatchTagContentsInput{ContentIDs: all[1:3], Add: []string{"later"}, Remove: []string{"GO", "later"}})
+	if result.Removed != 4 || result.Added != 2 {
+		t.Errorf("added %d and removed %d, want 2 added and 4 removed", result.Added, result.Removed)
+	}
+	want := [][]string{{"go", "later"}, {"later"}, {"later"}}
+	for i, content := range f.items {
+		tags, err := f.db.GetContentTags(f.user.ID, content.ID)
+		if err != nil {
+			t.Fatalf("GetContentTags() error = %v", err)
+		}
+		if !reflect.DeepEqual(tags, want[i]) {
+			t.Errorf("item %d tags = %v, want %v", i, tags, want[i])
+		}
+	}
+}
+
+func TestBatchTagContentsInvalid(t *testing.T) {
+	f := newTagFixture(t)
+	for _, input := range []BatchTagContentsInput{
+		{ContentIDs: []string{f.items[0].ID}, Add: []string{" "}},
+		{ContentIDs: []string{f.items[0].ID}, Remove: []string{strings.Repeat("x", MaxTagLength+1)}},
+	} {
+		if _, err := f.db.BatchTagContents(f.user.ID, input); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("BatchTagContents(%+v) error = %v, want ErrInvalidTag", input, err)
+		}
+	}
+	if _, err := f.db.BatchTagContents(f.user.ID, BatchTagContentsInput{ContentIDs: make([]string, MaxBatchSize+1)}); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("BatchTagContents() of an oversized batch error = %v, want ErrBatchTooLarge", err)
+	}
+}
+
+func TestListTagsAndDeleteTag(t *testing.T) {
+	f := newTagFixture(t)
+	f.tag(t, BatchTagContentsInput{ContentIDs: []string{f.items[0].ID, f.items[1].ID}, Add: []string{"go"}})
+	f.tag(t, BatchTagContentsInput{ContentIDs: []string{f.items[2].ID}, Add: []string{"rust"}})
+
+	// Other users' tags are not counted
+	other := newTestUser(t, f.db, "other", RoleReader)
+	if _, err := f.db.CreateSubscription(other.ID, CreateSubscriptionInput{SourceID: f.items[0].SourceID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if _, err := f.db.BatchTagContents(other.ID, BatchTagContentsInput{ContentIDs: []string{f.items[2].ID}, Add: []string{"go"}}); err != nil {
+		t.Fatalf("BatchTagContents() error = %v", err)
+	}
+
+	tags, err := f.db.ListTags(f.user.ID)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	want := []TagCount{{Tag: "go", Count: 2}, {Tag: "rust", Count: 1}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("ListTags() = %v, want %v", tags, want)
+	}
+
+	// Deleted items are not counted
+	if err := f.db.DeleteContent(f.items[1].ID); err != nil {
+		t.Fatalf("DeleteContent() error = %v", err)
+	}
+	if tags, _ := f.db.ListTags(f.user.ID); len(tags) != 2 || tags[0].Count != 1 {
+		t.Errorf("ListTags() after deleting an item = %v, want go counted once", tags)
+	}
+
+	// Deleting a tag leaves the other user's tag of the same name
+	n, err := f.db.DeleteTag(f.user.ID, " GO ")
+	if err != nil {
+		t.Fatalf("DeleteTag() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeleteTag() = %d, want 2", n)
+	}
+	if tags, _ := f.db.ListTags(f.user.ID); !reflect.DeepEqual(tags, []TagCount{{Tag: "rust", Count: 1}}) {
+		t.Errorf("ListTags() after DeleteTag() = %v, want only rust", tags)
+	}
+	if tags, _ := f.db.ListTags(other.ID); len(tags) != 1 || tags[0].Tag != "go" {
+		t.Errorf("other user's tags = %v, want go", tags)
+	}
+}
+
+func TestTagFilters(t *testing.T) {
+	f := newTagFixture(t)
+	f.tag(t, BatchTagContentsInput{ContentIDs: []string{f.items[0].ID, f.items[2].ID}, Add: []string{"release"}})
+	f.tag(t, BatchTagContentsInput{ContentIDs: []string{f.items[0].ID, f.items[1].ID}, Add: []string{"go"}})
+
+	tests := []struct {
+		tags []string
+		want []*RSSContent
+	}{
+		{nil, f.items},
+		{[]string{"Release"}, []*RSSContent{f.items[0], f.items[2]}},
+		{[]string{"release", "go"}, []*RSSContent{f.items[0]}},
+		{[]string{"unused"}, nil},
+	}
+	for _, tt := range tests {
+		var want []string
+		for _, content := range tt.want {
+			want = append(want, content.ID)
+		}
+
+		// Listing returns all matching items, tags included
+		contents, _, err := f.db.ListContents(ListContentsInput{UserID: f.user.ID, Tags: tt.tags})
+		if err != nil {
+			t.Fatalf("ListContents() error = %v", err)
+		}
+		if !sameIDs(ids(contents), want) {
+			t.Errorf("ListContents() with tags %v = %v, want %v", tt.tags, ids(contents), want)
+		}
+
+		// Searching applies the tags on top of the keywords
+		contents, err = f.db.SearchContents(SearchContentsInput{Keywords: "release", UserID: f.user.ID, Tags: tt.tags})
+		if err != nil {
+			t.Fatalf("SearchContents() error = %v", err)
+		}
+		var wantSearch []string
+		for _, id := range want {
+			if id != f.items[1].ID {
+				wantSearch = append(wantSearch, id)
+			}
+		}
+		if !sameIDs(ids(contents), wantSearch) {
+			t.Errorf("SearchContents() with tags %v = %v, want %v", tt.tags, ids(contents), wantSearch)
+		}
+	}
+}
+
+// sameIDs reports whether two lists hold the same IDs in any order
+func sameIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, id := range got {
+		seen[id] = true
+	}
+	for _, id := range want {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,420 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSourceDeleted is returned when restoring content whose source is still in the trash
+var ErrSourceDeleted = errors.New("the content's source is in the trash; restore the source first")
+
+// TrashedSource represents an RSS source in the trash
+type TrashedSource struct {
+	RSSSource
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// TrashedContent represents an RSS content item in the trash
+type TrashedContent struct {
+	RSSContent
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// PurgeResult represents the result of permanently deleting trashed items
+type PurgeResult struct {
+	PurgedSources  int `json:"purgedSources"`
+	PurgedContents int `json:"purgedContents"`
+}
+
+// ListTrashedSources lists RSS sources in the trash with pagination
+func (s *SQLiteDB) ListTrashedSources(limit int, nextToken string) ([]TrashedSource, string, error) {
+	// Default limit if not specified
+	if limit <= 0 {
+		limit = 50
+	}
+
+	// Build the query
+	query := `
+		SELECT id, name, url, description, created_at, updated_at, last_fetched_at, deleted_at
+		FROM rss_sources
+		WHERE deleted_at IS NOT NULL
+	`
+	args := []interface{}{}
+
+	// Add pagination if nextToken is provided
+	if nextToken != "" {
+		query += " AND id > ?"
+		args = append(args, nextToken)
+	}
+
+	// Add ordering and limit
+	query += " ORDER BY id ASC LIMIT ?"
+	args = append(args, limit+1) // Fetch one extra to determine if there are more results
+
+	// Execute the query
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list trashed RSS sources: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	sources := []TrashedSource{}
+	for rows.Next() {
+		var source TrashedSource
+		var lastFetchedAt sql.NullTime
+
+		err := rows.Scan(
+			&source.ID,
+			&source.Name,
+			&source.URL,
+			&source.Description,
+			&source.CreatedAt,
+			&source.UpdatedAt,
+			&lastFetchedAt,
+			&source.DeletedAt,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan trashed RSS source: %w", err)
+		}
+
+		if lastFetchedAt.Valid {
+			source.LastFetchedAt = &lastFetchedAt.Time
+		}
+
+		sources = append(sources, source)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating over trashed RSS sources: %w", err)
+	}
+
+	// Determine if there are more results and set the next token
+	var newNextToken string
+	if len(sources) > limit {
+		newNextToken = sources[limit-1].ID
+		sources = sources[:limit] // Remove the extra item
+	}
+
+	return sources, newNextToken, nil
+}
+
+// ListTrashedContents lists RSS content items in the trash with pagination
+func (s *SQLiteDB) ListTrashedContents(sourceID string, limit int, nextToken string) ([]TrashedContent, string, error) {
+	// Default limit if not specified
+	if limit <= 0 {
+		limit = 50
+	}
+
+	// Build the query
+	query := `
+		SELECT c.id, c.source_id, c.title, c.link, c.description, c.published_at, c.fetched_at, c.deleted_at
+		FROM rss_contents c
+		WHERE c.deleted_at IS NOT NULL
+	`
+	args := []interface{}{}
+
+	// Add filters
+	if sourceID != "" {
+		query += " AND c.source_id = ?"
+		args = append(args, sourceID)
+	}
+	if nextToken != "" {
+		query += " AND c.id > ?"
+		args = append(args, nextToken)
+	}
+
+	// Add ordering and limit
+	query += " ORDER BY c.id ASC LIMIT ?"
+	args = append(args, limit+1) // Fetch one extra to determine if there are more results
+
+	// Execute the query
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list trashed RSS contents: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	contents := []TrashedContent{}
+	for rows.Next() {
+		var content TrashedContent
+		err := rows.Scan(
+			&content.ID,
+			&content.SourceID,
+			&content.Title,
+			&content.Link,
+			&content.Description,
+			&content.PublishedAt,
+			&content.FetchedAt,
+			&content.DeletedAt,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan trashed RSS content: %w", err)
+		}
+		contents = append(contents, content)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating over trashed RSS contents: %w", err)
+	}
+
+	// Determine if there are more results and set the next token
+	var newNextToken string
+	if len(contents) > limit {
+		newNextToken = contents[limit-1].ID
+		contents = contents[:limit] // Remove the extra item
+	}
+
+	return contents, newNextToken, nil
+}
+
+// RestoreSource restores a trashed RSS source together with the contents
+// that were trashed along with it. It reports whether the source was in the trash.
+func (s *SQLiteDB) RestoreSource(id string) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Restore the contents deleted at the same time as the source
+	_, err = tx.Exec(
+		`UPDATE rss_contents SET deleted_at = NULL
+		WHERE source_id = ? AND deleted_at = (SELECT deleted_at FROM rss_sources WHERE id = ?)`,
+		id, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore RSS source contents: %w", err)
+	}
+
+	// Restore the source
+	res, err := tx.Exec("UPDATE rss_sources SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore RSS source: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil // Source not in the trash
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.resetCorpus()
+	return true, nil
+}
+
+// RestoreContent restores a trashed RSS content item. It reports whether the
+// content item was in the trash.
+func (s *SQLiteDB) RestoreContent(id string) (bool, error) {
+	// Check that the content's source is not in the trash
+	var sourceDeletedAt sql.NullTime
+	err := s.db.QueryRow(
+		`SELECT s.deleted_at
+		FROM rss_contents c JOIN rss_sources s ON s.id = c.source_id
+		WHERE c.id = ? AND c.deleted_at IS NOT NULL`,
+		id,
+	).Scan(&sourceDeletedAt)
+	if err == sql.ErrNoRows {
+		return false, nil // Content not in the trash
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get RSS content source: %w", err)
+	}
+	if sourceDeletedAt.Valid {
+		return false, ErrSourceDeleted
+	}
+
+	// Restore the content
+	res, err := s.db.Exec("UPDATE rss_contents SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore RSS content: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil // Content not in the trash
+	}
+
+	s.resetCorpus()
+	return true, nil
+}
+
+// PurgeSource permanently deletes a trashed RSS source and all of its
+// contents. It reports whether the source was in the trash.
+func (s *SQLiteDB) PurgeSource(id string) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	n, err := purgeSources(tx, "id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// PurgeContent permanently deletes a trashed RSS content item and leaves a
+// tombstone for its link. It reports whether the content item was in the trash.
+func (s *SQLiteDB) PurgeContent(id string) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	n, err := purgeContents(tx, "id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// PurgeTrash permanently deletes all sources and contents that were moved to
+// the trash before the given time
+func (s *SQLiteDB) PurgeTrash(before time.Time) (*PurgeResult, error) {
+	before = before.UTC()
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &PurgeResult{}
+
+	// Purge sources first, together with their contents
+	sources, err := purgeSources(tx, "deleted_at < ?", before)
+	if err != nil {
+		return nil, err
+	}
+	result.PurgedSources = sources
+
+	// Purge the remaining individually deleted contents
+	purged, err := purgeContents(tx, "deleted_at < ?", before)
+	if err != nil {
+		return nil, err
+	}
+	result.PurgedContents = purged
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// sourceDataTables are the tables holding data about RSS sources, keyed by
+// source_id
+var sourceDataTables = []string{"subscriptions", "routing_rules", "filter_rules"}
+
+// purgeSources permanently deletes trashed sources matching the given
+// condition with all of their contents and data
+func purgeSources(q queryer, condition string, args ...interface{}) (int, error) {
+	sources := "SELECT id FROM rss_sources WHERE deleted_at IS NOT NULL AND " + condition
+
+	// Purge the sources' trashed contents first so that their links get
+	// tombstones
+	if _, err := purgeContents(q, "source_id IN ("+sources+")", args...); err != nil {
+		return 0, err
+	}
+
+	// Delete the sources' remaining data explicitly, as foreign keys may be
+	// disabled
+	if err := deleteContentData(q, "source_id IN ("+sources+")", args...); err != nil {
+		return 0, err
+	}
+	if _, err := q.Exec("DELETE FROM rss_contents WHERE source_id IN ("+sources+")", args...); err != nil {
+		return 0, fmt.Errorf("failed to purge RSS contents: %w", err)
+	}
+	for _, table := range sourceDataTables {
+		if _, err := q.Exec("DELETE FROM "+table+" WHERE source_id IN ("+sources+")", args...); err != nil {
+			return 0, fmt.Errorf("failed to delete source data from %s: %w", table, err)
+		}
+	}
+	if _, err := q.Exec("UPDATE fetch_jobs SET source_id = NULL WHERE source_id IN ("+sources+")", args...); err != nil {
+		return 0, fmt.Errorf("failed to detach fetch jobs: %w", err)
+	}
+
+	res, err := q.Exec("DELETE FROM rss_sources WHERE deleted_at IS NOT NULL AND "+condition, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge RSS sources: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// purgeContents permanently deletes trashed contents matching the given
+// condition, recording a tombstone for each link first
+func purgeContents(q queryer, condition string, args ...interface{}) (int, error) {
+	// Record tombstones so the items are not ingested again
+	_, err := q.Exec(
+		`INSERT OR REPLACE INTO content_tombstones (link, source_id, deleted_at)
+		SELECT link, source_id, deleted_at FROM rss_contents
+		WHERE deleted_at IS NOT NULL AND `+condition,
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record content tombstones: %w", err)
+	}
+	_, err = q.Exec(
+		`INSERT OR REPLACE INTO content_tombstones (link, source_id, deleted_at)
+		SELECT canonical_link, source_id, deleted_at FROM rss_contents
+		WHERE deleted_at IS NOT NULL AND canonical_link IS NOT NULL AND canonical_link != link AND `+condition,
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record content tombstones: %w", err)
+	}
+
+	// Delete the contents' data explicitly, as foreign keys may be disabled
+	if err := deleteContentData(q, "deleted_at IS NOT NULL AND "+condition, args...); err != nil {
+		return 0, err
+	}
+	res, err := q.Exec("DELETE FROM rss_contents WHERE deleted_at IS NOT NULL AND "+condition, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge RSS contents: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// IsContentDeleted reports whether content with the given link or canonical
+// link was deleted, either because it is in the trash or because a tombstone
+// was left when it was purged. Deleted content must not be ingested again.
+func (s *SQLiteDB) IsContentDeleted(link, canonicalLink string) (bool, error) {
+	var deleted bool
+	err := s.readDB.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM rss_contents WHERE (canonical_link = ? OR link = ?) AND deleted_at IS NOT NULL)
+			OR EXISTS (SELECT 1 FROM content_tombstones WHERE link IN (?, ?))`,
+		canonicalLink, link, canonicalLink, link,
+	).Scan(&deleted)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for deleted RSS content: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countRows counts the rows of a table referring to a content item
+func countRows(t *testing.T, db *SQLiteDB, table, contentID string) int {
+	t.Helper()
+	var n int
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE content_id = ?", contentID).Scan(&n); err != nil {
+		t.Fatalf("failed to count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestDeleteSource(t *testing.T) {
+	db := newTestDB(t)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	content := newTestContent(t, db, source.ID, "https://example.com/post", "Post")
+
+	if found, err := db.DeleteSource("missing"); err != nil || found {
+		t.Errorf("DeleteSource() of a missing source = %v, %v, want false", found, err)
+	}
+	if found, err := db.DeleteSource(source.ID); err != nil || !found {
+		t.Fatalf("DeleteSource() = %v, %v, want true", found, err)
+	}
+	if found, err := db.DeleteSource(source.ID); err != nil || found {
+		t.Errorf("DeleteSource() of a trashed source = %v, %v, want false", found, err)
+	}
+
+	if got, err := db.GetSource(source.ID); err != nil || got != nil {
+		t.Errorf("GetSource() of a trashed source = %v, %v, want nil", got, err)
+	}
+	if got, err := db.GetContent(content.ID); err != nil || got != nil {
+		t.Errorf("GetContent() of a trashed source's content = %v, %v, want nil", got, err)
+	}
+}
+
+func TestRecreateTrashedSource(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", RoleReader)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+
+	// Both ways of creating the source point to the trashed one
+	var trashed *TrashedSourceError
+	_, err := db.CreateSource(CreateSourceInput{Name: "Again", URL: source.URL})
+	if !errors.As(err, &trashed) || trashed.SourceID != source.ID {
+		t.Errorf("CreateSource() error = %v, want a TrashedSourceError for %s", err, source.ID)
+	}
+	_, err = db.CreateSubscription(user.ID, CreateSubscriptionInput{URL: source.URL, AllowNewSource: true})
+	if !errors.As(err, &trashed) || trashed.SourceID != source.ID {
+		t.Errorf("CreateSubscription() error = %v, want a TrashedSourceError for %s", err, source.ID)
+	}
+	other := newTestSource(t, db, "https://example.com/other.xml")
+	_, err = db.UpdateSource(other.ID, UpdateSourceInput{Name: other.Name, URL: source.URL})
+	if !errors.As(err, &trashed) {
+		t.Errorf("UpdateSource() error = %v, want a TrashedSourceError", err)
+	}
+
+	// Subscribing to the restored source reuses it
+	if restored, err := db.RestoreSource(source.ID); err != nil || !restored {
+		t.Fatalf("RestoreSource() = %v, %v, want true", restored, err)
+	}
+	sub, err := db.CreateSubscription(user.ID, CreateSubscriptionInput{URL: source.URL, AllowNewSource: true})
+	if err != nil {
+		t.Fatalf("CreateSubscription() after restoring error = %v", err)
+	}
+	if sub.SourceID != source.ID {
+		t.Errorf("CreateSubscription().SourceID = %s, want the restored source %s", sub.SourceID, source.ID)
+	}
+
+	// Once purged, the URL is free again
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	if purged, err := db.PurgeSource(source.ID); err != nil || !purged {
+		t.Fatalf("PurgeSource() = %v, %v, want true", purged, err)
+	}
+	if _, err := db.CreateSource(CreateSourceInput{Name: "Again", URL: source.URL}); err != nil {
+		t.Errorf("CreateSource() after purging error = %v", err)
+	}
+}
+
+func TestRestoreSourceKeepsSeparatelyTrashedContents(t *testing.T) {
+	db := newTestDB(t)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	kept := newTestContent(t, db, source.ID, "https://example.com/kept", "Kept")
+	deleted := newTestContent(t, db, source.ID, "https://example.com/deleted", "Deleted")
+
+	if err := db.DeleteContent(deleted.ID); err != nil {
+		t.Fatalf("DeleteContent() error = %v", err)
+	}
+	// Make sure the source is trashed at a later time than the content
+	time.Sleep(10 * time.Millisecond)
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	if _, err := db.RestoreSource(source.ID); err != nil {
+		t.Fatalf("RestoreSource() error = %v", err)
+	}
+
+	if got, _ := db.GetContent(kept.ID); got == nil {
+		t.Error("the content trashed with the source was not restored")
+	}
+	if got, _ := db.GetContent(deleted.ID); got != nil {
+		t.Error("the content trashed before the source was restored")
+	}
+}
+
+func TestPurgeLeavesTombstones(t *testing.T) {
+	db := newTestDB(t)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	single := newTestContent(t, db, source.ID, "https://example.com/single", "Single")
+	withSource := newTestContent(t, db, source.ID, "https://example.com/with-source", "With source")
+
+	// Purge a content item, then the source with its other item
+	if err := db.DeleteContent(single.ID); err != nil {
+		t.Fatalf("DeleteContent() error = %v", err)
+	}
+	if purged, err := db.PurgeContent(single.ID); err != nil || !purged {
+		t.Fatalf("PurgeContent() = %v, %v, want true", purged, err)
+	}
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	result, err := db.PurgeTrash(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeTrash() error = %v", err)
+	}
+	if result.PurgedSources != 1 {
+		t.Errorf("PurgeTrash().PurgedSources = %d, want 1", result.PurgedSources)
+	}
+
+	for _, content := range []*RSSContent{single, withSource} {
+		deleted, err := db.IsContentDeleted(content.Link, content.Link)
+		if err != nil || !deleted {
+			t.Errorf("IsContentDeleted(%s) = %v, %v, want true", content.Link, deleted, err)
+		}
+	}
+	if deleted, _ := db.IsContentDeleted("https://example.com/new", "https://example.com/new"); deleted {
+		t.Error("IsContentDeleted() of new content = true")
+	}
+}
+
+func TestPurgeTrashRespectsRetention(t *testing.T) {
+	db := newTestDB(t)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+
+	result, err := db.PurgeTrash(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTrash() error = %v", err)
+	}
+	if result.PurgedSources != 0 {
+		t.Errorf("PurgeTrash() purged %d sources trashed after the cutoff", result.PurgedSources)
+	}
+	if restored, err := db.RestoreSource(source.ID); err != nil || !restored {
+		t.Errorf("RestoreSource() = %v, %v, want the source still in the trash", restored, err)
+	}
+}
+
+func TestPurgeWithoutForeignKeys(t *testing.T) {
+	opts := NewOptions(filepath.Join(t.TempDir(), "riffle.db"))
+	opts.ForeignKeys = false
+	db, err := NewSQLiteDBWithOptions(opts)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	user := newTestUser(t, db, "alice", RoleReader)
+	source := newTestSource(t, db, "https://example.com/feed.xml")
+	if _, err := db.CreateSubscription(user.ID, CreateSubscriptionInput{SourceID: source.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	content := &RSSContent{
+		SourceID:    source.ID,
+		Title:       "Post",
+		Link:        "https://example.com/post",
+		PublishedAt: time.Now().UTC(),
+		Categories:  []string{"go"},
+	}
+	if err := db.CreateContent(content); err != nil {
+		t.Fatalf("CreateContent() error = %v", err)
+	}
+	starred := true
+	if _, err := db.UpdateContentState(user.ID, content.ID, UpdateContentStateInput{Starred: &starred}); err != nil {
+		t.Fatalf("UpdateContentState() error = %v", err)
+	}
+
+	if _, err := db.DeleteSource(source.ID); err != nil {
+		t.Fatalf("DeleteSource() error = %v", err)
+	}
+	if _, err := db.PurgeSource(source.ID); err != nil {
+		t.Fatalf("PurgeSource() error = %v", err)
+	}
+
+	for _, table := range []string{"content_categories", "content_states"} {
+		if n := countRows(t, db, table, content.ID); n != 0 {
+			t.Errorf("%d rows of the purged content left in %s", n, table)
+		}
+	}
+	var subscriptions int
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM subscriptions WHERE source_id = ?", source.ID).Scan(&subscriptions); err != nil {
+		t.Fatalf("failed to count subscriptions: %v", err)
+	}
+	if subscriptions != 0 {
+		t.Errorf("%d subscriptions of the purged source left", subscriptions)
+	}
+}
@@ -0,0 +1,593 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// APITokenPrefix is prepended to generated API tokens so they are easy to recognize
+	APITokenPrefix = "rfl_"
+	// FeedTokenPrefix is prepended to generated feed tokens
+	FeedTokenPrefix = "rff_"
+	// MinPasswordLength is the minimum length of a user's password
+	MinPasswordLength = 8
+
+	// apiTokenUseInterval is how often the last use of an API token is
+	// recorded. Recording every request would queue API reads behind the
+	// single writer.
+	apiTokenUseInterval = time.Minute
+)
+
+// Roles a user can have, from most to least privileged. Admins manage users
+// and can delete shared data, editors manage sources and content, and readers
+// only organize their own reading.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleReader = "reader"
+)
+
+// roleRanks orders the roles by privilege
+var roleRanks = map[string]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// RoleIncludes reports whether role is the required role or a more privileged one
+func RoleIncludes(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+var (
+	// ErrUsernameTaken is returned when creating a user whose username already exists
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrInvalidPassword is returned when a password does not meet the requirements
+	ErrInvalidPassword = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	// ErrInvalidRole is returned for a role that does not exist
+	ErrInvalidRole = fmt.Errorf("role must be one of %s, %s or %s", RoleAdmin, RoleEditor, RoleReader)
+	// ErrLastAdmin is returned when an operation would leave no admin
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// User represents a user account
+type User struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// HasRole reports whether the user has the given role or a more privileged one
+func (u *User) HasRole(role string) bool {
+	return RoleIncludes(u.Role, role)
+}
+
+// CreateUserInput represents the input for creating a user
+type CreateUserInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Role defaults to reader
+	Role string `json:"-"`
+}
+
+// APIToken represents a personal API token. The token itself is only
+// returned once, when it is created.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// CreateAPITokenInput represents the input for creating an API token
+type CreateAPITokenInput struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Session represents a login session
+type Session struct {
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// generateSecret returns a random URL-safe secret with the given prefix
+func generateSecret(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret hashes a high-entropy secret such as an API or session token
+// for storage. Unlike passwords these do not need a slow hash.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashPassword hashes a password with bcrypt after checking its length
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CreateUser creates a new user account
+func (s *SQLiteDB) CreateUser(input CreateUserInput) (*User, error) {
+	input.Username = strings.TrimSpace(input.Username)
+	if input.Username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if input.Role == "" {
+		input.Role = RoleReader
+	} else if !ValidRole(input.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	// Hash the password
+	passwordHash, err := hashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the username is taken
+	existing, err := s.GetUserByUsername(input.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUsernameTaken
+	}
+
+	now := time.Now().UTC()
+	user := &User{
+		ID:        uuid.New().String(),
+		Username:  input.Username,
+		Role:      input.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Insert the user into the database
+	_, err = s.db.Exec(
+		`INSERT INTO users (id, username, password_hash, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, passwordHash, user.Role, user.CreatedAt, user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+// userColumns is the column list used to scan users from the users table u
+const userColumns = "u.id, u.username, u.role, u.created_at, u.updated_at"
+
+// scanUser scans a user from a row
+func scanUser(scan func(dest ...interface{}) error) (*User, error) {
+	var user User
+	if err := scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUser retrieves a user by ID
+func (s *SQLiteDB) GetUser(id string) (*User, error) {
+	row := s.readDB.QueryRow("SELECT "+userColumns+" FROM users u WHERE u.id = ?", id)
+	user, err := scanUser(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // User not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *SQLiteDB) GetUserByUsername(username string) (*User, error) {
+	row := s.readDB.QueryRow("SELECT "+userColumns+" FROM users u WHERE u.username = ?", username)
+	user, err := scanUser(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // User not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// AuthenticateUser checks a username and password. It returns nil if the
+// credentials are not valid.
+func (s *SQLiteDB) AuthenticateUser(username, password string) (*User, error) {
+	var passwordHash string
+	row := s.readDB.QueryRow(
+		"SELECT "+userColumns+", u.password_hash FROM users u WHERE u.username = ?",
+		username,
+	)
+	user, err := scanUser(func(dest ...interface{}) error {
+		return row.Scan(append(dest, &passwordHash)...)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil // User not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return nil, nil // Wrong password
+	}
+
+	return user, nil
+}
+
+// UpdateUserPassword sets a new password for a user and signs out all of
+// the user's sessions
+func (s *SQLiteDB) UpdateUserPassword(id, password string) error {
+	// Hash the password
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?",
+		passwordHash, time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user password: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListUsers lists all users ordered by username
+func (s *SQLiteDB) ListUsers() ([]User, error) {
+	rows, err := s.readDB.Query("SELECT " + userColumns + " FROM users u ORDER BY u.username ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over users: %w", err)
+	}
+
+	return users, nil
+}
+
+// checkNotLastAdmin returns ErrLastAdmin if the user is the only admin
+func checkNotLastAdmin(q queryer, id string) error {
+	var lastAdmin bool
+	err := q.QueryRow(
+		`SELECT role = ? AND (SELECT COUNT(*) FROM users WHERE role = ?) = 1
+		FROM users WHERE id = ?`,
+		RoleAdmin, RoleAdmin, id,
+	).Scan(&lastAdmin)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if lastAdmin {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// SetUserRole changes the role of a user. It returns nil if the user does
+// not exist, and ErrLastAdmin when demoting the only admin.
+func (s *SQLiteDB) SetUserRole(id, role string) (*User, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if role != RoleAdmin {
+		if err := checkNotLastAdmin(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec("UPDATE users SET role = ?, updated_at = ? WHERE id = ?", role, time.Now().UTC(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // User not found
+	}
+
+	// Read back the updated user
+	user, err := scanUser(tx.QueryRow("SELECT "+userColumns+" FROM users u WHERE u.id = ?", id).Scan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser deletes a user together with their tokens, sessions,
+// subscriptions and reading state. It reports whether the user existed and
+// returns ErrLastAdmin when deleting the only admin.
+func (s *SQLiteDB) DeleteUser(id string) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastAdmin(tx, id); err != nil {
+		return false, err
+	}
+
+	// Delete the user's data explicitly, as foreign keys may be disabled
+	_, err = tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user data from webhook_deliveries: %w", err)
+	}
+	for _, table := range []string{
+		"user_identities", "api_tokens", "sessions", "feed_tokens", "saved_searches",
+		"digest_items", "digests", "digest_subscriptions", "webhooks", "subscriptions", "content_states", "content_tags", "recommendation_feedback",
+		"routing_rules", "user_interests",
+	} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", id); err != nil {
+			return false, fmt.Errorf("failed to delete user data from %s: %w", table, err)
+		}
+	}
+
+	res, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// CreateAPIToken creates a new API token for a user. It returns the token
+// metadata and the secret token, which cannot be retrieved again.
+func (s *SQLiteDB) CreateAPIToken(userID string, input CreateAPITokenInput) (*APIToken, string, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+
+	secret, err := generateSecret(APITokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Store expiry in UTC so that it compares correctly with other timestamps
+	if input.ExpiresAt != nil {
+		expiresAt := input.ExpiresAt.UTC()
+		input.ExpiresAt = &expiresAt
+	}
+
+	token := &APIToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(input.Name),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: input.ExpiresAt,
+	}
+
+	// Insert the token into the database
+	_, err = s.db.Exec(
+		`INSERT INTO api_tokens (id, user_id, name, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token.ID, token.UserID, token.Name, hashSecret(secret), token.CreatedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return token, secret, nil
+}
+
+// ListAPITokens lists the API tokens of a user
+func (s *SQLiteDB) ListAPITokens(userID string) ([]APIToken, error) {
+	rows, err := s.readDB.Query(
+		`SELECT id, user_id, name, created_at, expires_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var token APIToken
+		var expiresAt, lastUsedAt sql.NullTime
+		err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.CreatedAt, &expiresAt, &lastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		if expiresAt.Valid {
+			token.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over API tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes one of a user's API tokens. It reports whether the
+// token existed.
+func (s *SQLiteDB) DeleteAPIToken(userID, id string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API token: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetUserByAPIToken returns the user owning a valid, unexpired API token and
+// records that the token was used, at most once per apiTokenUseInterval. It
+// returns nil if the token is not valid.
+func (s *SQLiteDB) GetUserByAPIToken(secret string) (*User, error) {
+	now := time.Now().UTC()
+	hash := hashSecret(secret)
+
+	var lastUsedAt sql.NullTime
+	row := s.readDB.QueryRow(
+		`SELECT `+userColumns+`, t.last_used_at
+		FROM api_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND (t.expires_at IS NULL OR t.expires_at > ?)`,
+		hash, now,
+	)
+	user, err := scanUser(func(dest ...interface{}) error {
+		return row.Scan(append(dest, &lastUsedAt)...)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil // Token not valid
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+
+	if !lastUsedAt.Valid || now.Sub(lastUsedAt.Time) >= apiTokenUseInterval {
+		if _, err := s.db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE token_hash = ?", now, hash); err != nil {
+			return nil, fmt.Errorf("failed to update API token: %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+// CreateSession starts a login session for a user that lasts for ttl. It
+// returns the session and its secret token.
+func (s *SQLiteDB) CreateSession(userID string, ttl time.Duration) (*Session, string, error) {
+	secret, err := generateSecret("")
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	session := &Session{
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	// Remove expired sessions while we are here
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now); err != nil {
+		return nil, "", fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		hashSecret(secret), session.UserID, session.CreatedAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, secret, nil
+}
+
+// GetUserBySession returns the user of a valid, unexpired session. It
+// returns nil if the session is not valid.
+func (s *SQLiteDB) GetUserBySession(secret string) (*User, error) {
+	row := s.readDB.QueryRow(
+		`SELECT `+userColumns+`
+		FROM sessions ss JOIN users u ON u.id = ss.user_id
+		WHERE ss.token_hash = ? AND ss.expires_at > ?`,
+		hashSecret(secret), time.Now().UTC(),
+	)
+	user, err := scanUser(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Session not valid
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return user, nil
+}
+
+// DeleteSession ends a login session
+func (s *SQLiteDB) DeleteSession(secret string) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashSecret(secret)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
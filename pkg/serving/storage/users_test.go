@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// tokenLastUsedAt returns when an API token was last used
+func tokenLastUsedAt(t *testing.T, db *SQLiteDB, id string) sql.NullTime {
+	t.Helper()
+	var lastUsedAt sql.NullTime
+	if err := db.db.QueryRow("SELECT last_used_at FROM api_tokens WHERE id = ?", id).Scan(&lastUsedAt); err != nil {
+		t.Fatalf("failed to get last use of API token: %v", err)
+	}
+	return lastUsedAt
+}
+
+func TestGetUserByAPIToken(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", RoleEditor)
+
+	token, secret, err := db.CreateAPIToken(user.ID, CreateAPITokenInput{Name: "cli"})
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+	expired := time.Now().Add(-time.Hour)
+	_, expiredSecret, err := db.CreateAPIToken(user.ID, CreateAPITokenInput{Name: "old", ExpiresAt: &expired})
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+
+	got, err := db.GetUserByAPIToken(secret)
+	if err != nil || got == nil || got.ID != user.ID || got.Role != RoleEditor {
+		t.Fatalf("GetUserByAPIToken() = %+v, %v, want %s", got, err, user.Username)
+	}
+	for name, secret := range map[string]string{"expired": expiredSecret, "unknown": APITokenPrefix + "unknown"} {
+		if got, err := db.GetUserByAPIToken(secret); err != nil || got != nil {
+			t.Errorf("GetUserByAPIToken() of an %s token = %+v, %v, want nil", name, got, err)
+		}
+	}
+
+	// Deleted tokens stop working
+	if deleted, err := db.DeleteAPIToken(user.ID, token.ID); err != nil || !deleted {
+		t.Fatalf("DeleteAPIToken() = %v, %v, want true", deleted, err)
+	}
+	if got, err := db.GetUserByAPIToken(secret); err != nil || got != nil {
+		t.Errorf("GetUserByAPIToken() of a deleted token = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestGetUserByAPITokenThrottlesLastUse(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", RoleReader)
+	token, secret, err := db.CreateAPIToken(user.ID, CreateAPITokenInput{Name: "cli"})
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+
+	if _, err := db.GetUserByAPIToken(secret); err != nil {
+		t.Fatalf("GetUserByAPIToken() error = %v", err)
+	}
+	first := tokenLastUsedAt(t, db, token.ID)
+	if !first.Valid {
+		t.Fatal("the first use of the token was not recorded")
+	}
+
+	// Uses within the interval are not written
+	if _, err := db.GetUserByAPIToken(secret); err != nil {
+		t.Fatalf("GetUserByAPIToken() error = %v", err)
+	}
+	if again := tokenLastUsedAt(t, db, token.ID); !again.Time.Equal(first.Time) {
+		t.Errorf("last use changed from %v to %v within the interval", first.Time, again.Time)
+	}
+
+	// Once the interval passed, the use is recorded again
+	old := time.Now().UTC().Add(-2 * apiTokenUseInterval)
+	if _, err := db.db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", old, token.ID); err != nil {
+		t.Fatalf("failed to age the token: %v", err)
+	}
+	if _, err := db.GetUserByAPIToken(secret); err != nil {
+		t.Fatalf("GetUserByAPIToken() error = %v", err)
+	}
+	if later := tokenLastUsedAt(t, db, token.ID); !later.Time.After(old) {
+		t.Errorf("last use = %v, want it recorded after the interval", later.Time)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice", RoleReader)
+
+	_, secret, err := db.CreateSession(user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if got, err := db.GetUserBySession(secret); err != nil || got == nil || got.ID != user.ID {
+		t.Fatalf("GetUserBySession() = %+v, %v, want %s", got, err, user.Username)
+	}
+
+	_, expiredSecret, err := db.CreateSession(user.ID, -time.Second)
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if got, err := db.GetUserBySession(expiredSecret); err != nil || got != nil {
+		t.Errorf("GetUserBySession() of an expired session = %+v, %v, want nil", got, err)
+	}
+
+	if err := db.DeleteSession(secret); err != nil {
+		t.Fatalf("DeleteSession() error = %v", err)
+	}
+	if got, err := db.GetUserBySession(secret); err != nil || got != nil {
+		t.Errorf("GetUserBySession() after logout = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "alice", RoleReader)
+
+	if got, err := db.AuthenticateUser("alice", "password123"); err != nil || got == nil {
+		t.Errorf("AuthenticateUser() = %+v, %v, want alice", got, err)
+	}
+	for _, tt := range []struct{ username, password string }{
+		{"alice", "wrong password"},
+		{"bob", "password123"},
+	} {
+		if got, err := db.AuthenticateUser(tt.username, tt.password); err != nil || got != nil {
+			t.Errorf("AuthenticateUser(%q, %q) = %+v, %v, want nil", tt.username, tt.password, got, err)
+		}
+	}
+}
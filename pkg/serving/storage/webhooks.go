@@ -0,0 +1,489 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/google/uuid"
+)
+
+// WebhookSecretPrefix is the prefix of generated webhook signing secrets
+const WebhookSecretPrefix = "rfw_"
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEvents are the event types webhooks can subscribe to
+var WebhookEvents = []string{events.ContentCreated, events.FetchJobCompleted, events.SourceFailed}
+
+// ErrInvalidWebhook is returned for webhooks with an invalid URL or events
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// Webhook is a user's subscription to events, which are POSTed to its URL
+type Webhook struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	URL    string `json:"url"`
+	// Secret is the key payloads are signed with; it is only returned when
+	// the webhook is created
+	Secret string   `json:"-"`
+	Events []string `json:"events"`
+	// SourceIDs limits content and source events to these sources
+	SourceIDs []string `json:"sourceIds,omitempty"`
+	// Query limits content events to items matching any of these
+	// comma-separated keywords
+	Query     string    `json:"query,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WebhookInput represents the input for creating or updating a webhook
+type WebhookInput struct {
+	URL string `json:"url" binding:"required"`
+	// Secret is the signing key; a random one is generated if empty
+	Secret    string   `json:"secret,omitempty"`
+	Events    []string `json:"events" binding:"required"`
+	SourceIDs []string `json:"sourceIds,omitempty"`
+	Query     string   `json:"query,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId"`
+	Event     string `json:"event"`
+	// Payload is the JSON body POSTed to the webhook
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+}
+
+// Matches reports whether a webhook wants events of the given type
+func (w *Webhook) Matches(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesSource reports whether the webhook's source filter allows a source
+func (w *Webhook) MatchesSource(sourceID string) bool {
+	if len(w.SourceIDs) == 0 {
+		return true
+	}
+	for _, id := range w.SourceIDs {
+		if id == sourceID {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesContent reports whether the webhook's filters allow a content item
+func (w *Webhook) MatchesContent(content *RSSContent) bool {
+	if !w.MatchesSource(content.SourceID) {
+		return false
+	}
+	if strings.TrimSpace(w.Query) == "" {
+		return true
+	}
+
+	// Match any keyword like SearchContents, ignoring case
+	text := strings.ToLower(content.Title + "\n" + content.Description + "\n" + content.Content)
+	for _, keyword := range strings.Split(w.Query, ",") {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeWebhook validates a webhook input
+func normalizeWebhook(input *WebhookInput) error {
+	input.URL = strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(input.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+
+	if len(input.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	for _, event := range input.Events {
+		valid := false
+		for _, known := range WebhookEvents {
+			valid = valid || event == known
+		}
+		if !valid {
+			return fmt.Errorf("%w: unknown event %q, use one of %s", ErrInvalidWebhook, event, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// encodeStrings encodes a list of strings for storage in a TEXT column
+func encodeStrings(values []string) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode list: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeStrings decodes a list of strings stored by encodeStrings
+func decodeStrings(value sql.NullString) ([]string, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	var values []string
+	if err := json.Unmarshal([]byte(value.String), &values); err != nil {
+		return nil, fmt.Errorf("failed to decode list: %w", err)
+	}
+	return values, nil
+}
+
+// webhookColumns is the column list used to scan webhooks
+const webhookColumns = "id, user_id, url, secret, events, source_ids, query, enabled, created_at, updated_at"
+
+// scanWebhook scans a webhook from a row
+func scanWebhook(scan func(dest ...interface{}) error) (*Webhook, error) {
+	var w Webhook
+	var eventList, sourceIDs sql.NullString
+	err := scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &eventList, &sourceIDs, &w.Query, &w.Enabled, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if w.Events, err = decodeStrings(eventList); err != nil {
+		return nil, err
+	}
+	if w.SourceIDs, err = decodeStrings(sourceIDs); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateWebhook creates a webhook for a user
+func (s *SQLiteDB) CreateWebhook(userID string, input WebhookInput) (*Webhook, error) {
+	if err := normalizeWebhook(&input); err != nil {
+		return nil, err
+	}
+	if input.Secret == "" {
+		secret, err := generateSecret(WebhookSecretPrefix)
+		if err != nil {
+			return nil, err
+		}
+		input.Secret = secret
+	}
+	eventList, err := encodeStrings(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	sourceIDs, err := encodeStrings(input.SourceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	webhook := &Webhook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		URL:       input.URL,
+		Secret:    input.Secret,
+		Events:    input.Events,
+		SourceIDs: input.SourceIDs,
+		Query:     input.Query,
+		Enabled:   input.Enabled == nil || *input.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO webhooks (`+webhookColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		webhook.ID, webhook.UserID, webhook.URL, webhook.Secret, eventList, sourceIDs, webhook.Query,
+		webhook.Enabled, webhook.CreatedAt, webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhook retrieves one of a user's webhooks
+func (s *SQLiteDB) GetWebhook(userID, id string) (*Webhook, error) {
+	row := s.readDB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ? AND user_id = ?", id, userID)
+	webhook, err := scanWebhook(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Webhook not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// GetWebhookByID retrieves a webhook regardless of its owner
+func (s *SQLiteDB) GetWebhookByID(id string) (*Webhook, error) {
+	row := s.readDB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id)
+	webhook, err := scanWebhook(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil // Webhook not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// listWebhooks lists the webhooks matching a condition
+func (s *SQLiteDB) listWebhooks(condition string, args ...interface{}) ([]Webhook, error) {
+	rows, err := s.readDB.Query("SELECT "+webhookColumns+" FROM webhooks WHERE "+condition+" ORDER BY created_at ASC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	// Process the results
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// ListWebhooks lists a user's webhooks
+func (s *SQLiteDB) ListWebhooks(userID string) ([]Webhook, error) {
+	return s.listWebhooks("user_id = ?", userID)
+}
+
+// ListWebhooksForEvent lists the enabled webhooks subscribed to an event type
+func (s *SQLiteDB) ListWebhooksForEvent(eventType string) ([]Webhook, error) {
+	webhooks, err := s.listWebhooks("enabled = 1")
+	if err != nil {
+		return nil, err
+	}
+	matching := webhooks[:0]
+	for _, webhook := range webhooks {
+		if webhook.Matches(eventType) {
+			matching = append(matching, webhook)
+		}
+	}
+	return matching, nil
+}
+
+// UpdateWebhook replaces one of a user's webhooks. The secret is kept unless
+// a new one is given. It returns nil if the webhook does not exist.
+func (s *SQLiteDB) UpdateWebhook(userID, id string, input WebhookInput) (*Webhook, error) {
+	if err := normalizeWebhook(&input); err != nil {
+		return nil, err
+	}
+	eventList, err := encodeStrings(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	sourceIDs, err := encodeStrings(input.SourceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE webhooks SET url = ?, secret = COALESCE(NULLIF(?, ''), secret), events = ?, source_ids = ?,
+			query = ?, enabled = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?`,
+		input.URL, input.Secret, eventList, sourceIDs, input.Query, input.Enabled == nil || *input.Enabled,
+		time.Now().UTC(), id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // Webhook not found
+	}
+
+	return s.GetWebhook(userID, id)
+}
+
+// DeleteWebhook deletes one of a user's webhooks together with its
+// deliveries. It reports whether the webhook existed.
+func (s *SQLiteDB) DeleteWebhook(userID, id string) (bool, error) {
+	// Begin transaction
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM webhooks WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return false, nil
+	}
+
+	// Delete the deliveries explicitly, as foreign keys may be disabled
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// webhookDeliveryColumns is the column list used to scan webhook deliveries
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status, error,
+	created_at, next_attempt_at, last_attempt_at`
+
+// scanWebhookDelivery scans a webhook delivery from a row
+func scanWebhookDelivery(scan func(dest ...interface{}) error) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload string
+	var responseStatus sql.NullInt64
+	var errorMsg sql.NullString
+	var nextAttemptAt, lastAttemptAt sql.NullTime
+	err := scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &responseStatus, &errorMsg,
+		&d.CreatedAt, &nextAttemptAt, &lastAttemptAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	d.ResponseStatus = int(responseStatus.Int64)
+	d.Error = errorMsg.String
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastAttemptAt.Valid {
+		d.LastAttemptAt = &lastAttemptAt.Time
+	}
+	return &d, nil
+}
+
+// CreateWebhookDelivery stores a delivery to a webhook. Deliveries without
+// a next attempt time are not picked up by ListDueWebhookDeliveries.
+func (s *SQLiteDB) CreateWebhookDelivery(delivery *WebhookDelivery) error {
+	// Generate a new UUID if not provided
+	if delivery.ID == "" {
+		delivery.ID = uuid.New().String()
+	}
+	if delivery.Status == "" {
+		delivery.Status = DeliveryPending
+	}
+	delivery.CreatedAt = time.Now().UTC()
+
+	_, err := s.db.Exec(
+		`INSERT INTO webhook_deliveries (id, webhook_id, event, payload, status, attempts, created_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.ID, delivery.WebhookID, delivery.Event, string(delivery.Payload), delivery.Status,
+		delivery.Attempts, delivery.CreatedAt, delivery.NextAttemptAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDueWebhookDeliveries lists pending deliveries whose next attempt is
+// due, oldest first
+func (s *SQLiteDB) ListDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := s.readDB.Query(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC LIMIT ?`,
+		DeliveryPending, now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// ListWebhookDeliveries lists the deliveries of a webhook, newest first
+func (s *SQLiteDB) ListWebhookDeliveries(webhookID string, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.readDB.Query(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = ? ORDER BY created_at DESC LIMIT ?`,
+		webhookID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// scanWebhookDeliveries scans all rows of a webhook delivery query
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt records the outcome of an attempt to deliver to a
+// webhook. nextAttemptAt is nil once the delivery succeeded or gave up.
+func (s *SQLiteDB) RecordWebhookAttempt(delivery *WebhookDelivery) error {
+	_, err := s.db.Exec(
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, error = ?,
+			next_attempt_at = ?, last_attempt_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.Error,
+		delivery.NextAttemptAt, delivery.LastAttemptAt, delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
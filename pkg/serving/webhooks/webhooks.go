@@ -0,0 +1,442 @@
+// Package webhooks POSTs events to the URLs users subscribed to them, signing
+// each payload and retrying failed deliveries with backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// TestEvent is the event type of test deliveries
+const TestEvent = "webhook.test"
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Riffle-Event"
+	DeliveryHeader  = "X-Riffle-Delivery"
+	TimestampHeader = "X-Riffle-Timestamp"
+	// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of
+	// the timestamp, a dot and the body, keyed with the webhook secret
+	SignatureHeader = "X-Riffle-Signature"
+)
+
+const (
+	// busBuffer is how many events may wait to be queued for delivery
+	busBuffer = 1024
+	// checkInterval is how often the worker looks for deliveries to retry
+	checkInterval = 5 * time.Second
+	// batchSize is the maximum number of deliveries attempted per check
+	batchSize = 100
+	// initialBackoff is the delay before the first retry, doubling after
+	// each failed attempt up to maxBackoff
+	initialBackoff = 30 * time.Second
+	maxBackoff     = time.Hour
+	// maxErrorBody is how much of an error response is kept in the log
+	maxErrorBody = 512
+)
+
+// Config configures webhook deliveries
+type Config struct {
+	// Timeout is how long to wait for a webhook to respond
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before giving up
+	MaxAttempts int
+	// AllowedNetworks are the loopback, private and link-local networks
+	// webhooks may be delivered to. Other addresses of these kinds are
+	// refused so that webhooks cannot reach internal services.
+	AllowedNetworks []*net.IPNet
+}
+
+// Payload is the JSON body POSTed to webhooks
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher turns events from the bus into webhook deliveries and sends them
+type Dispatcher struct {
+	config Config
+	db     *storage.SQLiteDB
+	bus    *events.Bus
+	client *http.Client
+	// wake makes the worker send new deliveries without waiting for the
+	// next check
+	wake chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher
+func NewDispatcher(config Config, db *storage.SQLiteDB, bus *events.Bus) *Dispatcher {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+
+	// Check every address connected to, including those of redirects, after
+	// it was resolved. Proxies are not used as they would connect instead.
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   config.checkAddress,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Dispatcher{
+		config: config,
+		db:     db,
+		bus:    bus,
+		client: &http.Client{Timeout: config.Timeout, Transport: transport},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// ParseNetworks parses networks in CIDR notation or single IP addresses
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// checkAddress refuses connections to loopback, private and link-local
+// addresses outside the allowed networks
+func (c Config) checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid webhook address %q", address)
+	}
+	if !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified() {
+		return nil
+	}
+	for _, allowed := range c.AllowedNetworks {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook address %s is not allowed: loopback, private and link-local addresses must be in the allowed networks", ip)
+}
+
+// Run queues deliveries for published events and sends them until stopCh is
+// closed
+func (d *Dispatcher) Run(stopCh <-chan struct{}) {
+	ch, _, cancel := d.bus.Subscribe(0, busBuffer)
+	defer func() { cancel() }()
+
+	go d.deliver(stopCh)
+
+	var lastID uint64
+	for {
+		select {
+		case <-stopCh:
+			return
+		case event, ok := <-ch:
+			if !ok {
+				// The bus dropped us for falling behind; catch up on the
+				// events it still has
+				var complete bool
+				ch, complete, cancel = d.bus.Subscribe(lastID, busBuffer)
+				if !complete {
+					klog.Warningf("Missed events after %d; some webhook deliveries were not queued", lastID)
+				}
+				continue
+			}
+			lastID = event.ID
+			if d.enqueue(event) {
+				select {
+				case d.wake <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// enqueue queues a delivery of an event to every webhook that wants it. It
+// reports whether any delivery was queued.
+func (d *Dispatcher) enqueue(event events.Event) bool {
+	if !isWebhookEvent(event.Type) {
+		return false
+	}
+	webhooks, err := d.db.ListWebhooksForEvent(event.Type)
+	if err != nil {
+		klog.Errorf("Failed to list webhooks for %s: %v", event.Type, err)
+		return false
+	}
+
+	queued := false
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		matches, err := d.matches(webhook, event)
+		if err != nil {
+			klog.Errorf("Failed to match webhook %s: %v", webhook.ID, err)
+			continue
+		}
+		if !matches {
+			continue
+		}
+
+		if _, err := d.createDelivery(webhook, event.Type, event.Time, event.Data, true); err != nil {
+			klog.Errorf("Failed to queue webhook delivery: %v", err)
+			continue
+		}
+		queued = true
+	}
+	return queued
+}
+
+// isWebhookEvent reports whether webhooks can subscribe to an event type
+func isWebhookEvent(eventType string) bool {
+	for _, e := range storage.WebhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether an event passes a webhook's filters. Content and
+// source events only go to users subscribed to the source, as they cannot
+// see other content.
+func (d *Dispatcher) matches(webhook *storage.Webhook, event events.Event) (bool, error) {
+	switch data := event.Data.(type) {
+	case *storage.RSSContent:
+		if !webhook.MatchesContent(data) {
+			return false, nil
+		}
+		return d.db.IsSubscribed(webhook.UserID, data.SourceID)
+	case events.SourceFailure:
+		if !webhook.MatchesSource(data.SourceID) {
+			return false, nil
+		}
+		return d.db.IsSubscribed(webhook.UserID, data.SourceID)
+	case *storage.FetchJob:
+		return data.SourceID == nil || webhook.MatchesSource(*data.SourceID), nil
+	}
+	return true, nil
+}
+
+// createDelivery stores a delivery of an event to a webhook, due now if
+// queue is set
+func (d *Dispatcher) createDelivery(webhook *storage.Webhook, eventType string, at time.Time, data interface{}, queue bool) (*storage.WebhookDelivery, error) {
+	// The payload carries the delivery ID so receivers can skip retries
+	// they already processed
+	delivery := &storage.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: webhook.ID,
+		Event:     eventType,
+	}
+	payload, err := json.Marshal(Payload{
+		ID:        delivery.ID,
+		Type:      eventType,
+		CreatedAt: at,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	delivery.Payload = payload
+	if queue {
+		now := time.Now().UTC()
+		delivery.NextAttemptAt = &now
+	}
+
+	if err := d.db.CreateWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// deliver sends due deliveries until stopCh is closed
+func (d *Dispatcher) deliver(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		d.sendDue(time.Now())
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// sendDue attempts the deliveries that are due
+func (d *Dispatcher) sendDue(now time.Time) {
+	deliveries, err := d.db.ListDueWebhookDeliveries(now, batchSize)
+	if err != nil {
+		klog.Errorf("Failed to list webhook deliveries: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		webhook, err := d.db.GetWebhookByID(delivery.WebhookID)
+		if err != nil {
+			klog.Errorf("Failed to get webhook %s: %v", delivery.WebhookID, err)
+			continue
+		}
+		if webhook == nil {
+			// The webhook was deleted after the delivery was queued
+			continue
+		}
+		d.attempt(webhook, delivery, true)
+	}
+}
+
+// Test sends a test event to a webhook once, without retrying, and returns
+// the recorded delivery
+func (d *Dispatcher) Test(webhook *storage.Webhook) (*storage.WebhookDelivery, error) {
+	delivery, err := d.createDelivery(webhook, TestEvent, time.Now().UTC(), map[string]string{
+		"webhookId": webhook.ID,
+		"message":   "This is a test delivery from riffle",
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.attempt(webhook, delivery, false); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// attempt POSTs a delivery to its webhook once and records the outcome,
+// scheduling a retry on failure if retry is set
+func (d *Dispatcher) attempt(webhook *storage.Webhook, delivery *storage.WebhookDelivery, retry bool) error {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.NextAttemptAt = nil
+	delivery.ResponseStatus = 0
+	delivery.Error = ""
+
+	status, err := d.post(webhook, delivery, now)
+	delivery.ResponseStatus = status
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+	case status < 200 || status > 299:
+		delivery.Error = fmt.Sprintf("unexpected status %d", status)
+	}
+
+	if delivery.Error == "" {
+		delivery.Status = storage.DeliverySucceeded
+	} else if retry && delivery.Attempts < d.config.MaxAttempts {
+		delivery.Status = storage.DeliveryPending
+		next := now.Add(Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	} else {
+		delivery.Status = storage.DeliveryFailed
+	}
+
+	if err := d.db.RecordWebhookAttempt(delivery); err != nil {
+		klog.Errorf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+		return err
+	}
+	if delivery.Error != "" {
+		klog.V(2).InfoS("Webhook delivery failed", "webhook", webhook.ID, "delivery", delivery.ID,
+			"attempts", delivery.Attempts, "error", delivery.Error)
+	}
+	return nil
+}
+
+// post sends a delivery and returns the response status
+func (d *Dispatcher) post(webhook *storage.Webhook, delivery *storage.WebhookDelivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "riffle-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Keep the start of error responses to help debugging
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		if len(bytes.TrimSpace(body)) > 0 {
+			return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of a payload
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying after a number of failed
+// attempts
+func Backoff(attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
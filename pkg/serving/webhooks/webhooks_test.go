@@ -0,0 +1,345 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/serving/events"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// receiver is a webhook endpoint that records the requests it gets and
+// answers with the queued statuses, then 200
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+// newReceiver starts a webhook endpoint that is closed when the test ends
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// count returns the number of requests received
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// loopback allows deliveries to the test receivers
+var loopback = []*net.IPNet{{IP: net.IPv4(127, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}}
+
+// newTestDispatcher opens a temporary database with a user and creates a
+// Dispatcher for it
+func newTestDispatcher(t *testing.T, config Config) (*Dispatcher, *storage.SQLiteDB, *storage.User) {
+	t.Helper()
+	db, err := storage.NewSQLiteDB(filepath.Join(t.TempDir(), "riffle.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	user, err := db.CreateUser(storage.CreateUserInput{Username: "alice", Password: "password123", Role: storage.RoleReader})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	return NewDispatcher(config, db, events.NewBus(10)), db, user
+}
+
+// newTestWebhook creates a webhook of a user for content.created events
+func newTestWebhook(t *testing.T, db *storage.SQLiteDB, userID, url string) *storage.Webhook {
+	t.Helper()
+	webhook, err := db.CreateWebhook(userID, storage.WebhookInput{URL: url, Events: []string{events.ContentCreated}})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	return webhook
+}
+
+// listDeliveries returns the deliveries of a webhook
+func listDeliveries(t *testing.T, db *storage.SQLiteDB, webhookID string) []storage.WebhookDelivery {
+	t.Helper()
+	deliveries, err := db.ListWebhookDeliveries(webhookID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries() error = %v", err)
+	}
+	return deliveries
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("secret", "1700000000", body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	if Sign("other", "1700000000", body) == want || Sign("secret", "1700000001", body) == want {
+		t.Error("signature does not depend on the secret and timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.1.0.0/16", " 192.168.1.5 ", "fd00::/8", "::1"})
+	if err != nil {
+		t.Fatalf("ParseNetworks() error = %v", err)
+	}
+	want := []string{"10.1.0.0/16", "192.168.1.5/32", "fd00::/8", "::1/128"}
+	if len(networks) != len(want) {
+		t.Fatalf("got %d networks, want %d", len(networks), len(want))
+	}
+	for i, network := range networks {
+		if network.String() != want[i] {
+			t.Errorf("network %d = %s, want %s", i, network, want[i])
+		}
+	}
+
+	for _, invalid := range []string{"example.com", "10.0.0.0/33", ""} {
+		if _, err := ParseNetworks([]string{invalid}); err == nil {
+			t.Errorf("ParseNetworks(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	allowed, err := ParseNetworks([]string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("ParseNetworks() error = %v", err)
+	}
+	config := Config{AllowedNetworks: allowed}
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1::]:443", true},
+		{"10.1.2.3:80", true},
+		{"10.2.0.1:80", false},
+		{"127.0.0.1:8080", false},
+		{"[::1]:8080", false},
+		{"192.168.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[fd00::1]:80", false},
+	}
+	for _, tt := range tests {
+		err := config.checkAddress("tcp", tt.address, nil)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkAddress(%s) error = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	endpoint := newReceiver(t)
+	dispatcher, db, user := newTestDispatcher(t, Config{AllowedNetworks: loopback})
+	webhook := newTestWebhook(t, db, user.ID, endpoint.URL)
+
+	delivery, err := dispatcher.Test(webhook)
+	if err != nil {
+		t.Fatalf("Test() error = %v", err)
+	}
+	if delivery.Status != storage.DeliverySucceeded || delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("delivery status = %s (%d), want succeeded (200): %s", delivery.Status, delivery.ResponseStatus, delivery.Error)
+	}
+	if endpoint.count() != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", endpoint.count())
+	}
+
+	req, body := endpoint.requests[0], endpoint.bodies[0]
+	if req.Header.Get(EventHeader) != TestEvent || req.Header.Get(DeliveryHeader) != delivery.ID {
+		t.Errorf("event %q, delivery %q, want %s, %s", req.Header.Get(EventHeader), req.Header.Get(DeliveryHeader), TestEvent, delivery.ID)
+	}
+	timestamp := req.Header.Get(TimestampHeader)
+	if want := Sign(webhook.Secret, timestamp, body); req.Header.Get(SignatureHeader) != want {
+		t.Errorf("signature = %s, want %s", req.Header.Get(SignatureHeader), want)
+	}
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload.ID != delivery.ID || payload.Type != TestEvent {
+		t.Errorf("payload = %+v, want delivery %s of %s", payload, delivery.ID, TestEvent)
+	}
+}
+
+func TestTestDeliveryIsNotRetried(t *testing.T) {
+	endpoint := newReceiver(t, http.StatusInternalServerError)
+	dispatcher, db, user := newTestDispatcher(t, Config{AllowedNetworks: loopback})
+	webhook := newTestWebhook(t, db, user.ID, endpoint.URL)
+
+	delivery, err := dispatcher.Test(webhook)
+	if err != nil {
+		t.Fatalf("Test() error = %v", err)
+	}
+	if delivery.Status != storage.DeliveryFailed || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery status = %s, next attempt %v, want failed without a retry", delivery.Status, delivery.NextAttemptAt)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	endpoint := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	dispatcher, db, user := newTestDispatcher(t, Config{AllowedNetworks: loopback, MaxAttempts: 5})
+	webhook := newTestWebhook(t, db, user.ID, endpoint.URL)
+	if _, err := dispatcher.createDelivery(webhook, events.ContentCreated, time.Now(), map[string]string{}, true); err != nil {
+		t.Fatalf("createDelivery() error = %v", err)
+	}
+
+	// The first failure is retried after the initial backoff
+	start := time.Now()
+	dispatcher.sendDue(start)
+	deliveries := listDeliveries(t, db, webhook.ID)
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	delivery := deliveries[0]
+	if delivery.Status != storage.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("after one attempt: status %s, attempts %d, response %d", delivery.Status, delivery.Attempts, delivery.ResponseStatus)
+	}
+	if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Sub(start) < initialBackoff-time.Second {
+		t.Fatalf("next attempt at %v, want %v after %v", delivery.NextAttemptAt, initialBackoff, start)
+	}
+
+	// Nothing is sent before the retry is due
+	dispatcher.sendDue(start.Add(initialBackoff / 2))
+	if endpoint.count() != 1 {
+		t.Fatalf("endpoint got %d requests before the retry was due, want 1", endpoint.count())
+	}
+
+	dispatcher.sendDue(start.Add(initialBackoff + time.Second))
+	delivery = listDeliveries(t, db, webhook.ID)[0]
+	if delivery.Attempts != 2 || time.Until(*delivery.NextAttemptAt) < Backoff(2)-time.Second {
+		t.Fatalf("after two attempts: attempts %d, next attempt at %v", delivery.Attempts, delivery.NextAttemptAt)
+	}
+
+	dispatcher.sendDue(start.Add(time.Hour))
+	delivery = listDeliveries(t, db, webhook.ID)[0]
+	if delivery.Status != storage.DeliverySucceeded || delivery.Attempts != 3 || delivery.Error != "" {
+		t.Errorf("after three attempts: status %s, attempts %d, error %q", delivery.Status, delivery.Attempts, delivery.Error)
+	}
+}
+
+func TestGiveUpAfterMaxAttempts(t *testing.T) {
+	endpoint := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	dispatcher, db, user := newTestDispatcher(t, Config{AllowedNetworks: loopback, MaxAttempts: 2})
+	webhook := newTestWebhook(t, db, user.ID, endpoint.URL)
+	if _, err := dispatcher.createDelivery(webhook, events.ContentCreated, time.Now(), map[string]string{}, true); err != nil {
+		t.Fatalf("createDelivery() error = %v", err)
+	}
+
+	for _, at := range []time.Duration{0, time.Hour, 2 * time.Hour} {
+		dispatcher.sendDue(time.Now().Add(at))
+	}
+	delivery := listDeliveries(t, db, webhook.ID)[0]
+	if delivery.Status != storage.DeliveryFailed || delivery.Attempts != 2 || delivery.NextAttemptAt != nil {
+		t.Errorf("status %s, attempts %d, next attempt %v, want failed after 2 attempts", delivery.Status, delivery.Attempts, delivery.NextAttemptAt)
+	}
+	if endpoint.count() != 2 {
+		t.Errorf("endpoint got %d requests, want 2", endpoint.count())
+	}
+}
+
+func TestRefuseInternalAddresses(t *testing.T) {
+	endpoint := newReceiver(t)
+	dispatcher, db, user := newTestDispatcher(t, Config{})
+	webhook := newTestWebhook(t, db, user.ID, endpoint.URL)
+
+	delivery, err := dispatcher.Test(webhook)
+	if err != nil {
+		t.Fatalf("Test() error = %v", err)
+	}
+	if delivery.Status != storage.DeliveryFailed || !strings.Contains(delivery.Error, "not allowed") {
+		t.Errorf("delivery status = %s, error %q, want failed as not allowed", delivery.Status, delivery.Error)
+	}
+	if endpoint.count() != 0 {
+		t.Errorf("endpoint on a loopback address got %d requests", endpoint.count())
+	}
+
+}
+
+func TestEnqueueOnlySubscribedSources(t *testing.T) {
+	endpoint := newReceiver(t)
+	dispatcher, db, user := newTestDispatcher(t, Config{AllowedNetworks: loopback})
+	webhook := newTestWebhook(t, db, user.ID, endpoint.URL)
+
+	subscribed, err := db.CreateSource(storage.CreateSourceInput{Name: "Subscribed", URL: "https://example.com/subscribed.xml"})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+	other, err := db.CreateSource(storage.CreateSourceInput{Name: "Other", URL: "https://example.com/other.xml"})
+	if err != nil {
+		t.Fatalf("CreateSource() error = %v", err)
+	}
+	if _, err := db.CreateSubscription(user.ID, storage.CreateSubscriptionInput{SourceID: subscribed.ID}); err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+
+	for _, source := range []*storage.RSSSource{other, subscribed} {
+		event := events.Event{Type: events.ContentCreated, Time: time.Now(), Data: &storage.RSSContent{ID: source.ID + "-item", SourceID: source.ID}}
+		queued := dispatcher.enqueue(event)
+		if queued != (source == subscribed) {
+			t.Errorf("enqueue() for %s = %v", source.Name, queued)
+		}
+	}
+	if n := len(listDeliveries(t, db, webhook.ID)); n != 1 {
+		t.Errorf("got %d deliveries, want 1", n)
+	}
+
+	// Events webhooks cannot subscribe to are ignored
+	if dispatcher.enqueue(events.Event{Type: TestEvent}) {
+		t.Error("enqueue() queued an unknown event type")
+	}
+}
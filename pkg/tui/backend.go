@@ -0,0 +1,26 @@
+package tui
+
+import (
+	"context"
+
+	"github.com/flyer103/riffle/pkg/client"
+)
+
+// Backend is where the reader gets the user's subscriptions and content
+// from, either the local database or a riffle server
+type Backend interface {
+	// Subscriptions lists the user's subscriptions
+	Subscriptions(ctx context.Context) ([]client.Subscription, error)
+	// UnreadCounts counts the unread items of the user's subscriptions
+	UnreadCounts(ctx context.Context) (*client.UnreadCounts, error)
+	// Contents lists the content of the user's subscriptions matching options,
+	// ignoring its limit
+	Contents(ctx context.Context, options client.ListContentsOptions) ([]client.Content, error)
+	// Content gets a content item with its full text and the user's state;
+	// it returns client.ErrNotFound if there is none
+	Content(ctx context.Context, id string) (*client.Content, error)
+	// UpdateState changes the user's reading state of a content item
+	UpdateState(ctx context.Context, id string, input client.ContentStateInput) (*client.ContentState, error)
+	// Rate records the user's rating of a content item for recommendations
+	Rate(ctx context.Context, id string, rating int) error
+}
@@ -0,0 +1,170 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/serving"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// backendFixture is a reader subscribed to a source with two items, and an
+// item of a source the reader is not subscribed to
+type backendFixture struct {
+	items        []*storage.RSSContent
+	unsubscribed *storage.RSSContent
+	backends     map[string]Backend
+}
+
+// newBackendFixture stores the fixture in a temporary database and returns
+// the remote backend of a server on it and the local backend of the reader
+func newBackendFixture(t *testing.T) *backendFixture {
+	t.Helper()
+
+	options := serving.NewServerOptions()
+	options.DBPath = filepath.Join(t.TempDir(), "riffle.db")
+	options.TrashRetention = 0
+
+	db, err := storage.NewSQLiteDB(options.DBPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	user, err := db.CreateUser(storage.CreateUserInput{Username: "reader", Password: "password123", Role: storage.RoleReader})
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	_, token, err := db.CreateAPIToken(user.ID, storage.CreateAPITokenInput{Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	f := &backendFixture{}
+	for _, url := range []string{"https://example.com/feed.xml", "https://example.com/other.xml"} {
+		source, err := db.CreateSource(storage.CreateSourceInput{Name: url, URL: url})
+		if err != nil {
+			t.Fatalf("failed to create source: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			content := &storage.RSSContent{
+				SourceID:    source.ID,
+				Title:       url,
+				Link:        url + "/" + string(rune('a'+i)),
+				Description: "<p>Item</p>",
+				PublishedAt: time.Now().UTC().Add(-time.Duration(i) * time.Hour),
+			}
+			if err := db.CreateContent(content); err != nil {
+				t.Fatalf("failed to create content: %v", err)
+			}
+			f.items = append(f.items, content)
+		}
+	}
+	f.unsubscribed = f.items[2]
+	f.items = f.items[:2]
+	if _, err := db.CreateSubscription(user.ID, storage.CreateSubscriptionInput{SourceID: f.items[0].SourceID}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	server, err := serving.NewServer(options)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		server.Shutdown(context.Background())
+	})
+	c, err := client.New(client.Config{URL: ts.URL, Token: token})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	f.backends = map[string]Backend{
+		"remote": NewRemoteBackend(c),
+		"local":  NewLocalBackend(db, user.ID),
+	}
+	return f
+}
+
+func TestBackends(t *testing.T) {
+	ctx := context.Background()
+	f := newBackendFixture(t)
+
+	for name, backend := range f.backends {
+		subscriptions, err := backend.Subscriptions(ctx)
+		if err != nil {
+			t.Fatalf("%s: Subscriptions() error = %v", name, err)
+		}
+		if len(subscriptions) != 1 || subscriptions[0].SourceID != f.items[0].SourceID {
+			t.Errorf("%s: Subscriptions() = %+v, want the subscribed source", name, subscriptions)
+		}
+
+		// Only the subscribed items are listed
+		contents, err := backend.Contents(ctx, client.ListContentsOptions{})
+		if err != nil {
+			t.Fatalf("%s: Contents() error = %v", name, err)
+		}
+		ids := map[string]bool{}
+		for _, content := range contents {
+			ids[content.ID] = true
+		}
+		if len(contents) != 2 || !ids[f.items[0].ID] || !ids[f.items[1].ID] {
+			t.Errorf("%s: Contents() = %+v, want the 2 subscribed items", name, contents)
+		}
+
+		// Reading an item changes its state and the unread counts
+		item := f.items[0].ID
+		read := true
+		state, err := backend.UpdateState(ctx, item, client.ContentStateInput{Read: &read})
+		if err != nil || !state.Read {
+			t.Fatalf("%s: UpdateState() = %+v, error = %v", name, state, err)
+		}
+		content, err := backend.Content(ctx, item)
+		if err != nil {
+			t.Fatalf("%s: Content() error = %v", name, err)
+		}
+		if content.State == nil || !content.State.Read || content.Description != "<p>Item</p>" {
+			t.Errorf("%s: Content() = %+v, want the read item", name, content)
+		}
+		counts, err := backend.UnreadCounts(ctx)
+		if err != nil {
+			t.Fatalf("%s: UnreadCounts() error = %v", name, err)
+		}
+		if counts.Total != 1 {
+			t.Errorf("%s: UnreadCounts().Total = %d, want 1", name, counts.Total)
+		}
+		read = false
+		if _, err := backend.UpdateState(ctx, item, client.ContentStateInput{Read: &read}); err != nil {
+			t.Fatalf("%s: UpdateState() error = %v", name, err)
+		}
+
+		if err := backend.Rate(ctx, item, 5); err != nil {
+			t.Errorf("%s: Rate() error = %v", name, err)
+		}
+	}
+}
+
+func TestBackendsHideUnsubscribedContent(t *testing.T) {
+	ctx := context.Background()
+	f := newBackendFixture(t)
+	read := true
+
+	for name, backend := range f.backends {
+		for _, id := range []string{f.unsubscribed.ID, "missing"} {
+			if _, err := backend.Content(ctx, id); !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("%s: Content(%s) error = %v, want ErrNotFound", name, id, err)
+			}
+			if _, err := backend.UpdateState(ctx, id, client.ContentStateInput{Read: &read}); !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("%s: UpdateState(%s) error = %v, want ErrNotFound", name, id, err)
+			}
+			if err := backend.Rate(ctx, id, 5); !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("%s: Rate(%s) error = %v, want ErrNotFound", name, id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/serving/storage"
+)
+
+// pageSize is the number of items got at a time
+const pageSize = 200
+
+// localBackend reads the database directly as one of its users
+type localBackend struct {
+	db     *storage.SQLiteDB
+	userID string
+}
+
+// NewLocalBackend creates a backend that uses the database as the user
+func NewLocalBackend(db *storage.SQLiteDB, userID string) Backend {
+	return &localBackend{db: db, userID: userID}
+}
+
+func (b *localBackend) Subscriptions(ctx context.Context) ([]client.Subscription, error) {
+	subscriptions, err := b.db.ListSubscriptions(b.userID, "")
+	if err != nil {
+		return nil, err
+	}
+	var out []client.Subscription
+	return out, convert(subscriptions, &out)
+}
+
+func (b *localBackend) UnreadCounts(ctx context.Context) (*client.UnreadCounts, error) {
+	counts, err := b.db.GetUnreadCounts(b.userID)
+	if err != nil {
+		return nil, err
+	}
+	var out client.UnreadCounts
+	return &out, convert(counts, &out)
+}
+
+func (b *localBackend) Contents(ctx context.Context, options client.ListContentsOptions) ([]client.Content, error) {
+	input := storage.ListContentsInput{
+		UserID:      b.userID,
+		Folder:      options.Folder,
+		Read:        options.Read,
+		Starred:     options.Starred,
+		ReadLater:   options.ReadLater,
+		MinPriority: options.MinPriority,
+		Collapse:    options.Collapse,
+		Language:    options.Language,
+		Tags:        options.Tags,
+		SourceID:    options.SourceID,
+		StartDate:   options.StartDate,
+		EndDate:     options.EndDate,
+		Limit:       pageSize,
+	}
+
+	// Go through all the pages
+	var contents []storage.RSSContent
+	for {
+		page, nextToken, err := b.db.ListContents(input)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, page...)
+		if nextToken == "" {
+			break
+		}
+		input.NextToken = nextToken
+	}
+
+	var out []client.Content
+	return out, convert(contents, &out)
+}
+
+func (b *localBackend) Content(ctx context.Context, id string) (*client.Content, error) {
+	content, err := b.subscribedContent(id)
+	if err != nil {
+		return nil, err
+	}
+	if content.State, err = b.db.GetContentState(b.userID, id); err != nil {
+		return nil, err
+	}
+	if content.Tags, err = b.db.GetContentTags(b.userID, id); err != nil {
+		return nil, err
+	}
+
+	var out client.Content
+	return &out, convert(content, &out)
+}
+
+func (b *localBackend) UpdateState(ctx context.Context, id string, input client.ContentStateInput) (*client.ContentState, error) {
+	if _, err := b.subscribedContent(id); err != nil {
+		return nil, err
+	}
+	state, err := b.db.UpdateContentState(b.userID, id, storage.UpdateContentStateInput{
+		Read:      input.Read,
+		Starred:   input.Starred,
+		ReadLater: input.ReadLater,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, client.ErrNotFound
+	}
+
+	var out client.ContentState
+	return &out, convert(state, &out)
+}
+
+func (b *localBackend) Rate(ctx context.Context, id string, rating int) error {
+	if _, err := b.subscribedContent(id); err != nil {
+		return err
+	}
+	_, err := b.db.CreateRecommendationFeedback(storage.CreateRecommendationFeedbackInput{
+		ContentID: id,
+		UserID:    b.userID,
+		Rating:    rating,
+	})
+	return err
+}
+
+// subscribedContent gets a content item of a source the user is subscribed
+// to. Like the server, it returns client.ErrNotFound for other items.
+func (b *localBackend) subscribedContent(id string) (*storage.RSSContent, error) {
+	content, err := b.db.GetContent(id)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, client.ErrNotFound
+	}
+	subscribed, err := b.db.IsSubscribed(b.userID, content.SourceID)
+	if err != nil {
+		return nil, err
+	}
+	if !subscribed {
+		return nil, client.ErrNotFound
+	}
+	return content, nil
+}
+
+// convert copies storage types into their client counterparts, which have
+// the same JSON encoding
+func convert(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to convert %T: %w", in, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to convert %T: %w", in, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package tui
+
+import (
+	"context"
+
+	"github.com/flyer103/riffle/pkg/client"
+)
+
+// remoteBackend reads through the API of a riffle server
+type remoteBackend struct {
+	client *client.Client
+}
+
+// NewRemoteBackend creates a backend that uses a riffle server
+func NewRemoteBackend(c *client.Client) Backend {
+	return &remoteBackend{client: c}
+}
+
+func (b *remoteBackend) Subscriptions(ctx context.Context) ([]client.Subscription, error) {
+	return b.client.ListSubscriptions(ctx, "")
+}
+
+func (b *remoteBackend) UnreadCounts(ctx context.Context) (*client.UnreadCounts, error) {
+	return b.client.GetUnreadCounts(ctx)
+}
+
+func (b *remoteBackend) Contents(ctx context.Context, options client.ListContentsOptions) ([]client.Content, error) {
+	options.Limit = pageSize
+	return b.client.Contents(ctx, options).All()
+}
+
+func (b *remoteBackend) Content(ctx context.Context, id string) (*client.Content, error) {
+	return b.client.GetContent(ctx, id)
+}
+
+func (b *remoteBackend) UpdateState(ctx context.Context, id string, input client.ContentStateInput) (*client.ContentState, error) {
+	return b.client.UpdateContentState(ctx, id, input)
+}
+
+func (b *remoteBackend) Rate(ctx context.Context, id string, rating int) error {
+	_, err := b.client.SubmitFeedback(ctx, client.FeedbackInput{ContentID: id, Rating: rating})
+	return err
+}
@@ -0,0 +1,207 @@
+package tui
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// Keys that are not runes
+const (
+	keyRune = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyCtrlC
+	keyCtrlL
+)
+
+// key is a key press read from the terminal
+type key struct {
+	code int
+	r    rune
+}
+
+// escapeSequences map the escape sequences of the common terminals to keys
+var escapeSequences = map[string]int{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[5~": keyPageUp, "[6~": keyPageDown,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd, "[7~": keyHome, "[8~": keyEnd,
+}
+
+// terminal is a terminal in raw mode showing the alternate screen
+type terminal struct {
+	fd      int
+	out     *bufio.Writer
+	restore func()
+}
+
+// openTerminal switches the terminal on standard input and output to raw
+// mode and the alternate screen
+func openTerminal() (*terminal, error) {
+	fd := int(os.Stdin.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &terminal{fd: fd, out: bufio.NewWriter(os.Stdout), restore: restore}
+	// Switch to the alternate screen and hide the cursor
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	t.out.Flush()
+	return t, nil
+}
+
+// close switches back to the normal screen and restores the terminal mode
+func (t *terminal) close() {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	t.out.Flush()
+	t.restore()
+}
+
+// size returns the number of columns and rows, or 80x24 if they are unknown
+func (t *terminal) size() (int, int) {
+	cols, rows, err := terminalSize(t.fd)
+	if err != nil || cols <= 0 || rows <= 0 {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+// draw replaces the screen with lines, each cut to cols columns. Lines
+// starting with reverse are shown in reverse video across the whole width.
+func (t *terminal) draw(lines []string, cols int) {
+	t.out.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			t.out.WriteString("\r\n")
+		}
+		if text, ok := strings.CutPrefix(line, reverse); ok {
+			text = fit(text, cols)
+			t.out.WriteString("\x1b[7m" + text + strings.Repeat(" ", cols-textWidth(text)) + "\x1b[0m")
+			continue
+		}
+		t.out.WriteString(fit(line, cols) + "\x1b[K")
+	}
+	t.out.WriteString("\x1b[J")
+	t.out.Flush()
+}
+
+// reverse marks a line to be shown in reverse video
+const reverse = "\x00"
+
+// readKeys reads key presses from standard input and sends them to ch until
+// reading fails
+func readKeys(ch chan<- key) {
+	buf := make([]byte, 256)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(ch)
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			ch <- k
+		}
+	}
+}
+
+// parseKeys splits the bytes of a read from the terminal into keys
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			// A lone escape is the escape key; otherwise look for a known
+			// sequence and skip unknown ones
+			if len(b) == 1 {
+				keys = append(keys, key{code: keyEscape})
+				return keys
+			}
+			if b[1] != '[' && b[1] != 'O' {
+				keys = append(keys, key{code: keyEscape})
+				b = b[1:]
+				continue
+			}
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end < len(b) {
+				end++
+			}
+			if code, ok := escapeSequences[string(b[1:end])]; ok {
+				keys = append(keys, key{code: code})
+			}
+			b = b[end:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{code: keyEnter})
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+			b = b[1:]
+		case c == 0x0c:
+			keys = append(keys, key{code: keyCtrlL})
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, key{code: keyRune, r: r})
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// runeWidth returns the number of columns a rune takes up
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// textWidth returns the number of columns s takes up
+func textWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// fit cuts s to at most cols columns, replacing control characters
+func fit(s string, cols int) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			r = ' '
+		}
+		w := runeWidth(r)
+		if n+w > cols {
+			break
+		}
+		b.WriteRune(r)
+		n += w
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+// errUnsupported is returned on platforms without a terminal implementation
+var errUnsupported = errors.New("the terminal reader is not supported on this platform")
+
+// makeRaw is not supported on this platform
+func makeRaw(fd int) (func(), error) {
+	return nil, errUnsupported
+}
+
+// terminalSize is not supported on this platform
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errUnsupported
+}
+
+// notifyResize does nothing on this platform
+func notifyResize(ch chan<- os.Signal) {}
@@ -0,0 +1,51 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeRaw puts the terminal into raw mode, returning a function that
+// restores its previous mode
+func makeRaw(fd int) (func(), error) {
+	saved, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, fmt.Errorf("standard input is not a terminal: %w", err)
+	}
+
+	// Same settings as cfmakeraw, but keep output processing so that
+	// newlines still return the cursor
+	raw := *saved
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+
+	return func() {
+		unix.IoctlSetTermios(fd, ioctlSetTermios, saved)
+	}, nil
+}
+
+// terminalSize returns the number of columns and rows of the terminal
+func terminalSize(fd int) (int, int, error) {
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get terminal size: %w", err)
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// notifyResize sends to ch when the terminal is resized
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, unix.SIGWINCH)
+}
@@ -0,0 +1,638 @@
+// Package tui implements riffle's full-screen terminal reader
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/flyer103/riffle/pkg/client"
+	"github.com/flyer103/riffle/pkg/riffle"
+)
+
+// Screens of the reader
+const (
+	screenFeeds = iota
+	screenItems
+	screenArticle
+)
+
+// maxArticleWidth is the widest articles are wrapped at, for readability
+const maxArticleWidth = 100
+
+// Help lines of the screens
+var help = map[int]string{
+	screenFeeds:   "q:Quit  Enter:Open  j/k:Move  r:Reload",
+	screenItems:   "q:Back  Enter:Read  j/k:Move  m:Read/Unread  s:Star  1-5:Rate  o:Open link  a:Show read  r:Reload",
+	screenArticle: "q:Back  j/k:Scroll  Space/b:Page  n/p:Next/Prev  m:Unread  s:Star  1-5:Rate  o:Open link",
+}
+
+// feed is a row of the feeds screen, either a subscription or all of them
+type feed struct {
+	name     string
+	sourceID string
+	folder   string
+	unread   int
+}
+
+// list is the cursor and scroll position of a list
+type list struct {
+	cursor int
+	top    int
+}
+
+// move moves the cursor by delta within a list of n rows
+func (l *list) move(delta, n int) {
+	l.cursor += delta
+	if l.cursor >= n {
+		l.cursor = n - 1
+	}
+	if l.cursor < 0 {
+		l.cursor = 0
+	}
+}
+
+// scroll keeps the cursor within height rows starting at top
+func (l *list) scroll(height int) {
+	if l.cursor < l.top {
+		l.top = l.cursor
+	}
+	if l.cursor >= l.top+height {
+		l.top = l.cursor - height + 1
+	}
+	if l.top < 0 {
+		l.top = 0
+	}
+}
+
+// reader is the state of the terminal reader
+type reader struct {
+	ctx     context.Context
+	backend Backend
+	term    *terminal
+	screen  int
+	status  string
+
+	// The feeds screen
+	feeds     []feed
+	feedList  list
+	unread    int
+	names     map[string]string
+	feedIndex int
+
+	// The items screen
+	items    []client.Content
+	itemList list
+	showRead bool
+
+	// The article screen
+	article *client.Content
+	lines   []string
+	wrapped int
+	offset  int
+	ratings map[string]int
+}
+
+// Run shows the reader on the terminal until the user quits or ctx is done
+func Run(ctx context.Context, backend Backend) error {
+	r := &reader{
+		ctx:     ctx,
+		backend: backend,
+		ratings: map[string]int{},
+	}
+
+	// Load the subscriptions before taking over the terminal so that
+	// errors are printed normally
+	if err := r.loadFeeds(); err != nil {
+		return err
+	}
+
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.close()
+	r.term = term
+
+	keys := make(chan key)
+	go readKeys(keys)
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+
+	for {
+		r.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-resize:
+		case k, ok := <-keys:
+			if !ok || !r.handle(k) {
+				return nil
+			}
+		}
+	}
+}
+
+// loadFeeds gets the subscriptions and their unread counts
+func (r *reader) loadFeeds() error {
+	subscriptions, err := r.backend.Subscriptions(r.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	counts, err := r.backend.UnreadCounts(r.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get unread counts: %w", err)
+	}
+
+	unread := map[string]int{}
+	for _, count := range counts.Sources {
+		unread[count.SourceID] = count.Unread
+	}
+
+	r.unread = counts.Total
+	r.names = map[string]string{}
+	r.feeds = []feed{{name: "All unread", unread: counts.Total}}
+	for _, subscription := range subscriptions {
+		r.names[subscription.SourceID] = subscription.Name()
+		r.feeds = append(r.feeds, feed{
+			name:     subscription.Name(),
+			sourceID: subscription.SourceID,
+			folder:   subscription.Folder,
+			unread:   unread[subscription.SourceID],
+		})
+	}
+	r.feedList.move(0, len(r.feeds))
+	return nil
+}
+
+// loadItems gets the items of the selected feed, newest first
+func (r *reader) loadItems() error {
+	f := r.feeds[r.feedIndex]
+	options := client.ListContentsOptions{SourceID: f.sourceID}
+	if !r.showRead {
+		read := false
+		options.Read = &read
+	}
+	items, err := r.backend.Contents(r.ctx, options)
+	if err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PublishedAt.After(items[j].PublishedAt)
+	})
+	r.items = items
+	r.itemList.move(0, len(r.items))
+	return nil
+}
+
+// handle acts on a key press, returning false to quit
+func (r *reader) handle(k key) bool {
+	r.status = ""
+	if k.code == keyCtrlC || (k.code == keyRune && k.r == 'Q') {
+		return false
+	}
+	if k.code == keyCtrlL {
+		return true
+	}
+
+	switch r.screen {
+	case screenFeeds:
+		return r.handleFeeds(k)
+	case screenItems:
+		r.handleItems(k)
+	case screenArticle:
+		r.handleArticle(k)
+	}
+	return true
+}
+
+// handleFeeds acts on a key press on the feeds screen
+func (r *reader) handleFeeds(k key) bool {
+	if r.handleList(&r.feedList, len(r.feeds), k) {
+		return true
+	}
+
+	switch {
+	case k.code == keyEnter || k.code == keyRight || k.r == 'l':
+		r.feedIndex = r.feedList.cursor
+		r.itemList = list{}
+		if err := r.loadItems(); err != nil {
+			r.status = err.Error()
+			return true
+		}
+		r.screen = screenItems
+	case k.r == 'r':
+		r.reloadFeeds()
+	case k.code == keyEscape || k.r == 'q':
+		return false
+	}
+	return true
+}
+
+// handleItems acts on a key press on the items screen
+func (r *reader) handleItems(k key) {
+	if r.handleList(&r.itemList, len(r.items), k) {
+		return
+	}
+
+	switch {
+	case k.code == keyEnter || k.code == keyRight || k.r == 'l':
+		r.open(r.itemList.cursor)
+	case k.r == 'a':
+		r.showRead = !r.showRead
+		r.reloadItems()
+		if r.showRead {
+			r.status = "Showing read items"
+		} else {
+			r.status = "Hiding read items"
+		}
+	case k.r == 'r':
+		r.reloadItems()
+	case k.code == keyEscape || k.code == keyLeft || k.r == 'q' || k.r == 'h':
+		r.screen = screenFeeds
+		r.reloadFeeds()
+	default:
+		if len(r.items) > 0 {
+			r.handleItem(&r.items[r.itemList.cursor], k)
+		}
+	}
+}
+
+// handleArticle acts on a key press on the article screen
+func (r *reader) handleArticle(k key) {
+	_, rows := r.term.size()
+	page := bodyHeight(rows) - 1
+	if page < 1 {
+		page = 1
+	}
+
+	switch {
+	case k.code == keyDown || k.r == 'j':
+		r.offset++
+	case k.code == keyUp || k.r == 'k':
+		r.offset--
+	case k.code == keyPageDown || k.r == ' ':
+		r.offset += page
+	case k.code == keyPageUp || k.r == 'b':
+		r.offset -= page
+	case k.code == keyHome || k.r == 'g':
+		r.offset = 0
+	case k.code == keyEnd || k.r == 'G':
+		r.offset = len(r.lines)
+	case k.r == 'n':
+		if r.itemList.cursor+1 < len(r.items) {
+			r.open(r.itemList.cursor + 1)
+		} else {
+			r.status = "No next item"
+		}
+	case k.r == 'p':
+		if r.itemList.cursor > 0 {
+			r.open(r.itemList.cursor - 1)
+		} else {
+			r.status = "No previous item"
+		}
+	case k.code == keyEscape || k.code == keyLeft || k.r == 'q' || k.r == 'h':
+		r.screen = screenItems
+	default:
+		r.handleItem(&r.items[r.itemList.cursor], k)
+	}
+}
+
+// handleList moves the cursor of a list, returning whether k was a
+// movement key
+func (r *reader) handleList(l *list, n int, k key) bool {
+	_, rows := r.term.size()
+	switch {
+	case k.code == keyDown || k.r == 'j':
+		l.move(1, n)
+	case k.code == keyUp || k.r == 'k':
+		l.move(-1, n)
+	case k.code == keyPageDown || k.r == ' ':
+		l.move(bodyHeight(rows), n)
+	case k.code == keyPageUp || k.r == 'b':
+		l.move(-bodyHeight(rows), n)
+	case k.code == keyHome || k.r == 'g':
+		l.move(-n, n)
+	case k.code == keyEnd || k.r == 'G':
+		l.move(n, n)
+	default:
+		return false
+	}
+	return true
+}
+
+// handleItem acts on a key press about an item on the items or article
+// screen
+func (r *reader) handleItem(item *client.Content, k key) {
+	if k.code != keyRune {
+		return
+	}
+
+	switch {
+	case k.r == 'm':
+		read := !isRead(item)
+		if r.updateState(item, client.ContentStateInput{Read: &read}) {
+			if read {
+				r.status = "Marked as read"
+			} else {
+				r.status = "Marked as unread"
+			}
+		}
+	case k.r == 's':
+		starred := !isStarred(item)
+		if r.updateState(item, client.ContentStateInput{Starred: &starred}) {
+			if starred {
+				r.status = "Starred"
+			} else {
+				r.status = "Unstarred"
+			}
+		}
+	case k.r >= '1' && k.r <= '5':
+		rating := int(k.r - '0')
+		if err := r.backend.Rate(r.ctx, item.ID, rating); err != nil {
+			r.status = "Failed to rate: " + err.Error()
+			return
+		}
+		r.ratings[item.ID] = rating
+		r.status = fmt.Sprintf("Rated %d out of 5", rating)
+	case k.r == 'o':
+		if item.Link == "" {
+			r.status = "The item has no link"
+			return
+		}
+		if err := openLink(item.Link); err != nil {
+			r.status = "Failed to open link: " + err.Error()
+			return
+		}
+		r.status = "Opened " + item.Link
+	}
+}
+
+// open shows the item at index i of the items screen, marking it read
+func (r *reader) open(i int) {
+	if i < 0 || i >= len(r.items) {
+		return
+	}
+	item := &r.items[i]
+
+	// Get the full text, which listings leave out
+	article, err := r.backend.Content(r.ctx, item.ID)
+	if err != nil {
+		r.status = "Failed to get item: " + err.Error()
+		return
+	}
+	if !isRead(article) {
+		read := true
+		r.updateState(article, client.ContentStateInput{Read: &read})
+	}
+	item.State = article.State
+
+	r.itemList.cursor = i
+	r.article = article
+	r.wrapped = 0
+	r.offset = 0
+	r.screen = screenArticle
+}
+
+// updateState changes the state of an item, reporting failures on the
+// status line
+func (r *reader) updateState(item *client.Content, input client.ContentStateInput) bool {
+	state, err := r.backend.UpdateState(r.ctx, item.ID, input)
+	if err != nil {
+		r.status = "Failed to update item: " + err.Error()
+		return false
+	}
+	item.State = state
+
+	// Keep the list and the open article in step
+	for i := range r.items {
+		if r.items[i].ID == item.ID {
+			r.items[i].State = state
+		}
+	}
+	if r.article != nil && r.article.ID == item.ID {
+		r.article.State = state
+	}
+	return true
+}
+
+// reloadFeeds reloads the feeds screen, reporting failures on the status line
+func (r *reader) reloadFeeds() {
+	if err := r.loadFeeds(); err != nil {
+		r.status = err.Error()
+	}
+}
+
+// reloadItems reloads the items screen, reporting failures on the status line
+func (r *reader) reloadItems() {
+	if err := r.loadItems(); err != nil {
+		r.status = err.Error()
+	}
+}
+
+// draw renders the current screen
+func (r *reader) draw() {
+	cols, rows := r.term.size()
+	height := bodyHeight(rows)
+
+	var title string
+	var body []string
+	switch r.screen {
+	case screenFeeds:
+		title = fmt.Sprintf("riffle - Subscriptions (%d unread)", r.unread)
+		body = r.drawFeeds(height)
+	case screenItems:
+		f := r.feeds[r.feedIndex]
+		title = fmt.Sprintf("riffle - %s (%d items)", f.name, len(r.items))
+		body = r.drawItems(cols, height)
+	case screenArticle:
+		title = "riffle - " + r.feeds[r.feedIndex].name
+		body = r.drawArticle(cols, height)
+	}
+
+	lines := []string{reverse + title}
+	lines = append(lines, body...)
+	for len(lines) < height+1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, reverse+help[r.screen], r.status)
+	r.term.draw(lines, cols)
+}
+
+// drawFeeds renders the rows of the feeds screen
+func (r *reader) drawFeeds(height int) []string {
+	r.feedList.scroll(height)
+	var lines []string
+	for i := r.feedList.top; i < len(r.feeds) && i < r.feedList.top+height; i++ {
+		f := r.feeds[i]
+		line := fmt.Sprintf("%6d  %s", f.unread, f.name)
+		if f.folder != "" {
+			line += "  [" + f.folder + "]"
+		}
+		lines = append(lines, selected(line, i == r.feedList.cursor))
+	}
+	if len(r.feeds) == 1 {
+		lines = append(lines, "", "  You have no subscriptions.")
+	}
+	return lines
+}
+
+// drawItems renders the rows of the items screen
+func (r *reader) drawItems(cols, height int) []string {
+	if len(r.items) == 0 {
+		if r.showRead {
+			return []string{"", "  No items."}
+		}
+		return []string{"", "  No unread items. Press a to show read items."}
+	}
+
+	// Name the sources only when the items are from more than one
+	r.itemList.scroll(height)
+	sourceWidth := 0
+	if r.feeds[r.feedIndex].sourceID == "" {
+		sourceWidth = cols / 5
+	}
+	var lines []string
+	for i := r.itemList.top; i < len(r.items) && i < r.itemList.top+height; i++ {
+		item := r.items[i]
+		flags := []byte("  ")
+		if !isRead(&item) {
+			flags[0] = 'N'
+		}
+		if isStarred(&item) {
+			flags[1] = '*'
+		}
+		line := fmt.Sprintf(" %s  %s  ", flags, item.PublishedAt.Local().Format("Jan 02"))
+		if sourceWidth > 0 {
+			source := fit(r.names[item.SourceID], sourceWidth)
+			line += source + strings.Repeat(" ", sourceWidth-textWidth(source)) + "  "
+		}
+		line += strings.TrimSpace(item.Title)
+		lines = append(lines, selected(line, i == r.itemList.cursor))
+	}
+	return lines
+}
+
+// drawArticle renders the visible lines of the open article
+func (r *reader) drawArticle(cols, height int) []string {
+	width := cols - 2
+	if width > maxArticleWidth {
+		width = maxArticleWidth
+	}
+	if width < 20 {
+		width = 20
+	}
+	if r.wrapped != width {
+		r.lines = articleLines(r.article, r.names[r.article.SourceID], width)
+		r.wrapped = width
+	}
+
+	if r.offset > len(r.lines)-height {
+		r.offset = len(r.lines) - height
+	}
+	if r.offset < 0 {
+		r.offset = 0
+	}
+
+	var lines []string
+	for i := r.offset; i < len(r.lines) && i < r.offset+height; i++ {
+		lines = append(lines, " "+r.lines[i])
+	}
+	return r.withFlags(lines)
+}
+
+// withFlags adds the state of the open article to its first line
+func (r *reader) withFlags(lines []string) []string {
+	if r.offset != 0 || len(lines) == 0 {
+		return lines
+	}
+	var flags []string
+	if isStarred(r.article) {
+		flags = append(flags, "starred")
+	}
+	if rating, ok := r.ratings[r.article.ID]; ok {
+		flags = append(flags, fmt.Sprintf("rated %d/5", rating))
+	}
+	if len(flags) > 0 {
+		lines[0] += "  (" + strings.Join(flags, ", ") + ")"
+	}
+	return lines
+}
+
+// articleLines renders an article as lines of text
+func articleLines(article *client.Content, source string, width int) []string {
+	header := []string{strings.TrimSpace(article.Title), ""}
+	if source != "" {
+		header = append(header, "Feed:   "+source)
+	}
+	if article.Author != "" {
+		header = append(header, "Author: "+article.Author)
+	}
+	header = append(header, "Date:   "+article.PublishedAt.Local().Format("Mon, 02 Jan 2006 15:04"))
+	if article.Link != "" {
+		header = append(header, "Link:   "+article.Link)
+	}
+	if len(article.Categories) > 0 {
+		header = append(header, "Tags:   "+strings.Join(article.Categories, ", "))
+	}
+	header = append(header, "")
+
+	body := article.Content
+	if strings.TrimSpace(body) == "" {
+		body = article.Description
+	}
+	return append(header, strings.Split(riffle.HTMLToText(body, width), "\n")...)
+}
+
+// bodyHeight is the number of rows between the title and the help line
+func bodyHeight(rows int) int {
+	if rows < 4 {
+		return 1
+	}
+	return rows - 3
+}
+
+// selected marks the row under the cursor
+func selected(line string, ok bool) string {
+	if ok {
+		return reverse + line
+	}
+	return line
+}
+
+// isRead reports whether the user has read an item
+func isRead(item *client.Content) bool {
+	return item.State != nil && item.State.Read
+}
+
+// isStarred reports whether the user has starred an item
+func isStarred(item *client.Content) bool {
+	return item.State != nil && item.State.Starred
+}
+
+// openLink opens a link with $BROWSER or the desktop's default browser
+func openLink(link string) error {
+	browser := os.Getenv("BROWSER")
+	if browser == "" {
+		switch runtime.GOOS {
+		case "darwin":
+			browser = "open"
+		case "windows":
+			browser = "explorer"
+		default:
+			browser = "xdg-open"
+		}
+	}
+
+	cmd := exec.Command(browser, link)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}